@@ -2,40 +2,336 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
 	"wallet-service/internal/logger"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	DBHost     string `mapstructure:"DB_HOST"`
-	DBPort     string `mapstructure:"DB_PORT"`
-	DBUser     string `mapstructure:"DB_USER"`
-	DBPassword string `mapstructure:"DB_PASSWORD"`
-	DBName     string `mapstructure:"DB_NAME"`
-	AppPort    string `mapstructure:"APP_PORT"`
+	// Хранилище кошельков: postgres, sqlite (файл SQLitePath) или memory (в памяти процесса,
+	// для тестов и локального запуска)
+	DBDriver          string        `mapstructure:"DB_DRIVER"`
+	SQLitePath        string        `mapstructure:"SQLITE_PATH"`
+	SQLiteBusyTimeout time.Duration `mapstructure:"SQLITE_BUSY_TIMEOUT"`
+	DBHost            string        `mapstructure:"DB_HOST"`
+	DBPort            string        `mapstructure:"DB_PORT"`
+	DBUser            string        `mapstructure:"DB_USER"`
+	DBPassword        string        `mapstructure:"DB_PASSWORD" secret:"true"`
+	DBName            string        `mapstructure:"DB_NAME"`
+	AppPort           string        `mapstructure:"APP_PORT"`
+
+	// TLS-подключение к PostgreSQL (disable, require, verify-ca, verify-full) и корневой сертификат для verify-*
+	DBSSLMode     string `mapstructure:"DB_SSLMODE"`
+	DBSSLRootCert string `mapstructure:"DB_SSLROOTCERT"`
+
+	// Пул соединений с базой (основной, реплики и шарды): размер (0 - без ограничения),
+	// время жизни соединений и ожидание установки соединения
+	DBMaxOpenConns    int           `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns    int           `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime time.Duration `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	DBConnMaxIdleTime time.Duration `mapstructure:"DB_CONN_MAX_IDLE_TIME"`
+	DBConnectTimeout  time.Duration `mapstructure:"DB_CONNECT_TIMEOUT"`
+
+	// Таймауты HTTP-сервера: чтение заголовков и тела запроса, запись ответа (0 - без ограничения,
+	// выписки отдаются потоком) и простой keep-alive соединения
+	HTTPReadHeaderTimeout time.Duration `mapstructure:"HTTP_READ_HEADER_TIMEOUT"`
+	HTTPReadTimeout       time.Duration `mapstructure:"HTTP_READ_TIMEOUT"`
+	HTTPWriteTimeout      time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT"`
+	HTTPIdleTimeout       time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT"`
+
+	// Токен для /admin/config (заголовок Authorization: Bearer), пустой - эндпоинты отключены.
+	// ConfigWatch - перечитывать .env и CONFIG_FILE при изменении и применять настройки без перезапуска
+	AdminToken  string `mapstructure:"ADMIN_TOKEN" secret:"true"`
+	ConfigWatch bool   `mapstructure:"CONFIG_WATCH"`
+
+	// Ограничение частоты запросов: правила операция.область=N/период[:burst] через запятую
+	// (меняются без перезапуска), хранилище корзин memory или postgres (общее для всех реплик)
+	// и заголовок с идентификатором клиента от API-шлюза (пустой - клиент определяется по IP)
+	RateLimits             []string      `mapstructure:"RATE_LIMITS"`
+	RateLimitStore         string        `mapstructure:"RATE_LIMIT_STORE"`
+	RateLimitClientHeader  string        `mapstructure:"RATE_LIMIT_CLIENT_HEADER"`
+	RateLimitCleanupPeriod time.Duration `mapstructure:"RATE_LIMIT_CLEANUP_INTERVAL"`
+
+	// Асинхронные операции ("mode": "async"): число воркеров реплики (0 - только постановка в очередь),
+	// период проверки очереди, число попыток и пауза между ними при сбоях базы
+	AsyncWorkers      int           `mapstructure:"ASYNC_WORKERS"`
+	AsyncPollInterval time.Duration `mapstructure:"ASYNC_POLL_INTERVAL"`
+	AsyncMaxAttempts  int           `mapstructure:"ASYNC_MAX_ATTEMPTS"`
+	AsyncRetryDelay   time.Duration `mapstructure:"ASYNC_RETRY_DELAY"`
+
+	// Файл правил проверки рисков перед пополнением и снятием, пустой - проверки отключены
+	RiskRulesFile string `mapstructure:"RISK_RULES_FILE"`
+
+	// Файл арендаторов с хешами API-ключей, лимитами, комиссиями и валютой, пустой - API без ключей
+	TenantsFile string `mapstructure:"TENANTS_FILE"`
+
+	// Логи: формат text или json, уровень, назначение (stdout, stderr или файл)
+	// и скрываемые значения через запятую (uuid, amount)
+	LogFormat string   `mapstructure:"LOG_FORMAT"`
+	LogLevel  string   `mapstructure:"LOG_LEVEL"`
+	LogOutput string   `mapstructure:"LOG_OUTPUT"`
+	LogRedact []string `mapstructure:"LOG_REDACT"`
+
+	// Завершение работы: после SIGTERM /readyz сразу отвечает 503, через ShutdownDelay сервер
+	// перестает принимать соединения и до ShutdownTimeout ждет завершения текущих запросов
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	ShutdownDelay   time.Duration `mapstructure:"SHUTDOWN_DELAY"`
+
+	// Проверки /readyz: дедлайн всех проверок и допустимая доля занятых соединений пула
+	ReadinessTimeout        time.Duration `mapstructure:"READINESS_TIMEOUT"`
+	ReadinessPoolSaturation float64       `mapstructure:"READINESS_POOL_SATURATION"`
+
+	// Миграции при старте (по умолчанию выключены, схема обновляется `wallet-service migrate up`)
+	// и ожидание блокировки миграций
+	DBAutoMigrate        bool          `mapstructure:"DB_AUTO_MIGRATE"`
+	DBMigrateLockTimeout time.Duration `mapstructure:"DB_MIGRATE_LOCK_TIMEOUT"`
+
+	// Реплики только для чтения (строки подключения через запятую) и допустимое отставание
+	DBReplicaDSNs          []string      `mapstructure:"DB_REPLICA_DSNS" secret:"true"`
+	DBReplicaMaxLag        time.Duration `mapstructure:"DB_REPLICA_MAX_LAG"`
+	DBReplicaCheckInterval time.Duration `mapstructure:"DB_REPLICA_CHECK_INTERVAL"`
+
+	// Шардирование кошельков: основная база - шард DBShardName, остальные шарды задаются
+	// элементами имя=строка подключения через запятую
+	DBShardName              string        `mapstructure:"DB_SHARD_NAME"`
+	DBShards                 []string      `mapstructure:"DB_SHARDS" secret:"true"`
+	DBShardVNodes            int           `mapstructure:"DB_SHARD_VNODES"`
+	TransferRecoveryInterval time.Duration `mapstructure:"TRANSFER_RECOVERY_INTERVAL"`
+	TransferRecoveryAge      time.Duration `mapstructure:"TRANSFER_RECOVERY_AGE"`
+
+	// Дедлайны операций с базой (0 - без дедлайна) и ожидание блокировки строки кошелька
+	DBDepositTimeout         time.Duration `mapstructure:"DB_DEPOSIT_TIMEOUT"`
+	DBWithdrawTimeout        time.Duration `mapstructure:"DB_WITHDRAW_TIMEOUT"`
+	DBReadTimeout            time.Duration `mapstructure:"DB_READ_TIMEOUT"`
+	DBWalletStatementTimeout time.Duration `mapstructure:"DB_WALLET_STATEMENT_TIMEOUT"`
+	DBLockTimeout            time.Duration `mapstructure:"DB_LOCK_TIMEOUT"`
+
+	// Уровни изоляции транзакций (read_committed, repeatable_read, serializable) и повторы
+	// транзакций при конфликтах сериализации и дедлоках
+	DBDepositIsolation  string        `mapstructure:"DB_DEPOSIT_ISOLATION"`
+	DBWithdrawIsolation string        `mapstructure:"DB_WITHDRAW_ISOLATION"`
+	DBTxMaxAttempts     int           `mapstructure:"DB_TX_MAX_ATTEMPTS"`
+	DBTxRetryBaseDelay  time.Duration `mapstructure:"DB_TX_RETRY_BASE_DELAY"`
+	DBTxRetryMaxDelay   time.Duration `mapstructure:"DB_TX_RETRY_MAX_DELAY"`
+
+	// Групповая фиксация депозитов: до DepositBatchSize депозитов за DepositBatchDelay в одной транзакции
+	DepositBatchEnabled bool          `mapstructure:"DEPOSIT_BATCH_ENABLED"`
+	DepositBatchSize    int           `mapstructure:"DEPOSIT_BATCH_SIZE"`
+	DepositBatchDelay   time.Duration `mapstructure:"DEPOSIT_BATCH_DELAY"`
+
+	// Кэш балансов: none, memory (LRU в памяти процесса) или redis. Записи старше
+	// BalanceCacheMaxStaleness не используются, изменения с других реплик приходят через LISTEN/NOTIFY
+	BalanceCache             string        `mapstructure:"BALANCE_CACHE"`
+	BalanceCacheSize         int           `mapstructure:"BALANCE_CACHE_SIZE"`
+	BalanceCacheMaxStaleness time.Duration `mapstructure:"BALANCE_CACHE_MAX_STALENESS"`
+	BalanceCacheListen       bool          `mapstructure:"BALANCE_CACHE_LISTEN"`
+	RedisAddr                string        `mapstructure:"REDIS_ADDR"`
+
+	// Сверка балансов с журналом транзакций. Интервал 0 отключает запуск по расписанию
+	ReconcileInterval  time.Duration `mapstructure:"RECONCILE_INTERVAL"`
+	ReconcileBatchSize int           `mapstructure:"RECONCILE_BATCH_SIZE"`
+	ReconcileReportDir string        `mapstructure:"RECONCILE_REPORT_DIR"`
+	ReconcileFormat    string        `mapstructure:"RECONCILE_FORMAT"`
+
+	// Сверка с файлами расчетов: правило по умолчанию (exact или amount_date) и окно по дате
+	SettlementMatchRule  string        `mapstructure:"SETTLEMENT_MATCH_RULE"`
+	SettlementDateWindow time.Duration `mapstructure:"SETTLEMENT_DATE_WINDOW"`
+
+	// Снимки балансов для запросов на момент времени. Интервал 0 отключает фоновое задание
+	SnapshotInterval time.Duration `mapstructure:"SNAPSHOT_INTERVAL"`
+
+	// Зачисление комиссий арендаторов на кошельки комиссий: интервал и число начислений в пачке.
+	// Интервал 0 отключает фоновое задание, начисления копятся в fee_accruals
+	FeeSettleInterval  time.Duration `mapstructure:"FEE_SETTLE_INTERVAL"`
+	FeeSettleBatchSize int           `mapstructure:"FEE_SETTLE_BATCH_SIZE"`
+
+	// Трассировка OpenTelemetry: экспортер none, otlp или stdout.
+	// Адрес OTLP-коллектора задается стандартной переменной OTEL_EXPORTER_OTLP_ENDPOINT
+	TracingExporter    string  `mapstructure:"TRACING_EXPORTER"`
+	TracingServiceName string  `mapstructure:"TRACING_SERVICE_NAME"`
+	TracingSampleRatio float64 `mapstructure:"TRACING_SAMPLE_RATIO"`
 }
 
+// LoadConfig собирает конфигурацию по возрастанию приоритета: значения по умолчанию, файл .env
+// в рабочем каталоге (если есть), файл YAML/TOML из CONFIG_FILE, переменные окружения и секреты из
+// файлов KEY_FILE (например DB_PASSWORD_FILE=/run/secrets/db_password). Результат проверяется Validate
 func LoadConfig() (*Config, error) {
-	viper.SetConfigName(".env")
-	viper.SetConfigType("env")
-	viper.AddConfigPath(".")
+	// Отдельный экземпляр viper на каждую загрузку: при перечитывании файлов не остается старых значений
+	v := viper.New()
 
-	viper.AutomaticEnv()
+	v.SetDefault("DB_DRIVER", "postgres")
+	v.SetDefault("SQLITE_PATH", "wallet.db")
+	v.SetDefault("SQLITE_BUSY_TIMEOUT", "5s")
+	v.SetDefault("CONFIG_WATCH", true)
+	v.SetDefault("RATE_LIMIT_STORE", "memory")
+	v.SetDefault("RATE_LIMIT_CLEANUP_INTERVAL", "10m")
+	v.SetDefault("ASYNC_WORKERS", 4)
+	v.SetDefault("ASYNC_POLL_INTERVAL", "1s")
+	v.SetDefault("ASYNC_MAX_ATTEMPTS", 5)
+	v.SetDefault("ASYNC_RETRY_DELAY", "10s")
+	v.SetDefault("LOG_FORMAT", "json")
+	v.SetDefault("LOG_LEVEL", "info")
+	v.SetDefault("LOG_OUTPUT", "stdout")
+	v.SetDefault("DB_SSLMODE", "disable")
+	v.SetDefault("DB_MAX_OPEN_CONNS", 25)
+	v.SetDefault("DB_MAX_IDLE_CONNS", 10)
+	v.SetDefault("DB_CONN_MAX_LIFETIME", "30m")
+	v.SetDefault("DB_CONN_MAX_IDLE_TIME", "5m")
+	v.SetDefault("DB_CONNECT_TIMEOUT", "5s")
+	v.SetDefault("HTTP_READ_HEADER_TIMEOUT", "10s")
+	v.SetDefault("HTTP_READ_TIMEOUT", "30s")
+	v.SetDefault("HTTP_WRITE_TIMEOUT", "0")
+	v.SetDefault("HTTP_IDLE_TIMEOUT", "2m")
+	v.SetDefault("SHUTDOWN_TIMEOUT", "30s")
+	v.SetDefault("SHUTDOWN_DELAY", "5s")
+	v.SetDefault("READINESS_TIMEOUT", "2s")
+	v.SetDefault("READINESS_POOL_SATURATION", 0.9)
+	v.SetDefault("DB_AUTO_MIGRATE", false)
+	v.SetDefault("DB_MIGRATE_LOCK_TIMEOUT", "1m")
+	v.SetDefault("DB_REPLICA_MAX_LAG", "1s")
+	v.SetDefault("DB_REPLICA_CHECK_INTERVAL", "1s")
+	v.SetDefault("DB_SHARD_NAME", "main")
+	v.SetDefault("DB_SHARD_VNODES", 128)
+	v.SetDefault("TRANSFER_RECOVERY_INTERVAL", "30s")
+	v.SetDefault("TRANSFER_RECOVERY_AGE", "1m")
+	v.SetDefault("DB_DEPOSIT_TIMEOUT", "5s")
+	v.SetDefault("DB_WITHDRAW_TIMEOUT", "5s")
+	v.SetDefault("DB_READ_TIMEOUT", "3s")
+	v.SetDefault("DB_WALLET_STATEMENT_TIMEOUT", "0")
+	v.SetDefault("DB_LOCK_TIMEOUT", "2s")
+	v.SetDefault("DB_DEPOSIT_ISOLATION", "read_committed")
+	v.SetDefault("DB_WITHDRAW_ISOLATION", "read_committed")
+	v.SetDefault("DB_TX_MAX_ATTEMPTS", 5)
+	v.SetDefault("DB_TX_RETRY_BASE_DELAY", "10ms")
+	v.SetDefault("DB_TX_RETRY_MAX_DELAY", "500ms")
+	v.SetDefault("DEPOSIT_BATCH_ENABLED", false)
+	v.SetDefault("DEPOSIT_BATCH_SIZE", 100)
+	v.SetDefault("DEPOSIT_BATCH_DELAY", "2ms")
+	v.SetDefault("BALANCE_CACHE", "none")
+	v.SetDefault("BALANCE_CACHE_SIZE", 100000)
+	v.SetDefault("BALANCE_CACHE_MAX_STALENESS", "5s")
+	v.SetDefault("BALANCE_CACHE_LISTEN", true)
+	v.SetDefault("REDIS_ADDR", "redis:6379")
+	v.SetDefault("RECONCILE_INTERVAL", "0")
+	v.SetDefault("RECONCILE_BATCH_SIZE", 1000)
+	v.SetDefault("RECONCILE_REPORT_DIR", "reports")
+	v.SetDefault("RECONCILE_FORMAT", "json")
+	v.SetDefault("SETTLEMENT_MATCH_RULE", "exact")
+	v.SetDefault("SETTLEMENT_DATE_WINDOW", "24h")
+	v.SetDefault("SNAPSHOT_INTERVAL", "1h")
+	v.SetDefault("FEE_SETTLE_INTERVAL", "5s")
+	v.SetDefault("FEE_SETTLE_BATCH_SIZE", 1000)
+	v.SetDefault("TRACING_EXPORTER", "none")
+	v.SetDefault("TRACING_SERVICE_NAME", "wallet-service")
+	v.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
 
-	if err := viper.ReadInConfig(); err != nil {
-		logger.Log.Errorf("Error reading config file: %v", err)
-		return nil, fmt.Errorf("error reading config file: %v", err)
+	// .env не обязателен: в контейнере конфигурация обычно приходит через переменные окружения
+	if _, err := os.Stat(".env"); err == nil {
+		v.SetConfigFile(".env")
+		if err := v.MergeInConfig(); err != nil {
+			logger.Log.Errorf("Error reading config file: %v", err)
+			return nil, fmt.Errorf("error reading .env: %w", err)
+		}
 	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		v.SetConfigFile(path)
+		if err := v.MergeInConfig(); err != nil {
+			logger.Log.Errorf("Error reading config file: %v", err)
+			return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+		}
+	}
+
+	// AutomaticEnv видит только ключи, уже известные viper, поэтому каждый ключ привязывается явно
+	for _, key := range keys() {
+		if err := v.BindEnv(key); err != nil {
+			return nil, fmt.Errorf("failed to bind %s: %w", key, err)
+		}
+		if err := readSecretFile(v, key); err != nil {
+			logger.Log.Errorf("Error reading secret: %v", err)
+			return nil, err
+		}
+	}
+
 	var config Config
 
 	logger.Log.Debug("Unmarshalling config data into struct...")
 
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config); err != nil {
 		logger.Log.Errorf("Error unmarshalling config data: %v", err)
 		return nil, fmt.Errorf("error unmarshalling config data: %v", err)
 	}
 
+	if err := config.Validate(); err != nil {
+		// Ошибки проверки в одну строку, чтобы запись лога не разрывалась
+		return nil, fmt.Errorf("invalid config: %s", strings.ReplaceAll(err.Error(), "\n", "; "))
+	}
+
 	logger.Log.Info("Configuration loaded successfully")
 	return &config, nil
 }
+
+// readSecretFile подставляет значение key из файла, указанного в переменной окружения key_FILE
+func readSecretFile(v *viper.Viper, key string) error {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return nil
+	}
+	if _, ok := os.LookupEnv(key); ok {
+		return fmt.Errorf("both %s and %s_FILE are set", key, key)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s_FILE: %w", key, err)
+	}
+	v.Set(key, strings.TrimRight(string(data), "\r\n"))
+	return nil
+}
+
+// keys - ключи конфигурации из тегов mapstructure в порядке полей Config
+func keys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		keys = append(keys, t.Field(i).Tag.Get("mapstructure"))
+	}
+	return keys
+}
+
+// Field - ключ конфигурации и его действующее значение
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Fields возвращает действующие значения всех ключей. При redact непустые секреты (пароли и строки
+// подключения, поля с тегом secret) заменяются на ***
+func (c *Config) Fields(redact bool) []Field {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	fields := make([]Field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		value := formatValue(v.Field(i).Interface())
+		if redact && value != "" && t.Field(i).Tag.Get("secret") == "true" {
+			value = "***"
+		}
+		fields = append(fields, Field{Key: t.Field(i).Tag.Get("mapstructure"), Value: value})
+	}
+	return fields
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, ",")
+	case time.Duration:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chdir переходит во временный каталог без .env
+func chdir(t *testing.T) string {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+	return dir
+}
+
+func setRequired(t *testing.T) {
+	t.Setenv("DB_HOST", "db")
+	t.Setenv("DB_PORT", "5432")
+	t.Setenv("DB_USER", "postgres")
+	t.Setenv("DB_NAME", "wallet_db")
+	t.Setenv("APP_PORT", "8080")
+}
+
+func Test_LoadConfig(t *testing.T) {
+	t.Run("environment without .env", func(t *testing.T) {
+		chdir(t)
+		setRequired(t)
+		t.Setenv("DB_SHARDS", "s1=host=a,s2=host=b")
+
+		cfg, err := LoadConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "db", cfg.DBHost)
+		assert.Equal(t, "disable", cfg.DBSSLMode)
+		assert.Equal(t, 25, cfg.DBMaxOpenConns)
+		assert.Equal(t, []string{"s1=host=a", "s2=host=b"}, cfg.DBShards)
+	})
+
+	t.Run("yaml file overridden by environment", func(t *testing.T) {
+		dir := chdir(t)
+		setRequired(t)
+		path := filepath.Join(dir, "wallet.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("db_host: yaml-db\ndb_sslmode: require\nlog_level: debug\n"), 0o600))
+		t.Setenv("CONFIG_FILE", path)
+		t.Setenv("LOG_LEVEL", "warn")
+		os.Unsetenv("DB_HOST")
+
+		cfg, err := LoadConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "yaml-db", cfg.DBHost)
+		assert.Equal(t, "require", cfg.DBSSLMode)
+		assert.Equal(t, "warn", cfg.LogLevel)
+	})
+
+	t.Run("secret from file", func(t *testing.T) {
+		dir := chdir(t)
+		setRequired(t)
+		path := filepath.Join(dir, "db_password")
+		require.NoError(t, os.WriteFile(path, []byte("s3cret\n"), 0o600))
+		t.Setenv("DB_PASSWORD_FILE", path)
+
+		cfg, err := LoadConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", cfg.DBPassword)
+	})
+
+	t.Run("secret set twice", func(t *testing.T) {
+		dir := chdir(t)
+		setRequired(t)
+		t.Setenv("DB_PASSWORD", "plain")
+		t.Setenv("DB_PASSWORD_FILE", filepath.Join(dir, "db_password"))
+
+		_, err := LoadConfig()
+		assert.ErrorContains(t, err, "both DB_PASSWORD and DB_PASSWORD_FILE are set")
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		chdir(t)
+		setRequired(t)
+		t.Setenv("APP_PORT", "http")
+		t.Setenv("DB_SSLMODE", "prefer")
+		os.Unsetenv("DB_HOST")
+
+		_, err := LoadConfig()
+		require.Error(t, err)
+		assert.ErrorContains(t, err, `APP_PORT: invalid port "http"`)
+		assert.ErrorContains(t, err, "DB_HOST: is required")
+		assert.ErrorContains(t, err, `DB_SSLMODE: "prefer" is not one of disable, require, verify-ca, verify-full`)
+	})
+}
+
+func Test_FieldsRedacted(t *testing.T) {
+	cfg := &Config{DBHost: "db", DBPassword: "s3cret", DBShards: []string{"s1=password=x"}}
+
+	values := map[string]string{}
+	for _, field := range cfg.Fields(true) {
+		values[field.Key] = field.Value
+	}
+	assert.Equal(t, "db", values["DB_HOST"])
+	assert.Equal(t, "***", values["DB_PASSWORD"])
+	assert.Equal(t, "***", values["DB_SHARDS"])
+	assert.Equal(t, "", values["DB_REPLICA_DSNS"])
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"wallet-service/internal/ratelimit"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Validate проверяет значения конфигурации и возвращает все найденные ошибки сразу, по одной на строку
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		if !slices.Contains(allowed, value) {
+			fail(key, "%q is not one of %s", value, strings.Join(allowed, ", "))
+		}
+	}
+	port := func(key, value string) {
+		if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+			fail(key, "invalid port %q", value)
+		}
+	}
+	required := func(key, value string) {
+		if strings.TrimSpace(value) == "" {
+			fail(key, "is required")
+		}
+	}
+	nonNegative := func(key string, value time.Duration) {
+		if value < 0 {
+			fail(key, "must not be negative, got %s", value)
+		}
+	}
+	ratio := func(key string, value float64) {
+		if value < 0 || value > 1 {
+			fail(key, "must be between 0 and 1, got %v", value)
+		}
+	}
+
+	port("APP_PORT", c.AppPort)
+
+	oneOf("DB_DRIVER", c.DBDriver, "postgres", "sqlite", "memory")
+	switch c.DBDriver {
+	case "postgres":
+		required("DB_HOST", c.DBHost)
+		port("DB_PORT", c.DBPort)
+		required("DB_USER", c.DBUser)
+		required("DB_NAME", c.DBName)
+		oneOf("DB_SSLMODE", c.DBSSLMode, "disable", "require", "verify-ca", "verify-full")
+	case "sqlite":
+		required("SQLITE_PATH", c.SQLitePath)
+	}
+
+	if c.DBMaxOpenConns < 0 {
+		fail("DB_MAX_OPEN_CONNS", "must not be negative, got %d", c.DBMaxOpenConns)
+	}
+	if c.DBMaxIdleConns < 0 {
+		fail("DB_MAX_IDLE_CONNS", "must not be negative, got %d", c.DBMaxIdleConns)
+	}
+	if c.DBMaxOpenConns > 0 && c.DBMaxIdleConns > c.DBMaxOpenConns {
+		fail("DB_MAX_IDLE_CONNS", "must not exceed DB_MAX_OPEN_CONNS (%d), got %d", c.DBMaxOpenConns, c.DBMaxIdleConns)
+	}
+
+	for key, value := range map[string]time.Duration{
+		"SQLITE_BUSY_TIMEOUT":         c.SQLiteBusyTimeout,
+		"DB_CONN_MAX_LIFETIME":        c.DBConnMaxLifetime,
+		"DB_CONN_MAX_IDLE_TIME":       c.DBConnMaxIdleTime,
+		"DB_CONNECT_TIMEOUT":          c.DBConnectTimeout,
+		"HTTP_READ_HEADER_TIMEOUT":    c.HTTPReadHeaderTimeout,
+		"HTTP_READ_TIMEOUT":           c.HTTPReadTimeout,
+		"HTTP_WRITE_TIMEOUT":          c.HTTPWriteTimeout,
+		"HTTP_IDLE_TIMEOUT":           c.HTTPIdleTimeout,
+		"SHUTDOWN_TIMEOUT":            c.ShutdownTimeout,
+		"SHUTDOWN_DELAY":              c.ShutdownDelay,
+		"READINESS_TIMEOUT":           c.ReadinessTimeout,
+		"DB_MIGRATE_LOCK_TIMEOUT":     c.DBMigrateLockTimeout,
+		"DB_DEPOSIT_TIMEOUT":          c.DBDepositTimeout,
+		"DB_WITHDRAW_TIMEOUT":         c.DBWithdrawTimeout,
+		"DB_READ_TIMEOUT":             c.DBReadTimeout,
+		"DB_WALLET_STATEMENT_TIMEOUT": c.DBWalletStatementTimeout,
+		"DB_LOCK_TIMEOUT":             c.DBLockTimeout,
+		"RECONCILE_INTERVAL":          c.ReconcileInterval,
+		"SNAPSHOT_INTERVAL":           c.SnapshotInterval,
+		"FEE_SETTLE_INTERVAL":         c.FeeSettleInterval,
+	} {
+		nonNegative(key, value)
+	}
+
+	if _, err := ratelimit.ParseRules(c.RateLimits); err != nil {
+		fail("RATE_LIMITS", "%v", err)
+	}
+	oneOf("RATE_LIMIT_STORE", c.RateLimitStore, "memory", "postgres")
+	if c.RateLimitStore == "postgres" && c.DBDriver != "postgres" {
+		fail("RATE_LIMIT_STORE", "postgres requires DB_DRIVER=postgres")
+	}
+	if c.RateLimitCleanupPeriod <= 0 {
+		fail("RATE_LIMIT_CLEANUP_INTERVAL", "must be positive, got %s", c.RateLimitCleanupPeriod)
+	}
+
+	if c.AsyncWorkers < 0 {
+		fail("ASYNC_WORKERS", "must not be negative, got %d", c.AsyncWorkers)
+	}
+	if c.AsyncPollInterval <= 0 {
+		fail("ASYNC_POLL_INTERVAL", "must be positive, got %s", c.AsyncPollInterval)
+	}
+	if c.AsyncMaxAttempts < 1 {
+		fail("ASYNC_MAX_ATTEMPTS", "must be at least 1, got %d", c.AsyncMaxAttempts)
+	}
+	nonNegative("ASYNC_RETRY_DELAY", c.AsyncRetryDelay)
+
+	if c.RiskRulesFile != "" && c.DBDriver != "postgres" {
+		fail("RISK_RULES_FILE", "requires DB_DRIVER=postgres")
+	}
+	if c.TenantsFile != "" && c.DBDriver != "postgres" {
+		fail("TENANTS_FILE", "requires DB_DRIVER=postgres")
+	}
+
+	oneOf("LOG_FORMAT", c.LogFormat, "text", "json")
+	if _, err := logrus.ParseLevel(c.LogLevel); err != nil {
+		fail("LOG_LEVEL", "%v", err)
+	}
+	for _, value := range c.LogRedact {
+		oneOf("LOG_REDACT", strings.TrimSpace(value), "uuid", "amount")
+	}
+
+	ratio("READINESS_POOL_SATURATION", c.ReadinessPoolSaturation)
+
+	isolationLevels := []string{"read_committed", "repeatable_read", "serializable"}
+	oneOf("DB_DEPOSIT_ISOLATION", c.DBDepositIsolation, isolationLevels...)
+	oneOf("DB_WITHDRAW_ISOLATION", c.DBWithdrawIsolation, isolationLevels...)
+	if c.DBTxMaxAttempts < 1 {
+		fail("DB_TX_MAX_ATTEMPTS", "must be at least 1, got %d", c.DBTxMaxAttempts)
+	}
+
+	if c.DBShardVNodes < 1 {
+		fail("DB_SHARD_VNODES", "must be at least 1, got %d", c.DBShardVNodes)
+	}
+	for _, entry := range c.DBShards {
+		if name, dsn, ok := strings.Cut(entry, "="); !ok || name == "" || dsn == "" {
+			// Строка подключения может содержать пароль, в ошибку попадает только имя
+			fail("DB_SHARDS", "invalid shard %q, expected name=dsn", name)
+		}
+	}
+
+	if c.DepositBatchEnabled && c.DepositBatchSize < 1 {
+		fail("DEPOSIT_BATCH_SIZE", "must be at least 1, got %d", c.DepositBatchSize)
+	}
+
+	oneOf("BALANCE_CACHE", c.BalanceCache, "none", "memory", "redis")
+	if c.BalanceCache == "memory" && c.BalanceCacheSize < 1 {
+		fail("BALANCE_CACHE_SIZE", "must be at least 1, got %d", c.BalanceCacheSize)
+	}
+	if c.BalanceCache == "redis" {
+		required("REDIS_ADDR", c.RedisAddr)
+	}
+
+	if c.ReconcileBatchSize < 1 {
+		fail("RECONCILE_BATCH_SIZE", "must be at least 1, got %d", c.ReconcileBatchSize)
+	}
+	if c.FeeSettleBatchSize < 1 {
+		fail("FEE_SETTLE_BATCH_SIZE", "must be at least 1, got %d", c.FeeSettleBatchSize)
+	}
+	oneOf("RECONCILE_FORMAT", c.ReconcileFormat, "json", "csv")
+	oneOf("SETTLEMENT_MATCH_RULE", c.SettlementMatchRule, "exact", "amount_date")
+
+	oneOf("TRACING_EXPORTER", c.TracingExporter, "none", "otlp", "stdout")
+	ratio("TRACING_SAMPLE_RATIO", c.TracingSampleRatio)
+
+	// Порядок ошибок не зависит от обхода map с таймаутами
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"wallet-service/internal/logger"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce - редакторы и kubectl пишут файл несколькими событиями, конфигурация перечитывается после паузы
+const watchDebounce = 200 * time.Millisecond
+
+// Watch следит за .env, файлом из CONFIG_FILE и файлами extra (например, TENANTS_FILE) и после каждого
+// изменения заново загружает конфигурацию.
+// Некорректная конфигурация в onChange не передается: ошибка пишется в лог, действуют прежние значения.
+// Отслеживается каталог файла, поэтому замена ConfigMap в Kubernetes (симлинк ..data) тоже замечается
+func Watch(onChange func(*Config), extra ...string) (func(), error) {
+	files := map[string]bool{}
+	for _, path := range extra {
+		if path == "" {
+			continue
+		}
+		if path, err := filepath.Abs(path); err == nil {
+			files[path] = true
+		}
+	}
+	if _, err := os.Stat(".env"); err == nil {
+		if path, err := filepath.Abs(".env"); err == nil {
+			files[path] = true
+		}
+	}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if path, err := filepath.Abs(path); err == nil {
+			files[path] = true
+		}
+	}
+	if len(files) == 0 {
+		return func() {}, nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string]bool{}
+	for path := range files {
+		dir := filepath.Dir(path)
+		if dirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+		dirs[dir] = true
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		timer := time.NewTimer(watchDebounce)
+		timer.Stop()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if files[filepath.Clean(event.Name)] || filepath.Base(event.Name) == "..data" {
+					timer.Reset(watchDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Log.Warnf("Config watcher error: %v", err)
+			case <-timer.C:
+				cfg, err := LoadConfig()
+				if err != nil {
+					logger.Log.Errorf("Ignoring config change: %v", err)
+					continue
+				}
+				onChange(cfg)
+			case <-done:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+
+	logger.Log.Infof("Watching config files for runtime settings changes")
+	return func() {
+		close(done)
+		watcher.Close()
+		wg.Wait()
+	}, nil
+}
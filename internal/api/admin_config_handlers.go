@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"wallet-service/internal/logger"
+	"wallet-service/internal/settings"
+)
+
+type AdminConfigHandlers struct {
+	Settings *settings.Manager
+}
+
+func NewAdminConfigHandler(manager *settings.Manager) *AdminConfigHandlers {
+	return &AdminConfigHandlers{Settings: manager}
+}
+
+// AdminAuth пропускает только запросы с заголовком Authorization: Bearer <token>
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			logger.Log.WithContext(c.Request.Context()).Warnf("Unauthorized admin request to %s", c.FullPath())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetConfig - действующая конфигурация без секретов (с учетом изменений во время работы)
+// и отдельно настройки, которые можно менять без перезапуска
+func (h *AdminConfigHandlers) GetConfig(c *gin.Context) {
+	current := h.Settings.Current()
+
+	runtimeValues := map[string]any{}
+	data, _ := json.Marshal(current)
+	json.Unmarshal(data, &runtimeValues)
+
+	effective := map[string]any{}
+	for _, field := range h.Settings.Config().Fields(true) {
+		effective[field.Key] = field.Value
+	}
+	for key, value := range runtimeValues {
+		effective[key] = value
+	}
+
+	c.JSON(http.StatusOK, gin.H{"config": effective, "runtime": current})
+}
+
+// PutConfig меняет настройки времени выполнения. Тело - JSON с изменяемыми ключами, например
+// {"LOG_LEVEL": "debug"}; остальные настройки сохраняют текущие значения
+func (h *AdminConfigHandlers) PutConfig(c *gin.Context) {
+	log := logger.Log.WithContext(c.Request.Context())
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	next := h.Settings.Current()
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&next); err != nil {
+		log.Warnf("Invalid runtime settings payload: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":         "Invalid request payload",
+			"runtimeFields": settings.Keys(),
+		})
+		return
+	}
+
+	if err := h.Settings.Update("admin API", next); err != nil {
+		log.Warnf("Rejected runtime settings: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runtime": h.Settings.Current()})
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"wallet-service/internal/db"
+)
+
+// respondTransientError отвечает 504 на таймаут операции, 503 на ее отмену или перенос кошелька и 409,
+// если транзакция не прошла из-за конфликтов после всех повторов.
+// Возвращает false, если ошибка другого типа и ее нужно обработать вызывающему
+func respondTransientError(c *gin.Context, log *logrus.Entry, err error) bool {
+	switch {
+	case errors.Is(err, db.ErrOperationTimeout) || errors.Is(err, context.DeadlineExceeded):
+		log.Warnf("Operation timed out: %v", err)
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Operation timed out"})
+	case errors.Is(err, db.ErrOperationCanceled) || errors.Is(err, context.Canceled):
+		log.Warnf("Operation canceled: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Operation canceled"})
+	case errors.Is(err, db.ErrWalletMoving):
+		log.Warnf("Wallet is being moved: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Wallet is being moved, retry later"})
+	case errors.Is(err, db.ErrTransactionConflict):
+		log.Warnf("Transaction conflict: %v", err)
+		c.JSON(http.StatusConflict, gin.H{"error": "Concurrent update conflict, retry the operation"})
+	default:
+		return false
+	}
+	return true
+}
@@ -1,13 +1,18 @@
 package api
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"wallet-service/internal/db"
 	"wallet-service/internal/logger"
+	"wallet-service/internal/risk"
 )
 
 type WalletHandlersInterface interface {
@@ -17,6 +22,14 @@ type WalletHandlersInterface interface {
 
 type WalletHandlers struct {
 	Repo db.Repository
+	// History нужен для запросов баланса на момент времени (?asOf=), может быть nil
+	History db.HistoryRepository
+	// Risk - проверка рисков перед пополнением и снятием, nil - проверки отключены
+	Risk *risk.Engine
+	// ClientHeader - заголовок с идентификатором клиента для правил риска, пустой - клиент определяется по IP
+	ClientHeader string
+	// Operations - очередь для операций с "mode": "async", nil - асинхронный режим недоступен
+	Operations db.OperationQueue
 }
 
 func NewWalletHandler(repo db.Repository) *WalletHandlers {
@@ -24,30 +37,70 @@ func NewWalletHandler(repo db.Repository) *WalletHandlers {
 }
 
 func (h *WalletHandlers) PostWalletOperation(c *gin.Context) {
-	logger.Log.Debugf("Entering handler PostWalletOperation")
-	defer logger.Log.Debugf("Exiting handler PostWalletOperation")
+	log := logger.Log.WithContext(c.Request.Context())
+	log.Debugf("Entering handler PostWalletOperation")
+	defer log.Debugf("Exiting handler PostWalletOperation")
 	//структура запроса
 	var req struct {
 		WalletUUID    string `json:"walletId" binding:"required,uuid"`
 		OperationType string `json:"operationType" binding:"required,oneof=DEPOSIT WITHDRAW"`
 		Amount        int64  `json:"amount" binding:"required,gt=0"`
+		// Mode - sync (по умолчанию) или async: операция ставится в очередь, ответ 202 с ID операции
+		Mode string `json:"mode" binding:"omitempty,oneof=sync async"`
+		// Currency - валюта операции, должна совпадать с валютой арендатора
+		Currency string `json:"currency" binding:"omitempty,len=3"`
 	}
 
 	//привязываем JSON запрос к структуре
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.Log.Warnf("Invalid request payload: %v", err)
+		log.Warnf("Invalid request payload: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 		return
 	}
 
-	logger.Log.Infof("Processing operation %s for wallet %s with amount %d", req.OperationType, req.WalletUUID, req.Amount)
+	log.Infof("Processing operation %s for wallet %s with amount %d", req.OperationType, req.WalletUUID, logger.Amount(req.Amount))
+
+	if req.Mode == "async" && h.Operations == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Asynchronous operations are not available"})
+		return
+	}
+
+	//валюта и лимиты арендатора
+	if !checkTenantOperation(c, req.OperationType, req.Currency, req.Amount) {
+		return
+	}
+
+	//проверка рисков до движения денег
+	if h.Risk != nil && !h.checkRisk(c, log, req.OperationType, req.WalletUUID, req.Amount) {
+		return
+	}
+
+	//асинхронный режим: операцию выполнит воркер, статус - GET /api/v1/operations/:id
+	if req.Mode == "async" {
+		h.enqueueOperation(c, log, req.WalletUUID, req.OperationType, req.Amount)
+		return
+	}
 
 	switch req.OperationType {
 	case "DEPOSIT":
 		//пополнение кошелька
-		err := h.Repo.DepositMoney(req.WalletUUID, req.Amount)
+		err := h.Repo.DepositMoney(c.Request.Context(), req.WalletUUID, req.Amount)
 		if err != nil {
-			logger.Log.Errorf("Failed to deposit money for wallet %s: %v", req.WalletUUID, err)
+			if respondTransientError(c, log, err) {
+				return
+			}
+			if errors.Is(err, db.ErrWalletFrozen) {
+				log.Warnf("Deposit rejected for wallet %s: %v", req.WalletUUID, err)
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			//кошелек другого арендатора
+			if errors.Is(err, db.ErrWalletNotFound) {
+				log.Warnf("Wallet %s not found", req.WalletUUID)
+				c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+				return
+			}
+			log.Errorf("Failed to deposit money for wallet %s: %v", req.WalletUUID, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deposit money"})
 			return
 		}
@@ -55,14 +108,23 @@ func (h *WalletHandlers) PostWalletOperation(c *gin.Context) {
 
 	case "WITHDRAW":
 		//Вывод средств
-		err := h.Repo.WithdrawMoney(req.WalletUUID, req.Amount)
+		err := h.Repo.WithdrawMoney(c.Request.Context(), req.WalletUUID, req.Amount)
 		if err != nil {
 			//Обработка ошибок в зависимости от их типа
-			if errors.Is(err, db.ErrInsufficientFunds) || errors.Is(err, db.ErrWalletNotFound) {
-				logger.Log.Warnf("Withdraw failed for wallet %s: %v", req.WalletUUID, err)
+			if respondTransientError(c, log, err) {
+				return
+			}
+			if errors.Is(err, db.ErrInsufficientFunds) {
+				log.Warnf("Withdraw failed for wallet %s: %v", req.WalletUUID, err)
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			} else if errors.Is(err, db.ErrWalletNotFound) {
+				log.Warnf("Wallet %s not found", req.WalletUUID)
+				c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+			} else if errors.Is(err, db.ErrWalletFrozen) {
+				log.Warnf("Withdraw rejected for wallet %s: %v", req.WalletUUID, err)
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 			} else {
-				logger.Log.Errorf("Failed to withdraw money for wallet %s: %v", req.WalletUUID, err)
+				log.Errorf("Failed to withdraw money for wallet %s: %v", req.WalletUUID, err)
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
 			}
 			return
@@ -70,35 +132,107 @@ func (h *WalletHandlers) PostWalletOperation(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "Withdraw successful"})
 
 	default:
-		logger.Log.Warnf("Invalid operation type: %s", req.OperationType)
+		log.Warnf("Invalid operation type: %s", req.OperationType)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid operation type"})
 	}
 }
 
 func (h *WalletHandlers) GetBalance(c *gin.Context) {
+	log := logger.Log.WithContext(c.Request.Context())
 	//получение UUID кошелька из параметра запроса
 	walletUUID := c.Param("walletUUID")
 	if walletUUID == "" {
-		logger.Log.Warn("Missing walletUUID parameter")
+		log.Warn("Missing walletUUID parameter")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing walletUUID parameter"})
 		return
 	}
 
-	logger.Log.Infof("Fetching balance for wallet %s", walletUUID)
+	if asOf := c.Query("asOf"); asOf != "" {
+		h.getBalanceAt(c, walletUUID, asOf)
+		return
+	}
+
+	ctx, err := balanceReadContext(c)
+	if err != nil {
+		log.Warnf("Invalid consistency parameter: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid consistency parameter"})
+		return
+	}
+
+	log.Infof("Fetching balance for wallet %s", walletUUID)
 
 	//получение баланса из репозитория
-	balance, err := h.Repo.GetBalance(walletUUID)
+	balance, err := h.Repo.GetBalance(ctx, walletUUID)
 	if err != nil {
+		if respondTransientError(c, log, err) {
+			return
+		}
+		if errors.Is(err, db.ErrWalletNotFound) {
+			log.Warnf("Wallet %s not found", walletUUID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		} else {
+			log.Errorf("Failed to fetch balance for wallet %s: %v", walletUUID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		}
+		return
+	}
+
+	log.Infof("Successfully retrieved balance for wallet %s: %d", walletUUID, logger.Amount(balance))
+	response := gin.H{"walletId": walletUUID, "balance": balance}
+	if t := currentTenant(c); t != nil && t.Currency != "" {
+		response["currency"] = t.Currency
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// balanceReadContext - контекст чтения баланса. ?consistency=strong или Cache-Control: no-cache
+// требуют строго согласованного чтения в обход кэша, ?consistency=eventual - значение по умолчанию
+func balanceReadContext(c *gin.Context) (context.Context, error) {
+	ctx := c.Request.Context()
+
+	switch consistency := c.Query("consistency"); consistency {
+	case "strong":
+		return db.WithStrongConsistency(ctx), nil
+	case "", "eventual":
+	default:
+		return nil, fmt.Errorf("unsupported consistency %q", consistency)
+	}
+
+	if strings.Contains(strings.ToLower(c.GetHeader("Cache-Control")), "no-cache") {
+		return db.WithStrongConsistency(ctx), nil
+	}
+	return ctx, nil
+}
+
+// getBalanceAt - баланс кошелька на момент времени по истории транзакций
+func (h *WalletHandlers) getBalanceAt(c *gin.Context, walletUUID, asOfParam string) {
+	log := logger.Log.WithContext(c.Request.Context())
+	if h.History == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Historical balance is not available"})
+		return
+	}
+
+	asOf, err := parseTimeParam(asOfParam)
+	if err != nil {
+		log.Warnf("Invalid asOf parameter %q: %v", asOfParam, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid asOf parameter"})
+		return
+	}
+
+	balance, err := h.History.GetBalanceAt(c.Request.Context(), walletUUID, asOf)
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return
+		}
 		if errors.Is(err, db.ErrWalletNotFound) {
-			logger.Log.Warnf("Wallet %s not found", walletUUID)
+			log.Warnf("Wallet %s not found", walletUUID)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
 		} else {
-			logger.Log.Errorf("Failed to fetch balance for wallet %s: %v", walletUUID, err)
+			log.Errorf("Failed to fetch balance for wallet %s as of %s: %v", walletUUID, asOf, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
 		}
 		return
 	}
 
-	logger.Log.Infof("Successfully retrieved balance for wallet %s: %d", walletUUID, balance)
-	c.JSON(http.StatusOK, gin.H{"walletId": walletUUID, "balance": balance})
+	c.JSON(http.StatusOK, gin.H{"walletId": walletUUID, "balance": balance, "asOf": asOf.Format(time.RFC3339)})
 }
@@ -2,17 +2,32 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"wallet-service/config"
 	"wallet-service/internal/db"
 	"wallet-service/internal/db/mocks"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/ratelimit"
+	"wallet-service/internal/risk"
+	"wallet-service/internal/settings"
+	"wallet-service/internal/tenant"
 )
 
 func Test_PostWalletOperation(t *testing.T) {
@@ -36,7 +51,7 @@ func Test_PostWalletOperation(t *testing.T) {
 			repoMock: func() *mocks.MockRepository {
 				repo := mocks.NewMockRepository(ctrl)
 
-				repo.EXPECT().DepositMoney("123e4567-e89b-12d3-a456-426614174000", int64(100)).Return(nil)
+				repo.EXPECT().DepositMoney(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000", int64(100)).Return(nil)
 
 				return repo
 			},
@@ -52,7 +67,7 @@ func Test_PostWalletOperation(t *testing.T) {
 			repoMock: func() *mocks.MockRepository {
 				repo := mocks.NewMockRepository(ctrl)
 
-				repo.EXPECT().DepositMoney("123e4567-e89b-12d3-a456-426614174000", int64(100)).Return(fmt.Errorf("random error"))
+				repo.EXPECT().DepositMoney(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000", int64(100)).Return(fmt.Errorf("random error"))
 
 				return repo
 			},
@@ -110,7 +125,7 @@ func Test_PostWalletOperation(t *testing.T) {
 			repoMock: func() *mocks.MockRepository {
 				repo := mocks.NewMockRepository(ctrl)
 
-				repo.EXPECT().WithdrawMoney("123e4567-e89b-12d3-a456-426614174000", int64(100)).Return(nil)
+				repo.EXPECT().WithdrawMoney(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000", int64(100)).Return(nil)
 
 				return repo
 			},
@@ -126,7 +141,55 @@ func Test_PostWalletOperation(t *testing.T) {
 			repoMock: func() *mocks.MockRepository {
 				repo := mocks.NewMockRepository(ctrl)
 
-				repo.EXPECT().WithdrawMoney("123e4567-e89b-12d3-a456-426614174000", int64(100)).Return(fmt.Errorf("random error"))
+				repo.EXPECT().WithdrawMoney(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000", int64(100)).Return(fmt.Errorf("random error"))
+
+				return repo
+			},
+		},
+		{
+			name: "WithdrawMoney timeout",
+			requestBody: []byte(`{
+					"walletId": "123e4567-e89b-12d3-a456-426614174000",
+					"operationType": "WITHDRAW",
+					"amount": 100
+				}`),
+			statusCode: http.StatusGatewayTimeout,
+			repoMock: func() *mocks.MockRepository {
+				repo := mocks.NewMockRepository(ctrl)
+
+				repo.EXPECT().WithdrawMoney(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000", int64(100)).Return(db.ErrOperationTimeout)
+
+				return repo
+			},
+		},
+		{
+			name: "WithdrawMoney frozen wallet",
+			requestBody: []byte(`{
+				"walletId": "123e4567-e89b-12d3-a456-426614174000",
+				"operationType": "WITHDRAW",
+				"amount": 100
+			}`),
+			statusCode: http.StatusForbidden,
+			repoMock: func() *mocks.MockRepository {
+				repo := mocks.NewMockRepository(ctrl)
+
+				repo.EXPECT().WithdrawMoney(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000", int64(100)).Return(db.ErrWalletFrozen)
+
+				return repo
+			},
+		},
+		{
+			name: "DepositMoney transaction conflict",
+			requestBody: []byte(`{
+					"walletId": "123e4567-e89b-12d3-a456-426614174000",
+					"operationType": "DEPOSIT",
+					"amount": 100
+				}`),
+			statusCode: http.StatusConflict,
+			repoMock: func() *mocks.MockRepository {
+				repo := mocks.NewMockRepository(ctrl)
+
+				repo.EXPECT().DepositMoney(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000", int64(100)).Return(db.ErrTransactionConflict)
 
 				return repo
 			},
@@ -207,7 +270,7 @@ func Test_GetBalance(t *testing.T) {
 			}`),
 			repoMock: func() *mocks.MockRepository {
 				repo := mocks.NewMockRepository(ctrl)
-				repo.EXPECT().GetBalance("123e4567-e89b-12d3-a456-426614174000").Return(int64(500), nil)
+				repo.EXPECT().GetBalance(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000").Return(int64(500), nil)
 				return repo
 			},
 		},
@@ -220,7 +283,7 @@ func Test_GetBalance(t *testing.T) {
 			}`),
 			repoMock: func() *mocks.MockRepository {
 				repo := mocks.NewMockRepository(ctrl)
-				repo.EXPECT().GetBalance("123e4567-e89b-12d3-a456-426614174000").Return(int64(0), db.ErrWalletNotFound)
+				repo.EXPECT().GetBalance(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000").Return(int64(0), db.ErrWalletNotFound)
 				return repo
 			},
 		},
@@ -233,7 +296,20 @@ func Test_GetBalance(t *testing.T) {
 			}`),
 			repoMock: func() *mocks.MockRepository {
 				repo := mocks.NewMockRepository(ctrl)
-				repo.EXPECT().GetBalance("123e4567-e89b-12d3-a456-426614174000").Return(int64(0), fmt.Errorf("random error"))
+				repo.EXPECT().GetBalance(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000").Return(int64(0), fmt.Errorf("random error"))
+				return repo
+			},
+		},
+		{
+			name:       "Request canceled",
+			walletUUID: "123e4567-e89b-12d3-a456-426614174000",
+			statusCode: http.StatusServiceUnavailable,
+			expectedBody: []byte(`{
+				"error": "Operation canceled"
+			}`),
+			repoMock: func() *mocks.MockRepository {
+				repo := mocks.NewMockRepository(ctrl)
+				repo.EXPECT().GetBalance(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000").Return(int64(0), db.ErrOperationCanceled)
 				return repo
 			},
 		},
@@ -284,3 +360,714 @@ func Test_GetBalance(t *testing.T) {
 		})
 	}
 }
+
+func Test_GetBalanceAsOf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	asOf := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	var tests = []struct {
+		name         string
+		query        string
+		expectedBody []byte
+		statusCode   int
+		historyMock  func() *mocks.MockHistoryRepository
+	}{
+		{
+			name:       "Balance as of success",
+			query:      "?asOf=2024-03-31T23:59:59Z",
+			statusCode: http.StatusOK,
+			expectedBody: []byte(`{
+				"balance": 300,
+				"walletId": "123e4567-e89b-12d3-a456-426614174000",
+				"asOf": "2024-03-31T23:59:59Z"
+			}`),
+			historyMock: func() *mocks.MockHistoryRepository {
+				history := mocks.NewMockHistoryRepository(ctrl)
+				history.EXPECT().GetBalanceAt(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000", asOf).Return(int64(300), nil)
+				return history
+			},
+		},
+		{
+			name:       "Wallet not found",
+			query:      "?asOf=2024-03-31T23:59:59Z",
+			statusCode: http.StatusNotFound,
+			expectedBody: []byte(`{
+				"error": "Wallet not found"
+			}`),
+			historyMock: func() *mocks.MockHistoryRepository {
+				history := mocks.NewMockHistoryRepository(ctrl)
+				history.EXPECT().GetBalanceAt(gomock.Any(), "123e4567-e89b-12d3-a456-426614174000", asOf).Return(int64(0), db.ErrWalletNotFound)
+				return history
+			},
+		},
+		{
+			name:       "Invalid asOf",
+			query:      "?asOf=yesterday",
+			statusCode: http.StatusBadRequest,
+			expectedBody: []byte(`{
+				"error": "Invalid asOf parameter"
+			}`),
+			historyMock: func() *mocks.MockHistoryRepository {
+				return mocks.NewMockHistoryRepository(ctrl)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handlerMocked := NewWalletHandler(mocks.NewMockRepository(ctrl))
+			handlerMocked.History = test.historyMock()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/wallets/:walletUUID", handlerMocked.GetBalance)
+
+			req, err := http.NewRequest(http.MethodGet, "/wallets/123e4567-e89b-12d3-a456-426614174000"+test.query, nil)
+			if err != nil {
+				t.Errorf("http.NewRequest: %v", err)
+			}
+
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, test.statusCode, resp.Code)
+
+			assert.JSONEq(t, string(test.expectedBody), resp.Body.String())
+		})
+	}
+}
+
+func Test_RequestID(t *testing.T) {
+	var tests = []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{name: "Request ID from header", header: "req-42", expected: "req-42"},
+		{name: "Generated when missing"},
+		{name: "Generated when invalid", header: "bad id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext string
+			router := gin.New()
+			router.Use(RequestID())
+			router.GET("/", func(c *gin.Context) {
+				fromContext = logger.RequestID(c.Request.Context())
+				c.Status(http.StatusOK)
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			requestID := w.Header().Get(RequestIDHeader)
+			assert.Equal(t, requestID, fromContext)
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, requestID)
+			} else {
+				assert.Len(t, requestID, 36)
+			}
+		})
+	}
+}
+
+func Test_AdminConfig(t *testing.T) {
+	var tests = []struct {
+		name        string
+		token       string
+		requestBody string
+		statusCode  int
+		logLevel    string
+	}{
+		{name: "Unauthorized", token: "wrong", requestBody: `{"LOG_LEVEL":"debug"}`, statusCode: http.StatusUnauthorized, logLevel: "info"},
+		{name: "Change log level", token: "secret", requestBody: `{"LOG_LEVEL":"debug"}`, statusCode: http.StatusOK, logLevel: "debug"},
+		{name: "Invalid value", token: "secret", requestBody: `{"LOG_LEVEL":"loud"}`, statusCode: http.StatusBadRequest, logLevel: "info"},
+		{name: "Not a runtime setting", token: "secret", requestBody: `{"APP_PORT":"9090"}`, statusCode: http.StatusBadRequest, logLevel: "info"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, err := settings.NewManager(&config.Config{LogLevel: "info"})
+			require.NoError(t, err)
+			handler := NewAdminConfigHandler(manager)
+			router := gin.New()
+			router.PUT("/admin/config", AdminAuth("secret"), handler.PutConfig)
+
+			req, _ := http.NewRequest(http.MethodPut, "/admin/config", bytes.NewBufferString(tt.requestBody))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.logLevel, manager.Current().LogLevel)
+		})
+	}
+}
+
+func Test_AdminConfigTenants(t *testing.T) {
+	tenantsFile := filepath.Join(t.TempDir(), "tenants.yaml")
+	require.NoError(t, os.WriteFile(tenantsFile, []byte(`
+tenants:
+  - id: acme
+    api_keys: [`+hashAPIKey("acme-key")+`]
+    limits: {withdraw: 1000}
+`), 0o600))
+
+	manager, err := settings.NewManager(&config.Config{LogLevel: "info", TenantsFile: tenantsFile})
+	require.NoError(t, err)
+	registry, err := tenant.NewRegistry(manager.Current().Tenants)
+	require.NoError(t, err)
+	manager.Subscribe(settings.ApplyTenants(registry))
+
+	handler := NewAdminConfigHandler(manager)
+	router := gin.New()
+	router.GET("/admin/config", AdminAuth("secret"), handler.GetConfig)
+	router.PUT("/admin/config", AdminAuth("secret"), handler.PutConfig)
+	request := func(method, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/admin/config", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"TENANTS":[{"id":"acme","api_keys":["[REDACTED]"]`)
+	assert.NotContains(t, w.Body.String(), hashAPIKey("acme-key"))
+
+	// Лимит меняется без перезапуска, некорректные арендаторы не применяются
+	body := `{"TENANTS":[{"id":"acme","api_keys":["` + hashAPIKey("acme-key") + `"],"limits":{"withdraw":50}}]}`
+	require.Equal(t, http.StatusOK, request(http.MethodPut, body).Code)
+	assert.Equal(t, int64(50), registry.Authenticate("acme-key").Limit("WITHDRAW"))
+
+	w = request(http.MethodPut, `{"TENANTS":[{"id":"acme","api_keys":["[REDACTED]"]}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "TENANTS")
+	assert.Equal(t, int64(50), registry.Authenticate("acme-key").Limit("WITHDRAW"))
+}
+
+func Test_RateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+	repo.EXPECT().WithdrawMoney(gomock.Any(), "d7af0768-704e-4f1c-9793-a44c2d1f9b75", int64(10)).Return(nil).Times(1)
+	repo.EXPECT().DepositMoney(gomock.Any(), "d7af0768-704e-4f1c-9793-a44c2d1f9b75", int64(10)).Return(nil).Times(1)
+
+	rules, err := ratelimit.ParseRules([]string{"withdraw.client=1/h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := &RateLimiter{Limiter: ratelimit.NewLimiter(ratelimit.NewMemoryStore(), rules)}
+	router := gin.New()
+	router.POST("/api/v1/wallet", limiter.WalletOperation(), NewWalletHandler(repo).PostWalletOperation)
+
+	post := func(operation string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"walletId":"d7af0768-704e-4f1c-9793-a44c2d1f9b75","operationType":"%s","amount":10}`, operation)
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("WITHDRAW")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = post("WITHDRAW")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	// Ограничение снятия не действует на депозиты
+	w = post("DEPOSIT")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func Test_RiskCheck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	rules, err := risk.ParseRules([]byte(`
+rules:
+  - name: large_withdraw
+    when: operation == "WITHDRAW" && amount > 1000
+    action: review
+  - name: huge_amount
+    when: amount > 100000
+    action: deny
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const wallet = "d7af0768-704e-4f1c-9793-a44c2d1f9b75"
+	var tests = []struct {
+		name       string
+		operation  string
+		amount     int64
+		statusCode int
+		outcome    string
+	}{
+		{name: "Allowed", operation: "DEPOSIT", amount: 5000, statusCode: http.StatusOK, outcome: db.RiskAllow},
+		{name: "Held for review", operation: "WITHDRAW", amount: 5000, statusCode: http.StatusAccepted, outcome: db.RiskReview},
+		{name: "Denied", operation: "DEPOSIT", amount: 500000, statusCode: http.StatusForbidden, outcome: db.RiskDeny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewMockRepository(ctrl)
+			if tt.outcome == db.RiskAllow {
+				repo.EXPECT().DepositMoney(gomock.Any(), wallet, tt.amount).Return(nil)
+			}
+			riskRepo := mocks.NewMockRiskRepository(ctrl)
+			riskRepo.EXPECT().GetRiskStats(gomock.Any(), wallet, "203.0.113.7", gomock.Any()).Return(&db.RiskStats{}, nil)
+			riskRepo.EXPECT().RecordRiskDecision(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, d *db.RiskDecision) error {
+				assert.Equal(t, tt.outcome, d.Outcome)
+				return nil
+			})
+
+			handler := NewWalletHandler(repo)
+			handler.Risk = risk.NewEngine(riskRepo, rules)
+			handler.ClientHeader = "X-Client-ID"
+			router := gin.New()
+			router.POST("/api/v1/wallet", handler.PostWalletOperation)
+
+			body := fmt.Sprintf(`{"walletId":"%s","operationType":"%s","amount":%d}`, wallet, tt.operation, tt.amount)
+			req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Client-ID", "203.0.113.7")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
+
+func Test_RiskReview(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const id = "0f8fad5b-d9cb-469f-a165-70867728950e"
+	pending := func(status string) *db.PendingOperation {
+		return &db.PendingOperation{ID: id, WalletUUID: "d7af0768-704e-4f1c-9793-a44c2d1f9b75", OperationType: "WITHDRAW",
+			Amount: 5000, Status: status, DecidedBy: "alice"}
+	}
+
+	var tests = []struct {
+		name       string
+		action     string
+		body       string
+		statusCode int
+		mock       func(repo *mocks.MockRepository, riskRepo *mocks.MockRiskRepository)
+	}{
+		{
+			name: "Approve executes operation", action: "approve", body: `{"operator":"alice"}`, statusCode: http.StatusOK,
+			mock: func(repo *mocks.MockRepository, riskRepo *mocks.MockRiskRepository) {
+				riskRepo.EXPECT().DecidePendingOperation(gomock.Any(), id, db.PendingStatusApproved, "alice", "").Return(pending(db.PendingStatusApproved), nil)
+				repo.EXPECT().WithdrawMoney(gomock.Any(), "d7af0768-704e-4f1c-9793-a44c2d1f9b75", int64(5000)).Return(nil)
+			},
+		},
+		{
+			name: "Approved operation fails", action: "approve", body: `{"operator":"alice"}`, statusCode: http.StatusConflict,
+			mock: func(repo *mocks.MockRepository, riskRepo *mocks.MockRiskRepository) {
+				riskRepo.EXPECT().DecidePendingOperation(gomock.Any(), id, db.PendingStatusApproved, "alice", "").Return(pending(db.PendingStatusApproved), nil)
+				repo.EXPECT().WithdrawMoney(gomock.Any(), gomock.Any(), gomock.Any()).Return(db.ErrInsufficientFunds)
+				riskRepo.EXPECT().FailPendingOperation(gomock.Any(), id, db.ErrInsufficientFunds.Error()).Return(nil)
+			},
+		},
+		{
+			name: "Reject", action: "reject", body: `{"operator":"alice","reason":"confirmed fraud"}`, statusCode: http.StatusOK,
+			mock: func(repo *mocks.MockRepository, riskRepo *mocks.MockRiskRepository) {
+				riskRepo.EXPECT().DecidePendingOperation(gomock.Any(), id, db.PendingStatusRejected, "alice", "confirmed fraud").Return(pending(db.PendingStatusRejected), nil)
+			},
+		},
+		{
+			name: "Already decided", action: "approve", body: `{"operator":"alice"}`, statusCode: http.StatusConflict,
+			mock: func(repo *mocks.MockRepository, riskRepo *mocks.MockRiskRepository) {
+				riskRepo.EXPECT().DecidePendingOperation(gomock.Any(), id, gomock.Any(), gomock.Any(), gomock.Any()).Return(pending(db.PendingStatusRejected), db.ErrPendingOperationDecided)
+			},
+		},
+		{
+			name: "Not found", action: "reject", body: `{"operator":"alice"}`, statusCode: http.StatusNotFound,
+			mock: func(repo *mocks.MockRepository, riskRepo *mocks.MockRiskRepository) {
+				riskRepo.EXPECT().DecidePendingOperation(gomock.Any(), id, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, db.ErrPendingOperationNotFound)
+			},
+		},
+		{
+			name: "Operator required", action: "approve", body: `{}`, statusCode: http.StatusBadRequest,
+			mock: func(repo *mocks.MockRepository, riskRepo *mocks.MockRiskRepository) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewMockRepository(ctrl)
+			riskRepo := mocks.NewMockRiskRepository(ctrl)
+			tt.mock(repo, riskRepo)
+
+			handler := NewRiskHandler(riskRepo, repo)
+			router := gin.New()
+			router.POST("/admin/risk/pending/:id/approve", handler.Approve)
+			router.POST("/admin/risk/pending/:id/reject", handler.Reject)
+
+			req, _ := http.NewRequest(http.MethodPost, "/admin/risk/pending/"+id+"/"+tt.action, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
+
+func Test_AsyncOperation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const wallet = "d7af0768-704e-4f1c-9793-a44c2d1f9b75"
+	const id = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+	t.Run("Async mode enqueues operation", func(t *testing.T) {
+		queue := mocks.NewMockOperationQueue(ctrl)
+		queue.EXPECT().EnqueueOperation(gomock.Any(), wallet, "DEPOSIT", int64(500)).
+			Return(&db.AsyncOperation{ID: id, WalletUUID: wallet, OperationType: "DEPOSIT", Amount: 500, Status: db.OperationPending}, nil)
+
+		handler := NewWalletHandler(mocks.NewMockRepository(ctrl))
+		handler.Operations = queue
+		router := gin.New()
+		router.POST("/api/v1/wallet", handler.PostWalletOperation)
+
+		body := fmt.Sprintf(`{"walletId":"%s","operationType":"DEPOSIT","amount":500,"mode":"async"}`, wallet)
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "/api/v1/operations/"+id, w.Header().Get("Location"))
+		assert.Contains(t, w.Body.String(), id)
+	})
+
+	t.Run("Async mode unavailable", func(t *testing.T) {
+		handler := NewWalletHandler(mocks.NewMockRepository(ctrl))
+		router := gin.New()
+		router.POST("/api/v1/wallet", handler.PostWalletOperation)
+
+		body := fmt.Sprintf(`{"walletId":"%s","operationType":"DEPOSIT","amount":500,"mode":"async"}`, wallet)
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+	})
+
+	var tests = []struct {
+		name       string
+		id         string
+		statusCode int
+		mock       func(queue *mocks.MockOperationQueue)
+	}{
+		{
+			name: "Completed", id: id, statusCode: http.StatusOK,
+			mock: func(queue *mocks.MockOperationQueue) {
+				queue.EXPECT().GetOperation(gomock.Any(), id).Return(&db.AsyncOperation{ID: id, Status: db.OperationCompleted}, nil)
+			},
+		},
+		{
+			name: "Not found", id: id, statusCode: http.StatusNotFound,
+			mock: func(queue *mocks.MockOperationQueue) {
+				queue.EXPECT().GetOperation(gomock.Any(), id).Return(nil, db.ErrOperationNotFound)
+			},
+		},
+		{
+			name: "Invalid id", id: "not-a-uuid", statusCode: http.StatusBadRequest,
+			mock: func(queue *mocks.MockOperationQueue) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := mocks.NewMockOperationQueue(ctrl)
+			tt.mock(queue)
+
+			router := gin.New()
+			router.GET("/api/v1/operations/:id", NewOperationHandler(queue).GetOperation)
+
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/operations/"+tt.id, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
+
+func Test_PutWalletShards(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const wallet = "d7af0768-704e-4f1c-9793-a44c2d1f9b75"
+
+	var tests = []struct {
+		name        string
+		query       string
+		requestBody string
+		statusCode  int
+		mock        func(repo *mocks.MockHotWalletRepository)
+	}{
+		{
+			name: "Split", requestBody: `{"shardCount":16}`, statusCode: http.StatusOK,
+			mock: func(repo *mocks.MockHotWalletRepository) {
+				repo.EXPECT().SetShardCount(gomock.Any(), wallet, 16).Return(nil)
+			},
+		},
+		{
+			name: "Wallet not found", requestBody: `{"shardCount":4}`, statusCode: http.StatusNotFound,
+			mock: func(repo *mocks.MockHotWalletRepository) {
+				repo.EXPECT().SetShardCount(gomock.Any(), wallet, 4).Return(db.ErrWalletNotFound)
+			},
+		},
+		{
+			name: "Rejected by repository", requestBody: `{"shardCount":4}`, statusCode: http.StatusBadRequest,
+			mock: func(repo *mocks.MockHotWalletRepository) {
+				repo.EXPECT().SetShardCount(gomock.Any(), wallet, 4).Return(db.ErrInvalidShardCount)
+			},
+		},
+		{
+			name: "Too many shards", requestBody: fmt.Sprintf(`{"shardCount":%d}`, db.MaxShardCount+1), statusCode: http.StatusBadRequest,
+			mock: func(repo *mocks.MockHotWalletRepository) {},
+		},
+		{
+			name: "Zero shards", requestBody: `{"shardCount":0}`, statusCode: http.StatusBadRequest,
+			mock: func(repo *mocks.MockHotWalletRepository) {},
+		},
+		{
+			name: "Tenant wallet", query: "?tenant=acme", requestBody: `{"shardCount":8}`, statusCode: http.StatusOK,
+			mock: func(repo *mocks.MockHotWalletRepository) {
+				repo.EXPECT().SetShardCount(gomock.Any(), wallet, 8).DoAndReturn(func(ctx context.Context, _ string, _ int) error {
+					assert.Equal(t, "acme", db.TenantFromContext(ctx))
+					return nil
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewMockHotWalletRepository(ctrl)
+			tt.mock(repo)
+
+			router := gin.New()
+			router.PUT("/api/v1/wallets/:walletUUID/shards", NewHotWalletHandler(repo).PutWalletShards)
+
+			req, _ := http.NewRequest(http.MethodPut, "/api/v1/wallets/"+wallet+"/shards"+tt.query, bytes.NewBufferString(tt.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
+
+func Test_Tenants(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const wallet = "d7af0768-704e-4f1c-9793-a44c2d1f9b75"
+	registry, err := tenant.ParseTenants([]byte(`
+tenants:
+  - id: acme
+    api_keys: [` + hashAPIKey("acme-key") + `]
+    currency: USD
+    limits: {withdraw: 1000}
+`))
+	assert.NoError(t, err)
+
+	var tests = []struct {
+		name       string
+		method     string
+		apiKey     string
+		body       string
+		statusCode int
+		mock       func(repo *mocks.MockRepository)
+		response   string
+	}{
+		{
+			name: "Missing API key", method: http.MethodPost, statusCode: http.StatusUnauthorized,
+			body: `{"walletId":"` + wallet + `","operationType":"DEPOSIT","amount":100}`,
+			mock: func(repo *mocks.MockRepository) {},
+		},
+		{
+			name: "Unknown API key", method: http.MethodPost, apiKey: "other-key", statusCode: http.StatusUnauthorized,
+			body: `{"walletId":"` + wallet + `","operationType":"DEPOSIT","amount":100}`,
+			mock: func(repo *mocks.MockRepository) {},
+		},
+		{
+			name: "Deposit scoped to tenant", method: http.MethodPost, apiKey: "acme-key", statusCode: http.StatusOK,
+			body: `{"walletId":"` + wallet + `","operationType":"DEPOSIT","amount":100,"currency":"USD"}`,
+			mock: func(repo *mocks.MockRepository) {
+				repo.EXPECT().DepositMoney(gomock.Any(), wallet, int64(100)).DoAndReturn(
+					func(ctx context.Context, walletUUID string, amount int64) error {
+						assert.Equal(t, "acme", db.TenantFromContext(ctx))
+						return nil
+					})
+			},
+		},
+		{
+			// Кошелек другого арендатора неотличим от несуществующего
+			name: "Wallet of another tenant", method: http.MethodPost, apiKey: "acme-key", statusCode: http.StatusNotFound,
+			body: `{"walletId":"` + wallet + `","operationType":"WITHDRAW","amount":100}`,
+			mock: func(repo *mocks.MockRepository) {
+				repo.EXPECT().WithdrawMoney(gomock.Any(), wallet, int64(100)).Return(db.ErrWalletNotFound)
+			},
+			response: `{"error":"Wallet not found"}`,
+		},
+		{
+			name: "Amount above tenant limit", method: http.MethodPost, apiKey: "acme-key", statusCode: http.StatusBadRequest,
+			body: `{"walletId":"` + wallet + `","operationType":"WITHDRAW","amount":1001}`,
+			mock: func(repo *mocks.MockRepository) {},
+		},
+		{
+			name: "Unsupported currency", method: http.MethodPost, apiKey: "acme-key", statusCode: http.StatusBadRequest,
+			body: `{"walletId":"` + wallet + `","operationType":"DEPOSIT","amount":100,"currency":"EUR"}`,
+			mock: func(repo *mocks.MockRepository) {},
+		},
+		{
+			name: "Balance with tenant currency", method: http.MethodGet, apiKey: "acme-key", statusCode: http.StatusOK,
+			mock: func(repo *mocks.MockRepository) {
+				repo.EXPECT().GetBalance(gomock.Any(), wallet).Return(int64(100), nil)
+			},
+			response: `{"balance":100,"currency":"USD","walletId":"` + wallet + `"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewMockRepository(ctrl)
+			tt.mock(repo)
+
+			handler := NewWalletHandler(repo)
+			router := gin.New()
+			api := router.Group("/api/v1", TenantAuth(registry))
+			api.POST("/wallet", handler.PostWalletOperation)
+			api.GET("/wallets/:walletUUID", handler.GetBalance)
+
+			path := "/api/v1/wallet"
+			if tt.method == http.MethodGet {
+				path = "/api/v1/wallets/" + wallet
+			}
+			req, _ := http.NewRequest(tt.method, path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			if tt.response != "" {
+				assert.JSONEq(t, tt.response, w.Body.String())
+			}
+		})
+	}
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func Test_GetStatement(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const wallet = "d7af0768-704e-4f1c-9793-a44c2d1f9b75"
+	entry := db.LedgerTransaction{ID: 1, WalletUUID: wallet, OperationType: "DEPOSIT", Amount: 50,
+		CreatedAt: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)}
+
+	var tests = []struct {
+		name       string
+		stream     func(ctx context.Context, walletUUID string, from, to time.Time, opening func(int64) error, entry func(db.LedgerTransaction) error) error
+		statusCode int
+		response   string
+		aborted    bool
+	}{
+		{
+			name: "Success",
+			stream: func(ctx context.Context, walletUUID string, from, to time.Time, opening func(int64) error, next func(db.LedgerTransaction) error) error {
+				if err := opening(100); err != nil {
+					return err
+				}
+				return next(entry)
+			},
+			statusCode: http.StatusOK,
+			response:   `"closingBalance":150}`,
+		},
+		{
+			name: "Wallet not found",
+			stream: func(ctx context.Context, walletUUID string, from, to time.Time, opening func(int64) error, next func(db.LedgerTransaction) error) error {
+				return db.ErrWalletNotFound
+			},
+			statusCode: http.StatusNotFound,
+			response:   `{"error":"Wallet not found"}`,
+		},
+		{
+			// Статус 200 уже отправлен: клиент должен получить ошибку чтения, а не обрезанную выписку
+			name: "Failure mid-stream",
+			stream: func(ctx context.Context, walletUUID string, from, to time.Time, opening func(int64) error, next func(db.LedgerTransaction) error) error {
+				if err := opening(100); err != nil {
+					return err
+				}
+				if err := next(entry); err != nil {
+					return err
+				}
+				return errors.New("connection reset")
+			},
+			aborted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mocks.NewMockHistoryRepository(ctrl)
+			repo.EXPECT().StreamStatement(gomock.Any(), wallet, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(tt.stream)
+
+			router := gin.New()
+			router.Use(Recovery())
+			router.GET("/api/v1/wallets/:walletUUID/statement", NewStatementHandler(repo).GetStatement)
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + "/api/v1/wallets/" + wallet + "/statement?from=2026-01-01&to=2026-02-01")
+			if err == nil {
+				defer resp.Body.Close()
+			}
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(resp.Body)
+			}
+
+			if tt.aborted {
+				assert.Error(t, err, "client must not get a complete response")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.statusCode, resp.StatusCode)
+			assert.Contains(t, string(body), tt.response)
+		})
+	}
+}
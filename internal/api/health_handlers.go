@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"wallet-service/internal/health"
+	"wallet-service/internal/logger"
+)
+
+type HealthHandlers struct {
+	readiness *health.Readiness
+}
+
+func NewHealthHandler(readiness *health.Readiness) *HealthHandlers {
+	return &HealthHandlers{readiness: readiness}
+}
+
+// Liveness - процесс жив и обрабатывает запросы (/healthz). Зависимости не проверяются,
+// чтобы недоступность базы не приводила к перезапуску всех подов
+func (h *HealthHandlers) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness - сервис готов принимать запросы (/readyz): 200 или 503 с результатом каждой проверки
+func (h *HealthHandlers) Readiness(c *gin.Context) {
+	result := h.readiness.Check(c.Request.Context())
+	if !result.Ready {
+		logger.Log.WithContext(c.Request.Context()).Warnf("Readiness check failed: %v", result.Checks)
+		c.JSON(http.StatusServiceUnavailable, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
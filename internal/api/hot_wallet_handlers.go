@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+)
+
+type HotWalletHandlers struct {
+	Repo db.HotWalletRepository
+}
+
+func NewHotWalletHandler(repo db.HotWalletRepository) *HotWalletHandlers {
+	return &HotWalletHandlers{Repo: repo}
+}
+
+// PutWalletShards меняет число частей баланса кошелька: 1 - обычный кошелек, больше 1 - горячий.
+// Кошелек арендатора задается параметром ?tenant=, без него - кошелек арендатора по умолчанию
+func (h *HotWalletHandlers) PutWalletShards(c *gin.Context) {
+	ctx := c.Request.Context()
+	if tenantID := c.Query("tenant"); tenantID != "" {
+		ctx = db.WithTenant(ctx, tenantID)
+	}
+	log := logger.Log.WithContext(ctx)
+	walletUUID := c.Param("walletUUID")
+
+	var req struct {
+		ShardCount int `json:"shardCount" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ShardCount > db.MaxShardCount {
+		log.Warnf("Invalid shard count request for wallet %s: %v", walletUUID, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": db.ErrInvalidShardCount.Error()})
+		return
+	}
+
+	err := h.Repo.SetShardCount(ctx, walletUUID, req.ShardCount)
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return
+		}
+		if errors.Is(err, db.ErrWalletNotFound) {
+			log.Warnf("Wallet %s not found", walletUUID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		} else if errors.Is(err, db.ErrInvalidShardCount) {
+			log.Warnf("Invalid shard count %d for wallet %s", req.ShardCount, walletUUID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": db.ErrInvalidShardCount.Error()})
+		} else {
+			log.Errorf("Failed to set shard count for wallet %s: %v", walletUUID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set shard count"})
+		}
+		return
+	}
+
+	log.Infof("Wallet %s now has %d shards", walletUUID, req.ShardCount)
+	c.JSON(http.StatusOK, gin.H{"walletId": walletUUID, "shardCount": req.ShardCount})
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+)
+
+// enqueueOperation ставит операцию в очередь и отвечает 202 с ID операции и ссылкой на ее статус
+func (h *WalletHandlers) enqueueOperation(c *gin.Context, log *logrus.Entry, walletUUID, operationType string, amount int64) {
+	op, err := h.Operations.EnqueueOperation(c.Request.Context(), walletUUID, operationType, amount)
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return
+		}
+		log.Errorf("Failed to enqueue %s for wallet %s: %v", operationType, walletUUID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue operation"})
+		return
+	}
+
+	log.Infof("Operation %s for wallet %s queued as %s", operationType, walletUUID, op.ID)
+	c.Header("Location", "/api/v1/operations/"+op.ID)
+	c.JSON(http.StatusAccepted, gin.H{"message": "Operation accepted", "operationId": op.ID, "status": op.Status})
+}
+
+type OperationHandlers struct {
+	Queue db.OperationQueue
+}
+
+func NewOperationHandler(queue db.OperationQueue) *OperationHandlers {
+	return &OperationHandlers{Queue: queue}
+}
+
+// GetOperation - статус асинхронной операции: PENDING, COMPLETED или FAILED с причиной в error
+func (h *OperationHandlers) GetOperation(c *gin.Context) {
+	log := logger.Log.WithContext(c.Request.Context())
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid operation id"})
+		return
+	}
+
+	op, err := h.Queue.GetOperation(c.Request.Context(), id)
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return
+		}
+		if errors.Is(err, db.ErrOperationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Operation not found"})
+		} else {
+			log.Errorf("Failed to fetch operation %s: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch operation"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, op)
+}
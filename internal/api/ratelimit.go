@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wallet-service/internal/logger"
+	"wallet-service/internal/ratelimit"
+)
+
+// maxPeekBody - сколько байт тела запроса читается, чтобы определить операцию и кошелек
+const maxPeekBody = 64 << 10
+
+// RateLimiter - ограничение частоты запросов к маршрутам API
+type RateLimiter struct {
+	Limiter *ratelimit.Limiter
+	// ClientHeader - заголовок с идентификатором клиента от API-шлюза, пустой - клиент определяется по IP
+	ClientHeader string
+}
+
+// WalletOperation - ограничение для POST /wallet: операция (deposit или withdraw) и кошелек берутся из тела запроса
+func (rl *RateLimiter) WalletOperation() gin.HandlerFunc {
+	return rl.middleware(func(c *gin.Context) (string, string) {
+		var body struct {
+			WalletUUID    string `json:"walletId"`
+			OperationType string `json:"operationType"`
+		}
+		peekJSON(c, &body)
+		switch body.OperationType {
+		case "DEPOSIT":
+			return ratelimit.OperationDeposit, body.WalletUUID
+		case "WITHDRAW":
+			return ratelimit.OperationWithdraw, body.WalletUUID
+		}
+		// Некорректный запрос отклонит обработчик, здесь к нему применяются только общие правила
+		return "", ""
+	})
+}
+
+// Transfer - ограничение для POST /transfers по кошельку списания
+func (rl *RateLimiter) Transfer() gin.HandlerFunc {
+	return rl.middleware(func(c *gin.Context) (string, string) {
+		var body struct {
+			FromWalletUUID string `json:"fromWalletId"`
+		}
+		peekJSON(c, &body)
+		return ratelimit.OperationTransfer, body.FromWalletUUID
+	})
+}
+
+// Wallet - ограничение операции над кошельком из параметра маршрута :walletUUID
+func (rl *RateLimiter) Wallet(operation string) gin.HandlerFunc {
+	return rl.middleware(func(c *gin.Context) (string, string) {
+		return operation, c.Param("walletUUID")
+	})
+}
+
+func (rl *RateLimiter) middleware(resolve func(c *gin.Context) (operation, wallet string)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		operation, wallet := resolve(c)
+		// Одинаковые идентификаторы клиентов разных арендаторов - разные клиенты
+		client := clientID(c, rl.ClientHeader)
+		if t := currentTenant(c); t != nil {
+			client = t.ID + "/" + client
+		}
+		decision := rl.Limiter.Allow(c.Request.Context(), ratelimit.Request{
+			Operation: operation,
+			Client:    client,
+			Wallet:    wallet,
+		})
+
+		if decision.Limited {
+			c.Header("RateLimit-Limit", strconv.Itoa(decision.Limit))
+			c.Header("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			c.Header("RateLimit-Reset", seconds(decision.Reset))
+		}
+		if !decision.Allowed {
+			logger.Log.WithContext(c.Request.Context()).Warnf("Rate limit exceeded for %s by %s scope", operation, decision.Scope)
+			c.Header("Retry-After", seconds(decision.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientID - идентификатор клиента API: значение заголовка header от API-шлюза или IP
+func clientID(c *gin.Context, header string) string {
+	if header != "" {
+		if client := strings.TrimSpace(c.GetHeader(header)); client != "" {
+			return client
+		}
+	}
+	return c.ClientIP()
+}
+
+// peekJSON разбирает начало тела запроса, не забирая его у обработчика
+func peekJSON(c *gin.Context, v any) {
+	if c.Request.Body == nil {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPeekBody))
+	if err != nil {
+		return
+	}
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+	json.Unmarshal(body, v)
+}
+
+// seconds - длительность в целых секундах с округлением вверх, как в Retry-After
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"wallet-service/internal/logger"
+)
+
+// RequestIDHeader - заголовок с идентификатором запроса во входящем запросе и в ответе
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength - более длинные идентификаторы от клиента заменяются сгенерированными
+const maxRequestIDLength = 128
+
+// RequestID берет идентификатор запроса из X-Request-ID или генерирует новый, кладет его в контекст
+// запроса (попадает во все записи логов этого запроса) и возвращает в заголовке ответа
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// validRequestID - непустой идентификатор разумной длины из печатных ASCII-символов
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLog пишет по записи на каждый запрос вместо стандартного логгера gin: метод, путь, шаблон маршрута,
+// статус и длительность. Ответы 5xx пишутся с уровнем error, 4xx - warning
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		entry := logger.Log.WithContext(c.Request.Context()).WithFields(logrus.Fields{
+			"method":      c.Request.Method,
+			"path":        c.Request.URL.Path,
+			"route":       c.FullPath(),
+			"status":      status,
+			"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":       c.Writer.Size(),
+			"client_ip":   c.ClientIP(),
+		})
+		if t := currentTenant(c); t != nil {
+			entry = entry.WithField("tenant", t.ID)
+		}
+		if len(c.Errors) > 0 {
+			entry = entry.WithField("error", c.Errors.String())
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			entry.Error("HTTP request")
+		case status >= http.StatusBadRequest:
+			entry.Warn("HTTP request")
+		default:
+			entry.Info("HTTP request")
+		}
+	}
+}
+
+// Recovery отвечает 500 на панику в обработчике и пишет ее в лог со стеком. Паника http.ErrAbortHandler
+// передается дальше в net/http, который обрывает соединение: так обработчик прерывает ответ, который уже
+// начал передаваться (gin.Recovery ее перехватывает, и клиент получает обрезанный ответ со статусом 200)
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		if err == http.ErrAbortHandler {
+			panic(err)
+		}
+		logger.Log.WithContext(c.Request.Context()).Errorf("Panic recovered in %s %s: %v\n%s",
+			c.Request.Method, c.Request.URL.Path, err, debug.Stack())
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
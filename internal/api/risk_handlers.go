@@ -0,0 +1,167 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/risk"
+)
+
+// maxPendingList - сколько операций на проверке отдается за один запрос
+const maxPendingList = 1000
+
+// checkRisk проверяет операцию правилами риска. Возвращает false, если ответ уже отправлен:
+// операция отклонена (403), отложена до ручной проверки (202) или решение не принято
+func (h *WalletHandlers) checkRisk(c *gin.Context, log *logrus.Entry, operationType, walletUUID string, amount int64) bool {
+	decision, err := h.Risk.Evaluate(c.Request.Context(), risk.Operation{
+		Type:       operationType,
+		WalletUUID: walletUUID,
+		Client:     clientID(c, h.ClientHeader),
+		Amount:     amount,
+	})
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return false
+		}
+		// Без решения деньги не двигаются
+		log.Errorf("Risk check failed for wallet %s: %v", walletUUID, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Risk check is unavailable, retry later"})
+		return false
+	}
+
+	switch decision.Outcome {
+	case db.RiskDeny:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Operation rejected by risk checks"})
+		return false
+	case db.RiskReview:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Operation is pending review", "operationId": decision.ID})
+		return false
+	}
+	return true
+}
+
+// RiskHandlers - ручная проверка операций, отложенных правилами риска
+type RiskHandlers struct {
+	Repo db.RiskRepository
+	// Wallets выполняет одобренные операции, минуя проверку рисков
+	Wallets db.Repository
+}
+
+func NewRiskHandler(repo db.RiskRepository, wallets db.Repository) *RiskHandlers {
+	return &RiskHandlers{Repo: repo, Wallets: wallets}
+}
+
+// ListPending - операции, ожидающие проверки, начиная с самых старых (?limit=, по умолчанию 100)
+func (h *RiskHandlers) ListPending(c *gin.Context) {
+	log := logger.Log.WithContext(c.Request.Context())
+
+	limit := 100
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPendingList {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+			return
+		}
+		limit = n
+	}
+
+	ops, err := h.Repo.ListPendingOperations(c.Request.Context(), limit)
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return
+		}
+		log.Errorf("Failed to list pending operations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list pending operations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"operations": ops})
+}
+
+// Approve одобряет операцию и выполняет ее. Если выполнить не удалось (например, не хватает средств),
+// операция остается в состоянии FAILED с текстом ошибки
+func (h *RiskHandlers) Approve(c *gin.Context) {
+	op, ok := h.decide(c, db.PendingStatusApproved)
+	if !ok {
+		return
+	}
+	// Операция выполняется от имени арендатора кошелька
+	ctx := db.WithTenant(c.Request.Context(), op.TenantID)
+	log := logger.Log.WithContext(ctx)
+
+	var err error
+	switch op.OperationType {
+	case "DEPOSIT":
+		err = h.Wallets.DepositMoney(ctx, op.WalletUUID, op.Amount)
+	case "WITHDRAW":
+		err = h.Wallets.WithdrawMoney(ctx, op.WalletUUID, op.Amount)
+	default:
+		err = errors.New("unsupported operation type " + op.OperationType)
+	}
+	if err != nil {
+		log.Warnf("Approved operation %s failed: %v", op.ID, err)
+		if failErr := h.Repo.FailPendingOperation(ctx, op.ID, err.Error()); failErr != nil {
+			log.Errorf("Failed to mark pending operation %s as failed: %v", op.ID, failErr)
+		}
+		op.Status, op.Error = db.PendingStatusFailed, err.Error()
+		c.JSON(http.StatusConflict, gin.H{"error": "Approved operation failed", "operation": op})
+		return
+	}
+
+	log.Infof("Pending operation %s approved by %s and executed", op.ID, op.DecidedBy)
+	c.JSON(http.StatusOK, gin.H{"operation": op})
+}
+
+// Reject отклоняет операцию, деньги не двигаются
+func (h *RiskHandlers) Reject(c *gin.Context) {
+	op, ok := h.decide(c, db.PendingStatusRejected)
+	if !ok {
+		return
+	}
+	logger.Log.WithContext(c.Request.Context()).Infof("Pending operation %s rejected by %s", op.ID, op.DecidedBy)
+	c.JSON(http.StatusOK, gin.H{"operation": op})
+}
+
+// decide записывает решение оператора. Тело запроса - {"operator": "имя", "reason": "комментарий"}
+func (h *RiskHandlers) decide(c *gin.Context, status string) (*db.PendingOperation, bool) {
+	log := logger.Log.WithContext(c.Request.Context())
+
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid operation id"})
+		return nil, false
+	}
+	var req struct {
+		Operator string `json:"operator" binding:"required,max=128"`
+		Reason   string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warnf("Invalid request payload: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return nil, false
+	}
+
+	op, err := h.Repo.DecidePendingOperation(c.Request.Context(), id, status, req.Operator, req.Reason)
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return nil, false
+		}
+		switch {
+		case errors.Is(err, db.ErrPendingOperationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pending operation not found"})
+		case errors.Is(err, db.ErrPendingOperationDecided):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "operation": op})
+		default:
+			log.Errorf("Failed to decide pending operation %s: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decide pending operation"})
+		}
+		return nil, false
+	}
+	return op, true
+}
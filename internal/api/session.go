@@ -0,0 +1,17 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"wallet-service/internal/db"
+)
+
+// ReadYourWrites открывает для каждого запроса область read-your-writes (db.WithSession).
+// Запись видна чтениям того же запроса; следующий запрос может прочитать реплику с отставанием,
+// если не требует строгой согласованности (?consistency=strong)
+func ReadYourWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(db.WithSession(c.Request.Context()))
+		c.Next()
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/settlement"
+)
+
+const defaultSettlementRunsLimit = 50
+
+type SettlementHandlers struct {
+	Service *settlement.Service
+}
+
+func NewSettlementHandler(service *settlement.Service) *SettlementHandlers {
+	return &SettlementHandlers{Service: service}
+}
+
+// PostSettlementRun принимает multipart-форму: file - файл расчетов, format - csv или fixed,
+// необязательные rule (exact, amount_date) и window (например 48h) переопределяют правило из конфигурации
+func (h *SettlementHandlers) PostSettlementRun(c *gin.Context) {
+	log := logger.Log.WithContext(c.Request.Context())
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		log.Warnf("Missing settlement file: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing settlement file"})
+		return
+	}
+
+	format := c.DefaultPostForm("format", settlement.FormatCSV)
+
+	rule := h.Service.DefaultRule
+	if name := c.PostForm("rule"); name != "" {
+		rule.Name = name
+	}
+	if window := c.PostForm("window"); window != "" {
+		if rule.DateWindow, err = time.ParseDuration(window); err != nil {
+			log.Warnf("Invalid settlement date window %q: %v", window, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date window"})
+			return
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Errorf("Failed to open uploaded settlement file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read settlement file"})
+		return
+	}
+	defer file.Close()
+
+	run, err := h.Service.Import(c.Request.Context(), fileHeader.Filename, format, file, rule)
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return
+		}
+		if errors.Is(err, settlement.ErrInvalidInput) {
+			log.Warnf("Settlement import rejected: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			log.Errorf("Settlement import failed: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run settlement reconciliation"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, run)
+}
+
+func (h *SettlementHandlers) GetSettlementRun(c *gin.Context) {
+	log := logger.Log.WithContext(c.Request.Context())
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		log.Warnf("Invalid settlement run id %q", c.Param("id"))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settlement run id"})
+		return
+	}
+
+	run, err := h.Service.GetRun(c.Request.Context(), id)
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return
+		}
+		if errors.Is(err, db.ErrSettlementRunNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Settlement run not found"})
+		} else {
+			log.Errorf("Failed to fetch settlement run %d: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settlement run"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+func (h *SettlementHandlers) ListSettlementRuns(c *gin.Context) {
+	log := logger.Log.WithContext(c.Request.Context())
+	limit := defaultSettlementRunsLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	runs, err := h.Service.ListRuns(c.Request.Context(), limit)
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return
+		}
+		log.Errorf("Failed to fetch settlement runs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settlement runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/statement"
+)
+
+// период выписки по умолчанию, если from не указан
+const defaultStatementPeriod = 30 * 24 * time.Hour
+
+type StatementHandlers struct {
+	Repo db.HistoryRepository
+}
+
+func NewStatementHandler(repo db.HistoryRepository) *StatementHandlers {
+	return &StatementHandlers{Repo: repo}
+}
+
+// GetStatement отдает выписку за период [from, to) в формате json, csv или pdf.
+// from и to принимаются в RFC3339 или как дата 2006-01-02
+func (h *StatementHandlers) GetStatement(c *gin.Context) {
+	log := logger.Log.WithContext(c.Request.Context())
+	walletUUID := c.Param("walletUUID")
+
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		parsed, err := parseTimeParam(value)
+		if err != nil {
+			log.Warnf("Invalid statement 'to' parameter %q: %v", value, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' parameter"})
+			return
+		}
+		to = parsed
+	}
+
+	from := to.Add(-defaultStatementPeriod)
+	if value := c.Query("from"); value != "" {
+		parsed, err := parseTimeParam(value)
+		if err != nil {
+			log.Warnf("Invalid statement 'from' parameter %q: %v", value, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' parameter"})
+			return
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'from' must be before 'to'"})
+		return
+	}
+
+	writer, err := statement.NewWriter(c.DefaultQuery("format", statement.FormatJSON), c.Writer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Infof("Generating statement for wallet %s from %s to %s", walletUUID, from, to)
+
+	c.Header("Content-Type", writer.ContentType())
+	if err := statement.Generate(c.Request.Context(), h.Repo, walletUUID, from, to, writer); err != nil {
+		//если выписка уже начала передаваться, статус ответа изменить нельзя - только оборвать соединение,
+		//чтобы клиент получил ошибку чтения, а не обрезанную выписку со статусом 200. Панику пропускает
+		//до net/http middleware Recovery
+		if c.Writer.Written() {
+			log.Errorf("Statement for wallet %s aborted mid-stream: %v", walletUUID, err)
+			panic(http.ErrAbortHandler)
+		}
+		c.Header("Content-Type", "")
+		if respondTransientError(c, log, err) {
+			return
+		}
+		if errors.Is(err, db.ErrWalletNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		} else {
+			log.Errorf("Failed to generate statement for wallet %s: %v", walletUUID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate statement"})
+		}
+		return
+	}
+}
+
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
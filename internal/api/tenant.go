@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/tenant"
+)
+
+// APIKeyHeader - заголовок с API-ключом арендатора
+const APIKeyHeader = "X-API-Key"
+
+const tenantContextKey = "tenant"
+
+// TenantAuth определяет арендатора по API-ключу и ограничивает запрос его кошельками (db.WithTenant).
+// Запросы без ключа или с неизвестным ключом отклоняются
+func TenantAuth(registry *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t := registry.Authenticate(c.GetHeader(APIKeyHeader))
+		if t == nil {
+			logger.Log.WithContext(c.Request.Context()).Warnf("Unauthorized API request to %s", c.FullPath())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.Set(tenantContextKey, t)
+		c.Request = c.Request.WithContext(db.WithTenant(c.Request.Context(), t.ID))
+		c.Next()
+	}
+}
+
+// currentTenant - арендатор запроса или nil, если арендаторы не настроены
+func currentTenant(c *gin.Context) *tenant.Tenant {
+	value, _ := c.Get(tenantContextKey)
+	t, _ := value.(*tenant.Tenant)
+	return t
+}
+
+// checkTenantOperation проверяет валюту и лимит арендатора для операции DEPOSIT, WITHDRAW или TRANSFER
+// и отвечает 400, если операция не разрешена
+func checkTenantOperation(c *gin.Context, operation, currency string, amount int64) bool {
+	t := currentTenant(c)
+	if t == nil {
+		return true
+	}
+	log := logger.Log.WithContext(c.Request.Context())
+
+	if currency != "" && t.Currency != "" && currency != t.Currency {
+		log.Warnf("Currency %s is not supported by tenant %s", currency, t.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency"})
+		return false
+	}
+	if limit := t.Limit(operation); limit > 0 && amount > limit {
+		log.Warnf("%s of %d exceeds limit %d of tenant %s", operation, logger.Amount(amount), logger.Amount(limit), t.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount exceeds tenant limit"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+)
+
+type TransferHandlers struct {
+	Repo db.TransferRepository
+}
+
+func NewTransferHandler(repo db.TransferRepository) *TransferHandlers {
+	return &TransferHandlers{Repo: repo}
+}
+
+// PostTransfer переводит сумму между кошельками. 200 - перевод завершен, 202 - перевод между шардами
+// списан, но еще не зачислен и будет завершен в фоне, 400 - перевод отклонен
+func (h *TransferHandlers) PostTransfer(c *gin.Context) {
+	log := logger.Log.WithContext(c.Request.Context())
+
+	var req struct {
+		FromWalletUUID string `json:"fromWalletId" binding:"required,uuid"`
+		ToWalletUUID   string `json:"toWalletId" binding:"required,uuid"`
+		Amount         int64  `json:"amount" binding:"required,gt=0"`
+		// Currency - валюта перевода, должна совпадать с валютой арендатора
+		Currency string `json:"currency" binding:"omitempty,len=3"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warnf("Invalid request payload: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	if req.FromWalletUUID == req.ToWalletUUID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": db.ErrSameWallet.Error()})
+		return
+	}
+
+	if !checkTenantOperation(c, "TRANSFER", req.Currency, req.Amount) {
+		return
+	}
+
+	log.Infof("Processing transfer of %d from wallet %s to wallet %s", logger.Amount(req.Amount), req.FromWalletUUID, req.ToWalletUUID)
+
+	transfer, err := h.Repo.Transfer(c.Request.Context(), req.FromWalletUUID, req.ToWalletUUID, req.Amount)
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return
+		}
+		if errors.Is(err, db.ErrInsufficientFunds) {
+			log.Warnf("Transfer from wallet %s failed: %v", req.FromWalletUUID, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if errors.Is(err, db.ErrWalletNotFound) {
+			log.Warnf("Transfer from wallet %s failed: %v", req.FromWalletUUID, err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		} else if errors.Is(err, db.ErrWalletFrozen) {
+			log.Warnf("Transfer from wallet %s rejected: %v", req.FromWalletUUID, err)
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		} else {
+			log.Errorf("Failed to transfer from wallet %s to wallet %s: %v", req.FromWalletUUID, req.ToWalletUUID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer money"})
+		}
+		return
+	}
+
+	switch transfer.State {
+	case db.TransferCompleted:
+		c.JSON(http.StatusOK, transfer)
+	case db.TransferCompensated:
+		c.JSON(http.StatusBadRequest, transfer)
+	default:
+		c.JSON(http.StatusAccepted, transfer)
+	}
+}
+
+func (h *TransferHandlers) GetTransfer(c *gin.Context) {
+	log := logger.Log.WithContext(c.Request.Context())
+	id := c.Param("id")
+
+	transfer, err := h.Repo.GetTransfer(c.Request.Context(), id)
+	if err != nil {
+		if respondTransientError(c, log, err) {
+			return
+		}
+		if errors.Is(err, db.ErrTransferNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		} else {
+			log.Errorf("Failed to fetch transfer %s: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transfer"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Entry - закэшированный баланс кошелька и время, когда он был прочитан из базы
+type Entry struct {
+	Balance  int64
+	CachedAt time.Time
+}
+
+// BalanceCache - хранилище закэшированных балансов: в памяти процесса (LRU) или общее (Redis).
+// Записи различаются по арендатору и UUID кошелька: у разных арендаторов могут быть кошельки с одним UUID
+type BalanceCache interface {
+	Get(ctx context.Context, tenant, walletUUID string) (Entry, bool, error)
+	Set(ctx context.Context, tenant, walletUUID string, entry Entry) error
+	// Delete удаляет записи кошелька walletUUID всех арендаторов: уведомление об изменении баланса
+	// содержит только UUID
+	Delete(ctx context.Context, walletUUID string) error
+	// Purge удаляет все записи, например после потери уведомлений об изменениях
+	Purge(ctx context.Context) error
+}
+
+// key - ключ записи: арендатор и UUID кошелька
+func key(tenant, walletUUID string) string {
+	return tenant + ":" + walletUUID
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"wallet-service/internal/db"
+	"wallet-service/internal/db/mocks"
+)
+
+const walletUUID = "123e4567-e89b-12d3-a456-426614174000"
+
+func Test_LRUEviction(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(2)
+
+	assert.NoError(t, lru.Set(ctx, "", "a", Entry{Balance: 1}))
+	assert.NoError(t, lru.Set(ctx, "", "b", Entry{Balance: 2}))
+	_, _, _ = lru.Get(ctx, "", "a")
+	assert.NoError(t, lru.Set(ctx, "", "c", Entry{Balance: 3}))
+
+	_, ok, _ := lru.Get(ctx, "", "b")
+	assert.False(t, ok, "least recently used entry must be evicted")
+	entry, ok, _ := lru.Get(ctx, "", "a")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), entry.Balance)
+	assert.Equal(t, 2, lru.Len())
+}
+
+func Test_LRUTenants(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(3)
+
+	assert.NoError(t, lru.Set(ctx, "acme", walletUUID, Entry{Balance: 1}))
+	assert.NoError(t, lru.Set(ctx, "globex", walletUUID, Entry{Balance: 2}))
+	assert.NoError(t, lru.Set(ctx, "acme", "other", Entry{Balance: 3}))
+
+	// Кошельки разных арендаторов с одним UUID - разные записи
+	entry, ok, _ := lru.Get(ctx, "acme", walletUUID)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), entry.Balance)
+	entry, ok, _ = lru.Get(ctx, "globex", walletUUID)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), entry.Balance)
+	_, ok, _ = lru.Get(ctx, "initech", walletUUID)
+	assert.False(t, ok)
+
+	// Удаление по UUID из уведомления сбрасывает записи всех арендаторов
+	assert.NoError(t, lru.Delete(ctx, walletUUID))
+	_, ok, _ = lru.Get(ctx, "acme", walletUUID)
+	assert.False(t, ok)
+	_, ok, _ = lru.Get(ctx, "globex", walletUUID)
+	assert.False(t, ok)
+	assert.Equal(t, 1, lru.Len())
+
+	// Вытесненная запись не остается в индексе кошелька
+	assert.NoError(t, lru.Set(ctx, "acme", walletUUID, Entry{Balance: 4}))
+	assert.NoError(t, lru.Set(ctx, "globex", walletUUID, Entry{Balance: 5}))
+	assert.NoError(t, lru.Set(ctx, "initech", walletUUID, Entry{Balance: 6}))
+	assert.Equal(t, 3, lru.Len())
+	assert.NoError(t, lru.Delete(ctx, walletUUID))
+	assert.Equal(t, 0, lru.Len())
+	assert.Empty(t, lru.wallets)
+}
+
+func Test_CachedRepositoryGetBalance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	repo := mocks.NewMockRepository(ctrl)
+	cached := NewCachedRepository(repo, NewLRU(10), time.Minute)
+
+	// Первое чтение идет в базу, второе - из кэша
+	repo.EXPECT().GetBalance(gomock.Any(), walletUUID).Return(int64(100), nil).Times(1)
+	for range 2 {
+		balance, err := cached.GetBalance(ctx, walletUUID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(100), balance)
+	}
+
+	// Строго согласованное чтение идет в базу
+	repo.EXPECT().GetBalance(gomock.Any(), walletUUID).Return(int64(100), nil).Times(1)
+	_, err := cached.GetBalance(db.WithStrongConsistency(ctx), walletUUID)
+	assert.NoError(t, err)
+
+	// Депозит сбрасывает запись кошелька
+	repo.EXPECT().DepositMoney(gomock.Any(), walletUUID, int64(50)).Return(nil)
+	repo.EXPECT().GetBalance(gomock.Any(), walletUUID).Return(int64(150), nil).Times(1)
+	assert.NoError(t, cached.DepositMoney(ctx, walletUUID, 50))
+	balance, err := cached.GetBalance(ctx, walletUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(150), balance)
+}
+
+func Test_CachedRepositoryTenants(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	acme := db.WithTenant(context.Background(), "acme")
+	globex := db.WithTenant(context.Background(), "globex")
+	repo := mocks.NewMockRepository(ctrl)
+	cached := NewCachedRepository(repo, NewLRU(10), time.Minute)
+
+	repo.EXPECT().GetBalance(gomock.Any(), walletUUID).Return(int64(100), nil).Times(1)
+	for range 2 {
+		balance, err := cached.GetBalance(acme, walletUUID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(100), balance)
+	}
+
+	// Баланс, закэшированный для одного арендатора, другому не отдается: у него свой кошелек с тем же UUID
+	repo.EXPECT().GetBalance(gomock.Any(), walletUUID).Return(int64(7), nil).Times(1)
+	for range 2 {
+		balance, err := cached.GetBalance(globex, walletUUID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), balance)
+	}
+	balance, err := cached.GetBalance(acme, walletUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), balance)
+
+	// Уведомление содержит только UUID и сбрасывает записи обоих арендаторов
+	cached.Invalidate(context.Background(), walletUUID)
+	repo.EXPECT().GetBalance(gomock.Any(), walletUUID).Return(int64(110), nil)
+	repo.EXPECT().GetBalance(gomock.Any(), walletUUID).Return(int64(8), nil)
+	balance, err = cached.GetBalance(acme, walletUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(110), balance)
+	balance, err = cached.GetBalance(globex, walletUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), balance)
+}
+
+func Test_CachedRepositoryStaleness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	repo := mocks.NewMockRepository(ctrl)
+	lru := NewLRU(10)
+	cached := NewCachedRepository(repo, lru, time.Second)
+
+	assert.NoError(t, lru.Set(ctx, "", walletUUID, Entry{Balance: 10, CachedAt: time.Now().Add(-time.Minute)}))
+	repo.EXPECT().GetBalance(gomock.Any(), walletUUID).Return(int64(20), nil)
+
+	balance, err := cached.GetBalance(ctx, walletUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), balance)
+}
+
+func Test_CachedRepositoryDoesNotCacheValueReadDuringInvalidation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	repo := mocks.NewMockRepository(ctrl)
+	lru := NewLRU(10)
+	cached := NewCachedRepository(repo, lru, time.Minute)
+
+	repo.EXPECT().GetBalance(gomock.Any(), walletUUID).DoAndReturn(func(ctx context.Context, walletUUID string) (int64, error) {
+		// Изменение с другой реплики пришло, пока читали баланс
+		cached.Invalidate(ctx, walletUUID)
+		return 10, nil
+	})
+
+	_, err := cached.GetBalance(ctx, walletUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, lru.Len())
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// LRU - кэш балансов в памяти процесса с вытеснением давно не использованных записей
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	// items - записи по ключу арендатор:кошелек
+	items map[string]*list.Element
+	// wallets - записи кошелька всех арендаторов по UUID, для Delete
+	wallets map[string]map[string]*list.Element
+}
+
+type lruItem struct {
+	key        string
+	walletUUID string
+	entry      Entry
+}
+
+func NewLRU(capacity int) *LRU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRU{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element, capacity),
+		wallets:  make(map[string]map[string]*list.Element, capacity),
+	}
+}
+
+func (c *LRU) Get(_ context.Context, tenant, walletUUID string) (Entry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key(tenant, walletUUID)]
+	if !ok {
+		return Entry{}, false, nil
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true, nil
+}
+
+func (c *LRU) Set(_ context.Context, tenant, walletUUID string, entry Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := key(tenant, walletUUID)
+	if el, ok := c.items[k]; ok {
+		el.Value.(*lruItem).entry = entry
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	el := c.order.PushFront(&lruItem{key: k, walletUUID: walletUUID, entry: entry})
+	c.items[k] = el
+	if c.wallets[walletUUID] == nil {
+		c.wallets[walletUUID] = map[string]*list.Element{}
+	}
+	c.wallets[walletUUID][k] = el
+	if c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Delete(_ context.Context, walletUUID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, el := range c.wallets[walletUUID] {
+		c.remove(el)
+	}
+	return nil
+}
+
+// remove удаляет запись из списка и обоих индексов
+func (c *LRU) remove(el *list.Element) {
+	item := el.Value.(*lruItem)
+	c.order.Remove(el)
+	delete(c.items, item.key)
+	delete(c.wallets[item.walletUUID], item.key)
+	if len(c.wallets[item.walletUUID]) == 0 {
+		delete(c.wallets, item.walletUUID)
+	}
+}
+
+func (c *LRU) Purge(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element, c.capacity)
+	c.wallets = make(map[string]map[string]*list.Element, c.capacity)
+	return nil
+}
+
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisKeyPrefix - записи балансов: wallet:balance:<арендатор>:<кошелек>
+	redisKeyPrefix = "wallet:balance:"
+	// redisTenantsPrefix - множество арендаторов с записями кошелька: wallet:balance-tenants:<кошелек>.
+	// Префикс другой, чтобы ключ множества не совпал с записью арендатора
+	redisTenantsPrefix = "wallet:balance-tenants:"
+)
+
+// Redis - кэш балансов, общий для всех реплик сервиса. Записи живут не дольше ttl
+type Redis struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedis(client *redis.Client, ttl time.Duration) *Redis {
+	return &Redis{client: client, ttl: ttl}
+}
+
+// Значение хранится как "<баланс>:<время кэширования в наносекундах>"
+func (c *Redis) Get(ctx context.Context, tenant, walletUUID string) (Entry, bool, error) {
+	value, err := c.client.Get(ctx, redisKeyPrefix+key(tenant, walletUUID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return Entry{}, false, nil
+	} else if err != nil {
+		return Entry{}, false, fmt.Errorf("failed to get cached balance: %w", err)
+	}
+
+	balancePart, cachedAtPart, ok := strings.Cut(value, ":")
+	if !ok {
+		return Entry{}, false, nil
+	}
+	balance, err := strconv.ParseInt(balancePart, 10, 64)
+	if err != nil {
+		return Entry{}, false, nil
+	}
+	cachedAt, err := strconv.ParseInt(cachedAtPart, 10, 64)
+	if err != nil {
+		return Entry{}, false, nil
+	}
+
+	return Entry{Balance: balance, CachedAt: time.Unix(0, cachedAt)}, true, nil
+}
+
+// Set сохраняет запись и добавляет арендатора в множество арендаторов кошелька для Delete
+func (c *Redis) Set(ctx context.Context, tenant, walletUUID string, entry Entry) error {
+	value := strconv.FormatInt(entry.Balance, 10) + ":" + strconv.FormatInt(entry.CachedAt.UnixNano(), 10)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, redisKeyPrefix+key(tenant, walletUUID), value, c.ttl)
+		pipe.SAdd(ctx, redisTenantsPrefix+walletUUID, tenant)
+		pipe.Expire(ctx, redisTenantsPrefix+walletUUID, c.ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cache balance: %w", err)
+	}
+	return nil
+}
+
+func (c *Redis) Delete(ctx context.Context, walletUUID string) error {
+	tenants, err := c.client.SMembers(ctx, redisTenantsPrefix+walletUUID).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete cached balance: %w", err)
+	}
+
+	keys := []string{redisTenantsPrefix + walletUUID}
+	for _, tenant := range tenants {
+		keys = append(keys, redisKeyPrefix+key(tenant, walletUUID))
+	}
+	if err := c.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to delete cached balance: %w", err)
+	}
+	return nil
+}
+
+func (c *Redis) Purge(ctx context.Context) error {
+	for _, prefix := range []string{redisKeyPrefix, redisTenantsPrefix} {
+		iter := c.client.Scan(ctx, 0, prefix+"*", 1000).Iterator()
+		for iter.Next(ctx) {
+			if err := c.client.Del(ctx, iter.Val()).Err(); err != nil {
+				return fmt.Errorf("failed to purge cached balances: %w", err)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return fmt.Errorf("failed to purge cached balances: %w", err)
+		}
+	}
+	return nil
+}
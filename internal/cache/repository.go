@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/metrics"
+)
+
+// CachedRepository - кэш балансов поверх db.Repository. GetBalance отдает баланс из кэша,
+// если он прочитан не раньше maxStaleness назад. Депозит и снятие сбрасывают запись кошелька,
+// изменения с других реплик приходят через Invalidate (LISTEN/NOTIFY).
+// Балансы кэшируются отдельно для каждого арендатора (db.WithTenant).
+// Строго согласованное чтение (db.WithStrongConsistency) всегда идет в базу.
+type CachedRepository struct {
+	repo         db.Repository
+	cache        BalanceCache
+	maxStaleness time.Duration
+
+	// invalidations растет при каждом сбросе. Баланс, прочитанный из базы, не кладется в кэш,
+	// если за время чтения был сброс: прочитанное значение могло уже устареть
+	invalidations atomic.Uint64
+}
+
+func NewCachedRepository(repo db.Repository, cache BalanceCache, maxStaleness time.Duration) *CachedRepository {
+	return &CachedRepository{repo: repo, cache: cache, maxStaleness: maxStaleness}
+}
+
+func (c *CachedRepository) DepositMoney(ctx context.Context, walletUUID string, amount int64) error {
+	// Сбрасываем запись и при ошибке: транзакция могла быть зафиксирована
+	defer c.Invalidate(ctx, walletUUID)
+	return c.repo.DepositMoney(ctx, walletUUID, amount)
+}
+
+func (c *CachedRepository) WithdrawMoney(ctx context.Context, walletUUID string, amount int64) error {
+	defer c.Invalidate(ctx, walletUUID)
+	return c.repo.WithdrawMoney(ctx, walletUUID, amount)
+}
+
+func (c *CachedRepository) GetBalance(ctx context.Context, walletUUID string) (int64, error) {
+	log := logger.Log.WithContext(ctx)
+
+	if db.StrongConsistency(ctx) {
+		metrics.BalanceCacheRequests.WithLabelValues("bypass").Inc()
+		return c.load(ctx, walletUUID)
+	}
+
+	entry, ok, err := c.cache.Get(ctx, db.TenantFromContext(ctx), walletUUID)
+	if err != nil {
+		log.Warnf("Balance cache read failed for wallet %s: %v", walletUUID, err)
+	} else if ok && time.Since(entry.CachedAt) <= c.maxStaleness {
+		metrics.BalanceCacheRequests.WithLabelValues("hit").Inc()
+		return entry.Balance, nil
+	}
+
+	metrics.BalanceCacheRequests.WithLabelValues("miss").Inc()
+	return c.load(ctx, walletUUID)
+}
+
+// load читает баланс из базы и кладет его в кэш
+func (c *CachedRepository) load(ctx context.Context, walletUUID string) (int64, error) {
+	version := c.invalidations.Load()
+	cachedAt := time.Now()
+
+	balance, err := c.repo.GetBalance(ctx, walletUUID)
+	if err != nil {
+		return 0, err
+	}
+
+	if c.invalidations.Load() == version {
+		entry := Entry{Balance: balance, CachedAt: cachedAt}
+		if err := c.cache.Set(ctx, db.TenantFromContext(ctx), walletUUID, entry); err != nil {
+			logger.Log.WithContext(ctx).Warnf("Balance cache write failed for wallet %s: %v", walletUUID, err)
+		}
+	}
+	return balance, nil
+}
+
+// Invalidate сбрасывает закэшированный баланс кошелька у всех арендаторов
+func (c *CachedRepository) Invalidate(ctx context.Context, walletUUID string) {
+	c.invalidations.Add(1)
+	if err := c.cache.Delete(ctx, walletUUID); err != nil {
+		logger.Log.WithContext(ctx).Warnf("Balance cache invalidation failed for wallet %s: %v", walletUUID, err)
+	}
+}
+
+// Purge сбрасывает весь кэш
+func (c *CachedRepository) Purge(ctx context.Context) {
+	c.invalidations.Add(1)
+	if err := c.cache.Purge(ctx); err != nil {
+		logger.Log.WithContext(ctx).Warnf("Balance cache purge failed: %v", err)
+	}
+}
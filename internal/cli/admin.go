@@ -0,0 +1,389 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/reconcile"
+
+	"github.com/google/uuid"
+)
+
+// AdminStore - репозиторий, через который работает административная CLI. Изменения идут
+// теми же методами Repository, что и запросы API, а не отдельным SQL
+type AdminStore interface {
+	db.Repository
+	db.HistoryRepository
+	db.AdminRepository
+}
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// admin - одна команда оператора
+type admin struct {
+	store     AdminStore
+	ledger    []reconcile.Shard
+	batchSize int
+	operator  string
+	output    string
+	out       io.Writer
+}
+
+// Admin - подкоманды `wallet-service admin [-operator имя] [-output table|json] [-tenant арендатор]`:
+//
+//	admin create [walletUUID]                      - создать пустой кошелек (UUID генерируется, если не задан)
+//	admin deposit <walletUUID> <amount>            - пополнить кошелек
+//	admin withdraw <walletUUID> <amount>           - снять средства
+//	admin balance <walletUUID>                     - баланс и состояние кошелька
+//	admin history <walletUUID> [-from] [-to]       - транзакции за период (по умолчанию 30 дней)
+//	admin freeze <walletUUID> -reason <причина>    - заморозить кошелек
+//	admin unfreeze <walletUUID>                    - снять заморозку
+//	admin reconcile                                - сверка балансов с журналом
+//
+// Все изменения и сверка записываются в журнал admin_audit_log с именем оператора
+// (-operator, по умолчанию WALLET_OPERATOR или USER). С -tenant команды работают с кошельками
+// арендатора и создают кошельки для него, без -tenant - с кошельками арендатора default
+func Admin(args []string, store AdminStore, ledger []reconcile.Shard, batchSize int) error {
+	return runAdmin(args, store, ledger, batchSize, os.Stdout)
+}
+
+func runAdmin(args []string, store AdminStore, ledger []reconcile.Shard, batchSize int, out io.Writer) error {
+	defaultOperator := os.Getenv("WALLET_OPERATOR")
+	if defaultOperator == "" {
+		defaultOperator = os.Getenv("USER")
+	}
+
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	operator := fs.String("operator", defaultOperator, "operator name for the audit log")
+	output := fs.String("output", outputTable, "output format: table or json")
+	tenant := fs.String("tenant", "", "tenant whose wallets the commands address, empty - default tenant")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	if strings.TrimSpace(*operator) == "" {
+		return errors.New("operator name is required (-operator or WALLET_OPERATOR)")
+	}
+	if *output != outputTable && *output != outputJSON {
+		return fmt.Errorf("unsupported output format %q", *output)
+	}
+	if len(args) == 0 {
+		return errors.New("usage: admin create|deposit|withdraw|balance|history|freeze|unfreeze|reconcile")
+	}
+
+	a := &admin{store: store, ledger: ledger, batchSize: batchSize, operator: *operator, output: *output, out: out}
+	ctx := context.Background()
+	if *tenant != "" {
+		ctx = db.WithTenant(ctx, *tenant)
+	}
+
+	switch args[0] {
+	case "create":
+		return a.create(ctx, args[1:])
+	case "deposit":
+		return a.operation(ctx, "deposit", args[1:], a.store.DepositMoney)
+	case "withdraw":
+		return a.operation(ctx, "withdraw", args[1:], a.store.WithdrawMoney)
+	case "balance":
+		return a.balance(ctx, args[1:])
+	case "history":
+		return a.history(ctx, args[1:])
+	case "freeze":
+		return a.freeze(ctx, args[1:])
+	case "unfreeze":
+		return a.unfreeze(ctx, args[1:])
+	case "reconcile":
+		return a.reconcile(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown admin command %q", args[0])
+	}
+}
+
+func (a *admin) create(ctx context.Context, args []string) error {
+	walletUUID := uuid.NewString()
+	if len(args) > 0 {
+		walletUUID = args[0]
+	}
+	if err := validateWalletUUID(walletUUID); err != nil {
+		return err
+	}
+
+	err := a.audit(ctx, db.AuditRecord{Action: "create", WalletUUID: walletUUID}, func() error {
+		return a.store.CreateWallet(ctx, walletUUID)
+	})
+	if err != nil {
+		return err
+	}
+	return a.showWallet(ctx, walletUUID)
+}
+
+func (a *admin) operation(ctx context.Context, action string, args []string, apply func(ctx context.Context, walletUUID string, amount int64) error) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: admin %s <walletUUID> <amount>", action)
+	}
+	walletUUID := args[0]
+	if err := validateWalletUUID(walletUUID); err != nil {
+		return err
+	}
+	amount, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || amount <= 0 {
+		return fmt.Errorf("invalid amount %q", args[1])
+	}
+
+	err = a.audit(ctx, db.AuditRecord{Action: action, WalletUUID: walletUUID, Amount: amount}, func() error {
+		return apply(ctx, walletUUID, amount)
+	})
+	if err != nil {
+		return err
+	}
+	return a.showWallet(ctx, walletUUID)
+}
+
+func (a *admin) balance(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: admin balance <walletUUID>")
+	}
+	return a.showWallet(ctx, args[0])
+}
+
+func (a *admin) history(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: admin history <walletUUID> [-from date] [-to date]")
+	}
+	walletUUID := args[0]
+
+	fs := flag.NewFlagSet("admin history", flag.ContinueOnError)
+	fromParam := fs.String("from", "", "period start, YYYY-MM-DD or RFC3339 (30 days ago if empty)")
+	toParam := fs.String("to", "", "period end, exclusive (now if empty)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	to := time.Now()
+	if *toParam != "" {
+		var err error
+		if to, err = parseAdminTime(*toParam); err != nil {
+			return err
+		}
+	}
+	from := to.AddDate(0, 0, -30)
+	if *fromParam != "" {
+		var err error
+		if from, err = parseAdminTime(*fromParam); err != nil {
+			return err
+		}
+	}
+
+	var opening int64
+	entries := []db.LedgerTransaction{}
+	err := a.store.StreamStatement(ctx, walletUUID, from, to,
+		func(balance int64) error { opening = balance; return nil },
+		func(tx db.LedgerTransaction) error { entries = append(entries, tx); return nil },
+	)
+	if err != nil {
+		return err
+	}
+
+	if a.output == outputJSON {
+		type entry struct {
+			ID            int64     `json:"id"`
+			OperationType string    `json:"operationType"`
+			Amount        int64     `json:"amount"`
+			CreatedAt     time.Time `json:"createdAt"`
+		}
+		result := struct {
+			WalletUUID     string    `json:"walletId"`
+			From           time.Time `json:"from"`
+			To             time.Time `json:"to"`
+			OpeningBalance int64     `json:"openingBalance"`
+			Transactions   []entry   `json:"transactions"`
+		}{WalletUUID: walletUUID, From: from, To: to, OpeningBalance: opening, Transactions: []entry{}}
+		for _, t := range entries {
+			result.Transactions = append(result.Transactions, entry{t.ID, t.OperationType, t.Amount, t.CreatedAt})
+		}
+		return a.writeJSON(result)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Opening balance %s\t%d\n\n", from.Format(time.RFC3339), opening)
+	fmt.Fprintln(w, "ID\tTYPE\tAMOUNT\tBALANCE\tCREATED AT")
+	balance := opening
+	for _, t := range entries {
+		if t.OperationType == "WITHDRAW" {
+			balance -= t.Amount
+		} else {
+			balance += t.Amount
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\n", t.ID, t.OperationType, t.Amount, balance, t.CreatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func (a *admin) freeze(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: admin freeze <walletUUID> -reason <reason>")
+	}
+	walletUUID := args[0]
+
+	fs := flag.NewFlagSet("admin freeze", flag.ContinueOnError)
+	reason := fs.String("reason", "", "reason, stored with the wallet and in the audit log")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*reason) == "" {
+		return errors.New("freeze reason is required (-reason)")
+	}
+
+	err := a.audit(ctx, db.AuditRecord{Action: "freeze", WalletUUID: walletUUID, Details: *reason}, func() error {
+		return a.store.FreezeWallet(ctx, walletUUID, *reason)
+	})
+	if err != nil {
+		return err
+	}
+	return a.showWallet(ctx, walletUUID)
+}
+
+func (a *admin) unfreeze(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: admin unfreeze <walletUUID>")
+	}
+	walletUUID := args[0]
+
+	err := a.audit(ctx, db.AuditRecord{Action: "unfreeze", WalletUUID: walletUUID}, func() error {
+		return a.store.UnfreezeWallet(ctx, walletUUID)
+	})
+	if err != nil {
+		return err
+	}
+	return a.showWallet(ctx, walletUUID)
+}
+
+func (a *admin) reconcile(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("admin reconcile", flag.ContinueOnError)
+	batchSize := fs.Int("batch-size", a.batchSize, "wallets per batch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var report *reconcile.Report
+	err := a.audit(ctx, db.AuditRecord{Action: "reconcile"}, func() (err error) {
+		report, err = reconcile.NewShardedReconciler(a.ledger, *batchSize).Run(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if a.output == outputJSON {
+		return a.writeJSON(report)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Wallets checked\t%d\nMismatches\t%d\nTotal drift\t%d\n", report.WalletsChecked, len(report.Mismatches), report.TotalDrift)
+	if len(report.Mismatches) > 0 {
+		fmt.Fprintln(w, "\nWALLET\tSTORED\tLEDGER\tDRIFT")
+		for _, m := range report.Mismatches {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", m.WalletUUID, m.StoredBalance, m.LedgerBalance, m.Drift)
+		}
+	}
+	return w.Flush()
+}
+
+// audit выполняет действие и записывает его результат в журнал операторов.
+// Если запись в журнал не удалась, команда завершается ошибкой даже при успешном действии
+func (a *admin) audit(ctx context.Context, rec db.AuditRecord, action func() error) error {
+	rec.Operator = a.operator
+	log := logger.Log.WithContext(ctx).WithFields(map[string]any{
+		"operator": rec.Operator,
+		"action":   rec.Action,
+		"wallet":   rec.WalletUUID,
+		"amount":   rec.Amount,
+	})
+
+	// Запись создается до действия: действие без записи в журнале не выполняется,
+	// а если результат записать не удастся, в журнале останется pending
+	if err := a.store.StartAudit(ctx, &rec); err != nil {
+		log.Errorf("Failed to write audit record, action not performed: %v", err)
+		return err
+	}
+	log = log.WithField("tenant", rec.TenantID)
+
+	actionErr := action()
+	rec.Result = db.AuditSuccess
+	if actionErr != nil {
+		rec.Result = db.AuditFailure
+		rec.Error = actionErr.Error()
+	}
+
+	log = log.WithField("result", rec.Result)
+	if actionErr != nil {
+		log.Warnf("Admin action failed: %v", actionErr)
+	} else {
+		log.Info("Admin action completed")
+	}
+
+	if err := a.store.FinishAudit(ctx, &rec); err != nil {
+		log.Errorf("Failed to record result of audit record %d, it stays pending: %v", rec.ID, err)
+		return errors.Join(actionErr, err)
+	}
+	return actionErr
+}
+
+func (a *admin) showWallet(ctx context.Context, walletUUID string) error {
+	info, err := a.store.GetWalletInfo(ctx, walletUUID)
+	if err != nil {
+		return err
+	}
+
+	if a.output == outputJSON {
+		return a.writeJSON(info)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Wallet\t%s\nTenant\t%s\nBalance\t%d\nShards\t%d\nCreated at\t%s\n",
+		info.UUID, info.TenantID, info.Balance, info.ShardCount, info.CreatedAt.Format(time.RFC3339))
+	if info.Frozen {
+		fmt.Fprintf(w, "Frozen\tsince %s: %s\n", info.FrozenAt.Format(time.RFC3339), info.FrozenReason)
+	} else {
+		fmt.Fprintln(w, "Frozen\tno")
+	}
+	return w.Flush()
+}
+
+func (a *admin) writeJSON(v any) error {
+	enc := json.NewEncoder(a.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func validateWalletUUID(walletUUID string) error {
+	if _, err := uuid.Parse(walletUUID); err != nil {
+		return fmt.Errorf("invalid wallet UUID %q", walletUUID)
+	}
+	return nil
+}
+
+func parseAdminTime(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected YYYY-MM-DD or RFC3339", value)
+	}
+	return t, nil
+}
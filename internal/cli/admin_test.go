@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wallet-service/internal/db"
+)
+
+// fakeAdminStore - кошельки в памяти и журнал операторов
+type fakeAdminStore struct {
+	*db.MemoryRepository
+	frozen map[string]string
+	audit  []db.AuditRecord
+	// startErr и finishErr - ошибки записи журнала
+	startErr, finishErr error
+}
+
+func newFakeAdminStore() *fakeAdminStore {
+	return &fakeAdminStore{MemoryRepository: db.NewMemoryRepository(), frozen: map[string]string{}}
+}
+
+func (f *fakeAdminStore) CreateWallet(ctx context.Context, walletUUID string) error {
+	if _, err := f.GetBalance(ctx, walletUUID); err == nil {
+		return db.ErrWalletExists
+	}
+	return f.DepositMoney(ctx, walletUUID, 0)
+}
+
+func (f *fakeAdminStore) FreezeWallet(ctx context.Context, walletUUID, reason string) error {
+	f.frozen[walletUUID] = reason
+	return nil
+}
+
+func (f *fakeAdminStore) UnfreezeWallet(ctx context.Context, walletUUID string) error {
+	delete(f.frozen, walletUUID)
+	return nil
+}
+
+func (f *fakeAdminStore) GetWalletInfo(ctx context.Context, walletUUID string) (*db.WalletInfo, error) {
+	balance, err := f.GetBalance(ctx, walletUUID)
+	if err != nil {
+		return nil, err
+	}
+	reason, frozen := f.frozen[walletUUID]
+	return &db.WalletInfo{UUID: walletUUID, Balance: balance, ShardCount: 1, Frozen: frozen, FrozenReason: reason, CreatedAt: time.Now()}, nil
+}
+
+func (f *fakeAdminStore) StartAudit(ctx context.Context, rec *db.AuditRecord) error {
+	if f.startErr != nil {
+		return f.startErr
+	}
+	rec.Result, rec.TenantID = db.AuditPending, db.TenantFromContext(ctx)
+	if rec.TenantID == "" {
+		rec.TenantID = db.DefaultTenant
+	}
+	f.audit = append(f.audit, *rec)
+	rec.ID = int64(len(f.audit))
+	return nil
+}
+
+func (f *fakeAdminStore) FinishAudit(ctx context.Context, rec *db.AuditRecord) error {
+	if f.finishErr != nil {
+		return f.finishErr
+	}
+	f.audit[rec.ID-1] = *rec
+	return nil
+}
+
+const adminTestWallet = "123e4567-e89b-12d3-a456-426614174000"
+
+func Test_AdminDepositAudited(t *testing.T) {
+	store := newFakeAdminStore()
+	var out bytes.Buffer
+
+	err := runAdmin([]string{"-operator", "alice", "-output", "json", "deposit", adminTestWallet, "150"}, store, nil, 100, &out)
+	require.NoError(t, err)
+
+	var info db.WalletInfo
+	require.NoError(t, json.Unmarshal(out.Bytes(), &info))
+	assert.Equal(t, int64(150), info.Balance)
+
+	require.Len(t, store.audit, 1)
+	assert.Equal(t, db.AuditRecord{
+		ID: 1, Operator: "alice", Action: "deposit", WalletUUID: adminTestWallet, TenantID: db.DefaultTenant, Amount: 150,
+		Result: db.AuditSuccess,
+	}, store.audit[0])
+
+	// Тот же UUID у другого арендатора - другой кошелек, в журнале они различаются
+	err = runAdmin([]string{"-operator", "alice", "-tenant", "acme", "deposit", adminTestWallet, "150"}, store, nil, 100, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.Len(t, store.audit, 2)
+	assert.Equal(t, "acme", store.audit[1].TenantID)
+}
+
+func Test_AdminFailedWithdrawAudited(t *testing.T) {
+	store := newFakeAdminStore()
+	require.NoError(t, store.DepositMoney(context.Background(), adminTestWallet, 10))
+
+	err := runAdmin([]string{"-operator", "bob", "withdraw", adminTestWallet, "50"}, store, nil, 100, &bytes.Buffer{})
+	assert.ErrorIs(t, err, db.ErrInsufficientFunds)
+
+	require.Len(t, store.audit, 1)
+	assert.Equal(t, "bob", store.audit[0].Operator)
+	assert.Equal(t, db.AuditFailure, store.audit[0].Result)
+	assert.Equal(t, db.ErrInsufficientFunds.Error(), store.audit[0].Error)
+}
+
+func Test_AdminFreezeRequiresReason(t *testing.T) {
+	store := newFakeAdminStore()
+
+	err := runAdmin([]string{"-operator", "carol", "freeze", adminTestWallet}, store, nil, 100, &bytes.Buffer{})
+	assert.Error(t, err)
+	assert.Empty(t, store.audit)
+
+	t.Setenv("WALLET_OPERATOR", "")
+	t.Setenv("USER", "")
+	err = runAdmin([]string{"balance", adminTestWallet}, store, nil, 100, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "operator name is required")
+}
+
+func Test_AdminAuditWrittenBeforeAction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("action is not performed without audit record", func(t *testing.T) {
+		store := newFakeAdminStore()
+		store.startErr = errors.New("connection refused")
+
+		err := runAdmin([]string{"-operator", "alice", "deposit", adminTestWallet, "150"}, store, nil, 100, &bytes.Buffer{})
+		assert.ErrorIs(t, err, store.startErr)
+		_, err = store.GetBalance(ctx, adminTestWallet)
+		assert.ErrorIs(t, err, db.ErrWalletNotFound)
+	})
+
+	t.Run("unrecorded result stays pending", func(t *testing.T) {
+		store := newFakeAdminStore()
+		store.finishErr = errors.New("connection reset")
+
+		err := runAdmin([]string{"-operator", "alice", "deposit", adminTestWallet, "150"}, store, nil, 100, &bytes.Buffer{})
+		assert.ErrorIs(t, err, store.finishErr)
+
+		balance, err := store.GetBalance(ctx, adminTestWallet)
+		require.NoError(t, err)
+		assert.Equal(t, int64(150), balance)
+		require.Len(t, store.audit, 1)
+		assert.Equal(t, db.AuditPending, store.audit[0].Result)
+	})
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"wallet-service/config"
+)
+
+// Config - подкоманда `wallet-service config print [--redacted]`: действующая конфигурация
+// после слияния файлов, переменных окружения и секретов в формате KEY=value
+func Config(args []string, cfg *config.Config) error {
+	return runConfig(args, cfg, os.Stdout)
+}
+
+func runConfig(args []string, cfg *config.Config, out io.Writer) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("usage: config print [--redacted]")
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	redacted := fs.Bool("redacted", false, "hide passwords and connection strings")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	for _, field := range cfg.Fields(*redacted) {
+		if _, err := fmt.Fprintf(out, "%s=%s\n", field.Key, field.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
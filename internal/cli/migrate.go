@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+	"wallet-service/internal/db"
+)
+
+// Migrate - подкоманды `wallet-service migrate [-shard имя]`:
+//
+//	migrate up              - применить все миграции
+//	migrate down [n]        - откатить n последних миграций (по умолчанию одну)
+//	migrate to <version>    - перейти на версию схемы
+//	migrate status          - текущая версия и число неприменных миграций
+//	migrate force <version> - записать версию без выполнения миграций (после ручного исправления dirty-схемы)
+//
+// Без -shard миграции применяются к основной базе. open возвращает миграции базы шарда,
+// изменения схемы ждут advisory-блокировку не дольше lockTimeout
+func Migrate(args []string, open func(shard string) (*db.Migrator, error), lockTimeout time.Duration) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	shard := fs.String("shard", "", "shard name from DB_SHARDS (main database if empty)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [n]|to <version>|status|force <version>")
+	}
+
+	migrator, err := open(*shard)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	ctx := context.Background()
+	if lockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, lockTimeout)
+		defer cancel()
+	}
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		err = migrator.Down(ctx, steps)
+
+	case "to":
+		if len(args) != 2 {
+			return errors.New("usage: migrate to <version>")
+		}
+		version, parseErr := strconv.ParseUint(args[1], 10, 64)
+		if parseErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		err = migrator.To(ctx, uint(version))
+
+	case "force":
+		if len(args) != 2 {
+			return errors.New("usage: migrate force <version>")
+		}
+		version, parseErr := strconv.Atoi(args[1])
+		if parseErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		err = migrator.Force(ctx, version)
+
+	case "status":
+		// статус печатается ниже
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("version: %d\ndirty: %t\nlatest: %d\npending: %d\n", status.Version, status.Dirty, status.Latest, status.Pending)
+	return nil
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"wallet-service/internal/reconcile"
+)
+
+// Reconcile - подкоманда `wallet-service reconcile`: один прогон сверки всех шардов с выводом отчета в файл или stdout
+func Reconcile(args []string, shards []reconcile.Shard, defaultBatchSize int) error {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	format := fs.String("format", reconcile.FormatJSON, "report format: json or csv")
+	out := fs.String("out", "", "report file (stdout if empty)")
+	batchSize := fs.Int("batch-size", defaultBatchSize, "wallets per batch")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *format != reconcile.FormatJSON && *format != reconcile.FormatCSV {
+		return fmt.Errorf("unsupported report format %q", *format)
+	}
+
+	report, err := reconcile.NewShardedReconciler(shards, *batchSize).Run(context.Background())
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return report.Write(w, *format)
+}
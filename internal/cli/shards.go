@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"wallet-service/internal/db"
+	"wallet-service/internal/sharding"
+)
+
+// Shards - подкоманды `wallet-service shards [-tenant арендатор]`:
+//
+//	shards locate <walletUUID>            - домашний шард и шард, где кошелек живет сейчас
+//	shards move <walletUUID> <shard>      - перенос кошелька на шард
+//	shards rebalance [-dry-run]           - перенос всех кошельков на их домашние шарды
+//
+// UUID кошелька уникален в пределах арендатора: locate и move без -tenant работают
+// с кошельком арендатора default, rebalance переносит кошельки всех арендаторов
+func Shards(args []string, repo *sharding.ShardedRepository) error {
+	fs := flag.NewFlagSet("shards", flag.ContinueOnError)
+	tenant := fs.String("tenant", "", "tenant of the wallet for locate and move, empty - default tenant")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	if len(args) == 0 {
+		return errors.New("usage: shards locate|move|rebalance")
+	}
+	ctx := context.Background()
+	if *tenant != "" {
+		ctx = db.WithTenant(ctx, *tenant)
+	}
+
+	switch args[0] {
+	case "locate":
+		if len(args) != 2 {
+			return errors.New("usage: shards locate <walletUUID>")
+		}
+		shard, err := repo.Locate(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("home: %s\ncurrent: %s\n", repo.Home(args[1]).Name, shard.Name)
+		return nil
+
+	case "move":
+		if len(args) != 3 {
+			return errors.New("usage: shards move <walletUUID> <shard>")
+		}
+		return repo.MoveWallet(ctx, args[1], args[2])
+
+	case "rebalance":
+		fs := flag.NewFlagSet("shards rebalance", flag.ContinueOnError)
+		dryRun := fs.Bool("dry-run", false, "only list wallets that would be moved")
+		batchSize := fs.Int("batch-size", 1000, "wallets per batch")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		result, err := repo.Rebalance(ctx, *batchSize, *dryRun, func(walletUUID, from, to string, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s -> %s failed: %v\n", walletUUID, from, to, err)
+				return
+			}
+			fmt.Printf("%s: %s -> %s\n", walletUUID, from, to)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("checked %d, moved %d, failed %d\n", result.Checked, result.Moved, result.Failed)
+		if result.Failed > 0 {
+			return fmt.Errorf("%d wallets failed to move", result.Failed)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown shards command %q", args[0])
+	}
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"wallet-service/internal/logger"
+)
+
+// Результаты действий в журнале операторов. pending - действие начато, но результат не записан
+const (
+	AuditPending = "pending"
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// WalletInfo - сведения о кошельке для администрирования
+type WalletInfo struct {
+	UUID         string     `json:"walletId"`
+	Balance      int64      `json:"balance"`
+	ShardCount   int        `json:"shardCount"`
+	Frozen       bool       `json:"frozen"`
+	FrozenAt     *time.Time `json:"frozenAt,omitempty"`
+	FrozenReason string     `json:"frozenReason,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	TenantID     string     `json:"tenantId"`
+}
+
+// AuditRecord - действие оператора через административную CLI
+type AuditRecord struct {
+	// ID - запись в журнале, заполняется StartAudit
+	ID         int64
+	Operator   string
+	Action     string
+	WalletUUID string
+	// TenantID - арендатор кошелька, заполняется StartAudit по контексту
+	TenantID string
+	Amount   int64
+	Details  string
+	Result   string
+	Error    string
+}
+
+type allowFrozenKey struct{}
+
+// allowFrozen разрешает пополнение замороженного кошелька в этом контексте:
+// возврат средств по саге не должен зависеть от заморозки
+func allowFrozen(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowFrozenKey{}, true)
+}
+
+func frozenAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(allowFrozenKey{}).(bool)
+	return allowed
+}
+
+// createWallet создает пустой кошелек. ErrWalletExists, если UUID уже занят
+func (r *PostgresRepository) createWallet(ctx context.Context, walletUUID string) error {
+	res, err := execSQL(ctx, r.db, "CreateEmptyWallet", QueryCreateEmptyWallet, walletUUID, tenantOrDefault(ctx))
+	if err != nil {
+		logger.Log.WithContext(ctx).Errorf("Failed to create wallet with UUID %s: %v", walletUUID, err)
+		return fmt.Errorf("failed to create wallet: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to create wallet: %w", err)
+	} else if affected == 0 {
+		return ErrWalletExists
+	}
+	return nil
+}
+
+// setWalletFrozen замораживает кошелек с причиной reason или снимает заморозку (reason == nil)
+func (r *PostgresRepository) setWalletFrozen(ctx context.Context, walletUUID string, reason *string) error {
+	return r.runInTx(ctx, r.Isolation.Withdraw, func(tx *sql.Tx) error {
+		var walletID int
+		err := scanRow(ctx, tx, "LockWalletForFreeze", QueryLockWalletForFreeze, []any{walletUUID, tenantOrDefault(ctx)}, &walletID)
+		if err == sql.ErrNoRows {
+			return ErrWalletNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to lock wallet: %w", err)
+		}
+
+		if _, err := execSQL(ctx, tx, "SetWalletFrozen", QuerySetWalletFrozen, walletID, reason); err != nil {
+			logger.Log.WithContext(ctx).Errorf("Failed to update frozen state of wallet UUID %s: %v", walletUUID, err)
+			return fmt.Errorf("failed to update wallet frozen state: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *PostgresRepository) getWalletInfo(ctx context.Context, walletUUID string) (*WalletInfo, error) {
+	info := &WalletInfo{UUID: walletUUID}
+	err := scanRow(ctx, r.db, "GetWalletInfo", QueryGetWalletInfo, []any{walletUUID, tenantOrDefault(ctx)},
+		&info.Balance, &info.ShardCount, &info.FrozenAt, &info.FrozenReason, &info.CreatedAt, &info.TenantID)
+	if err == sql.ErrNoRows {
+		return nil, ErrWalletNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get wallet info: %w", err)
+	}
+	info.Frozen = info.FrozenAt != nil
+	return info, nil
+}
+
+// StartAudit записывает в журнал admin_audit_log действие оператора до его выполнения с результатом pending.
+// Если запись не удалась, действие выполнять нельзя
+func (r *PostgresRepository) StartAudit(ctx context.Context, rec *AuditRecord) error {
+	var walletUUID *string
+	if rec.WalletUUID != "" {
+		walletUUID = &rec.WalletUUID
+	}
+	var amount *int64
+	if rec.Amount != 0 {
+		amount = &rec.Amount
+	}
+
+	rec.Result, rec.TenantID = AuditPending, tenantOrDefault(ctx)
+	if err := scanRow(ctx, r.db, "CreateAuditRecord", QueryCreateAuditRecord,
+		[]any{rec.Operator, rec.Action, walletUUID, amount, rec.Details, rec.Result, rec.TenantID}, &rec.ID); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// FinishAudit записывает результат действия, начатого StartAudit
+func (r *PostgresRepository) FinishAudit(ctx context.Context, rec *AuditRecord) error {
+	var errText *string
+	if rec.Error != "" {
+		errText = &rec.Error
+	}
+
+	res, err := execSQL(ctx, r.db, "FinishAuditRecord", QueryFinishAuditRecord, rec.ID, rec.Result, errText)
+	if err != nil {
+		return fmt.Errorf("failed to finish audit record: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to finish audit record: %w", err)
+	} else if affected == 0 {
+		return fmt.Errorf("audit record %d is not pending", rec.ID)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"wallet-service/internal/logger"
+
+	"github.com/google/uuid"
+)
+
+// Состояния асинхронных операций
+const (
+	OperationPending   = "PENDING"
+	OperationCompleted = "COMPLETED"
+	OperationFailed    = "FAILED"
+)
+
+var ErrOperationNotFound = errors.New("operation not found")
+
+// AsyncOperation - пополнение или снятие, выполняемое воркером из очереди
+type AsyncOperation struct {
+	ID            string     `json:"id"`
+	WalletUUID    string     `json:"walletId"`
+	OperationType string     `json:"operationType"`
+	Amount        int64      `json:"amount"`
+	Status        string     `json:"status"`
+	Error         string     `json:"error,omitempty"`
+	Attempts      int        `json:"attempts"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	CompletedAt   *time.Time `json:"completedAt,omitempty"`
+	// TenantID - арендатор кошелька: воркер выполняет операцию от его имени
+	TenantID string `json:"-"`
+}
+
+// OperationRetry - повторы операции после ошибок, не связанных с самой операцией (сбой базы, таймаут)
+type OperationRetry struct {
+	MaxAttempts int
+	Delay       time.Duration
+}
+
+// EnqueueOperation ставит операцию в очередь. Операция выполняется воркером (ProcessNextOperation)
+func (r *PostgresRepository) EnqueueOperation(ctx context.Context, walletUUID, operationType string, amount int64) (*AsyncOperation, error) {
+	op := &AsyncOperation{
+		ID:            uuid.NewString(),
+		WalletUUID:    walletUUID,
+		OperationType: operationType,
+		Amount:        amount,
+		Status:        OperationPending,
+		TenantID:      tenantOrDefault(ctx),
+	}
+	if err := scanRow(ctx, r.db, "EnqueueOperation", QueryEnqueueOperation,
+		[]any{op.ID, walletUUID, operationType, amount, op.TenantID}, &op.CreatedAt, &op.UpdatedAt); err != nil {
+		logger.Log.WithContext(ctx).Errorf("Failed to enqueue %s for wallet %s: %v", operationType, walletUUID, err)
+		return nil, fmt.Errorf("failed to enqueue operation: %w", err)
+	}
+	return op, nil
+}
+
+// GetOperation - асинхронная операция по ID
+func (r *PostgresRepository) GetOperation(ctx context.Context, id string) (*AsyncOperation, error) {
+	op := &AsyncOperation{}
+	err := scanRow(ctx, r.db, "GetOperation", QueryGetOperation, []any{id, tenantArg(ctx)},
+		&op.ID, &op.WalletUUID, &op.OperationType, &op.Amount, &op.Status, &op.Error, &op.Attempts,
+		&op.CreatedAt, &op.UpdatedAt, &op.CompletedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrOperationNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get operation: %w", err)
+	}
+	return op, nil
+}
+
+// ProcessNextOperation берет из очереди очередную операцию и выполняет ее в той же транзакции,
+// в которой отмечает результат: операция не может быть выполнена дважды, даже если воркер упадет.
+// Отказ по самой операции (нет средств, кошелек заморожен или не найден) сразу делает ее FAILED,
+// прочие ошибки откладывают повтор на retry.Delay. Возвращает nil, nil, если очередь пуста
+func (r *PostgresRepository) ProcessNextOperation(ctx context.Context, retry OperationRetry) (*AsyncOperation, error) {
+	log := logger.Log.WithContext(ctx)
+
+	isolation := max(r.Isolation.Deposit, r.Isolation.Withdraw)
+	var op *AsyncOperation
+	var opErr error
+	err := r.runInTx(ctx, isolation, func(tx *sql.Tx) error {
+		op, opErr = nil, nil
+
+		claimed := &AsyncOperation{}
+		err := scanRow(ctx, tx, "ClaimOperation", QueryClaimOperation, nil,
+			&claimed.ID, &claimed.WalletUUID, &claimed.OperationType, &claimed.Amount, &claimed.Attempts, &claimed.CreatedAt, &claimed.TenantID)
+		if err == sql.ErrNoRows {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to claim operation: %w", err)
+		}
+		op = claimed
+
+		// Отказ по операции откатывается до точки сохранения, а результат записывается в той же транзакции
+		if _, err := execSQL(ctx, tx, "SavepointOperation", "SAVEPOINT operation"); err != nil {
+			return fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		// Операция выполняется от имени арендатора, поставившего ее в очередь
+		opCtx := WithTenant(ctx, op.TenantID)
+		switch op.OperationType {
+		case "DEPOSIT":
+			opErr = r.depositInTx(opCtx, tx, op.WalletUUID, op.Amount)
+		case "WITHDRAW":
+			opErr = r.withdrawWithFeeInTx(opCtx, tx, op.WalletUUID, op.Amount)
+		default:
+			opErr = fmt.Errorf("unsupported operation type %q", op.OperationType)
+		}
+
+		op.Status, op.Error = OperationCompleted, ""
+		if opErr != nil {
+			if !operationRejected(opErr) {
+				return opErr
+			}
+			if _, err := execSQL(ctx, tx, "RollbackToSavepointOperation", "ROLLBACK TO SAVEPOINT operation"); err != nil {
+				return fmt.Errorf("failed to roll back to savepoint: %w", err)
+			}
+			op.Status, op.Error = OperationFailed, opErr.Error()
+		}
+
+		var errText *string
+		if op.Error != "" {
+			errText = &op.Error
+		}
+		if err := scanRow(ctx, tx, "FinishOperation", QueryFinishOperation, []any{op.ID, op.Status, errText},
+			&op.Attempts, &op.UpdatedAt, &op.CompletedAt); err != nil {
+			return fmt.Errorf("failed to finish operation: %w", err)
+		}
+		return nil
+	})
+	err = contextError(ctx, err)
+	if op == nil {
+		return nil, err
+	}
+
+	if err == nil {
+		observeOperation(op.TenantID, strings.ToLower(op.OperationType), op.Amount, opErr)
+		return op, nil
+	}
+	observeOperation(op.TenantID, strings.ToLower(op.OperationType), op.Amount, err)
+
+	// Транзакция откатилась, операция осталась в очереди: попытка записывается отдельно,
+	// в том числе после истечения дедлайна ctx
+	log.Warnf("Operation %s attempt failed: %v", op.ID, err)
+	if retryErr := scanRow(context.WithoutCancel(ctx), r.db, "RetryOperation", QueryRetryOperation,
+		[]any{op.ID, err.Error(), retry.Delay.Seconds(), retry.MaxAttempts}, &op.Status, &op.Attempts); retryErr != nil {
+		log.Errorf("Failed to record attempt of operation %s: %v", op.ID, retryErr)
+	}
+	op.Error = err.Error()
+	return op, err
+}
+
+// operationRejected - ошибка самой операции, повтор которой ничего не изменит
+func operationRejected(err error) bool {
+	return errors.Is(err, ErrInsufficientFunds) || errors.Is(err, ErrWalletNotFound) || errors.Is(err, ErrWalletFrozen)
+}
@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/metrics"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DepositBatcher - групповая фиксация депозитов: параллельные депозиты, пришедшие в течение
+// короткого окна, проводятся одной транзакцией, но каждый вызывающий получает свой результат.
+// Пачки обрабатываются строго по очереди, поэтому депозиты одного кошелька проводятся в порядке
+// поступления. Если транзакция пачки откатилась, депозиты проводятся по одному; если исход
+// коммита неизвестен, все депозиты пачки получают ошибку без повторного проведения.
+// Снятие средств и чтение баланса передаются репозиторию без изменений.
+type DepositBatcher struct {
+	repo     *PostgresRepository
+	maxSize  int
+	maxDelay time.Duration
+
+	// commit проводит пачку одной транзакцией, deposit - отдельный депозит
+	commit  func(ctx context.Context, batch []*depositRequest) error
+	deposit func(ctx context.Context, walletUUID string, amount int64) error
+
+	mu       sync.RWMutex
+	closed   bool
+	requests chan *depositRequest
+	done     chan struct{}
+}
+
+type depositRequest struct {
+	ctx        context.Context
+	walletUUID string
+	amount     int64
+	result     chan error
+	// state - кто распоряжается депозитом: пачка, забравшая его (requestTaken),
+	// или вызывающий, который перестал ждать (requestAbandoned)
+	state atomic.Int32
+}
+
+const (
+	requestPending int32 = iota
+	requestTaken
+	requestAbandoned
+)
+
+// take забирает депозит в пачку. Депозит, вызывающий которого уже получил отмену
+// или таймаут, не проводится
+func (req *depositRequest) take() bool {
+	if err := req.ctx.Err(); err != nil {
+		if req.state.CompareAndSwap(requestPending, requestAbandoned) {
+			req.result <- contextError(req.ctx, err)
+		}
+		return false
+	}
+	return req.state.CompareAndSwap(requestPending, requestTaken)
+}
+
+// abandon снимает депозит, который еще не попал в пачку. false - пачка уже проводит депозит
+// или отказалась от него и отправила результат
+func (req *depositRequest) abandon() bool {
+	return req.state.CompareAndSwap(requestPending, requestAbandoned)
+}
+
+// NewDepositBatcher запускает обработку пачек: пачка отправляется, когда набралось maxSize
+// депозитов или прошло maxDelay с первого из них
+func NewDepositBatcher(repo *PostgresRepository, maxSize int, maxDelay time.Duration) *DepositBatcher {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	b := &DepositBatcher{
+		repo:     repo,
+		maxSize:  maxSize,
+		maxDelay: maxDelay,
+		requests: make(chan *depositRequest, maxSize),
+		done:     make(chan struct{}),
+	}
+	b.commit = b.commitBatch
+	b.deposit = repo.depositMoney
+	go b.run()
+	return b
+}
+
+func (b *DepositBatcher) DepositMoney(ctx context.Context, walletUUID string, amount int64) error {
+	markWrite(ctx)
+	ctx, span := startRepositorySpan(ctx, "DepositMoney", walletUUID)
+	start := time.Now()
+	err := b.submit(ctx, walletUUID, amount)
+	observeOperation(tenantOrDefault(ctx), "deposit", amount, err)
+	observeRepositoryCall("DepositMoney", start, span, err)
+	return err
+}
+
+func (b *DepositBatcher) WithdrawMoney(ctx context.Context, walletUUID string, amount int64) error {
+	return b.repo.WithdrawMoney(ctx, walletUUID, amount)
+}
+
+func (b *DepositBatcher) GetBalance(ctx context.Context, walletUUID string) (int64, error) {
+	return b.repo.GetBalance(ctx, walletUUID)
+}
+
+// Close перестает принимать депозиты в пачки и дожидается обработки уже принятых.
+// Депозиты после Close проводятся напрямую через репозиторий
+func (b *DepositBatcher) Close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.requests)
+	}
+	b.mu.Unlock()
+	<-b.done
+}
+
+func (b *DepositBatcher) submit(ctx context.Context, walletUUID string, amount int64) error {
+	ctx, cancel := withTimeout(ctx, b.repo.Timeouts.Deposit)
+	defer cancel()
+
+	req := &depositRequest{ctx: ctx, walletUUID: walletUUID, amount: amount, result: make(chan error, 1)}
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return contextError(ctx, b.deposit(ctx, walletUUID, amount))
+	}
+	select {
+	case b.requests <- req:
+		b.mu.RUnlock()
+	case <-ctx.Done():
+		b.mu.RUnlock()
+		return contextError(ctx, ctx.Err())
+	}
+
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		if req.abandon() {
+			return contextError(ctx, ctx.Err())
+		}
+		// Депозит уже в пачке: ответ должен совпадать с тем, что зафиксировано в базе,
+		// иначе повтор клиента после таймаута зачислит деньги дважды
+		return <-req.result
+	}
+}
+
+func (b *DepositBatcher) run() {
+	defer close(b.done)
+
+	for first := range b.requests {
+		batch := []*depositRequest{first}
+		timer := time.NewTimer(b.maxDelay)
+
+	collect:
+		for len(batch) < b.maxSize {
+			select {
+			case req, ok := <-b.requests:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		b.flush(batch)
+	}
+}
+
+// flush проводит пачку одной транзакцией. Если транзакция откатилась, депозиты проводятся
+// по одному, так что ошибка одного депозита не влияет на остальные
+func (b *DepositBatcher) flush(batch []*depositRequest) {
+	batch = slices.DeleteFunc(batch, func(req *depositRequest) bool { return !req.take() })
+	if len(batch) == 0 {
+		return
+	}
+
+	metrics.DepositBatchSize.Observe(float64(len(batch)))
+
+	// Единый порядок блокировок кошельков между пачками разных реплик снижает риск дедлоков.
+	// Сортировка устойчивая: депозиты одного кошелька остаются в порядке поступления
+	slices.SortStableFunc(batch, func(a, c *depositRequest) int {
+		return strings.Compare(a.walletUUID, c.walletUUID)
+	})
+
+	links := make([]trace.Link, 0, len(batch))
+	for _, req := range batch {
+		links = append(links, trace.LinkFromContext(req.ctx))
+	}
+	ctx, span := tracer.Start(context.Background(), "DepositBatcher.flush",
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("batch.size", len(batch))),
+	)
+	defer span.End()
+
+	ctx, cancel := withTimeout(ctx, b.repo.Timeouts.Deposit)
+	defer cancel()
+
+	err := b.commit(ctx, batch)
+	if err == nil {
+		for _, req := range batch {
+			req.result <- nil
+		}
+		return
+	}
+
+	log := logger.Log.WithContext(ctx)
+
+	// Пачка могла быть зафиксирована - поштучное проведение зачислило бы депозиты второй раз
+	if errors.Is(err, ErrCommitUnknown) {
+		log.Errorf("Deposit batch of %d has unknown commit outcome, deposits are not retried: %v", len(batch), err)
+		err = contextError(ctx, err)
+		for _, req := range batch {
+			req.result <- err
+		}
+		return
+	}
+
+	log.Warnf("Deposit batch of %d failed, processing deposits individually: %v", len(batch), err)
+	metrics.DepositBatchFallbacks.Inc()
+
+	for _, req := range batch {
+		req.result <- contextError(req.ctx, b.deposit(req.ctx, req.walletUUID, req.amount))
+	}
+}
+
+// commitBatch проводит депозиты пачки в одной транзакции
+func (b *DepositBatcher) commitBatch(ctx context.Context, batch []*depositRequest) error {
+	return b.repo.runInTx(ctx, b.repo.Isolation.Deposit, func(tx *sql.Tx) error {
+		for _, req := range batch {
+			// В пачке депозиты разных арендаторов, каждый проводится от имени своего
+			if err := b.repo.depositInTx(WithTenant(ctx, TenantFromContext(req.ctx)), tx, req.walletUUID, req.amount); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type batchedDeposit struct {
+	walletUUID string
+	amount     int64
+}
+
+// newTestBatcher - пачки без базы: commit и deposit подменяются в тестах
+func newTestBatcher(t *testing.T, maxSize int, maxDelay time.Duration) *DepositBatcher {
+	b := NewDepositBatcher(NewPostgresRepository(nil), maxSize, maxDelay)
+	b.commit = func(ctx context.Context, batch []*depositRequest) error { return nil }
+	b.deposit = func(ctx context.Context, walletUUID string, amount int64) error {
+		return errors.New("unexpected individual deposit")
+	}
+	t.Cleanup(b.Close)
+	return b
+}
+
+func newDepositRequest(ctx context.Context, walletUUID string, amount int64) *depositRequest {
+	return &depositRequest{ctx: ctx, walletUUID: walletUUID, amount: amount, result: make(chan error, 1)}
+}
+
+func Test_DepositBatcherGroupsByWallet(t *testing.T) {
+	ctx := context.Background()
+	b := newTestBatcher(t, 10, time.Hour)
+
+	var committed [][]batchedDeposit
+	b.commit = func(ctx context.Context, batch []*depositRequest) error {
+		var deposits []batchedDeposit
+		for _, req := range batch {
+			deposits = append(deposits, batchedDeposit{req.walletUUID, req.amount})
+		}
+		committed = append(committed, deposits)
+		return nil
+	}
+
+	batch := []*depositRequest{
+		newDepositRequest(ctx, "b", 1),
+		newDepositRequest(ctx, "a", 1),
+		newDepositRequest(ctx, "b", 2),
+		newDepositRequest(ctx, "c", 1),
+		newDepositRequest(ctx, "a", 2),
+	}
+	b.flush(batch)
+
+	// Кошельки в едином порядке, депозиты одного кошелька - в порядке поступления
+	require.Len(t, committed, 1)
+	assert.Equal(t, []batchedDeposit{{"a", 1}, {"a", 2}, {"b", 1}, {"b", 2}, {"c", 1}}, committed[0])
+	for _, req := range batch {
+		assert.NoError(t, <-req.result)
+	}
+}
+
+func Test_DepositBatcherCollectsBatch(t *testing.T) {
+	const size = 8
+	b := newTestBatcher(t, size, time.Hour)
+
+	var mu sync.Mutex
+	var sizes []int
+	b.commit = func(ctx context.Context, batch []*depositRequest) error {
+		mu.Lock()
+		sizes = append(sizes, len(batch))
+		mu.Unlock()
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for range size {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, b.DepositMoney(context.Background(), "d7af0768-704e-4f1c-9793-a44c2d1f9b75", 10))
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int{size}, sizes, "one commit for a full batch")
+}
+
+func Test_DepositBatcherContextExpiry(t *testing.T) {
+	t.Run("caller waits for a batch in progress", func(t *testing.T) {
+		b := newTestBatcher(t, 1, time.Millisecond)
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		b.commit = func(ctx context.Context, batch []*depositRequest) error {
+			close(started)
+			<-release
+			return nil
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() { result <- b.DepositMoney(ctx, "a", 10) }()
+
+		<-started
+		cancel()
+		select {
+		case err := <-result:
+			t.Fatalf("caller returned before the batch committed: %v", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		close(release)
+		assert.NoError(t, <-result, "the deposit is committed, so the caller must see success")
+	})
+
+	t.Run("expired request is not committed", func(t *testing.T) {
+		b := newTestBatcher(t, 10, time.Hour)
+
+		var committed []string
+		b.commit = func(ctx context.Context, batch []*depositRequest) error {
+			for _, req := range batch {
+				committed = append(committed, req.walletUUID)
+			}
+			return nil
+		}
+
+		expired, cancel := context.WithCancel(context.Background())
+		cancel()
+		live := newDepositRequest(context.Background(), "a", 10)
+		gone := newDepositRequest(expired, "b", 10)
+		b.flush([]*depositRequest{live, gone})
+
+		assert.Equal(t, []string{"a"}, committed)
+		assert.NoError(t, <-live.result)
+		assert.ErrorIs(t, <-gone.result, ErrOperationCanceled)
+	})
+
+	t.Run("abandoned request is skipped", func(t *testing.T) {
+		b := newTestBatcher(t, 10, time.Hour)
+		b.commit = func(ctx context.Context, batch []*depositRequest) error {
+			t.Fatalf("batch of %d must not be committed", len(batch))
+			return nil
+		}
+
+		req := newDepositRequest(context.Background(), "a", 10)
+		require.True(t, req.abandon())
+		b.flush([]*depositRequest{req})
+		assert.Empty(t, req.result)
+	})
+}
+
+func Test_DepositBatcherFallback(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rolled back batch is deposited individually", func(t *testing.T) {
+		b := newTestBatcher(t, 10, time.Hour)
+		b.commit = func(ctx context.Context, batch []*depositRequest) error { return ErrWalletFrozen }
+
+		var deposited []string
+		b.deposit = func(ctx context.Context, walletUUID string, amount int64) error {
+			deposited = append(deposited, walletUUID)
+			if walletUUID == "frozen" {
+				return ErrWalletFrozen
+			}
+			return nil
+		}
+
+		ok := newDepositRequest(ctx, "a", 10)
+		frozen := newDepositRequest(ctx, "frozen", 10)
+		b.flush([]*depositRequest{frozen, ok})
+
+		assert.Equal(t, []string{"a", "frozen"}, deposited)
+		assert.NoError(t, <-ok.result)
+		assert.ErrorIs(t, <-frozen.result, ErrWalletFrozen)
+	})
+
+	t.Run("unknown commit outcome is not retried", func(t *testing.T) {
+		b := newTestBatcher(t, 10, time.Hour)
+		b.commit = func(ctx context.Context, batch []*depositRequest) error {
+			return errors.Join(ErrCommitUnknown, errors.New("connection reset by peer"))
+		}
+		b.deposit = func(ctx context.Context, walletUUID string, amount int64) error {
+			t.Errorf("deposit to %s replayed after an unknown commit outcome", walletUUID)
+			return nil
+		}
+
+		batch := []*depositRequest{newDepositRequest(ctx, "a", 10), newDepositRequest(ctx, "b", 10)}
+		b.flush(batch)
+
+		for _, req := range batch {
+			assert.ErrorIs(t, <-req.result, ErrCommitUnknown)
+		}
+	})
+}
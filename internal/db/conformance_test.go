@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// conformanceRepository - то, что проверяет общий набор тестов: операции с кошельком и журнал транзакций
+type conformanceRepository interface {
+	Repository
+	HistoryRepository
+}
+
+// Общий набор тестов, который должны проходить все реализации Repository.
+// PostgresRepository проверяется, только если задана TEST_DATABASE_URL
+func Test_RepositoryConformance(t *testing.T) {
+	implementations := map[string]func(t *testing.T) conformanceRepository{
+		"memory": func(t *testing.T) conformanceRepository { return NewMemoryRepository() },
+		"sqlite": func(t *testing.T) conformanceRepository {
+			conn, err := OpenSQLite(filepath.Join(t.TempDir(), "wallet.db"), 5*time.Second)
+			require.NoError(t, err)
+			t.Cleanup(func() { conn.Close() })
+			require.NoError(t, RunSQLiteMigrations(conn))
+			return NewSQLiteRepository(conn)
+		},
+		"postgres": func(t *testing.T) conformanceRepository {
+			dsn := os.Getenv("TEST_DATABASE_URL")
+			if dsn == "" {
+				t.Skip("TEST_DATABASE_URL is not set")
+			}
+			conn, err := Open(dsn)
+			require.NoError(t, err)
+			t.Cleanup(func() { conn.Close() })
+			require.NoError(t, RunMigrations(context.Background(), conn))
+			return NewPostgresRepository(conn)
+		},
+	}
+
+	for name, newRepo := range implementations {
+		t.Run(name, func(t *testing.T) {
+			runConformance(t, newRepo)
+		})
+	}
+}
+
+func runConformance(t *testing.T, newRepo func(t *testing.T) conformanceRepository) {
+	ctx := context.Background()
+
+	t.Run("deposit creates wallet", func(t *testing.T) {
+		repo := newRepo(t)
+		wallet := uuid.NewString()
+
+		require.NoError(t, repo.DepositMoney(ctx, wallet, 100))
+		require.NoError(t, repo.DepositMoney(ctx, wallet, 50))
+
+		balance, err := repo.GetBalance(ctx, wallet)
+		require.NoError(t, err)
+		assert.Equal(t, int64(150), balance)
+	})
+
+	t.Run("unknown wallet", func(t *testing.T) {
+		repo := newRepo(t)
+		wallet := uuid.NewString()
+
+		_, err := repo.GetBalance(ctx, wallet)
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+		assert.ErrorIs(t, repo.WithdrawMoney(ctx, wallet, 10), ErrWalletNotFound)
+	})
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		repo := newRepo(t)
+		wallet := uuid.NewString()
+
+		require.NoError(t, repo.DepositMoney(ctx, wallet, 100))
+		assert.ErrorIs(t, repo.WithdrawMoney(ctx, wallet, 101), ErrInsufficientFunds)
+		require.NoError(t, repo.WithdrawMoney(ctx, wallet, 100))
+
+		balance, err := repo.GetBalance(ctx, wallet)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), balance)
+	})
+
+	t.Run("transaction records", func(t *testing.T) {
+		repo := newRepo(t)
+		wallet := uuid.NewString()
+		// Запас в сутки: TIMESTAMP в Postgres хранится без часового пояса
+		from := time.Now().Add(-24 * time.Hour)
+
+		require.NoError(t, repo.DepositMoney(ctx, wallet, 100))
+		require.NoError(t, repo.WithdrawMoney(ctx, wallet, 30))
+		// Отклоненное снятие не попадает в журнал
+		require.ErrorIs(t, repo.WithdrawMoney(ctx, wallet, 1000), ErrInsufficientFunds)
+
+		var opening int64 = -1
+		var entries []LedgerTransaction
+		err := repo.StreamStatement(ctx, wallet, from, time.Now().Add(24*time.Hour),
+			func(balance int64) error { opening = balance; return nil },
+			func(tx LedgerTransaction) error { entries = append(entries, tx); return nil },
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(0), opening)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "DEPOSIT", entries[0].OperationType)
+		assert.Equal(t, int64(100), entries[0].Amount)
+		assert.Equal(t, "WITHDRAW", entries[1].OperationType)
+		assert.Equal(t, int64(30), entries[1].Amount)
+		assert.Less(t, entries[0].ID, entries[1].ID)
+
+		balance, err := repo.GetBalanceAt(ctx, wallet, time.Now().Add(24*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(70), balance)
+
+		balance, err = repo.GetBalanceAt(ctx, wallet, from)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), balance)
+	})
+
+	t.Run("concurrent withdrawals never overdraw", func(t *testing.T) {
+		repo := newRepo(t)
+		wallet := uuid.NewString()
+		require.NoError(t, repo.DepositMoney(ctx, wallet, 500))
+
+		var succeeded atomic.Int64
+		var wg sync.WaitGroup
+		for range 80 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := repo.WithdrawMoney(ctx, wallet, 10)
+				if err == nil {
+					succeeded.Add(1)
+					return
+				}
+				assert.ErrorIs(t, err, ErrInsufficientFunds)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int64(50), succeeded.Load())
+		balance, err := repo.GetBalance(ctx, wallet)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), balance)
+	})
+
+	t.Run("concurrent deposits", func(t *testing.T) {
+		repo := newRepo(t)
+		wallet := uuid.NewString()
+		require.NoError(t, repo.DepositMoney(ctx, wallet, 10))
+
+		var wg sync.WaitGroup
+		for range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, repo.DepositMoney(ctx, wallet, 10))
+			}()
+		}
+		wg.Wait()
+
+		balance, err := repo.GetBalance(ctx, wallet)
+		require.NoError(t, err)
+		assert.Equal(t, int64(510), balance)
+	})
+}
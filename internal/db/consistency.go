@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type strongConsistencyKey struct{}
+
+// WithStrongConsistency помечает контекст: чтения должны видеть все зафиксированные изменения,
+// кэши и прочие источники с отставанием пропускаются
+func WithStrongConsistency(ctx context.Context) context.Context {
+	return context.WithValue(ctx, strongConsistencyKey{}, true)
+}
+
+// StrongConsistency сообщает, запрошено ли строго согласованное чтение
+func StrongConsistency(ctx context.Context) bool {
+	strong, _ := ctx.Value(strongConsistencyKey{}).(bool)
+	return strong
+}
+
+// session - область read-your-writes, обычно один HTTP-запрос
+type session struct {
+	wrote atomic.Bool
+}
+
+type sessionKey struct{}
+
+// WithSession открывает область read-your-writes: после записи через репозиторий все чтения
+// в этом контексте идут на основную базу, а не на реплики. Отметка о записи живет только
+// в этом контексте и не переносится в следующие запросы клиента - для них нужен WithStrongConsistency
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+func markWrite(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.wrote.Store(true)
+	}
+}
+
+func wroteInSession(ctx context.Context) bool {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	return ok && s.wrote.Load()
+}
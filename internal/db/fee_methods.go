@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"wallet-service/internal/logger"
+)
+
+// feeSettlement - сумма пачки начислений на один кошелек комиссий
+type feeSettlement struct {
+	tenantID  string
+	feeWallet string
+	amount    int64
+	count     int64
+}
+
+// SettleFeeAccruals зачисляет до limit начисленных комиссий на кошельки комиссий: по одной транзакции
+// DEPOSIT на кошелек за пачку. Начисления отмечаются зачисленными в той же транзакции базы.
+// Задание можно запускать на всех репликах: начисления, которые зачисляет другая реплика, пропускаются.
+// Возвращает число зачисленных начислений
+func (r *PostgresRepository) SettleFeeAccruals(ctx context.Context, limit int) (int64, error) {
+	log := logger.Log.WithContext(ctx)
+
+	var settled int64
+	err := r.runInTx(ctx, sql.LevelReadCommitted, func(tx *sql.Tx) error {
+		settled = 0
+		batch, err := settleFeeBatch(ctx, tx, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range batch {
+			// Комиссия зачисляется и на замороженный кошелек комиссий
+			err := r.depositInTx(allowFrozen(WithTenant(ctx, s.tenantID)), tx, s.feeWallet, s.amount)
+			if errors.Is(err, ErrWalletNotFound) {
+				return fmt.Errorf("fee wallet %s of tenant %s is not available: %w", s.feeWallet, s.tenantID, err)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to credit fee wallet %s of tenant %s: %w", s.feeWallet, s.tenantID, err)
+			}
+			settled += s.count
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Failed to settle fee accruals: %v", err)
+		return 0, err
+	}
+
+	if settled > 0 {
+		log.Infof("Settled %d fee accruals", settled)
+	}
+	return settled, nil
+}
+
+// settleFeeBatch отмечает пачку начислений зачисленными и возвращает суммы по кошелькам комиссий
+func settleFeeBatch(ctx context.Context, tx *sql.Tx, limit int) ([]feeSettlement, error) {
+	rows, err := querySQL(ctx, tx, "SettleFeeAccruals", QuerySettleFeeAccruals, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to settle fee accruals: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []feeSettlement
+	for rows.Next() {
+		var s feeSettlement
+		if err := rows.Scan(&s.tenantID, &s.feeWallet, &s.amount, &s.count); err != nil {
+			return nil, fmt.Errorf("failed to scan fee accruals: %w", err)
+		}
+		batch = append(batch, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate fee accruals: %w", err)
+	}
+	return batch, nil
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"wallet-service/internal/logger"
+)
+
+// streamStatement читает начальный баланс и транзакции кошелька за период [from, to)
+// в одной read-only транзакции REPEATABLE READ, чтобы выписка была согласованной.
+// opening вызывается один раз до первой транзакции, entry - для каждой транзакции по порядку.
+// Строки не накапливаются в памяти, поэтому период может быть любым.
+func (r *PostgresRepository) streamStatement(ctx context.Context, walletUUID string, from, to time.Time,
+	opening func(balance int64) error, entry func(tx LedgerTransaction) error) error {
+	log := logger.Log.WithContext(ctx)
+
+	tx, err := r.reader(ctx).BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		log.Errorf("Failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var walletID int
+	if err := scanRow(ctx, tx, "GetWalletID", QueryGetWalletID, []any{walletUUID, tenantOrDefault(ctx)}, &walletID); err != nil {
+		if err == sql.ErrNoRows {
+			log.Warnf("Wallet with UUID %s not found.", walletUUID)
+			return ErrWalletNotFound
+		}
+		log.Errorf("Failed to retrieve wallet ID for UUID %s: %v", walletUUID, err)
+		return fmt.Errorf("failed to get wallet ID: %w", err)
+	}
+
+	var openingBalance int64
+	if err := scanRow(ctx, tx, "GetLedgerBalanceBefore", QueryGetLedgerBalanceBefore, []any{walletID, from}, &openingBalance); err != nil {
+		log.Errorf("Failed to compute opening balance for wallet UUID %s: %v", walletUUID, err)
+		return fmt.Errorf("failed to compute opening balance: %w", err)
+	}
+
+	if err := opening(openingBalance); err != nil {
+		return err
+	}
+
+	rows, err := querySQL(ctx, tx, "ListWalletTransactions", QueryListWalletTransactions, walletID, from, to)
+	if err != nil {
+		log.Errorf("Failed to fetch transactions for wallet UUID %s: %v", walletUUID, err)
+		return fmt.Errorf("failed to fetch wallet transactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t := LedgerTransaction{WalletUUID: walletUUID}
+		if err := rows.Scan(&t.ID, &t.OperationType, &t.Amount, &t.CreatedAt); err != nil {
+			log.Errorf("Failed to scan wallet transaction: %v", err)
+			return fmt.Errorf("failed to scan wallet transaction: %w", err)
+		}
+		if err := entry(t); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorf("Failed to iterate wallet transactions: %v", err)
+		return fmt.Errorf("failed to iterate wallet transactions: %w", err)
+	}
+
+	return nil
+}
+
+// getBalanceAt возвращает баланс кошелька на момент asOf: последний снимок не позже asOf
+// плюс транзакции, проведенные после снимка и не позже asOf
+func (r *PostgresRepository) getBalanceAt(ctx context.Context, walletUUID string, asOf time.Time) (int64, error) {
+	log := logger.Log.WithContext(ctx)
+
+	log.Infof("Fetching balance for wallet UUID %s as of %s", walletUUID, asOf)
+
+	// Оба запроса выполняются на одной и той же базе
+	reader := r.reader(ctx)
+
+	var walletID int
+	if err := scanRow(ctx, reader, "GetWalletID", QueryGetWalletID, []any{walletUUID, tenantOrDefault(ctx)}, &walletID); err != nil {
+		if err == sql.ErrNoRows {
+			log.Warnf("Wallet with UUID %s not found.", walletUUID)
+			return 0, ErrWalletNotFound
+		}
+		log.Errorf("Failed to retrieve wallet ID for UUID %s: %v", walletUUID, err)
+		return 0, fmt.Errorf("failed to get wallet ID: %w", err)
+	}
+
+	var balance int64
+	if err := scanRow(ctx, reader, "GetBalanceAt", QueryGetBalanceAt, []any{walletID, asOf}, &balance); err != nil {
+		log.Errorf("Failed to compute balance for wallet UUID %s as of %s: %v", walletUUID, asOf, err)
+		return 0, fmt.Errorf("failed to compute balance as of %s: %w", asOf, err)
+	}
+
+	return balance, nil
+}
+
+// snapshotsLockKey - ключ pg_try_advisory_xact_lock прогона снимков балансов: задание выполняет одна реплика
+const snapshotsLockKey int64 = 0x736e617073686f74 // "snapshot"
+
+// CreateBalanceSnapshots создает снимки для всех кошельков, у которых появились транзакции
+// после предыдущего прогона. Граница прогона - xmin снимка БД: все транзакции ниже нее уже завершены,
+// поэтому транзакция, закоммиченная позже соседних, попадет в следующий прогон.
+// Прогон выполняется под advisory-блокировкой: если задание уже идет на другой реплике, возвращается 0
+func (r *PostgresRepository) CreateBalanceSnapshots(ctx context.Context) (int64, error) {
+	log := logger.Log.WithContext(ctx)
+
+	var created int64
+	err := r.runInTx(ctx, sql.LevelReadCommitted, func(tx *sql.Tx) error {
+		var locked bool
+		if err := scanRow(ctx, tx, "TryAdvisoryXactLock", QueryTryAdvisoryXactLock, []any{snapshotsLockKey}, &locked); err != nil {
+			return fmt.Errorf("failed to acquire snapshots lock: %w", err)
+		}
+		if !locked {
+			log.Debug("Balance snapshots are being created by another instance, skipping")
+			return nil
+		}
+
+		res, err := execSQL(ctx, tx, "CreateBalanceSnapshots", QueryCreateBalanceSnapshots)
+		if err != nil {
+			return fmt.Errorf("failed to create balance snapshots: %w", err)
+		}
+		created, _ = res.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Failed to create balance snapshots: %v", err)
+		return 0, err
+	}
+
+	if created > 0 {
+		log.Infof("Created %d balance snapshots", created)
+	} else {
+		log.Debug("No new transactions for balance snapshots")
+	}
+	return created, nil
+}
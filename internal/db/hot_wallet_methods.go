@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"wallet-service/internal/logger"
+)
+
+// MaxShardCount - ограничение числа частей баланса горячего кошелька
+const MaxShardCount = 256
+
+var ErrInvalidShardCount = fmt.Errorf("shard count must be between 1 and %d", MaxShardCount)
+
+// Горячие кошельки (shard_count > 1): баланс разделен между строкой кошелька и частями в wallet_shards.
+// Депозит блокирует одну случайную часть, поэтому параллельные депозиты не ждут друг друга.
+// Снятие средств блокирует строку кошелька и все части, баланс - сумма строки и частей.
+
+// depositToShard пополняет случайную часть горячего кошелька. Возвращает false, если кошелек
+// не горячий (или еще не создан) и депозит нужно провести обычным путем
+func (r *PostgresRepository) depositToShard(ctx context.Context, tx *sql.Tx, walletUUID string, amount int64) (bool, error) {
+	log := logger.Log.WithContext(ctx)
+
+	var walletID, shardCount int
+	var frozen bool
+	err := scanRow(ctx, tx, "GetWalletShardCount", QueryGetWalletShardCount, []any{walletUUID, tenantOrDefault(ctx)}, &walletID, &shardCount, &frozen)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		log.Errorf("Failed to get shard count for wallet UUID %s: %v", walletUUID, err)
+		return false, fmt.Errorf("failed to get shard count: %w", err)
+	}
+
+	if frozen && !frozenAllowed(ctx) {
+		log.Warnf("Deposit to frozen wallet %s rejected", walletUUID)
+		return false, ErrWalletFrozen
+	}
+
+	if shardCount <= 1 {
+		return false, nil
+	}
+
+	shardNo := rand.IntN(shardCount)
+	res, err := execSQL(ctx, tx, "DepositToShard", QueryDepositToShard, amount, walletID, shardNo)
+	if err != nil {
+		log.Errorf("Failed to deposit money to shard %d of wallet UUID %s: %v", shardNo, walletUUID, err)
+		return false, fmt.Errorf("failed to deposit money to shard: %w", err)
+	}
+
+	// Часть могла быть удалена параллельной перебалансировкой - тогда депозит идет в строку кошелька
+	if affected, err := res.RowsAffected(); err != nil {
+		return false, fmt.Errorf("failed to deposit money to shard: %w", err)
+	} else if affected == 0 {
+		log.Infof("Shard %d of wallet UUID %s no longer exists, depositing to wallet row", shardNo, walletUUID)
+		return false, nil
+	}
+
+	if _, err = execSQL(ctx, tx, "CreateTransaction", QueryCreateTransaction, walletID, "DEPOSIT", amount, "ACTIVE"); err != nil {
+		log.Errorf("Failed to create transaction for wallet UUID %s: %v", walletUUID, err)
+		return false, fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	log.Infof("Deposit of %d to shard %d of wallet UUID %s completed successfully.", logger.Amount(amount), shardNo, walletUUID)
+	return true, r.notifyBalanceChanged(ctx, tx, walletUUID)
+}
+
+// withdrawFromShards списывает amount с горячего кошелька, строка которого уже заблокирована.
+// Сначала списывается баланс строки кошелька, затем части по порядку, ни одна из них не уходит в минус
+func (r *PostgresRepository) withdrawFromShards(ctx context.Context, tx *sql.Tx, walletUUID string, walletBalance, amount int64) error {
+	log := logger.Log.WithContext(ctx)
+
+	var walletID int
+	if err := scanRow(ctx, tx, "GetWalletID", QueryGetWalletID, []any{walletUUID, tenantOrDefault(ctx)}, &walletID); err != nil {
+		log.Errorf("Failed to get wallet ID for UUID %s: %v", walletUUID, err)
+		return fmt.Errorf("failed to get wallet ID: %w", err)
+	}
+
+	type shard struct {
+		no      int
+		balance int64
+	}
+
+	rows, err := querySQL(ctx, tx, "GetWalletShardsForUpdate", QueryGetWalletShardsForUpdate, walletID)
+	if err != nil {
+		log.Errorf("Failed to lock shards of wallet UUID %s: %v", walletUUID, err)
+		return fmt.Errorf("failed to lock wallet shards: %w", err)
+	}
+
+	var shards []shard
+	total := walletBalance
+	for rows.Next() {
+		var s shard
+		if err := rows.Scan(&s.no, &s.balance); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan wallet shard: %w", err)
+		}
+		shards = append(shards, s)
+		total += s.balance
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate wallet shards: %w", err)
+	}
+
+	if total < amount {
+		log.Warnf("Withdraw of %d from wallet %s rejected: %v", logger.Amount(amount), walletUUID, ErrInsufficientFunds)
+		return ErrInsufficientFunds
+	}
+
+	remaining := amount
+	if take := min(walletBalance, remaining); take > 0 {
+		if _, err := execSQL(ctx, tx, "Withdraw", QueryWithdraw, take, walletUUID, tenantOrDefault(ctx)); err != nil {
+			log.Errorf("Failed to withdraw money from wallet UUID %s: %v", walletUUID, err)
+			return fmt.Errorf("failed to withdraw money: %w", err)
+		}
+		remaining -= take
+	}
+
+	for _, s := range shards {
+		if remaining == 0 {
+			break
+		}
+		take := min(s.balance, remaining)
+		if take <= 0 {
+			continue
+		}
+		if _, err := execSQL(ctx, tx, "WithdrawFromShard", QueryWithdrawFromShard, take, walletID, s.no); err != nil {
+			log.Errorf("Failed to withdraw money from shard %d of wallet UUID %s: %v", s.no, walletUUID, err)
+			return fmt.Errorf("failed to withdraw money from shard: %w", err)
+		}
+		remaining -= take
+	}
+
+	if _, err := execSQL(ctx, tx, "CreateTransaction", QueryCreateTransaction, walletID, "WITHDRAW", amount, "ACTIVE"); err != nil {
+		log.Errorf("Failed to create transaction for wallet UUID %s: %v", walletUUID, err)
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	return r.notifyBalanceChanged(ctx, tx, walletUUID)
+}
+
+// setShardCount меняет число частей кошелька без остановки сервиса: балансы всех частей переносятся
+// в строку кошелька, затем создаются новые пустые части. Депозиты, ожидавшие удаленную часть,
+// проводятся через строку кошелька
+func (r *PostgresRepository) setShardCount(ctx context.Context, walletUUID string, shardCount int) error {
+	if shardCount < 1 || shardCount > MaxShardCount {
+		return ErrInvalidShardCount
+	}
+
+	return r.runInTx(ctx, r.Isolation.Withdraw, func(tx *sql.Tx) error {
+		log := logger.Log.WithContext(ctx)
+
+		var balance int64
+		var current, walletID int
+		var frozen bool
+
+		// Блокировка строки кошелька сериализует перебалансировку со снятиями средств
+		err := scanRow(ctx, tx, "GetWalletForUpdate", QueryGetWalletForUpdate, []any{walletUUID, tenantOrDefault(ctx)}, &balance, &current, &frozen)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrWalletNotFound
+		} else if err != nil {
+			log.Errorf("Failed to lock wallet with UUID %s for update: %v", walletUUID, err)
+			return fmt.Errorf("failed to lock wallet for update: %w", err)
+		}
+
+		if err := scanRow(ctx, tx, "GetWalletID", QueryGetWalletID, []any{walletUUID, tenantOrDefault(ctx)}, &walletID); err != nil {
+			log.Errorf("Failed to get wallet ID for UUID %s: %v", walletUUID, err)
+			return fmt.Errorf("failed to get wallet ID: %w", err)
+		}
+
+		if _, err := execSQL(ctx, tx, "CollapseWalletShards", QueryCollapseWalletShards, walletID, shardCount); err != nil {
+			log.Errorf("Failed to collapse shards of wallet UUID %s: %v", walletUUID, err)
+			return fmt.Errorf("failed to collapse wallet shards: %w", err)
+		}
+
+		if shardCount > 1 {
+			if _, err := execSQL(ctx, tx, "CreateWalletShards", QueryCreateWalletShards, walletID, shardCount); err != nil {
+				log.Errorf("Failed to create shards of wallet UUID %s: %v", walletUUID, err)
+				return fmt.Errorf("failed to create wallet shards: %w", err)
+			}
+		}
+
+		log.Infof("Wallet UUID %s rebalanced from %d to %d shards", walletUUID, current, shardCount)
+		return nil
+	})
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+	"wallet-service/internal/metrics"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Публичные методы PostgresRepository открывают спан, ограничивают операцию дедлайном из Timeouts,
+// снимают метрики и делегируют работу неэкспортируемым реализациям в repo_methods.go и history_methods.go.
+// Записи отмечаются в области read-your-writes, чтобы следующие чтения шли на основную базу
+
+func (r *PostgresRepository) DepositMoney(ctx context.Context, walletUUID string, amount int64) error {
+	markWrite(ctx)
+	ctx, span := startRepositorySpan(ctx, "DepositMoney", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Deposit)
+	defer cancel()
+	start := time.Now()
+	err := contextError(ctx, r.depositMoney(ctx, walletUUID, amount))
+	observeOperation(tenantOrDefault(ctx), "deposit", amount, err)
+	observeRepositoryCall("DepositMoney", start, span, err)
+	return err
+}
+
+func (r *PostgresRepository) WithdrawMoney(ctx context.Context, walletUUID string, amount int64) error {
+	markWrite(ctx)
+	ctx, span := startRepositorySpan(ctx, "WithdrawMoney", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Withdraw)
+	defer cancel()
+	start := time.Now()
+	err := contextError(ctx, r.withdrawMoney(ctx, walletUUID, amount))
+	observeOperation(tenantOrDefault(ctx), "withdraw", amount, err)
+	observeRepositoryCall("WithdrawMoney", start, span, err)
+	return err
+}
+
+func (r *PostgresRepository) GetBalance(ctx context.Context, walletUUID string) (int64, error) {
+	ctx, span := startRepositorySpan(ctx, "GetBalance", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Read)
+	defer cancel()
+	start := time.Now()
+	balance, err := r.getBalance(ctx, walletUUID)
+	err = contextError(ctx, err)
+	observeRepositoryCall("GetBalance", start, span, err)
+	return balance, err
+}
+
+func (r *PostgresRepository) GetBalanceAt(ctx context.Context, walletUUID string, asOf time.Time) (int64, error) {
+	ctx, span := startRepositorySpan(ctx, "GetBalanceAt", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Read)
+	defer cancel()
+	start := time.Now()
+	balance, err := r.getBalanceAt(ctx, walletUUID, asOf)
+	err = contextError(ctx, err)
+	observeRepositoryCall("GetBalanceAt", start, span, err)
+	return balance, err
+}
+
+func (r *PostgresRepository) StreamStatement(ctx context.Context, walletUUID string, from, to time.Time,
+	opening func(balance int64) error, entry func(tx LedgerTransaction) error) error {
+	ctx, span := startRepositorySpan(ctx, "StreamStatement", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.WalletStatement)
+	defer cancel()
+	start := time.Now()
+	err := contextError(ctx, r.streamStatement(ctx, walletUUID, from, to, opening, entry))
+	observeRepositoryCall("StreamStatement", start, span, err)
+	return err
+}
+
+// outcome сводит ошибку репозитория к метке с фиксированным набором значений
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeSuccess
+	case errors.Is(err, ErrInsufficientFunds):
+		return metrics.OutcomeInsufficientFunds
+	case errors.Is(err, ErrWalletFrozen):
+		return metrics.OutcomeFrozen
+	case errors.Is(err, ErrWalletNotFound) || errors.Is(err, ErrTransferNotFound):
+		return metrics.OutcomeNotFound
+	case errors.Is(err, ErrOperationTimeout):
+		return metrics.OutcomeTimeout
+	case errors.Is(err, ErrOperationCanceled):
+		return metrics.OutcomeCanceled
+	case errors.Is(err, ErrTransactionConflict):
+		return metrics.OutcomeConflict
+	default:
+		return metrics.OutcomeError
+	}
+}
+
+func observeOperation(tenantID, operation string, amount int64, err error) {
+	result := outcome(err)
+	metrics.Operations.WithLabelValues(tenantID, operation, result).Inc()
+	if result == metrics.OutcomeSuccess {
+		metrics.OperationAmount.WithLabelValues(tenantID, operation).Observe(float64(amount))
+	}
+}
+
+// observeRepositoryCall записывает длительность вызова и закрывает его спан.
+// Бизнес-ошибки (нет кошелька, недостаточно средств) не помечают спан как ошибочный
+func observeRepositoryCall(method string, start time.Time, span trace.Span, err error) {
+	result := outcome(err)
+	metrics.RepositoryDuration.WithLabelValues(method, result).Observe(time.Since(start).Seconds())
+
+	if result == metrics.OutcomeError || result == metrics.OutcomeTimeout {
+		endSpan(span, err)
+		return
+	}
+	span.End()
+}
+
+func (r *PostgresRepository) SetShardCount(ctx context.Context, walletUUID string, shardCount int) error {
+	markWrite(ctx)
+	ctx, span := startRepositorySpan(ctx, "SetShardCount", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Withdraw)
+	defer cancel()
+	start := time.Now()
+	err := contextError(ctx, r.setShardCount(ctx, walletUUID, shardCount))
+	observeRepositoryCall("SetShardCount", start, span, err)
+	return err
+}
+
+func (r *PostgresRepository) CreateWallet(ctx context.Context, walletUUID string) error {
+	markWrite(ctx)
+	ctx, span := startRepositorySpan(ctx, "CreateWallet", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Deposit)
+	defer cancel()
+	start := time.Now()
+	err := contextError(ctx, r.createWallet(ctx, walletUUID))
+	observeRepositoryCall("CreateWallet", start, span, err)
+	return err
+}
+
+func (r *PostgresRepository) FreezeWallet(ctx context.Context, walletUUID, reason string) error {
+	markWrite(ctx)
+	ctx, span := startRepositorySpan(ctx, "FreezeWallet", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Withdraw)
+	defer cancel()
+	start := time.Now()
+	err := contextError(ctx, r.setWalletFrozen(ctx, walletUUID, &reason))
+	observeRepositoryCall("FreezeWallet", start, span, err)
+	return err
+}
+
+func (r *PostgresRepository) UnfreezeWallet(ctx context.Context, walletUUID string) error {
+	markWrite(ctx)
+	ctx, span := startRepositorySpan(ctx, "UnfreezeWallet", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Withdraw)
+	defer cancel()
+	start := time.Now()
+	err := contextError(ctx, r.setWalletFrozen(ctx, walletUUID, nil))
+	observeRepositoryCall("UnfreezeWallet", start, span, err)
+	return err
+}
+
+func (r *PostgresRepository) GetWalletInfo(ctx context.Context, walletUUID string) (*WalletInfo, error) {
+	ctx, span := startRepositorySpan(ctx, "GetWalletInfo", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Read)
+	defer cancel()
+	start := time.Now()
+	info, err := r.getWalletInfo(ctx, walletUUID)
+	err = contextError(ctx, err)
+	observeRepositoryCall("GetWalletInfo", start, span, err)
+	return info, err
+}
+
+func (r *PostgresRepository) Transfer(ctx context.Context, fromWalletUUID, toWalletUUID string, amount int64) (*Transfer, error) {
+	markWrite(ctx)
+	ctx, span := startRepositorySpan(ctx, "Transfer", fromWalletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Withdraw)
+	defer cancel()
+	start := time.Now()
+	t, err := r.transfer(ctx, fromWalletUUID, toWalletUUID, amount)
+	err = contextError(ctx, err)
+	observeOperation(tenantOrDefault(ctx), "transfer", amount, err)
+	observeRepositoryCall("Transfer", start, span, err)
+	return t, err
+}
+
+func (r *PostgresRepository) GetTransfer(ctx context.Context, id string) (*Transfer, error) {
+	ctx, span := startRepositorySpan(ctx, "GetTransfer", "")
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Read)
+	defer cancel()
+	start := time.Now()
+	t, err := r.getTransfer(ctx, id)
+	err = contextError(ctx, err)
+	observeRepositoryCall("GetTransfer", start, span, err)
+	return t, err
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"wallet-service/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ObserveOperation(t *testing.T) {
+	var tests = []struct {
+		err     error
+		outcome string
+	}{
+		{nil, metrics.OutcomeSuccess},
+		{ErrInsufficientFunds, metrics.OutcomeInsufficientFunds},
+		{fmt.Errorf("withdraw: %w", ErrInsufficientFunds), metrics.OutcomeInsufficientFunds},
+		{ErrWalletFrozen, metrics.OutcomeFrozen},
+		{ErrWalletNotFound, metrics.OutcomeNotFound},
+		{ErrTransferNotFound, metrics.OutcomeNotFound},
+		{ErrOperationTimeout, metrics.OutcomeTimeout},
+		{ErrOperationCanceled, metrics.OutcomeCanceled},
+		{ErrTransactionConflict, metrics.OutcomeConflict},
+		{errors.New("connection refused"), metrics.OutcomeError},
+		{context.DeadlineExceeded, metrics.OutcomeError},
+	}
+	for i, tt := range tests {
+		t.Run(tt.outcome, func(t *testing.T) {
+			// Отдельный арендатор на случай, чтобы счетчики не зависели от других тестов
+			tenantID := fmt.Sprintf("observe-test-%d", i)
+			amounts := testutil.CollectAndCount(metrics.OperationAmount)
+
+			observeOperation(tenantID, "withdraw", 100, tt.err)
+
+			assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Operations.WithLabelValues(tenantID, "withdraw", tt.outcome)))
+			// Сумма записывается только для успешных операций
+			expected := amounts
+			if tt.outcome == metrics.OutcomeSuccess {
+				expected++
+			}
+			assert.Equal(t, expected, testutil.CollectAndCount(metrics.OperationAmount))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"wallet-service/internal/logger"
+
+	"github.com/lib/pq"
+)
+
+// BalanceChangesChannel - канал LISTEN/NOTIFY, в который депозит и снятие отправляют UUID кошелька,
+// если у репозитория включен NotifyBalanceChanges
+const BalanceChangesChannel = "wallet_balance_changed"
+
+// notifyBalanceChanged отправляет UUID кошелька в BalanceChangesChannel в транзакции изменения баланса.
+// NOTIFY берет общую для базы блокировку на время коммита, поэтому отправляется, только когда
+// уведомления кто-то слушает (NotifyBalanceChanges)
+func (r *PostgresRepository) notifyBalanceChanged(ctx context.Context, tx *sql.Tx, walletUUID string) error {
+	if !r.NotifyBalanceChanges {
+		return nil
+	}
+	if _, err := execSQL(ctx, tx, "NotifyBalanceChanged", QueryNotifyBalanceChanged, BalanceChangesChannel, walletUUID); err != nil {
+		logger.Log.WithContext(ctx).Errorf("Failed to notify balance change of wallet UUID %s: %v", walletUUID, err)
+		return fmt.Errorf("failed to notify balance change: %w", err)
+	}
+	return nil
+}
+
+// ListenBalanceChanges подписывается на изменения балансов: onChange вызывается с UUID кошелька
+// после фиксации изменившей его транзакции (в том числе на других репликах сервиса).
+// После переподключения уведомления за время разрыва потеряны, поэтому вызывается onReset.
+// Возвращает функцию остановки.
+func ListenBalanceChanges(connStr string, onChange func(walletUUID string), onReset func()) (func(), error) {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			logger.Log.Warnf("Balance change listener disconnected: %v", err)
+		case pq.ListenerEventReconnected:
+			logger.Log.Info("Balance change listener reconnected")
+			onReset()
+		case pq.ListenerEventConnectionAttemptFailed:
+			logger.Log.Warnf("Balance change listener failed to connect: %v", err)
+		}
+	})
+
+	if err := listener.Listen(BalanceChangesChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		// Проверка соединения на случай, если разрыв не был замечен
+		ping := time.NewTicker(time.Minute)
+		defer ping.Stop()
+		for {
+			select {
+			case n := <-listener.Notify:
+				// nil приходит после переподключения, его обрабатывает onReset
+				if n != nil {
+					onChange(n.Extra)
+				}
+			case <-ping.C:
+				go listener.Ping()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	logger.Log.Infof("Listening for balance changes on channel %s", BalanceChangesChannel)
+	return func() {
+		close(done)
+		listener.Close()
+	}, nil
+}
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRepository - хранилище кошельков в памяти процесса с той же семантикой, что и PostgresRepository:
+// депозит создает кошелек, снятие проверяет баланс, каждая операция записывается в журнал транзакций.
+// Нужно для интеграционных тестов и локального запуска без базы, данные теряются при перезапуске
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	wallets map[string]*memoryWallet
+	nextID  int64
+	now     func() time.Time
+}
+
+type memoryWallet struct {
+	balance      int64
+	transactions []LedgerTransaction
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{wallets: map[string]*memoryWallet{}, now: time.Now}
+}
+
+func (r *MemoryRepository) DepositMoney(ctx context.Context, walletUUID string, amount int64) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(ctx, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.wallets[walletUUID]
+	if !ok {
+		w = &memoryWallet{}
+		r.wallets[walletUUID] = w
+	}
+	w.balance += amount
+	r.record(w, walletUUID, "DEPOSIT", amount)
+	return nil
+}
+
+func (r *MemoryRepository) WithdrawMoney(ctx context.Context, walletUUID string, amount int64) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(ctx, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.wallets[walletUUID]
+	if !ok {
+		return ErrWalletNotFound
+	}
+	if w.balance < amount {
+		return ErrInsufficientFunds
+	}
+	w.balance -= amount
+	r.record(w, walletUUID, "WITHDRAW", amount)
+	return nil
+}
+
+func (r *MemoryRepository) GetBalance(ctx context.Context, walletUUID string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, contextError(ctx, err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	w, ok := r.wallets[walletUUID]
+	if !ok {
+		return 0, ErrWalletNotFound
+	}
+	return w.balance, nil
+}
+
+// StreamStatement - выписка за период [from, to) по журналу транзакций, как у PostgresRepository
+func (r *MemoryRepository) StreamStatement(ctx context.Context, walletUUID string, from, to time.Time,
+	opening func(balance int64) error, entry func(tx LedgerTransaction) error) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(ctx, err)
+	}
+
+	// Копия журнала под блокировкой, колбэки вызываются без нее
+	r.mu.RLock()
+	w, ok := r.wallets[walletUUID]
+	var transactions []LedgerTransaction
+	if ok {
+		transactions = append(transactions, w.transactions...)
+	}
+	r.mu.RUnlock()
+	if !ok {
+		return ErrWalletNotFound
+	}
+
+	var openingBalance int64
+	for _, t := range transactions {
+		if t.CreatedAt.Before(from) {
+			openingBalance += signedAmount(t)
+		}
+	}
+	if err := opening(openingBalance); err != nil {
+		return err
+	}
+
+	for _, t := range transactions {
+		if t.CreatedAt.Before(from) || !t.CreatedAt.Before(to) {
+			continue
+		}
+		if err := entry(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBalanceAt - баланс кошелька по транзакциям, проведенным не позже asOf
+func (r *MemoryRepository) GetBalanceAt(ctx context.Context, walletUUID string, asOf time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, contextError(ctx, err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	w, ok := r.wallets[walletUUID]
+	if !ok {
+		return 0, ErrWalletNotFound
+	}
+
+	var balance int64
+	for _, t := range w.transactions {
+		if !t.CreatedAt.After(asOf) {
+			balance += signedAmount(t)
+		}
+	}
+	return balance, nil
+}
+
+// record добавляет транзакцию в журнал кошелька, вызывается под блокировкой на запись
+func (r *MemoryRepository) record(w *memoryWallet, walletUUID, operationType string, amount int64) {
+	r.nextID++
+	w.transactions = append(w.transactions, LedgerTransaction{
+		ID:            r.nextID,
+		WalletUUID:    walletUUID,
+		OperationType: operationType,
+		Amount:        amount,
+		CreatedAt:     r.now(),
+	})
+}
+
+func signedAmount(t LedgerTransaction) int64 {
+	if t.OperationType == "WITHDRAW" {
+		return -t.Amount
+	}
+	return t.Amount
+}
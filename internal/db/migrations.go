@@ -1,40 +1,265 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
 	"wallet-service/internal/logger"
 
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	_ "github.com/lib/pq"
 )
 
-func RunMigrations(db *sql.DB) error {
-	// Экземпляр драйвера для работы с базой
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+// Миграции встроены в бинарник, сервис не зависит от расположения файлов на диске
+var (
+	//go:embed migrations/*.sql
+	postgresMigrations embed.FS
+
+	//go:embed sqlite_migrations/*.sql
+	sqliteMigrations embed.FS
+)
+
+// migrationsLockKey - ключ pg_advisory_lock, под которым выполняются миграции.
+// Несколько реплик сервиса, стартующих одновременно, применяют миграции по очереди
+const migrationsLockKey int64 = 0x77616c6c6574 // "wallet"
+
+// MigrationStatus - состояние схемы: текущая версия, признак незавершенной миграции и последняя доступная версия
+type MigrationStatus struct {
+	Version uint
+	Dirty   bool
+	Latest  uint
+	// Pending - число еще не примененных миграций
+	Pending int
+}
+
+// Migrator применяет встроенные миграции к базе. Все изменения схемы выполняются под advisory-блокировкой
+type Migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+	// lock берет блокировку миграций и возвращает функцию ее снятия
+	lock func(ctx context.Context) (func(), error)
+	// release освобождает ресурсы, не закрывая пул соединений вызывающего
+	release func() error
+}
+
+// NewMigrator - миграции PostgreSQL. Миграции выполняются на отдельном соединении из пула db,
+// оно же держит advisory-блокировку
+func NewMigrator(ctx context.Context, db *sql.DB) (*Migrator, error) {
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		logger.Log.Fatalf("Failed to create a driver for migrations: %v", err)
-		return err
+		return nil, fmt.Errorf("failed to get a connection for migrations: %w", err)
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create a driver for migrations: %w", err)
+	}
+
+	migrator, err := newMigrator(postgresMigrations, "migrations", "postgres", driver)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	migrator.release = func() error {
+		sourceErr, dbErr := migrator.m.Close()
+		return errors.Join(sourceErr, dbErr)
+	}
+	migrator.lock = func(ctx context.Context) (func(), error) {
+		if _, err := execSQL(ctx, conn, "AdvisoryLock", QueryAdvisoryLock, migrationsLockKey); err != nil {
+			return nil, fmt.Errorf("failed to acquire migrations lock: %w", err)
+		}
+		return func() {
+			if _, err := execSQL(context.Background(), conn, "AdvisoryUnlock", QueryAdvisoryUnlock, migrationsLockKey); err != nil {
+				logger.Log.Errorf("Failed to release migrations lock: %v", err)
+			}
+		}, nil
+	}
+	return migrator, nil
+}
+
+// NewSQLiteMigrator - миграции SQLite. Файл базы используется одним процессом, отдельная блокировка не нужна
+func NewSQLiteMigrator(db *sql.DB) (*Migrator, error) {
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a driver for migrations: %w", err)
+	}
+	return newMigrator(sqliteMigrations, "sqlite_migrations", "sqlite", driver)
+}
+
+func newMigrator(fsys fs.FS, dir, name string, driver database.Driver) (*Migrator, error) {
+	src, err := iofs.New(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
 	}
 
-	// Создаем объект миграций
-	m, err := migrate.NewWithDatabaseInstance(
-		"file:///app/internal/db/migrations",
-		"wallet_db",
-		driver,
-	)
+	m, err := migrate.NewWithInstance("iofs", src, name, driver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrations: %w", err)
+	}
+
+	return &Migrator{
+		m:      m,
+		source: src,
+		lock:   func(context.Context) (func(), error) { return func() {}, nil },
+		// Драйвер SQLite закрывает базу целиком, поэтому закрывается только источник миграций
+		release: src.Close,
+	}, nil
+}
+
+// Close освобождает соединение и источник миграций. Пул соединений, переданный в конструктор, остается открытым
+func (mg *Migrator) Close() error {
+	return mg.release()
+}
+
+// Up применяет все доступные миграции
+func (mg *Migrator) Up(ctx context.Context) error {
+	return mg.locked(ctx, mg.m.Up)
+}
+
+// Down откатывает steps последних миграций
+func (mg *Migrator) Down(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("invalid number of steps %d", steps)
+	}
+	return mg.locked(ctx, func() error { return mg.m.Steps(-steps) })
+}
+
+// To применяет или откатывает миграции до версии version
+func (mg *Migrator) To(ctx context.Context, version uint) error {
+	return mg.locked(ctx, func() error { return mg.m.Migrate(version) })
+}
+
+// Force записывает версию схемы без выполнения миграций и снимает признак dirty.
+// Нужно после ручного исправления упавшей миграции; version -1 - схема без миграций
+func (mg *Migrator) Force(ctx context.Context, version int) error {
+	return mg.locked(ctx, func() error { return mg.m.Force(version) })
+}
+
+// Status возвращает текущую версию схемы и число еще не примененных миграций
+func (mg *Migrator) Status() (MigrationStatus, error) {
+	var status MigrationStatus
+
+	version, dirty, err := mg.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return status, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	status.Version, status.Dirty = version, dirty
+
+	next, err := mg.source.First()
+	for err == nil {
+		status.Latest = next
+		if next > status.Version {
+			status.Pending++
+		}
+		next, err = mg.source.Next(next)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return status, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	return status, nil
+}
+
+// locked выполняет изменение схемы под блокировкой миграций. Отсутствие изменений ошибкой не считается
+func (mg *Migrator) locked(ctx context.Context, fn func() error) error {
+	unlock, err := mg.lock(ctx)
 	if err != nil {
-		logger.Log.Fatalf("Failed to create migrations: %v", err)
 		return err
 	}
+	defer unlock()
 
-	// Выполняем миграции
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		logger.Log.Fatalf("Failed to apply migrations: %v", err)
+	if err := fn(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return err
 	}
+	return nil
+}
+
+// ErrSchemaOutdated - схема базы отстает от встроенных миграций или последняя миграция не завершена
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+// CheckSchemaVersion проверяет, что к базе PostgreSQL применены все встроенные миграции.
+// Более новая схема допустима: во время выкатки ее уже могла обновить следующая версия сервиса
+func CheckSchemaVersion(ctx context.Context, db *sql.DB) error {
+	return checkSchemaVersion(ctx, db, postgresMigrations, "migrations")
+}
+
+// CheckSQLiteSchemaVersion - то же для базы SQLite
+func CheckSQLiteSchemaVersion(ctx context.Context, db *sql.DB) error {
+	return checkSchemaVersion(ctx, db, sqliteMigrations, "sqlite_migrations")
+}
+
+func checkSchemaVersion(ctx context.Context, db *sql.DB, fsys fs.FS, dir string) error {
+	src, err := iofs.New(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+	defer src.Close()
+
+	var latest uint
+	next, err := src.First()
+	for err == nil {
+		latest = next
+		next, err = src.Next(next)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	var version uint
+	var dirty bool
+	err = scanRow(ctx, db, "GetSchemaVersion", QueryGetSchemaVersion, nil, &version, &dirty)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("%w: migration %d is dirty", ErrSchemaOutdated, version)
+	}
+	if version < latest {
+		return fmt.Errorf("%w: version %d, expected %d", ErrSchemaOutdated, version, latest)
+	}
+	return nil
+}
+
+// RunMigrations применяет все миграции PostgreSQL. Реплики сервиса, стартующие одновременно,
+// ждут друг друга на advisory-блокировке
+func RunMigrations(ctx context.Context, db *sql.DB) error {
+	migrator, err := NewMigrator(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
 
 	logger.Log.Println("Migrations applied successfully")
 	return nil
 }
+
+// RunSQLiteMigrations применяет к базе SQLite миграции из sqlite_migrations. Схема SQLite ведется
+// отдельно: в ней только кошельки и журнал транзакций
+func RunSQLiteMigrations(db *sql.DB) error {
+	migrator, err := NewSQLiteMigrator(db)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if err := migrator.Up(context.Background()); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	logger.Log.Println("SQLite migrations applied successfully")
+	return nil
+}
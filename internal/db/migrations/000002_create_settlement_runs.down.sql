@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS settlement_items;
+DROP TABLE IF EXISTS settlement_runs;
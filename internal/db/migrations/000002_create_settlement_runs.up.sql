@@ -0,0 +1,35 @@
+-- Прогоны сверки с файлами расчетов банка/PSP
+CREATE TABLE settlement_runs (
+    id SERIAL PRIMARY KEY,                                 -- Автоинкрементируемый ID
+    source VARCHAR(255) NOT NULL,                          -- Имя загруженного файла
+    format VARCHAR(20) NOT NULL,                           -- Формат файла (csv, fixed)
+    match_rule VARCHAR(20) NOT NULL,                       -- Правило сопоставления (exact, amount_date)
+    date_window_seconds BIGINT NOT NULL DEFAULT 0,         -- Окно по дате для сопоставления
+    period_from TIMESTAMP NULL,                            -- Начало периода сверки по журналу
+    period_to TIMESTAMP NULL,                              -- Конец периода сверки по журналу
+    matched_count INT NOT NULL DEFAULT 0,                  -- Сопоставлено строк
+    unmatched_file_count INT NOT NULL DEFAULT 0,           -- Строки файла без транзакции
+    unmatched_ledger_count INT NOT NULL DEFAULT 0,         -- Транзакции без строки в файле
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()            -- Дата прогона
+);
+
+-- Результаты сопоставления по каждой строке файла и транзакции
+CREATE TABLE settlement_items (
+    id SERIAL PRIMARY KEY,                                 -- Автоинкрементируемый ID
+    run_id INT NOT NULL,                                   -- Связь с прогоном
+    status VARCHAR(20) NOT NULL,                           -- MATCHED, UNMATCHED_FILE, UNMATCHED_LEDGER
+    line_no INT NULL,                                      -- Номер строки в файле (NULL для UNMATCHED_LEDGER)
+    reference VARCHAR(64) NULL,                            -- Референс из файла
+    amount BIGINT NOT NULL,                                -- Сумма
+    operation_type VARCHAR(10) NULL,                       -- DEPOSIT или WITHDRAW, если известно
+    occurred_at TIMESTAMP NULL,                            -- Дата операции
+    transaction_id INT NULL,                               -- Транзакция в журнале (NULL для UNMATCHED_FILE)
+
+    CONSTRAINT fk_settlement_run
+        FOREIGN KEY (run_id)
+        REFERENCES settlement_runs(id)
+        ON DELETE CASCADE
+);
+
+-- Индекс для выборки результатов прогона
+CREATE INDEX idx_settlement_items_run_id ON settlement_items (run_id);
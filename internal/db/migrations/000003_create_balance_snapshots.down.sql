@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transactions_wallet_id_created_at;
+DROP TABLE IF EXISTS balance_snapshots;
@@ -0,0 +1,23 @@
+-- Снимки балансов для быстрого ответа на запросы баланса на момент времени
+CREATE TABLE balance_snapshots (
+    id SERIAL PRIMARY KEY,                                 -- Автоинкрементируемый ID
+    wallet_id INT NOT NULL,                                -- Связь с кошельком
+    balance BIGINT NOT NULL,                               -- Баланс по всем транзакциям с id <= last_transaction_id
+    last_transaction_id INT NOT NULL,                      -- Последняя учтенная транзакция
+    as_of TIMESTAMP NOT NULL,                              -- Дата последней учтенной транзакции
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),           -- Дата создания снимка
+
+    CONSTRAINT fk_balance_snapshot_wallet
+        FOREIGN KEY (wallet_id)
+        REFERENCES wallets(wallet_id)
+        ON DELETE NO ACTION
+);
+
+-- Индекс для поиска последнего снимка кошелька на момент времени
+CREATE INDEX idx_balance_snapshots_wallet_as_of ON balance_snapshots (wallet_id, as_of);
+
+-- Индекс для поиска последнего снимка кошелька по журналу
+CREATE INDEX idx_balance_snapshots_wallet_last_tx ON balance_snapshots (wallet_id, last_transaction_id);
+
+-- Индекс для выборки транзакций кошелька за период (баланс на момент времени, выписки)
+CREATE INDEX idx_transactions_wallet_id_created_at ON transactions (wallet_id, created_at);
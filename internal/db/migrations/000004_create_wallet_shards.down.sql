@@ -0,0 +1,8 @@
+-- Перед удалением частей их балансы возвращаются в строку кошелька
+UPDATE wallets w
+SET balance = w.balance + s.total
+FROM (SELECT wallet_id, SUM(balance) AS total FROM wallet_shards GROUP BY wallet_id) s
+WHERE w.wallet_id = s.wallet_id;
+
+DROP TABLE IF EXISTS wallet_shards;
+ALTER TABLE wallets DROP COLUMN IF EXISTS shard_count;
@@ -0,0 +1,18 @@
+-- Число частей баланса кошелька. 1 - обычный кошелек, весь баланс в wallets.balance.
+-- У "горячих" кошельков (shard_count > 1) баланс равен wallets.balance плюс сумма частей в wallet_shards
+ALTER TABLE wallets ADD COLUMN shard_count INT NOT NULL DEFAULT 1 CHECK (shard_count >= 1);
+
+-- Части баланса горячих кошельков: депозиты блокируют одну случайную часть вместо строки кошелька
+CREATE TABLE wallet_shards (
+    wallet_id INT NOT NULL,                                -- Связь с кошельком
+    shard_no INT NOT NULL,                                 -- Номер части, от 0 до shard_count - 1
+    balance BIGINT NOT NULL DEFAULT 0,                     -- Баланс части
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),           -- Дата последнего обновления
+
+    PRIMARY KEY (wallet_id, shard_no),
+
+    CONSTRAINT fk_wallet_shard_wallet
+        FOREIGN KEY (wallet_id)
+        REFERENCES wallets(wallet_id)
+        ON DELETE NO ACTION
+);
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS wallet_shards_balance_changed ON wallet_shards;
+DROP TRIGGER IF EXISTS wallets_balance_changed ON wallets;
+DROP FUNCTION IF EXISTS notify_wallet_shard_balance_changed();
+DROP FUNCTION IF EXISTS notify_wallet_balance_changed();
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS transfer_credits;
+DROP INDEX IF EXISTS idx_transfers_state_updated_at;
+DROP TABLE IF EXISTS transfers;
+ALTER TABLE wallets DROP COLUMN IF EXISTS moved_to;
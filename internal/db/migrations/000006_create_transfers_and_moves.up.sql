@@ -0,0 +1,27 @@
+-- Перенос кошельков между шардами: строка кошелька на старом шарде остается с moved_to -
+-- именем шарда, куда кошелек перенесен. Такие строки не участвуют в операциях
+ALTER TABLE wallets ADD COLUMN moved_to VARCHAR(64) NULL;
+
+-- Переводы между кошельками. Перевод между шардами - сага: запись хранится на шарде
+-- кошелька-отправителя, состояние меняется в одной транзакции с каждым шагом
+CREATE TABLE transfers (
+    id UUID PRIMARY KEY,                                   -- ID перевода
+    from_wallet UUID NOT NULL,                             -- Кошелек-отправитель
+    to_wallet UUID NOT NULL,                               -- Кошелек-получатель
+    amount BIGINT NOT NULL CHECK (amount > 0),             -- Сумма перевода
+    state VARCHAR(20) NOT NULL,                            -- PENDING, DEBITED, COMPLETED, COMPENSATED, FAILED
+    error TEXT NULL,                                       -- Причина отказа или компенсации
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),           -- Дата создания
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()            -- Дата последнего изменения состояния
+);
+
+-- Индекс для поиска незавершенных саг
+CREATE INDEX idx_transfers_state_updated_at ON transfers (state, updated_at);
+
+-- Зачисления по переводам на шарде получателя: гарантируют, что перевод зачислен не больше одного раза,
+-- а после отмены (ABORTED) уже не будет зачислен
+CREATE TABLE transfer_credits (
+    transfer_id UUID PRIMARY KEY,                          -- ID перевода
+    status VARCHAR(10) NOT NULL,                           -- CREDITED или ABORTED
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()            -- Дата зачисления или отмены
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_admin_audit_log_wallet_uuid;
+DROP TABLE IF EXISTS admin_audit_log;
+ALTER TABLE wallets DROP COLUMN IF EXISTS frozen_reason;
+ALTER TABLE wallets DROP COLUMN IF EXISTS frozen_at;
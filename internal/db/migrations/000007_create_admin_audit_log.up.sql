@@ -0,0 +1,18 @@
+-- Заморозка кошелька: пополнение и снятие запрещены, баланс и история доступны
+ALTER TABLE wallets ADD COLUMN frozen_at TIMESTAMP NULL;
+ALTER TABLE wallets ADD COLUMN frozen_reason TEXT NULL;
+
+-- Журнал действий операторов через административную CLI
+CREATE TABLE admin_audit_log (
+    id BIGSERIAL PRIMARY KEY,                              -- Автоинкрементируемый ID
+    operator VARCHAR(128) NOT NULL,                        -- Имя оператора
+    action VARCHAR(32) NOT NULL,                           -- create, deposit, withdraw, freeze, unfreeze, reconcile
+    wallet_uuid UUID NULL,                                 -- Кошелек (NULL для действий без кошелька)
+    amount BIGINT NULL,                                    -- Сумма операции
+    details TEXT NOT NULL DEFAULT '',                      -- Параметры действия (причина заморозки, аргументы)
+    result VARCHAR(16) NOT NULL,                           -- success или failure
+    error TEXT NULL,                                       -- Текст ошибки при неудаче
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()            -- Время действия
+);
+
+CREATE INDEX idx_admin_audit_log_wallet_uuid ON admin_audit_log (wallet_uuid, created_at);
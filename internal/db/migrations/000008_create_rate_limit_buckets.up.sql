@@ -0,0 +1,10 @@
+-- Корзины токенов ограничения частоты запросов, общие для всех реплик сервиса.
+-- UNLOGGED: после сбоя базы корзины просто начинаются заново, WAL на каждый запрос не пишется
+CREATE UNLOGGED TABLE rate_limit_buckets (
+    key VARCHAR(256) PRIMARY KEY,                          -- Операция, область и идентификатор (клиент, кошелек)
+    tokens DOUBLE PRECISION NOT NULL,                      -- Токенов в корзине на момент updated_at
+    allowed BOOLEAN NOT NULL,                              -- Был ли выдан токен при последнем обращении
+    updated_at TIMESTAMPTZ NOT NULL                        -- Время последнего обращения
+);
+
+CREATE INDEX idx_rate_limit_buckets_updated_at ON rate_limit_buckets (updated_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pending_operations;
+DROP TABLE IF EXISTS risk_decisions;
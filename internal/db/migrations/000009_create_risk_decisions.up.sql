@@ -0,0 +1,35 @@
+-- Решения проверки рисков по каждой операции пополнения и снятия: вместе со значениями
+-- переменных правил, чтобы по журналу подбирать пороги
+CREATE TABLE risk_decisions (
+    id UUID PRIMARY KEY,                                   -- ID решения
+    wallet_uuid UUID NOT NULL,                             -- Кошелек операции
+    client VARCHAR(256) NOT NULL DEFAULT '',               -- Клиент API (заголовок шлюза или IP)
+    operation_type VARCHAR(10) NOT NULL,                   -- DEPOSIT или WITHDRAW
+    amount BIGINT NOT NULL,                                -- Сумма операции
+    outcome VARCHAR(10) NOT NULL,                          -- allow, review или deny
+    score INT NOT NULL,                                    -- Итоговая оценка риска 0-100
+    rules TEXT NOT NULL DEFAULT '',                        -- Сработавшие правила через запятую
+    facts JSONB NOT NULL,                                  -- Значения переменных правил
+    wallet_new BOOLEAN NOT NULL,                           -- У кошелька не было транзакций
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()            -- Время решения
+);
+
+-- Индекс для подсчета новых кошельков клиента
+CREATE INDEX idx_risk_decisions_client_new_wallets ON risk_decisions (client, created_at) WHERE wallet_new;
+
+-- Индекс для выборок журнала за период
+CREATE INDEX idx_risk_decisions_created_at ON risk_decisions (created_at);
+
+-- Операции, отложенные до ручной проверки. Параметры операции - в решении с тем же id
+CREATE TABLE pending_operations (
+    id UUID PRIMARY KEY REFERENCES risk_decisions (id),    -- ID решения
+    status VARCHAR(10) NOT NULL,                           -- PENDING, APPROVED, REJECTED или FAILED
+    decided_by VARCHAR(128) NULL,                          -- Оператор, принявший решение
+    reason TEXT NULL,                                      -- Комментарий оператора
+    error TEXT NULL,                                       -- Почему одобренная операция не выполнена
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),           -- Время постановки на проверку
+    decided_at TIMESTAMP NULL                              -- Время решения оператора
+);
+
+-- Индекс для очереди проверки
+CREATE INDEX idx_pending_operations_status_created_at ON pending_operations (status, created_at);
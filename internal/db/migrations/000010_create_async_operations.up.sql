@@ -0,0 +1,18 @@
+-- Асинхронные операции с кошельками: очередь, которую разбирают воркеры всех реплик сервиса
+-- через SELECT ... FOR UPDATE SKIP LOCKED
+CREATE TABLE async_operations (
+    id UUID PRIMARY KEY,                                   -- ID операции
+    wallet_uuid UUID NOT NULL,                             -- Кошелек
+    operation_type VARCHAR(10) NOT NULL,                   -- DEPOSIT или WITHDRAW
+    amount BIGINT NOT NULL CHECK (amount > 0),             -- Сумма операции
+    status VARCHAR(10) NOT NULL,                           -- PENDING, COMPLETED или FAILED
+    error TEXT NULL,                                       -- Причина отказа или ошибка последней попытки
+    attempts INT NOT NULL DEFAULT 0,                       -- Число попыток выполнения
+    available_at TIMESTAMP NOT NULL DEFAULT NOW(),         -- Не раньше этого времени операцию можно брать в работу
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),           -- Дата постановки в очередь
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),           -- Дата последнего изменения
+    completed_at TIMESTAMP NULL                            -- Дата выполнения или отказа
+);
+
+-- Индекс для выборки очередной операции воркером
+CREATE INDEX idx_async_operations_pending ON async_operations (available_at, created_at) WHERE status = 'PENDING';
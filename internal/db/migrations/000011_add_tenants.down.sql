@@ -0,0 +1,5 @@
+ALTER TABLE settlement_runs DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE async_operations DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE risk_decisions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE transfers DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE wallets DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,10 @@
+-- Арендаторы: каждый кошелек принадлежит арендатору, которого API определяет по ключу.
+-- Существующие кошельки и записи относятся к арендатору по умолчанию. UUID кошелька
+-- остается уникальным среди всех арендаторов
+ALTER TABLE wallets ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+-- Переводы, решения проверки рисков, асинхронные операции и прогоны сверки видны только своему арендатору
+ALTER TABLE transfers ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE risk_decisions ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE async_operations ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE settlement_runs ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
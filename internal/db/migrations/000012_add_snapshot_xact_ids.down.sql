@@ -0,0 +1,5 @@
+TRUNCATE balance_snapshots;
+ALTER TABLE balance_snapshots DROP COLUMN IF EXISTS xact_bound;
+
+DROP INDEX IF EXISTS idx_transactions_xact_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS xact_id;
@@ -0,0 +1,12 @@
+-- Граница снимков балансов по идентификаторам транзакций PostgreSQL вместо id журнала.
+-- id выдается до коммита, поэтому транзакция с меньшим id может закоммититься после прогона снимков.
+-- Все транзакции с xact_id меньше xmin текущего снимка БД уже завершены, такая граница не пропускает поздние коммиты
+ALTER TABLE transactions ADD COLUMN xact_id XID8 NOT NULL DEFAULT '0';
+ALTER TABLE transactions ALTER COLUMN xact_id SET DEFAULT pg_current_xact_id();
+
+-- Индекс для выборки транзакций между границами снимков
+CREATE INDEX idx_transactions_xact_id ON transactions (xact_id);
+
+-- Снимки с прежней границей пересоздаются фоновым заданием с начала журнала
+TRUNCATE balance_snapshots;
+ALTER TABLE balance_snapshots ADD COLUMN xact_bound XID8 NOT NULL;
@@ -0,0 +1,2 @@
+DELETE FROM admin_audit_log WHERE result = 'pending';
+ALTER TABLE admin_audit_log DROP COLUMN IF EXISTS finished_at;
@@ -0,0 +1,4 @@
+-- Запись журнала операторов создается до действия с результатом pending и завершается после него:
+-- действие, после которого завершить запись не удалось, остается в журнале как pending
+ALTER TABLE admin_audit_log ADD COLUMN finished_at TIMESTAMP NULL;  -- Время завершения действия (NULL для pending)
+UPDATE admin_audit_log SET finished_at = created_at;
@@ -0,0 +1,4 @@
+-- Откат невозможен, если разные арендаторы уже завели кошельки с одинаковым UUID
+DROP INDEX IF EXISTS idx_wallets_uuid;
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_tenant_uuid_key;
+ALTER TABLE wallets ADD CONSTRAINT wallets_uuid_key UNIQUE (uuid);
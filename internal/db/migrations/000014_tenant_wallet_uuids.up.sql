@@ -0,0 +1,7 @@
+-- UUID кошелька уникален в пределах арендатора: глобальная уникальность позволяла по ответу
+-- на пополнение узнать, что UUID занят другим арендатором, и занимать чужие UUID заранее
+ALTER TABLE wallets DROP CONSTRAINT wallets_uuid_key;
+ALTER TABLE wallets ADD CONSTRAINT wallets_tenant_uuid_key UNIQUE (tenant_id, uuid);
+
+-- Индекс для поиска кошелька по UUID без арендатора (уведомления об изменении баланса, перенос между шардами)
+CREATE INDEX idx_wallets_uuid ON wallets (uuid);
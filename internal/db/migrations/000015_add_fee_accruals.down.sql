@@ -0,0 +1,2 @@
+-- Незачисленные комиссии теряются: перед откатом их нужно зачислить
+DROP TABLE IF EXISTS fee_accruals;
@@ -0,0 +1,14 @@
+-- Начисленные комиссии за снятие. Снятие только добавляет строку, не блокируя кошелек комиссий,
+-- а фоновое задание пачками зачисляет начисления на кошельки комиссий и отмечает их settled_at
+CREATE TABLE fee_accruals (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,                        -- Арендатор кошелька комиссий
+    fee_wallet UUID NOT NULL,                              -- Кошелек комиссий арендатора
+    wallet_id INT NOT NULL REFERENCES wallets(wallet_id),  -- Кошелек, с которого списана комиссия
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    settled_at TIMESTAMP NULL                              -- NULL - еще не зачислена на кошелек комиссий
+);
+
+-- Индекс для выборки незачисленных комиссий
+CREATE INDEX idx_fee_accruals_unsettled ON fee_accruals (id) WHERE settled_at IS NULL;
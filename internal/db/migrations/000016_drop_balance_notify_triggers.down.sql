@@ -0,0 +1,36 @@
+-- Триггеры уведомлений из миграции 000005
+CREATE FUNCTION notify_wallet_balance_changed() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('wallet_balance_changed', NEW.uuid::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallets_balance_changed
+    AFTER UPDATE ON wallets
+    FOR EACH ROW
+    WHEN (OLD.balance IS DISTINCT FROM NEW.balance OR OLD.deleted_at IS DISTINCT FROM NEW.deleted_at)
+    EXECUTE FUNCTION notify_wallet_balance_changed();
+
+-- Части баланса горячих кошельков
+CREATE FUNCTION notify_wallet_shard_balance_changed() RETURNS TRIGGER AS $$
+DECLARE
+    changed_wallet_id INT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed_wallet_id := OLD.wallet_id;
+    ELSE
+        changed_wallet_id := NEW.wallet_id;
+    END IF;
+
+    PERFORM pg_notify('wallet_balance_changed', w.uuid::text)
+    FROM wallets w
+    WHERE w.wallet_id = changed_wallet_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallet_shards_balance_changed
+    AFTER INSERT OR UPDATE OR DELETE ON wallet_shards
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_wallet_shard_balance_changed();
@@ -0,0 +1,7 @@
+-- Уведомления об изменении баланса отправляет сервис, и только при включенном кэше балансов.
+-- Транзакция с NOTIFY берет общую для базы блокировку на время коммита, поэтому триггеры
+-- выстраивали в очередь коммиты всех депозитов и снятий, даже когда уведомления никто не слушал
+DROP TRIGGER IF EXISTS wallet_shards_balance_changed ON wallet_shards;
+DROP TRIGGER IF EXISTS wallets_balance_changed ON wallets;
+DROP FUNCTION IF EXISTS notify_wallet_shard_balance_changed();
+DROP FUNCTION IF EXISTS notify_wallet_balance_changed();
@@ -0,0 +1,3 @@
+-- Арендатор кошелька действия оператора: после 000014 один UUID может принадлежать разным арендаторам.
+-- У записей, сделанных раньше, арендатор неизвестен (NULL)
+ALTER TABLE admin_audit_log ADD COLUMN tenant_id VARCHAR(64) NULL;
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MigratorEmbeddedSQLite(t *testing.T) {
+	ctx := context.Background()
+	conn, err := OpenSQLite(filepath.Join(t.TempDir(), "wallet.db"), time.Second)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	migrator, err := NewSQLiteMigrator(conn)
+	require.NoError(t, err)
+
+	status, err := migrator.Status()
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), status.Version)
+	assert.Equal(t, uint(1), status.Latest)
+	assert.Equal(t, 1, status.Pending)
+
+	require.NoError(t, migrator.Up(ctx))
+	// Повторный запуск без изменений не ошибка
+	require.NoError(t, migrator.Up(ctx))
+
+	status, err = migrator.Status()
+	require.NoError(t, err)
+	assert.Equal(t, MigrationStatus{Version: 1, Latest: 1}, status)
+
+	require.NoError(t, migrator.Down(ctx, 1))
+	_, err = conn.Exec("SELECT 1 FROM wallets")
+	assert.Error(t, err)
+
+	require.NoError(t, migrator.To(ctx, 1))
+	_, err = conn.Exec("SELECT 1 FROM wallets")
+	assert.NoError(t, err)
+
+	// Закрытие миграций не закрывает базу вызывающего
+	require.NoError(t, migrator.Close())
+	assert.NoError(t, conn.Ping())
+}
@@ -5,7 +5,10 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
+	db "wallet-service/internal/db"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,44 +37,443 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 }
 
 // DepositMoney mocks base method.
-func (m *MockRepository) DepositMoney(walletUUID string, amount int64) error {
+func (m *MockRepository) DepositMoney(ctx context.Context, walletUUID string, amount int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DepositMoney", walletUUID, amount)
+	ret := m.ctrl.Call(m, "DepositMoney", ctx, walletUUID, amount)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DepositMoney indicates an expected call of DepositMoney.
-func (mr *MockRepositoryMockRecorder) DepositMoney(walletUUID, amount interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) DepositMoney(ctx, walletUUID, amount interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DepositMoney", reflect.TypeOf((*MockRepository)(nil).DepositMoney), walletUUID, amount)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DepositMoney", reflect.TypeOf((*MockRepository)(nil).DepositMoney), ctx, walletUUID, amount)
 }
 
 // GetBalance mocks base method.
-func (m *MockRepository) GetBalance(walletUUID string) (int64, error) {
+func (m *MockRepository) GetBalance(ctx context.Context, walletUUID string) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBalance", walletUUID)
+	ret := m.ctrl.Call(m, "GetBalance", ctx, walletUUID)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetBalance indicates an expected call of GetBalance.
-func (mr *MockRepositoryMockRecorder) GetBalance(walletUUID interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetBalance(ctx, walletUUID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockRepository)(nil).GetBalance), walletUUID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockRepository)(nil).GetBalance), ctx, walletUUID)
 }
 
 // WithdrawMoney mocks base method.
-func (m *MockRepository) WithdrawMoney(walletUUID string, amount int64) error {
+func (m *MockRepository) WithdrawMoney(ctx context.Context, walletUUID string, amount int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WithdrawMoney", walletUUID, amount)
+	ret := m.ctrl.Call(m, "WithdrawMoney", ctx, walletUUID, amount)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // WithdrawMoney indicates an expected call of WithdrawMoney.
-func (mr *MockRepositoryMockRecorder) WithdrawMoney(walletUUID, amount interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) WithdrawMoney(ctx, walletUUID, amount interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawMoney", reflect.TypeOf((*MockRepository)(nil).WithdrawMoney), walletUUID, amount)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawMoney", reflect.TypeOf((*MockRepository)(nil).WithdrawMoney), ctx, walletUUID, amount)
+}
+
+// MockHistoryRepository is a mock of HistoryRepository interface.
+type MockHistoryRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockHistoryRepositoryMockRecorder
+}
+
+// MockHistoryRepositoryMockRecorder is the mock recorder for MockHistoryRepository.
+type MockHistoryRepositoryMockRecorder struct {
+	mock *MockHistoryRepository
+}
+
+// NewMockHistoryRepository creates a new mock instance.
+func NewMockHistoryRepository(ctrl *gomock.Controller) *MockHistoryRepository {
+	mock := &MockHistoryRepository{ctrl: ctrl}
+	mock.recorder = &MockHistoryRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHistoryRepository) EXPECT() *MockHistoryRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetBalanceAt mocks base method.
+func (m *MockHistoryRepository) GetBalanceAt(ctx context.Context, walletUUID string, asOf time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceAt", ctx, walletUUID, asOf)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceAt indicates an expected call of GetBalanceAt.
+func (mr *MockHistoryRepositoryMockRecorder) GetBalanceAt(ctx, walletUUID, asOf interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceAt", reflect.TypeOf((*MockHistoryRepository)(nil).GetBalanceAt), ctx, walletUUID, asOf)
+}
+
+// StreamStatement mocks base method.
+func (m *MockHistoryRepository) StreamStatement(ctx context.Context, walletUUID string, from, to time.Time, opening func(int64) error, entry func(db.LedgerTransaction) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamStatement", ctx, walletUUID, from, to, opening, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamStatement indicates an expected call of StreamStatement.
+func (mr *MockHistoryRepositoryMockRecorder) StreamStatement(ctx, walletUUID, from, to, opening, entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamStatement", reflect.TypeOf((*MockHistoryRepository)(nil).StreamStatement), ctx, walletUUID, from, to, opening, entry)
+}
+
+// MockHotWalletRepository is a mock of HotWalletRepository interface.
+type MockHotWalletRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockHotWalletRepositoryMockRecorder
+}
+
+// MockHotWalletRepositoryMockRecorder is the mock recorder for MockHotWalletRepository.
+type MockHotWalletRepositoryMockRecorder struct {
+	mock *MockHotWalletRepository
+}
+
+// NewMockHotWalletRepository creates a new mock instance.
+func NewMockHotWalletRepository(ctrl *gomock.Controller) *MockHotWalletRepository {
+	mock := &MockHotWalletRepository{ctrl: ctrl}
+	mock.recorder = &MockHotWalletRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHotWalletRepository) EXPECT() *MockHotWalletRepositoryMockRecorder {
+	return m.recorder
+}
+
+// SetShardCount mocks base method.
+func (m *MockHotWalletRepository) SetShardCount(ctx context.Context, walletUUID string, shardCount int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetShardCount", ctx, walletUUID, shardCount)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetShardCount indicates an expected call of SetShardCount.
+func (mr *MockHotWalletRepositoryMockRecorder) SetShardCount(ctx, walletUUID, shardCount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetShardCount", reflect.TypeOf((*MockHotWalletRepository)(nil).SetShardCount), ctx, walletUUID, shardCount)
+}
+
+// MockTransferRepository is a mock of TransferRepository interface.
+type MockTransferRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransferRepositoryMockRecorder
+}
+
+// MockTransferRepositoryMockRecorder is the mock recorder for MockTransferRepository.
+type MockTransferRepositoryMockRecorder struct {
+	mock *MockTransferRepository
+}
+
+// NewMockTransferRepository creates a new mock instance.
+func NewMockTransferRepository(ctrl *gomock.Controller) *MockTransferRepository {
+	mock := &MockTransferRepository{ctrl: ctrl}
+	mock.recorder = &MockTransferRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransferRepository) EXPECT() *MockTransferRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetTransfer mocks base method.
+func (m *MockTransferRepository) GetTransfer(ctx context.Context, id string) (*db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfer", ctx, id)
+	ret0, _ := ret[0].(*db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfer indicates an expected call of GetTransfer.
+func (mr *MockTransferRepositoryMockRecorder) GetTransfer(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfer", reflect.TypeOf((*MockTransferRepository)(nil).GetTransfer), ctx, id)
+}
+
+// Transfer mocks base method.
+func (m *MockTransferRepository) Transfer(ctx context.Context, fromWalletUUID, toWalletUUID string, amount int64) (*db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transfer", ctx, fromWalletUUID, toWalletUUID, amount)
+	ret0, _ := ret[0].(*db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Transfer indicates an expected call of Transfer.
+func (mr *MockTransferRepositoryMockRecorder) Transfer(ctx, fromWalletUUID, toWalletUUID, amount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transfer", reflect.TypeOf((*MockTransferRepository)(nil).Transfer), ctx, fromWalletUUID, toWalletUUID, amount)
+}
+
+// MockAdminRepository is a mock of AdminRepository interface.
+type MockAdminRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminRepositoryMockRecorder
+}
+
+// MockAdminRepositoryMockRecorder is the mock recorder for MockAdminRepository.
+type MockAdminRepositoryMockRecorder struct {
+	mock *MockAdminRepository
+}
+
+// NewMockAdminRepository creates a new mock instance.
+func NewMockAdminRepository(ctrl *gomock.Controller) *MockAdminRepository {
+	mock := &MockAdminRepository{ctrl: ctrl}
+	mock.recorder = &MockAdminRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdminRepository) EXPECT() *MockAdminRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateWallet mocks base method.
+func (m *MockAdminRepository) CreateWallet(ctx context.Context, walletUUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWallet", ctx, walletUUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateWallet indicates an expected call of CreateWallet.
+func (mr *MockAdminRepositoryMockRecorder) CreateWallet(ctx, walletUUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWallet", reflect.TypeOf((*MockAdminRepository)(nil).CreateWallet), ctx, walletUUID)
+}
+
+// FinishAudit mocks base method.
+func (m *MockAdminRepository) FinishAudit(ctx context.Context, rec *db.AuditRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishAudit", ctx, rec)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FinishAudit indicates an expected call of FinishAudit.
+func (mr *MockAdminRepositoryMockRecorder) FinishAudit(ctx, rec interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishAudit", reflect.TypeOf((*MockAdminRepository)(nil).FinishAudit), ctx, rec)
+}
+
+// FreezeWallet mocks base method.
+func (m *MockAdminRepository) FreezeWallet(ctx context.Context, walletUUID, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FreezeWallet", ctx, walletUUID, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FreezeWallet indicates an expected call of FreezeWallet.
+func (mr *MockAdminRepositoryMockRecorder) FreezeWallet(ctx, walletUUID, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FreezeWallet", reflect.TypeOf((*MockAdminRepository)(nil).FreezeWallet), ctx, walletUUID, reason)
+}
+
+// GetWalletInfo mocks base method.
+func (m *MockAdminRepository) GetWalletInfo(ctx context.Context, walletUUID string) (*db.WalletInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWalletInfo", ctx, walletUUID)
+	ret0, _ := ret[0].(*db.WalletInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWalletInfo indicates an expected call of GetWalletInfo.
+func (mr *MockAdminRepositoryMockRecorder) GetWalletInfo(ctx, walletUUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWalletInfo", reflect.TypeOf((*MockAdminRepository)(nil).GetWalletInfo), ctx, walletUUID)
+}
+
+// StartAudit mocks base method.
+func (m *MockAdminRepository) StartAudit(ctx context.Context, rec *db.AuditRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartAudit", ctx, rec)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StartAudit indicates an expected call of StartAudit.
+func (mr *MockAdminRepositoryMockRecorder) StartAudit(ctx, rec interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartAudit", reflect.TypeOf((*MockAdminRepository)(nil).StartAudit), ctx, rec)
+}
+
+// UnfreezeWallet mocks base method.
+func (m *MockAdminRepository) UnfreezeWallet(ctx context.Context, walletUUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnfreezeWallet", ctx, walletUUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnfreezeWallet indicates an expected call of UnfreezeWallet.
+func (mr *MockAdminRepositoryMockRecorder) UnfreezeWallet(ctx, walletUUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnfreezeWallet", reflect.TypeOf((*MockAdminRepository)(nil).UnfreezeWallet), ctx, walletUUID)
+}
+
+// MockRiskRepository is a mock of RiskRepository interface.
+type MockRiskRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRiskRepositoryMockRecorder
+}
+
+// MockRiskRepositoryMockRecorder is the mock recorder for MockRiskRepository.
+type MockRiskRepositoryMockRecorder struct {
+	mock *MockRiskRepository
+}
+
+// NewMockRiskRepository creates a new mock instance.
+func NewMockRiskRepository(ctrl *gomock.Controller) *MockRiskRepository {
+	mock := &MockRiskRepository{ctrl: ctrl}
+	mock.recorder = &MockRiskRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRiskRepository) EXPECT() *MockRiskRepositoryMockRecorder {
+	return m.recorder
+}
+
+// DecidePendingOperation mocks base method.
+func (m *MockRiskRepository) DecidePendingOperation(ctx context.Context, id, status, decidedBy, reason string) (*db.PendingOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecidePendingOperation", ctx, id, status, decidedBy, reason)
+	ret0, _ := ret[0].(*db.PendingOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DecidePendingOperation indicates an expected call of DecidePendingOperation.
+func (mr *MockRiskRepositoryMockRecorder) DecidePendingOperation(ctx, id, status, decidedBy, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecidePendingOperation", reflect.TypeOf((*MockRiskRepository)(nil).DecidePendingOperation), ctx, id, status, decidedBy, reason)
+}
+
+// FailPendingOperation mocks base method.
+func (m *MockRiskRepository) FailPendingOperation(ctx context.Context, id, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailPendingOperation", ctx, id, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailPendingOperation indicates an expected call of FailPendingOperation.
+func (mr *MockRiskRepositoryMockRecorder) FailPendingOperation(ctx, id, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailPendingOperation", reflect.TypeOf((*MockRiskRepository)(nil).FailPendingOperation), ctx, id, reason)
+}
+
+// GetRiskStats mocks base method.
+func (m *MockRiskRepository) GetRiskStats(ctx context.Context, walletUUID, client string, windows db.RiskWindows) (*db.RiskStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRiskStats", ctx, walletUUID, client, windows)
+	ret0, _ := ret[0].(*db.RiskStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRiskStats indicates an expected call of GetRiskStats.
+func (mr *MockRiskRepositoryMockRecorder) GetRiskStats(ctx, walletUUID, client, windows interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRiskStats", reflect.TypeOf((*MockRiskRepository)(nil).GetRiskStats), ctx, walletUUID, client, windows)
+}
+
+// ListPendingOperations mocks base method.
+func (m *MockRiskRepository) ListPendingOperations(ctx context.Context, limit int) ([]db.PendingOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPendingOperations", ctx, limit)
+	ret0, _ := ret[0].([]db.PendingOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPendingOperations indicates an expected call of ListPendingOperations.
+func (mr *MockRiskRepositoryMockRecorder) ListPendingOperations(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPendingOperations", reflect.TypeOf((*MockRiskRepository)(nil).ListPendingOperations), ctx, limit)
+}
+
+// RecordRiskDecision mocks base method.
+func (m *MockRiskRepository) RecordRiskDecision(ctx context.Context, d *db.RiskDecision) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordRiskDecision", ctx, d)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordRiskDecision indicates an expected call of RecordRiskDecision.
+func (mr *MockRiskRepositoryMockRecorder) RecordRiskDecision(ctx, d interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordRiskDecision", reflect.TypeOf((*MockRiskRepository)(nil).RecordRiskDecision), ctx, d)
+}
+
+// MockOperationQueue is a mock of OperationQueue interface.
+type MockOperationQueue struct {
+	ctrl     *gomock.Controller
+	recorder *MockOperationQueueMockRecorder
+}
+
+// MockOperationQueueMockRecorder is the mock recorder for MockOperationQueue.
+type MockOperationQueueMockRecorder struct {
+	mock *MockOperationQueue
+}
+
+// NewMockOperationQueue creates a new mock instance.
+func NewMockOperationQueue(ctrl *gomock.Controller) *MockOperationQueue {
+	mock := &MockOperationQueue{ctrl: ctrl}
+	mock.recorder = &MockOperationQueueMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOperationQueue) EXPECT() *MockOperationQueueMockRecorder {
+	return m.recorder
+}
+
+// EnqueueOperation mocks base method.
+func (m *MockOperationQueue) EnqueueOperation(ctx context.Context, walletUUID, operationType string, amount int64) (*db.AsyncOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueOperation", ctx, walletUUID, operationType, amount)
+	ret0, _ := ret[0].(*db.AsyncOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnqueueOperation indicates an expected call of EnqueueOperation.
+func (mr *MockOperationQueueMockRecorder) EnqueueOperation(ctx, walletUUID, operationType, amount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueOperation", reflect.TypeOf((*MockOperationQueue)(nil).EnqueueOperation), ctx, walletUUID, operationType, amount)
+}
+
+// GetOperation mocks base method.
+func (m *MockOperationQueue) GetOperation(ctx context.Context, id string) (*db.AsyncOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOperation", ctx, id)
+	ret0, _ := ret[0].(*db.AsyncOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOperation indicates an expected call of GetOperation.
+func (mr *MockOperationQueueMockRecorder) GetOperation(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOperation", reflect.TypeOf((*MockOperationQueue)(nil).GetOperation), ctx, id)
 }
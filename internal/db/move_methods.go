@@ -0,0 +1,212 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-service/internal/logger"
+)
+
+var (
+	// ErrWalletExists - кошелек с таким UUID уже есть (при переносе - активный кошелек на шарде назначения)
+	ErrWalletExists = errors.New("wallet already exists")
+	// ErrWalletMoving - кошелек в процессе переноса между шардами (перенос прерван и не завершен)
+	ErrWalletMoving = errors.New("wallet is being moved between shards")
+)
+
+// Перенос кошелька между шардами (см. internal/sharding):
+//  1. ExportWallet блокирует кошелек на шарде-источнике и читает баланс и журнал;
+//  2. ImportWallet создает на шарде назначения неактивную копию, указывающую обратно на источник;
+//  3. WalletExport.Commit отмечает кошелек на источнике перенесенным и снимает блокировку;
+//  4. ActivateWallet активирует копию.
+// Прерванный перенос оставляет либо активный источник, либо источник и копию, указывающие друг
+// на друга - тогда повторный запуск переноса завершает его шагом 4.
+
+// MovedTransaction - транзакция журнала, переносимая вместе с кошельком
+type MovedTransaction struct {
+	OperationType string
+	Amount        int64
+	WalletStatus  string
+	CreatedAt     time.Time
+}
+
+// WalletExport - кошелек, заблокированный на шарде-источнике на время переноса
+type WalletExport struct {
+	tx       *sql.Tx
+	walletID int
+
+	WalletUUID   string
+	Balance      int64
+	FrozenAt     *time.Time
+	FrozenReason *string
+	TenantID     string
+	Transactions []MovedTransaction
+}
+
+// WalletRef - ID, UUID и арендатор кошелька
+type WalletRef struct {
+	ID       int64
+	UUID     string
+	TenantID string
+}
+
+// WalletLocation сообщает, есть ли кошелек арендатора на этом шарде, и если он перенесен - куда
+func (r *PostgresRepository) WalletLocation(ctx context.Context, walletUUID string) (found bool, movedTo string, err error) {
+	err = scanRow(ctx, r.db, "GetWalletLocation", QueryGetWalletLocation, []any{walletUUID, tenantOrDefault(ctx)}, &movedTo)
+	if err == sql.ErrNoRows {
+		return false, "", nil
+	} else if err != nil {
+		return false, "", fmt.Errorf("failed to get wallet location: %w", err)
+	}
+	return true, movedTo, nil
+}
+
+// ExportWallet блокирует кошелек и читает его баланс и журнал. Части горячего кошелька
+// сворачиваются в строку кошелька. Блокировка держится до Commit или Rollback
+func (r *PostgresRepository) ExportWallet(ctx context.Context, walletUUID string) (*WalletExport, error) {
+	log := logger.Log.WithContext(ctx)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	export := &WalletExport{tx: tx, WalletUUID: walletUUID}
+	if err := export.load(ctx); err != nil {
+		tx.Rollback()
+		if err != ErrWalletNotFound {
+			log.Errorf("Failed to export wallet %s: %v", walletUUID, err)
+		}
+		return nil, err
+	}
+
+	log.Infof("Wallet %s locked for move: balance %d, %d transactions", walletUUID, logger.Amount(export.Balance), len(export.Transactions))
+	return export, nil
+}
+
+func (e *WalletExport) load(ctx context.Context) error {
+	err := scanRow(ctx, e.tx, "GetWalletForMove", QueryGetWalletForMove, []any{e.WalletUUID, tenantOrDefault(ctx)}, &e.walletID, &e.Balance, &e.FrozenAt, &e.FrozenReason, &e.TenantID)
+	if err == sql.ErrNoRows {
+		return ErrWalletNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to lock wallet for move: %w", err)
+	}
+
+	if _, err := execSQL(ctx, e.tx, "CollapseWalletShards", QueryCollapseWalletShards, e.walletID, 1); err != nil {
+		return fmt.Errorf("failed to collapse wallet shards: %w", err)
+	}
+	if err := scanRow(ctx, e.tx, "GetBalance", QueryGetBalance, []any{e.WalletUUID, tenantOrDefault(ctx)}, &e.Balance); err != nil {
+		return fmt.Errorf("failed to get wallet balance: %w", err)
+	}
+
+	rows, err := querySQL(ctx, e.tx, "ListAllWalletTransactions", QueryListAllWalletTransactions, e.walletID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch wallet transactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t MovedTransaction
+		if err := rows.Scan(&t.OperationType, &t.Amount, &t.WalletStatus, &t.CreatedAt); err != nil {
+			return fmt.Errorf("failed to scan wallet transaction: %w", err)
+		}
+		e.Transactions = append(e.Transactions, t)
+	}
+	return rows.Err()
+}
+
+// Commit отмечает кошелек на источнике перенесенным на шард movedTo
+func (e *WalletExport) Commit(ctx context.Context, movedTo string) error {
+	if _, err := execSQL(ctx, e.tx, "MarkWalletMoved", QueryMarkWalletMoved, e.walletID, movedTo); err != nil {
+		e.tx.Rollback()
+		return fmt.Errorf("failed to mark wallet as moved: %w", err)
+	}
+	if err := e.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Rollback снимает блокировку без переноса
+func (e *WalletExport) Rollback() error {
+	return e.tx.Rollback()
+}
+
+// ImportWallet создает неактивную копию кошелька, указывающую на шард-источник source.
+// Если кошелек раньше уже жил на этом шарде, его строка и старый журнал переиспользуются:
+// журнал источника начинается с тех же транзакций, поэтому копируются только новые
+func (r *PostgresRepository) ImportWallet(ctx context.Context, export *WalletExport, source string) error {
+	return r.runInTx(ctx, sql.LevelDefault, func(tx *sql.Tx) error {
+		var walletID int
+		err := scanRow(ctx, tx, "ImportWallet", QueryImportWallet, []any{export.WalletUUID, export.Balance, source, export.FrozenAt, export.FrozenReason, export.TenantID}, &walletID)
+		if err == sql.ErrNoRows {
+			return ErrWalletExists
+		} else if err != nil {
+			return fmt.Errorf("failed to import wallet: %w", err)
+		}
+
+		var existing int
+		if err := scanRow(ctx, tx, "CountWalletTransactions", QueryCountWalletTransactions, []any{walletID}, &existing); err != nil {
+			return fmt.Errorf("failed to count wallet transactions: %w", err)
+		}
+		if existing > len(export.Transactions) {
+			return fmt.Errorf("target shard has %d transactions for wallet %s, source has %d", existing, export.WalletUUID, len(export.Transactions))
+		}
+
+		stmt, err := tx.PrepareContext(ctx, QueryImportTransaction)
+		if err != nil {
+			return fmt.Errorf("failed to prepare transaction import: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, t := range export.Transactions[existing:] {
+			if _, err := stmt.ExecContext(ctx, walletID, t.OperationType, t.Amount, t.WalletStatus, t.CreatedAt); err != nil {
+				return fmt.Errorf("failed to import transaction: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ActivateWallet активирует копию кошелька, перенесенного с шарда source
+func (r *PostgresRepository) ActivateWallet(ctx context.Context, walletUUID, source string) error {
+	res, err := execSQL(ctx, r.db, "ActivateWallet", QueryActivateWallet, walletUUID, source, tenantOrDefault(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to activate wallet: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to activate wallet: %w", err)
+	} else if affected == 0 {
+		return ErrWalletNotFound
+	}
+	return nil
+}
+
+// SetWalletForwarding записывает на этом шарде указатель на шард, где живет кошелек
+func (r *PostgresRepository) SetWalletForwarding(ctx context.Context, walletUUID, shard string) error {
+	if _, err := execSQL(ctx, r.db, "SetWalletForwarding", QuerySetWalletForwarding, walletUUID, shard, tenantOrDefault(ctx)); err != nil {
+		return fmt.Errorf("failed to set wallet forwarding: %w", err)
+	}
+	return nil
+}
+
+// ListWalletUUIDs возвращает пачку активных кошельков шарда с ID больше afterID
+func (r *PostgresRepository) ListWalletUUIDs(ctx context.Context, afterID int64, limit int) ([]WalletRef, error) {
+	rows, err := querySQL(ctx, r.db, "ListWalletUUIDs", QueryListWalletUUIDs, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	defer rows.Close()
+
+	var refs []WalletRef
+	for rows.Next() {
+		var ref WalletRef
+		if err := rows.Scan(&ref.ID, &ref.UUID, &ref.TenantID); err != nil {
+			return nil, fmt.Errorf("failed to scan wallet: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
@@ -0,0 +1,422 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тесты запросов PostgreSQL, которые не покрывает общий набор. Выполняются, только если задана TEST_DATABASE_URL
+
+func newTestPostgres(t *testing.T) (*PostgresRepository, *sql.DB) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	conn, err := Open(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, RunMigrations(context.Background(), conn))
+	return NewPostgresRepository(conn), conn
+}
+
+func Test_PostgresBalanceSnapshots(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("late commit", func(t *testing.T) {
+		repo, conn := newTestPostgres(t)
+		wallet := uuid.NewString()
+		require.NoError(t, repo.DepositMoney(ctx, wallet, 100))
+
+		// Транзакция с меньшим id коммитится после прогона снимков
+		late, err := conn.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer late.Rollback()
+		require.NoError(t, repo.depositInTx(ctx, late, wallet, 50))
+		require.NoError(t, repo.DepositMoney(ctx, wallet, 25))
+
+		_, err = repo.CreateBalanceSnapshots(ctx)
+		require.NoError(t, err)
+		require.NoError(t, late.Commit())
+		_, err = repo.CreateBalanceSnapshots(ctx)
+		require.NoError(t, err)
+
+		balance, err := repo.GetBalanceAt(ctx, wallet, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(175), balance)
+	})
+
+	t.Run("another instance holds the lock", func(t *testing.T) {
+		repo, conn := newTestPostgres(t)
+		require.NoError(t, repo.DepositMoney(ctx, uuid.NewString(), 100))
+
+		other, err := conn.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer other.Rollback()
+		var locked bool
+		require.NoError(t, other.QueryRowContext(ctx, QueryTryAdvisoryXactLock, snapshotsLockKey).Scan(&locked))
+		require.True(t, locked)
+
+		created, err := repo.CreateBalanceSnapshots(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, created)
+
+		require.NoError(t, other.Rollback())
+		created, err = repo.CreateBalanceSnapshots(ctx)
+		require.NoError(t, err)
+		assert.NotZero(t, created)
+	})
+}
+
+func Test_PostgresReconciliationLock(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestPostgres(t)
+	other, _ := newTestPostgres(t)
+
+	unlock, locked, err := repo.TryLockReconciliation(ctx)
+	require.NoError(t, err)
+	require.True(t, locked)
+
+	// Другая реплика не ждет блокировку, а пропускает прогон
+	_, locked, err = other.TryLockReconciliation(ctx)
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	unlock()
+	unlockOther, locked, err := other.TryLockReconciliation(ctx)
+	require.NoError(t, err)
+	assert.True(t, locked)
+	unlockOther()
+}
+
+func Test_PostgresHotWallet(t *testing.T) {
+	ctx := context.Background()
+	repo, conn := newTestPostgres(t)
+
+	shardBalances := func(t *testing.T, wallet string) (int64, []int64) {
+		var walletBalance int64
+		require.NoError(t, conn.QueryRowContext(ctx, `SELECT balance FROM wallets WHERE uuid = $1`, wallet).Scan(&walletBalance))
+		rows, err := conn.QueryContext(ctx, `
+			SELECT s.balance FROM wallet_shards s JOIN wallets w ON w.wallet_id = s.wallet_id
+			WHERE w.uuid = $1 ORDER BY s.shard_no`, wallet)
+		require.NoError(t, err)
+		defer rows.Close()
+		var shards []int64
+		for rows.Next() {
+			var balance int64
+			require.NoError(t, rows.Scan(&balance))
+			shards = append(shards, balance)
+		}
+		require.NoError(t, rows.Err())
+		return walletBalance, shards
+	}
+
+	t.Run("split and aggregate", func(t *testing.T) {
+		wallet := uuid.NewString()
+		require.NoError(t, repo.DepositMoney(ctx, wallet, 100))
+		require.NoError(t, repo.SetShardCount(ctx, wallet, 4))
+
+		walletBalance, shards := shardBalances(t, wallet)
+		assert.Equal(t, int64(100), walletBalance)
+		assert.Equal(t, []int64{0, 0, 0, 0}, shards)
+
+		for range 20 {
+			require.NoError(t, repo.DepositMoney(ctx, wallet, 10))
+		}
+		walletBalance, shards = shardBalances(t, wallet)
+		assert.Equal(t, int64(100), walletBalance, "deposits go to shards")
+		var inShards int64
+		for _, balance := range shards {
+			inShards += balance
+		}
+		assert.Equal(t, int64(200), inShards)
+
+		balance, err := repo.GetBalance(ctx, wallet)
+		require.NoError(t, err)
+		assert.Equal(t, int64(300), balance)
+	})
+
+	t.Run("withdraw across shards", func(t *testing.T) {
+		wallet := uuid.NewString()
+		require.NoError(t, repo.DepositMoney(ctx, wallet, 50))
+		require.NoError(t, repo.SetShardCount(ctx, wallet, 3))
+		for range 10 {
+			require.NoError(t, repo.DepositMoney(ctx, wallet, 25))
+		}
+
+		assert.ErrorIs(t, repo.WithdrawMoney(ctx, wallet, 301), ErrInsufficientFunds)
+		require.NoError(t, repo.WithdrawMoney(ctx, wallet, 280))
+
+		walletBalance, shards := shardBalances(t, wallet)
+		assert.Zero(t, walletBalance, "wallet row is drained first")
+		for _, balance := range shards {
+			assert.GreaterOrEqual(t, balance, int64(0))
+		}
+		balance, err := repo.GetBalance(ctx, wallet)
+		require.NoError(t, err)
+		assert.Equal(t, int64(20), balance)
+	})
+
+	t.Run("collapse keeps balance", func(t *testing.T) {
+		wallet := uuid.NewString()
+		assert.ErrorIs(t, repo.SetShardCount(ctx, wallet, 2), ErrWalletNotFound)
+		require.NoError(t, repo.DepositMoney(ctx, wallet, 10))
+		require.NoError(t, repo.SetShardCount(ctx, wallet, 8))
+		for range 8 {
+			require.NoError(t, repo.DepositMoney(ctx, wallet, 5))
+		}
+
+		require.NoError(t, repo.SetShardCount(ctx, wallet, 1))
+		walletBalance, shards := shardBalances(t, wallet)
+		assert.Equal(t, int64(50), walletBalance)
+		assert.Empty(t, shards)
+
+		assert.ErrorIs(t, repo.SetShardCount(ctx, wallet, 0), ErrInvalidShardCount)
+		assert.ErrorIs(t, repo.SetShardCount(ctx, wallet, MaxShardCount+1), ErrInvalidShardCount)
+	})
+}
+
+func Test_PostgresAuditIntent(t *testing.T) {
+	ctx := context.Background()
+	repo, conn := newTestPostgres(t)
+
+	rec := AuditRecord{Operator: "alice", Action: "deposit", WalletUUID: uuid.NewString(), Amount: 100}
+	require.NoError(t, repo.StartAudit(ctx, &rec))
+	require.NotZero(t, rec.ID)
+
+	var result, tenantID string
+	var finished *time.Time
+	query := `SELECT result, finished_at FROM admin_audit_log WHERE id = $1`
+	require.NoError(t, conn.QueryRowContext(ctx, query, rec.ID).Scan(&result, &finished))
+	assert.Equal(t, AuditPending, result)
+	assert.Nil(t, finished)
+
+	// Действия с одним UUID у разных арендаторов различаются по арендатору
+	tenantQuery := `SELECT tenant_id FROM admin_audit_log WHERE id = $1`
+	require.NoError(t, conn.QueryRowContext(ctx, tenantQuery, rec.ID).Scan(&tenantID))
+	assert.Equal(t, DefaultTenant, tenantID)
+	acme := AuditRecord{Operator: "alice", Action: "deposit", WalletUUID: rec.WalletUUID, Amount: 100}
+	require.NoError(t, repo.StartAudit(WithTenant(ctx, "acme"), &acme))
+	assert.Equal(t, "acme", acme.TenantID)
+	require.NoError(t, conn.QueryRowContext(ctx, tenantQuery, acme.ID).Scan(&tenantID))
+	assert.Equal(t, "acme", tenantID)
+
+	rec.Result, rec.Error = AuditFailure, ErrInsufficientFunds.Error()
+	require.NoError(t, repo.FinishAudit(ctx, &rec))
+	require.NoError(t, conn.QueryRowContext(ctx, query, rec.ID).Scan(&result, &finished))
+	assert.Equal(t, AuditFailure, result)
+	assert.NotNil(t, finished)
+
+	// Результат записывается один раз
+	rec.Result = AuditSuccess
+	assert.Error(t, repo.FinishAudit(ctx, &rec))
+}
+
+func Test_PostgresTenantIsolation(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestPostgres(t)
+
+	// Арендаторы acme и globex заводят кошелек с одним UUID: это разные кошельки
+	wallet := uuid.NewString()
+	acme, globex := WithTenant(ctx, "acme"), WithTenant(ctx, "globex")
+	require.NoError(t, repo.DepositMoney(acme, wallet, 100))
+	require.NoError(t, repo.DepositMoney(globex, wallet, 7))
+
+	balance := func(ctx context.Context) int64 {
+		balance, err := repo.GetBalance(ctx, wallet)
+		require.NoError(t, err)
+		return balance
+	}
+
+	t.Run("balance and history", func(t *testing.T) {
+		assert.Equal(t, int64(100), balance(acme))
+		assert.Equal(t, int64(7), balance(globex))
+		_, err := repo.GetBalance(WithTenant(ctx, "initech"), wallet)
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+		_, err = repo.GetBalance(ctx, wallet)
+		assert.ErrorIs(t, err, ErrWalletNotFound, "system context addresses the default tenant")
+
+		at, err := repo.GetBalanceAt(globex, wallet, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), at)
+	})
+
+	t.Run("withdraw", func(t *testing.T) {
+		assert.ErrorIs(t, repo.WithdrawMoney(globex, wallet, 50), ErrInsufficientFunds)
+		require.NoError(t, repo.WithdrawMoney(acme, wallet, 50))
+		assert.Equal(t, int64(50), balance(acme))
+		assert.Equal(t, int64(7), balance(globex))
+	})
+
+	t.Run("freeze and info", func(t *testing.T) {
+		require.NoError(t, repo.FreezeWallet(acme, wallet, "review"))
+		assert.ErrorIs(t, repo.DepositMoney(acme, wallet, 1), ErrWalletFrozen)
+		require.NoError(t, repo.DepositMoney(globex, wallet, 1))
+
+		info, err := repo.GetWalletInfo(globex, wallet)
+		require.NoError(t, err)
+		assert.Equal(t, "globex", info.TenantID)
+		assert.False(t, info.Frozen)
+		require.NoError(t, repo.UnfreezeWallet(acme, wallet))
+	})
+
+	t.Run("hot wallet", func(t *testing.T) {
+		require.NoError(t, repo.SetShardCount(globex, wallet, 4))
+		info, err := repo.GetWalletInfo(acme, wallet)
+		require.NoError(t, err)
+		assert.Equal(t, 1, info.ShardCount)
+		require.NoError(t, repo.SetShardCount(globex, wallet, 1))
+	})
+
+	t.Run("risk stats", func(t *testing.T) {
+		windows := RiskWindows{History: time.Hour, Recent: time.Hour, Client: time.Hour}
+		stats, err := repo.GetRiskStats(acme, wallet, "", windows)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), stats.TxCount)
+
+		stats, err = repo.GetRiskStats(WithTenant(ctx, "initech"), wallet, "", windows)
+		require.NoError(t, err)
+		assert.True(t, stats.WalletNew)
+	})
+
+	t.Run("location", func(t *testing.T) {
+		found, _, err := repo.WalletLocation(acme, wallet)
+		require.NoError(t, err)
+		assert.True(t, found)
+		found, _, err = repo.WalletLocation(WithTenant(ctx, "initech"), wallet)
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("transfers and ledger", func(t *testing.T) {
+		other := uuid.NewString()
+		transfer, err := repo.Transfer(acme, wallet, other, 10)
+		require.NoError(t, err)
+		_, err = repo.GetTransfer(globex, transfer.ID)
+		assert.ErrorIs(t, err, ErrTransferNotFound)
+		_, err = repo.GetTransfer(ctx, transfer.ID)
+		require.NoError(t, err, "system context sees every tenant")
+
+		_, err = repo.GetBalance(globex, other)
+		assert.ErrorIs(t, err, ErrWalletNotFound, "transfer credits the sender tenant's wallet")
+
+		txs, err := repo.ListTransactionsBetween(globex, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		var amounts []int64
+		for _, tx := range txs {
+			if tx.WalletUUID == wallet || tx.WalletUUID == other {
+				amounts = append(amounts, tx.Amount)
+			}
+		}
+		assert.Equal(t, []int64{7, 1}, amounts)
+	})
+}
+
+// fixedFee - комиссия fee за любое снятие, зачисляемая на кошелек wallet
+type fixedFee struct {
+	fee    int64
+	wallet string
+}
+
+func (f fixedFee) WithdrawFee(tenantID string, amount int64) (int64, string) {
+	return f.fee, f.wallet
+}
+
+func Test_PostgresFeeAccruals(t *testing.T) {
+	repo, conn := newTestPostgres(t)
+	ctx := WithTenant(context.Background(), "acme")
+	feeWallet, wallet := uuid.NewString(), uuid.NewString()
+	repo.Fees = fixedFee{fee: 5, wallet: feeWallet}
+
+	require.NoError(t, repo.CreateWallet(ctx, feeWallet))
+	require.NoError(t, repo.FreezeWallet(ctx, feeWallet, "fees only"))
+	require.NoError(t, repo.DepositMoney(ctx, wallet, 100))
+
+	// Снятие не трогает кошелек комиссий, а только добавляет начисление
+	require.NoError(t, repo.WithdrawMoney(ctx, wallet, 10))
+	require.NoError(t, repo.WithdrawMoney(ctx, wallet, 20))
+	assert.ErrorIs(t, repo.WithdrawMoney(ctx, wallet, 56), ErrInsufficientFunds, "fee is charged on top of the amount")
+
+	balance, err := repo.GetBalance(ctx, wallet)
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), balance)
+	balance, err = repo.GetBalance(ctx, feeWallet)
+	require.NoError(t, err)
+	assert.Zero(t, balance)
+
+	var unsettled int64
+	query := `SELECT COALESCE(SUM(amount), 0) FROM fee_accruals WHERE fee_wallet = $1 AND settled_at IS NULL`
+	require.NoError(t, conn.QueryRowContext(ctx, query, feeWallet).Scan(&unsettled))
+	assert.Equal(t, int64(10), unsettled)
+
+	// Зачисление пачкой: одна транзакция на кошелек комиссий, в том числе замороженный
+	for {
+		settled, err := repo.SettleFeeAccruals(ctx, 1000)
+		require.NoError(t, err)
+		if settled == 0 {
+			break
+		}
+	}
+	balance, err = repo.GetBalance(ctx, feeWallet)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), balance)
+	require.NoError(t, conn.QueryRowContext(ctx, query, feeWallet).Scan(&unsettled))
+	assert.Zero(t, unsettled)
+
+	var deposits int
+	require.NoError(t, conn.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM transactions t JOIN wallets w ON w.wallet_id = t.wallet_id
+		WHERE w.uuid = $1 AND w.tenant_id = 'acme'`, feeWallet).Scan(&deposits))
+	assert.Equal(t, 1, deposits)
+}
+
+func Test_PostgresNotifyBalanceChanges(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestPostgres(t)
+
+	changes := make(chan string, 16)
+	stop, err := ListenBalanceChanges(os.Getenv("TEST_DATABASE_URL"), func(walletUUID string) { changes <- walletUUID }, func() {})
+	require.NoError(t, err)
+	defer stop()
+
+	silent, notified, hot := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	ours := map[string]bool{silent: true, notified: true, hot: true}
+
+	// received - кошельки теста, уведомления о которых пришли за время ожидания. База может быть общей
+	// с тестами других пакетов, их уведомления пропускаются
+	received := func(wait time.Duration) map[string]bool {
+		got := map[string]bool{}
+		timeout := time.After(wait)
+		for {
+			select {
+			case walletUUID := <-changes:
+				if ours[walletUUID] {
+					got[walletUUID] = true
+				}
+			case <-timeout:
+				return got
+			}
+		}
+	}
+
+	require.NoError(t, repo.DepositMoney(ctx, silent, 10))
+	require.NoError(t, repo.DepositMoney(ctx, notified, 10))
+	require.NoError(t, repo.DepositMoney(ctx, hot, 10))
+	require.NoError(t, repo.SetShardCount(ctx, hot, 4))
+	assert.Empty(t, received(500*time.Millisecond), "no NOTIFY unless a cache listens")
+
+	repo.NotifyBalanceChanges = true
+	require.NoError(t, repo.DepositMoney(ctx, notified, 10))
+	require.NoError(t, repo.DepositMoney(ctx, hot, 5))
+	assert.Equal(t, map[string]bool{notified: true, hot: true}, received(time.Second), "deposits to a wallet row and to a shard")
+
+	require.NoError(t, repo.WithdrawMoney(ctx, notified, 5))
+	require.NoError(t, repo.WithdrawMoney(ctx, hot, 15))
+	assert.Equal(t, map[string]bool{notified: true, hot: true}, received(time.Second), "withdraws from a wallet row and from shards")
+}
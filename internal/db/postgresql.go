@@ -3,30 +3,130 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 	"wallet-service/internal/logger"
 )
 
-func InitDB(dbHost, dbPort, dbUser, dbPassword, dbName string) (*sql.DB, error) {
+// ConnConfig - параметры подключения к PostgreSQL
+type ConnConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	// SSLMode - disable, require, verify-ca или verify-full, SSLRootCert - корневой сертификат для verify-*
+	SSLMode     string
+	SSLRootCert string
+	// ConnectTimeout - ожидание установки соединения, округляется до секунд (0 - без ограничения)
+	ConnectTimeout time.Duration
+}
+
+// PoolConfig - размер пула соединений и время жизни соединений, нулевые значения - без ограничения
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// Apply настраивает пул соединений db
+func (p PoolConfig) Apply(db *sql.DB) {
+	db.SetMaxOpenConns(p.MaxOpenConns)
+	db.SetMaxIdleConns(p.MaxIdleConns)
+	db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+}
+
+// ConnString формирует строку подключения к PostgreSQL
+func ConnString(cfg ConnConfig) string {
+	sslMode := cfg.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	params := []string{
+		"host=" + connValue(cfg.Host),
+		"port=" + connValue(cfg.Port),
+		"user=" + connValue(cfg.User),
+		"password=" + connValue(cfg.Password),
+		"dbname=" + connValue(cfg.Name),
+		"sslmode=" + connValue(sslMode),
+	}
+	if cfg.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+connValue(cfg.SSLRootCert))
+	}
+	if cfg.ConnectTimeout > 0 {
+		// connect_timeout задается в секундах, меньшее значение libpq не поддерживает
+		seconds := int(math.Ceil(cfg.ConnectTimeout.Seconds()))
+		params = append(params, "connect_timeout="+strconv.Itoa(seconds))
+	}
+	return strings.Join(params, " ")
+}
+
+// connValue экранирует значение строки подключения: пароль может содержать пробелы и кавычки
+func connValue(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + value + "'"
+}
+
+// InitDB подключается к основной базе и к репликам только для чтения по строкам подключения replicaDSNs.
+// Недоступная при старте реплика не мешает запуску: она не используется, пока проверка отставания не пройдет.
+// Пулы соединений основной базы и реплик настраиваются по pool
+func InitDB(conn ConnConfig, pool PoolConfig, replicaDSNs ...string) (*sql.DB, []*sql.DB, error) {
 
 	// Формируем строку подключения
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+	connStr := ConnString(conn)
 
 	// Подключаемся к базе данных
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		logger.Log.Errorf("Failed to connect to data base: %v", err)
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	pool.Apply(db)
+
 	// Проверяем соединение
 	if err = db.Ping(); err != nil {
 		logger.Log.Errorf("Failed to ping database: %v", err)
-		return nil, fmt.Errorf("failed to ping database: %v", err)
+		return nil, nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
 	logger.Log.Println("Successfully connected to the database")
-	return db, nil
 
+	var replicas []*sql.DB
+	for i, dsn := range replicaDSNs {
+		replica, err := sql.Open("postgres", dsn)
+		if err != nil {
+			db.Close()
+			for _, opened := range replicas {
+				opened.Close()
+			}
+			logger.Log.Errorf("Failed to connect to replica %d: %v", i, err)
+			return nil, nil, fmt.Errorf("failed to connect to replica %d: %w", i, err)
+		}
+		pool.Apply(replica)
+		if err := replica.Ping(); err != nil {
+			logger.Log.Warnf("Replica %d is not reachable yet: %v", i, err)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	return db, replicas, nil
+
+}
+
+// Open подключается к базе по строке подключения и проверяет соединение
+func Open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return db, nil
 }
@@ -1,10 +1,45 @@
 package db
 
 const (
-	//создание кошелька
+	//блокировка на время миграций (на уровне сессии, снимается QueryAdvisoryUnlock или при разрыве соединения)
+	QueryAdvisoryLock = `
+		SELECT pg_advisory_lock($1)
+	`
+
+	QueryAdvisoryUnlock = `
+		SELECT pg_advisory_unlock($1)
+	`
+
+	//блокировка на уровне сессии без ожидания: false, если ее держит другая сессия
+	QueryTryAdvisoryLock = `
+		SELECT pg_try_advisory_lock($1)
+	`
+
+	//версия схемы из таблицы golang-migrate (PostgreSQL и SQLite)
+	QueryGetSchemaVersion = `
+		SELECT version, dirty
+		FROM schema_migrations
+		LIMIT 1
+	`
+
+	//ограничение ожидания блокировок в текущей транзакции (аналог SET LOCAL lock_timeout)
+	QuerySetLockTimeout = `
+		SELECT set_config('lock_timeout', $1, true)
+	`
+
+	//отставание реплики в секундах; 0, если все полученные изменения уже применены
+	QueryGetReplicaLag = `
+		SELECT CASE
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END
+	`
+
+	//создание кошелька арендатора $3. UUID уникален в пределах арендатора
 	QueryCreateWallet = `
-		INSERT INTO wallets (uuid, balance) 
-		VALUES ($1, $2)
+		INSERT INTO wallets (uuid, balance, tenant_id) 
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tenant_id, uuid) DO NOTHING
 	`
 
 	//проверка существует ли кошелек по uuid
@@ -12,44 +47,49 @@ const (
 		SELECT EXISTS (
 			SELECT 1 
 			FROM wallets 
-			WHERE uuid = $1 AND deleted_at IS NULL
+			WHERE uuid = $1 AND deleted_at IS NULL AND moved_to IS NULL AND tenant_id = $2
 		)
 	`
 
-	//получение баланса с блокировкой строки
+	//получение баланса и числа частей с блокировкой строки.
+	//FOR NO KEY UPDATE не мешает проверке внешнего ключа при записи транзакций горячего кошелька
 	QueryGetWalletForUpdate = `
-		SELECT balance 
+		SELECT balance, shard_count, frozen_at IS NOT NULL
 		FROM wallets 
-		WHERE uuid = $1 AND deleted_at IS NULL
-		FOR UPDATE
+		WHERE uuid = $1 AND deleted_at IS NULL AND moved_to IS NULL AND tenant_id = $2
+		FOR NO KEY UPDATE
 	`
 
 	//обновление баланса
 	QueryUpdateBalance = `
 		UPDATE wallets 
 		SET balance = balance + $1, updated_at = NOW() 
-		WHERE uuid = $2 AND deleted_at IS NULL
+		WHERE uuid = $2 AND deleted_at IS NULL AND moved_to IS NULL AND tenant_id = $3
 	`
 
-	//получение баланса
+	//получение баланса (у горячих кошельков - вместе с частями)
 	QueryGetBalance = `
-		SELECT balance 
-		FROM wallets 
-		WHERE uuid = $1 AND deleted_at IS NULL
+		SELECT w.balance + COALESCE((
+			SELECT SUM(s.balance)
+			FROM wallet_shards s
+			WHERE s.wallet_id = w.wallet_id
+		), 0)
+		FROM wallets w
+		WHERE w.uuid = $1 AND w.deleted_at IS NULL AND w.moved_to IS NULL AND w.tenant_id = $2
 	`
 
 	//снятие средств
 	QueryWithdraw = `
 		UPDATE wallets 
 		SET balance = balance - $1, updated_at = NOW() 
-		WHERE uuid = $2 AND deleted_at IS NULL
+		WHERE uuid = $2 AND deleted_at IS NULL AND moved_to IS NULL AND tenant_id = $3
 	`
 
 	//получение ID кошелька по UUID (для транзакций)
 	QueryGetWalletID = `
 	SELECT wallet_id 
 	FROM wallets 
-	WHERE uuid = $1 AND deleted_at IS NULL
+	WHERE uuid = $1 AND deleted_at IS NULL AND moved_to IS NULL AND tenant_id = $2
 	`
 
 	//создание записи транзакции
@@ -57,4 +97,573 @@ const (
 		INSERT INTO transactions (wallet_id, operation_type, amount, wallet_status) 
 		VALUES ($1, $2, $3, $4)
 	`
+
+	//ID и число частей кошелька без блокировки (выбор пути депозита)
+	QueryGetWalletShardCount = `
+		SELECT wallet_id, shard_count, frozen_at IS NOT NULL
+		FROM wallets
+		WHERE uuid = $1 AND deleted_at IS NULL AND moved_to IS NULL AND tenant_id = $2
+		FOR KEY SHARE
+	`
+
+	//депозит в часть горячего кошелька. Блокируется только строка части
+	QueryDepositToShard = `
+		UPDATE wallet_shards
+		SET balance = balance + $1, updated_at = NOW()
+		WHERE wallet_id = $2 AND shard_no = $3
+	`
+
+	//части горячего кошелька с блокировкой (для снятия средств)
+	QueryGetWalletShardsForUpdate = `
+		SELECT shard_no, balance
+		FROM wallet_shards
+		WHERE wallet_id = $1
+		ORDER BY shard_no
+		FOR UPDATE
+	`
+
+	//снятие средств с части горячего кошелька
+	QueryWithdrawFromShard = `
+		UPDATE wallet_shards
+		SET balance = balance - $1, updated_at = NOW()
+		WHERE wallet_id = $2 AND shard_no = $3
+	`
+
+	//перенос балансов всех частей в строку кошелька и новое число частей
+	QueryCollapseWalletShards = `
+		WITH removed AS (
+			DELETE FROM wallet_shards
+			WHERE wallet_id = $1
+			RETURNING balance
+		)
+		UPDATE wallets
+		SET balance = balance + COALESCE((SELECT SUM(balance) FROM removed), 0),
+			shard_count = $2, updated_at = NOW()
+		WHERE wallet_id = $1
+	`
+
+	//создание пустых частей 0..$2-1
+	QueryCreateWalletShards = `
+		INSERT INTO wallet_shards (wallet_id, shard_no)
+		SELECT $1, generate_series(0, $2 - 1)
+	`
+
+	//пачка кошельков с балансом, пересчитанным по журналу транзакций (для сверки).
+	//Обычный SELECT без FOR UPDATE: сверка не должна блокировать кошельки
+	QueryGetWalletLedgerBatch = `
+		SELECT w.wallet_id, w.uuid, w.balance, COALESCE(t.ledger_balance, 0)
+		FROM (
+			SELECT wallet_id, uuid, balance + COALESCE((
+				SELECT SUM(s.balance)
+				FROM wallet_shards s
+				WHERE s.wallet_id = wallets.wallet_id
+			), 0) AS balance
+			FROM wallets
+			WHERE wallet_id > $1 AND deleted_at IS NULL AND moved_to IS NULL
+			ORDER BY wallet_id
+			LIMIT $2
+		) w
+		LEFT JOIN LATERAL (
+			SELECT SUM(
+				CASE operation_type
+					WHEN 'DEPOSIT' THEN amount
+					WHEN 'WITHDRAW' THEN -amount
+					ELSE 0
+				END
+			) AS ledger_balance
+			FROM transactions
+			WHERE wallet_id = w.wallet_id
+		) t ON TRUE
+		ORDER BY w.wallet_id
+	`
+
+	//транзакции журнала за период (для сверки с файлами расчетов). Журнал перенесенного
+	//кошелька скопирован на шард, где он живет, и учитывается там
+	QueryListTransactionsBetween = `
+		SELECT t.id, w.uuid, t.operation_type, t.amount, t.created_at
+		FROM transactions t
+		JOIN wallets w ON w.wallet_id = t.wallet_id
+		WHERE t.created_at BETWEEN $1 AND $2
+		  AND ($3::TEXT IS NULL OR w.tenant_id = $3)
+		  AND w.moved_to IS NULL
+		ORDER BY t.created_at, t.id
+	`
+
+	//создание прогона сверки с файлом расчетов
+	QueryCreateSettlementRun = `
+		INSERT INTO settlement_runs (source, format, match_rule, date_window_seconds, period_from, period_to,
+			matched_count, unmatched_file_count, unmatched_ledger_count, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`
+
+	//строка результата сверки с файлом расчетов
+	QueryCreateSettlementItem = `
+		INSERT INTO settlement_items (run_id, status, line_no, reference, amount, operation_type, occurred_at, transaction_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	//прогон сверки по ID
+	QueryGetSettlementRun = `
+		SELECT id, source, format, match_rule, date_window_seconds, period_from, period_to,
+			matched_count, unmatched_file_count, unmatched_ledger_count, created_at
+		FROM settlement_runs
+		WHERE id = $1 AND ($2::TEXT IS NULL OR tenant_id = $2)
+	`
+
+	//последние прогоны сверки
+	QueryListSettlementRuns = `
+		SELECT id, source, format, match_rule, date_window_seconds, period_from, period_to,
+			matched_count, unmatched_file_count, unmatched_ledger_count, created_at
+		FROM settlement_runs
+		WHERE ($2::TEXT IS NULL OR tenant_id = $2)
+		ORDER BY id DESC
+		LIMIT $1
+	`
+
+	//результаты прогона сверки
+	QueryListSettlementItems = `
+		SELECT status, line_no, reference, amount, operation_type, occurred_at, transaction_id
+		FROM settlement_items
+		WHERE run_id = $1
+		ORDER BY id
+	`
+
+	//баланс кошелька по журналу транзакций на момент времени (начальный баланс выписки)
+	QueryGetLedgerBalanceBefore = `
+		SELECT COALESCE(SUM(
+			CASE operation_type
+				WHEN 'DEPOSIT' THEN amount
+				WHEN 'WITHDRAW' THEN -amount
+				ELSE 0
+			END
+		), 0)
+		FROM transactions
+		WHERE wallet_id = $1 AND created_at < $2
+	`
+
+	//транзакции кошелька за период [from, to) в порядке проведения (для выписки)
+	QueryListWalletTransactions = `
+		SELECT id, operation_type, amount, created_at
+		FROM transactions
+		WHERE wallet_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at, id
+	`
+
+	//баланс на момент времени: последний снимок не позже $2 плюс транзакции после него
+	QueryGetBalanceAt = `
+		SELECT COALESCE(s.balance, 0) + COALESCE((
+			SELECT SUM(
+				CASE t.operation_type
+					WHEN 'DEPOSIT' THEN t.amount
+					WHEN 'WITHDRAW' THEN -t.amount
+					ELSE 0
+				END
+			)
+			FROM transactions t
+			WHERE t.wallet_id = $1
+				AND t.xact_id >= COALESCE(s.xact_bound, '0'::XID8)
+				AND t.created_at <= $2
+		), 0)
+		FROM (SELECT 1) d
+		LEFT JOIN LATERAL (
+			SELECT balance, xact_bound
+			FROM balance_snapshots
+			WHERE wallet_id = $1 AND as_of <= $2
+			ORDER BY as_of DESC, xact_bound DESC
+			LIMIT 1
+		) s ON TRUE
+	`
+
+	//блокировка до конца транзакции без ожидания: false, если ее держит другая сессия
+	QueryTryAdvisoryXactLock = `
+		SELECT pg_try_advisory_xact_lock($1)
+	`
+
+	//новые снимки для кошельков с транзакциями между границей предыдущего прогона и xmin текущего снимка БД:
+	//предыдущий снимок кошелька плюс сумма новых транзакций. Транзакции ниже xmin уже завершены,
+	//поэтому поздний коммит попадет в следующий прогон, а не будет пропущен
+	QueryCreateBalanceSnapshots = `
+		WITH bounds AS (
+			SELECT COALESCE((
+				SELECT xact_bound
+				FROM balance_snapshots
+				ORDER BY xact_bound DESC
+				LIMIT 1
+			), '0'::XID8) AS low,
+			pg_snapshot_xmin(pg_current_snapshot()) AS high
+		)
+		INSERT INTO balance_snapshots (wallet_id, balance, last_transaction_id, as_of, xact_bound)
+		SELECT t.wallet_id,
+			COALESCE(s.balance, 0) + SUM(
+				CASE t.operation_type
+					WHEN 'DEPOSIT' THEN t.amount
+					WHEN 'WITHDRAW' THEN -t.amount
+					ELSE 0
+				END
+			),
+			GREATEST(MAX(t.id), s.last_transaction_id),
+			GREATEST(MAX(t.created_at), s.as_of),
+			b.high
+		FROM transactions t
+		CROSS JOIN bounds b
+		LEFT JOIN LATERAL (
+			SELECT balance, last_transaction_id, as_of
+			FROM balance_snapshots bs
+			WHERE bs.wallet_id = t.wallet_id
+			ORDER BY bs.xact_bound DESC
+			LIMIT 1
+		) s ON TRUE
+		WHERE t.xact_id >= b.low AND t.xact_id < b.high
+		GROUP BY t.wallet_id, s.balance, s.last_transaction_id, s.as_of, b.high
+	`
+
+	//перевод между кошельками
+	QueryCreateTransfer = `
+		INSERT INTO transfers (id, from_wallet, to_wallet, amount, state, error, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING created_at, updated_at
+	`
+
+	//переход саги перевода из состояния $4 в $2
+	QueryUpdateTransferState = `
+		UPDATE transfers
+		SET state = $2, error = NULLIF($3, ''), updated_at = NOW()
+		WHERE id = $1 AND state = $4
+	`
+
+	//перевод по ID
+	QueryGetTransfer = `
+		SELECT id, from_wallet, to_wallet, amount, state, COALESCE(error, ''), created_at, updated_at, tenant_id
+		FROM transfers
+		WHERE id = $1 AND ($2::TEXT IS NULL OR tenant_id = $2)
+	`
+
+	//незавершенные саги переводов, не менявшиеся с $1
+	QueryListUnfinishedTransfers = `
+		SELECT id, from_wallet, to_wallet, amount, state, COALESCE(error, ''), created_at, updated_at, tenant_id
+		FROM transfers
+		WHERE state IN ('PENDING', 'DEBITED') AND updated_at < $1
+		ORDER BY updated_at
+		LIMIT $2
+	`
+
+	//отметка о зачислении или отмене зачисления перевода на шарде получателя
+	QueryCreateTransferCredit = `
+		INSERT INTO transfer_credits (transfer_id, status)
+		VALUES ($1, $2)
+		ON CONFLICT (transfer_id) DO NOTHING
+	`
+
+	//отметка о зачислении перевода
+	QueryGetTransferCredit = `
+		SELECT status
+		FROM transfer_credits
+		WHERE transfer_id = $1
+	`
+
+	//где находится кошелек: пустая строка - на этом шарде, иначе имя шарда, куда он перенесен
+	QueryGetWalletLocation = `
+		SELECT COALESCE(moved_to, '')
+		FROM wallets
+		WHERE uuid = $1 AND tenant_id = $2 AND deleted_at IS NULL
+	`
+
+	//блокировка кошелька на время переноса на другой шард. FOR UPDATE блокирует и запись транзакций
+	QueryGetWalletForMove = `
+		SELECT wallet_id, balance, frozen_at, frozen_reason, tenant_id
+		FROM wallets
+		WHERE uuid = $1 AND tenant_id = $2 AND deleted_at IS NULL AND moved_to IS NULL
+		FOR UPDATE
+	`
+
+	//все транзакции кошелька в порядке проведения (для переноса)
+	QueryListAllWalletTransactions = `
+		SELECT operation_type, amount, wallet_status, created_at
+		FROM transactions
+		WHERE wallet_id = $1
+		ORDER BY created_at, id
+	`
+
+	//отметка о переносе кошелька на другой шард
+	QueryMarkWalletMoved = `
+		UPDATE wallets
+		SET moved_to = $2, updated_at = NOW()
+		WHERE wallet_id = $1
+	`
+
+	//перенесенный кошелек на шарде назначения. Строка создается неактивной (moved_to указывает
+	//на шард-источник) и активируется после фиксации переноса на источнике.
+	//Ранее перенесенный отсюда кошелек переиспользует свою строку, активный - не перезаписывается
+	QueryImportWallet = `
+		INSERT INTO wallets (uuid, balance, moved_to, frozen_at, frozen_reason, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant_id, uuid) DO UPDATE
+		SET balance = EXCLUDED.balance, moved_to = EXCLUDED.moved_to, shard_count = 1,
+			frozen_at = EXCLUDED.frozen_at, frozen_reason = EXCLUDED.frozen_reason,
+			tenant_id = EXCLUDED.tenant_id, updated_at = NOW()
+		WHERE wallets.moved_to IS NOT NULL
+		RETURNING wallet_id
+	`
+
+	//число транзакций кошелька
+	QueryCountWalletTransactions = `
+		SELECT COUNT(*)
+		FROM transactions
+		WHERE wallet_id = $1
+	`
+
+	//перенос транзакции с сохранением даты проведения
+	QueryImportTransaction = `
+		INSERT INTO transactions (wallet_id, operation_type, amount, wallet_status, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	//активация перенесенного кошелька
+	QueryActivateWallet = `
+		UPDATE wallets
+		SET moved_to = NULL, updated_at = NOW()
+		WHERE uuid = $1 AND tenant_id = $3 AND moved_to = $2
+	`
+
+	//указатель на шард кошелька на его домашнем шарде
+	QuerySetWalletForwarding = `
+		INSERT INTO wallets (uuid, balance, moved_to, tenant_id)
+		VALUES ($1, 0, $2, $3)
+		ON CONFLICT (tenant_id, uuid) DO UPDATE
+		SET moved_to = EXCLUDED.moved_to, updated_at = NOW()
+		WHERE wallets.moved_to IS NOT NULL
+	`
+
+	//пачка UUID активных кошельков шарда (для перебалансировки шардов)
+	QueryListWalletUUIDs = `
+		SELECT wallet_id, uuid, tenant_id
+		FROM wallets
+		WHERE wallet_id > $1 AND deleted_at IS NULL AND moved_to IS NULL
+		ORDER BY wallet_id
+		LIMIT $2
+	`
+
+	//создание пустого кошелька арендатора $2. UUID уникален в пределах арендатора, в том числе среди удаленных
+	//и перенесенных кошельков
+	QueryCreateEmptyWallet = `
+		INSERT INTO wallets (uuid, balance, tenant_id)
+		VALUES ($1, 0, $2)
+		ON CONFLICT (tenant_id, uuid) DO NOTHING
+	`
+
+	//сведения о кошельке для администрирования
+	QueryGetWalletInfo = `
+		SELECT w.balance + COALESCE((SELECT SUM(s.balance) FROM wallet_shards s WHERE s.wallet_id = w.wallet_id), 0),
+			w.shard_count, w.frozen_at, COALESCE(w.frozen_reason, ''), w.created_at, w.tenant_id
+		FROM wallets w
+		WHERE w.uuid = $1 AND w.deleted_at IS NULL AND w.moved_to IS NULL AND w.tenant_id = $2
+	`
+
+	//блокировка кошелька перед заморозкой. FOR UPDATE конфликтует с FOR KEY SHARE депозитов
+	//в части горячего кошелька, поэтому заморозка дожидается их и следующие депозиты ее видят
+	QueryLockWalletForFreeze = `
+		SELECT wallet_id
+		FROM wallets
+		WHERE uuid = $1 AND deleted_at IS NULL AND moved_to IS NULL AND tenant_id = $2
+		FOR UPDATE
+	`
+
+	//заморозка кошелька ($2 - причина) или снятие заморозки ($2 = NULL)
+	QuerySetWalletFrozen = `
+		UPDATE wallets
+		SET frozen_at = CASE WHEN $2::TEXT IS NULL THEN NULL ELSE NOW() END,
+			frozen_reason = $2, updated_at = NOW()
+		WHERE wallet_id = $1
+	`
+
+	//запись в журнал действий операторов до выполнения действия
+	QueryCreateAuditRecord = `
+		INSERT INTO admin_audit_log (operator, action, wallet_uuid, amount, details, result, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	//результат действия оператора
+	QueryFinishAuditRecord = `
+		UPDATE admin_audit_log
+		SET result = $2, error = $3, finished_at = NOW()
+		WHERE id = $1 AND finished_at IS NULL
+	`
+
+	//корзина токенов ограничения частоты: пополнение по времени с последнего обращения ($3 токенов в секунду,
+	//не больше $2) и выдача токена, если он есть. Новая корзина создается полной за вычетом выданного токена
+	QueryTakeRateLimitToken = `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		VALUES ($1, $2::FLOAT8 - 1, TRUE, clock_timestamp())
+		ON CONFLICT (key) DO UPDATE SET (tokens, allowed, updated_at) = (
+			SELECT CASE WHEN r.tokens >= 1 THEN r.tokens - 1 ELSE r.tokens END, r.tokens >= 1, r.now
+			FROM (
+				SELECT LEAST($2::FLOAT8, b.tokens + GREATEST(EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at), 0) * $3::FLOAT8) AS tokens,
+					clock_timestamp() AS now
+			) r
+		)
+		RETURNING tokens, allowed
+	`
+
+	//удаление корзин, к которым не обращались дольше $1 секунд: они уже снова полные
+	QueryDeleteIdleRateLimitBuckets = `
+		DELETE FROM rate_limit_buckets
+		WHERE updated_at < clock_timestamp() - make_interval(secs => $1::FLOAT8)
+	`
+
+	//данные кошелька для правил риска: число, средняя и максимальная сумма транзакций за $2 секунд,
+	//сумма пополнений за $3 секунд, давность последнего пополнения и были ли у кошелька транзакции вообще
+	QueryGetWalletRiskStats = `
+		SELECT
+			COUNT(t.id),
+			COALESCE(AVG(t.amount), 0)::FLOAT8,
+			COALESCE(MAX(t.amount), 0),
+			COALESCE(SUM(t.amount) FILTER (
+				WHERE t.operation_type = 'DEPOSIT' AND t.created_at > NOW() - make_interval(secs => $3::FLOAT8)
+			), 0),
+			EXTRACT(EPOCH FROM NOW() - MAX(t.created_at) FILTER (WHERE t.operation_type = 'DEPOSIT'))::FLOAT8,
+			EXISTS (
+				SELECT 1
+				FROM transactions a
+				JOIN wallets aw ON aw.wallet_id = a.wallet_id
+				WHERE aw.uuid = $1 AND aw.tenant_id = $4
+			)
+		FROM transactions t
+		JOIN wallets w ON w.wallet_id = t.wallet_id
+		WHERE w.uuid = $1 AND w.tenant_id = $4 AND t.created_at > NOW() - make_interval(secs => $2::FLOAT8)
+	`
+
+	//число разных новых кошельков, с которыми клиент работал за $2 секунд
+	QueryCountClientNewWallets = `
+		SELECT COUNT(DISTINCT wallet_uuid)
+		FROM risk_decisions
+		WHERE client = $1 AND wallet_new AND created_at > NOW() - make_interval(secs => $2::FLOAT8)
+			AND ($3::TEXT IS NULL OR tenant_id = $3)
+	`
+
+	//запись решения проверки рисков
+	QueryCreateRiskDecision = `
+		INSERT INTO risk_decisions (id, wallet_uuid, client, operation_type, amount, outcome, score, rules, facts, wallet_new, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	//постановка операции на ручную проверку
+	QueryCreatePendingOperation = `
+		INSERT INTO pending_operations (id, status)
+		VALUES ($1, 'PENDING')
+	`
+
+	//операции на проверке в порядке поступления
+	QueryListPendingOperations = `
+		SELECT p.id, d.wallet_uuid, d.client, d.operation_type, d.amount, d.score, d.rules,
+			p.status, COALESCE(p.decided_by, ''), COALESCE(p.reason, ''), COALESCE(p.error, ''), p.created_at, p.decided_at, d.tenant_id
+		FROM pending_operations p
+		JOIN risk_decisions d ON d.id = p.id
+		WHERE p.status = $1
+		ORDER BY p.created_at, p.id
+		LIMIT $2
+	`
+
+	//операция на проверке по ID
+	QueryGetPendingOperation = `
+		SELECT p.id, d.wallet_uuid, d.client, d.operation_type, d.amount, d.score, d.rules,
+			p.status, COALESCE(p.decided_by, ''), COALESCE(p.reason, ''), COALESCE(p.error, ''), p.created_at, p.decided_at, d.tenant_id
+		FROM pending_operations p
+		JOIN risk_decisions d ON d.id = p.id
+		WHERE p.id = $1
+	`
+
+	//решение оператора; только для операций, которые еще ждут проверки
+	QueryDecidePendingOperation = `
+		UPDATE pending_operations
+		SET status = $2, decided_by = $3, reason = $4, decided_at = NOW()
+		WHERE id = $1 AND status = 'PENDING'
+	`
+
+	//одобренная операция не выполнена
+	QueryFailPendingOperation = `
+		UPDATE pending_operations
+		SET status = 'FAILED', error = $2
+		WHERE id = $1
+	`
+
+	//постановка асинхронной операции в очередь
+	QueryEnqueueOperation = `
+		INSERT INTO async_operations (id, wallet_uuid, operation_type, amount, status, tenant_id)
+		VALUES ($1, $2, $3, $4, 'PENDING', $5)
+		RETURNING created_at, updated_at
+	`
+
+	//очередная операция для воркера. Строки, заблокированные другими воркерами, пропускаются
+	QueryClaimOperation = `
+		SELECT id, wallet_uuid, operation_type, amount, attempts, created_at, tenant_id
+		FROM async_operations
+		WHERE status = 'PENDING' AND available_at <= NOW()
+		ORDER BY available_at, created_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`
+
+	//результат операции: COMPLETED или FAILED с причиной отказа
+	QueryFinishOperation = `
+		UPDATE async_operations
+		SET status = $2, error = $3, attempts = attempts + 1, updated_at = NOW(), completed_at = NOW()
+		WHERE id = $1
+		RETURNING attempts, updated_at, completed_at
+	`
+
+	//неудачная попытка: повтор через $3 секунд, после $4 попыток операция отклоняется
+	QueryRetryOperation = `
+		UPDATE async_operations
+		SET attempts = attempts + 1,
+			error = $2,
+			available_at = NOW() + make_interval(secs => $3::FLOAT8),
+			status = CASE WHEN attempts + 1 >= $4 THEN 'FAILED' ELSE status END,
+			completed_at = CASE WHEN attempts + 1 >= $4 THEN NOW() END,
+			updated_at = NOW()
+		WHERE id = $1 AND status = 'PENDING'
+		RETURNING status, attempts
+	`
+
+	//асинхронная операция по ID
+	QueryGetOperation = `
+		SELECT id, wallet_uuid, operation_type, amount, status, COALESCE(error, ''), attempts, created_at, updated_at, completed_at
+		FROM async_operations
+		WHERE id = $1 AND ($2::TEXT IS NULL OR tenant_id = $2)
+	`
+
+	//начисление комиссии за снятие с кошелька $3 на кошелек комиссий $2 арендатора $1
+	QueryCreateFeeAccrual = `
+		INSERT INTO fee_accruals (tenant_id, fee_wallet, wallet_id, amount)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	//пачка незачисленных комиссий, отмеченных зачисленными, с суммами по кошелькам комиссий.
+	//Начисления, которые зачисляет другая реплика, пропускаются
+	QuerySettleFeeAccruals = `
+		WITH batch AS (
+			SELECT id
+			FROM fee_accruals
+			WHERE settled_at IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), settled AS (
+			UPDATE fee_accruals f
+			SET settled_at = NOW()
+			FROM batch
+			WHERE f.id = batch.id
+			RETURNING f.tenant_id, f.fee_wallet, f.amount
+		)
+		SELECT tenant_id, fee_wallet, SUM(amount)::BIGINT, COUNT(*)
+		FROM settled
+		GROUP BY tenant_id, fee_wallet
+		ORDER BY tenant_id, fee_wallet
+	`
+
+	//уведомление об изменении баланса кошелька $2 в канал $1, доставляется после фиксации транзакции
+	QueryNotifyBalanceChanged = `
+		SELECT pg_notify($1, $2)
+	`
 )
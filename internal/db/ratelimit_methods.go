@@ -0,0 +1,27 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TakeRateLimitToken пополняет корзину key (rate токенов в секунду, не больше burst) и забирает из нее токен.
+// Возвращает оставшееся число токенов и был ли выдан токен
+func (r *PostgresRepository) TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (float64, bool, error) {
+	var tokens float64
+	var allowed bool
+	if err := scanRow(ctx, r.db, "TakeRateLimitToken", QueryTakeRateLimitToken, []any{key, burst, rate}, &tokens, &allowed); err != nil {
+		return 0, false, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return tokens, allowed, nil
+}
+
+// DeleteIdleRateLimitBuckets удаляет корзины, к которым не обращались дольше idle
+func (r *PostgresRepository) DeleteIdleRateLimitBuckets(ctx context.Context, idle time.Duration) (int64, error) {
+	res, err := execSQL(ctx, r.db, "DeleteIdleRateLimitBuckets", QueryDeleteIdleRateLimitBuckets, idle.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete idle rate limit buckets: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"wallet-service/internal/logger"
+)
+
+// reconcileLockKey - ключ pg_try_advisory_lock плановой сверки: сверку базы выполняет одна реплика
+const reconcileLockKey int64 = 0x7265636f6e63696c // "reconcil"
+
+// WalletLedger - баланс кошелька из таблицы wallets и баланс, пересчитанный по транзакциям
+type WalletLedger struct {
+	WalletID      int64
+	WalletUUID    string
+	Balance       int64
+	LedgerBalance int64
+}
+
+// GetWalletLedgerBatch возвращает до limit кошельков с wallet_id > afterID, упорядоченных по wallet_id.
+// Используется для постраничного обхода всех кошельков без блокировки таблицы.
+func (r *PostgresRepository) GetWalletLedgerBatch(ctx context.Context, afterID int64, limit int) ([]WalletLedger, error) {
+	log := logger.Log.WithContext(ctx)
+
+	log.Debugf("Fetching wallet ledger batch after wallet_id %d (limit %d)", afterID, limit)
+
+	rows, err := querySQL(ctx, r.db, "GetWalletLedgerBatch", QueryGetWalletLedgerBatch, afterID, limit)
+	if err != nil {
+		log.Errorf("Failed to fetch wallet ledger batch: %v", err)
+		return nil, fmt.Errorf("failed to fetch wallet ledger batch: %w", err)
+	}
+	defer rows.Close()
+
+	batch := make([]WalletLedger, 0, limit)
+	for rows.Next() {
+		var wl WalletLedger
+		if err := rows.Scan(&wl.WalletID, &wl.WalletUUID, &wl.Balance, &wl.LedgerBalance); err != nil {
+			log.Errorf("Failed to scan wallet ledger row: %v", err)
+			return nil, fmt.Errorf("failed to scan wallet ledger row: %w", err)
+		}
+		batch = append(batch, wl)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorf("Failed to iterate wallet ledger batch: %v", err)
+		return nil, fmt.Errorf("failed to iterate wallet ledger batch: %w", err)
+	}
+
+	return batch, nil
+}
+
+// TryLockReconciliation берет блокировку плановой сверки без ожидания. Сверка читает кошельки
+// несколькими запросами, поэтому блокировка сессионная и держится на отдельном соединении до вызова unlock.
+// locked = false, если сверка уже идет на другой реплике
+func (r *PostgresRepository) TryLockReconciliation(ctx context.Context) (unlock func(), locked bool, err error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get a connection for reconciliation lock: %w", err)
+	}
+
+	if err := scanRow(ctx, conn, "TryAdvisoryLock", QueryTryAdvisoryLock, []any{reconcileLockKey}, &locked); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to acquire reconciliation lock: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	return func() {
+		if _, err := execSQL(context.Background(), conn, "AdvisoryUnlock", QueryAdvisoryUnlock, reconcileLockKey); err != nil {
+			logger.Log.Errorf("Failed to release reconciliation lock: %v", err)
+			// Соединение с блокировкой не возвращается в пул: блокировка снимется при его закрытии
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, true, nil
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/metrics"
+)
+
+// ReplicaSet - реплики только для чтения. Реплика используется, пока ее отставание
+// от основной базы не превышает maxLag; отставание проверяется в фоне раз в интервал
+type ReplicaSet struct {
+	replicas []*replica
+	maxLag   time.Duration
+	next     atomic.Uint64
+}
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+func NewReplicaSet(dbs []*sql.DB, maxLag time.Duration) *ReplicaSet {
+	s := &ReplicaSet{maxLag: maxLag}
+	for i, db := range dbs {
+		s.replicas = append(s.replicas, &replica{name: strconv.Itoa(i), db: db})
+	}
+	return s
+}
+
+// Start проверяет отставание реплик сразу и затем каждые interval. Возвращает функцию остановки
+func (s *ReplicaSet) Start(interval time.Duration) func() {
+	s.checkLag()
+
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.checkLag()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func (s *ReplicaSet) checkLag() {
+	for _, rep := range s.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		var lagSeconds float64
+		err := rep.db.QueryRowContext(ctx, QueryGetReplicaLag).Scan(&lagSeconds)
+		cancel()
+
+		lag := time.Duration(lagSeconds * float64(time.Second))
+		healthy := err == nil && lag <= s.maxLag
+		if was := rep.healthy.Swap(healthy); was != healthy {
+			if healthy {
+				logger.Log.Infof("Replica %s is back in rotation (lag %s)", rep.name, lag)
+			} else if err != nil {
+				logger.Log.Warnf("Replica %s removed from rotation: %v", rep.name, err)
+			} else {
+				logger.Log.Warnf("Replica %s removed from rotation: lag %s exceeds %s", rep.name, lag, s.maxLag)
+			}
+		}
+		if err == nil {
+			metrics.ReplicaLag.WithLabelValues(rep.name).Set(lagSeconds)
+		}
+	}
+}
+
+// pick выбирает реплику по кругу среди реплик с допустимым отставанием, nil - таких нет
+func (s *ReplicaSet) pick() *sql.DB {
+	n := len(s.replicas)
+	start := s.next.Add(1)
+	for i := range n {
+		rep := s.replicas[(start+uint64(i))%uint64(n)]
+		if rep.healthy.Load() {
+			return rep.db
+		}
+	}
+	return nil
+}
+
+// Close закрывает соединения с репликами
+func (s *ReplicaSet) Close() {
+	for _, rep := range s.replicas {
+		rep.db.Close()
+	}
+}
+
+// reader выбирает базу для чтения: реплику, если чтение допускает отставание, иначе основную базу.
+// На основную базу идут строго согласованные чтения и чтения после записи в том же запросе
+func (r *PostgresRepository) reader(ctx context.Context) *sql.DB {
+	if r.Replicas == nil || StrongConsistency(ctx) || wroteInSession(ctx) {
+		metrics.DBReads.WithLabelValues("primary").Inc()
+		return r.db
+	}
+	if db := r.Replicas.pick(); db != nil {
+		metrics.DBReads.WithLabelValues("replica").Inc()
+		return db
+	}
+	metrics.DBReads.WithLabelValues("primary").Inc()
+	return r.db
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lagDriver - реплика, которая на любой запрос отвечает текущим отставанием в секундах
+type lagDriver struct {
+	mu  sync.Mutex
+	lag float64
+	err error
+}
+
+func (d *lagDriver) set(lag time.Duration, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lag, d.err = lag.Seconds(), err
+}
+
+func (d *lagDriver) Open(string) (driver.Conn, error) { return &lagConn{driver: d}, nil }
+
+type lagConn struct{ driver *lagDriver }
+
+func (c *lagConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *lagConn) Close() error                        { return nil }
+func (c *lagConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *lagConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	if c.driver.err != nil {
+		return nil, c.driver.err
+	}
+	return &lagRows{lag: c.driver.lag}, nil
+}
+
+type lagRows struct {
+	lag  float64
+	read bool
+}
+
+func (r *lagRows) Columns() []string { return []string{"lag"} }
+func (r *lagRows) Close() error      { return nil }
+
+func (r *lagRows) Next(dest []driver.Value) error {
+	if r.read {
+		return io.EOF
+	}
+	r.read = true
+	dest[0] = r.lag
+	return nil
+}
+
+var lagDriverSeq atomic.Int32
+
+func newLagReplica(t *testing.T) (*sql.DB, *lagDriver) {
+	d := &lagDriver{}
+	name := fmt.Sprintf("replica-%d", lagDriverSeq.Add(1))
+	sql.Register(name, d)
+	conn, err := sql.Open(name, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn, d
+}
+
+func Test_ReplicaSelection(t *testing.T) {
+	ctx := context.Background()
+	primary, _ := newLagReplica(t)
+	first, firstLag := newLagReplica(t)
+	second, secondLag := newLagReplica(t)
+
+	repo := NewPostgresRepository(primary)
+	repo.Replicas = NewReplicaSet([]*sql.DB{first, second}, time.Second)
+
+	// До первой проверки отставания реплики не используются
+	assert.Same(t, primary, repo.reader(ctx))
+
+	repo.Replicas.checkLag()
+	picked := map[*sql.DB]int{}
+	for range 10 {
+		picked[repo.reader(ctx)]++
+	}
+	assert.Equal(t, map[*sql.DB]int{first: 5, second: 5}, picked, "round robin between healthy replicas")
+
+	t.Run("lagging replica leaves rotation", func(t *testing.T) {
+		firstLag.set(5*time.Second, nil)
+		repo.Replicas.checkLag()
+		for range 4 {
+			assert.Same(t, second, repo.reader(ctx))
+		}
+	})
+
+	t.Run("falls back to primary when all replicas lag", func(t *testing.T) {
+		secondLag.set(0, errors.New("connection refused"))
+		repo.Replicas.checkLag()
+		assert.Same(t, primary, repo.reader(ctx))
+	})
+
+	t.Run("replica returns after catching up", func(t *testing.T) {
+		firstLag.set(time.Second, nil)
+		repo.Replicas.checkLag()
+		assert.Same(t, first, repo.reader(ctx), "lag equal to the limit is allowed")
+	})
+
+	t.Run("consistent reads use primary", func(t *testing.T) {
+		assert.Same(t, primary, repo.reader(WithStrongConsistency(ctx)))
+
+		session := WithSession(ctx)
+		assert.Same(t, first, repo.reader(session))
+		markWrite(session)
+		assert.Same(t, primary, repo.reader(session), "read after write in the same session")
+		assert.Same(t, first, repo.reader(WithSession(ctx)), "the write marker does not outlive the session")
+	})
+}
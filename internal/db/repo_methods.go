@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -10,170 +11,197 @@ import (
 var (
 	ErrWalletNotFound    = errors.New("wallet not found")
 	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrWalletFrozen - кошелек заморожен оператором, пополнение и снятие запрещены
+	ErrWalletFrozen = errors.New("wallet is frozen")
 )
 
-func (r *PostgresRepository) DepositMoney(walletUUID string, amount int64) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		logger.Log.Errorf("Failed to start transaction: %v", err)
-		return fmt.Errorf("failed to start transaction: %w", err)
-	}
+func (r *PostgresRepository) depositMoney(ctx context.Context, walletUUID string, amount int64) error {
+	return r.runInTx(ctx, r.Isolation.Deposit, func(tx *sql.Tx) error {
+		return r.depositInTx(ctx, tx, walletUUID, amount)
+	})
+}
 
-	var committed bool
+// depositInTx - депозит в рамках уже открытой транзакции. Если кошелька нет, он создается
+func (r *PostgresRepository) depositInTx(ctx context.Context, tx *sql.Tx, walletUUID string, amount int64) error {
+	log := logger.Log.WithContext(ctx)
 
-	defer func() {
-		if p := recover(); p != nil {
-			tx.Rollback()
-			logger.Log.Errorf("Transaction panicked: %v", p)
-		} else if err != nil && !committed {
-			tx.Rollback()
-			logger.Log.Errorf("Transaction rolled back: %v", err)
-		}
-	}()
+	// Горячий кошелек пополняется через одну из частей, не блокируя строку кошелька
+	done, err := r.depositToShard(ctx, tx, walletUUID, amount)
+	if err != nil || done {
+		return err
+	}
 
 	// Блокируем строку кошелька
 	var balance int64
+	var shardCount int
 	var walletID int
+	var frozen bool
 
-	logger.Log.Debugf("Executing query: %s with params: %v", QueryGetWalletForUpdate, walletUUID)
-	err = tx.QueryRow(QueryGetWalletForUpdate, walletUUID).Scan(&balance)
+	err = scanRow(ctx, tx, "GetWalletForUpdate", QueryGetWalletForUpdate, []any{walletUUID, tenantOrDefault(ctx)}, &balance, &shardCount, &frozen)
 	if err == sql.ErrNoRows {
 		// Если кошелька нет, создаем новый
-		logger.Log.Infof("Wallet with UUID %s not found. Creating a new wallet.", walletUUID)
+		log.Infof("Wallet with UUID %s not found. Creating a new wallet.", walletUUID)
 
-		if _, err = tx.Exec(QueryCreateWallet, walletUUID, amount); err != nil {
-			logger.Log.Errorf("Failed to create wallet with UUID %s: %v", walletUUID, err)
+		res, err := execSQL(ctx, tx, "CreateWallet", QueryCreateWallet, walletUUID, amount, tenantOrDefault(ctx))
+		if err != nil {
+			log.Errorf("Failed to create wallet with UUID %s: %v", walletUUID, err)
 			return fmt.Errorf("failed to create wallet: %w", err)
 		}
+		// UUID занят удаленным или перенесенным на другой шард кошельком арендатора
+		if affected, err := res.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to create wallet: %w", err)
+		} else if affected == 0 {
+			log.Warnf("Wallet UUID %s is not available to tenant %s", walletUUID, tenantOrDefault(ctx))
+			return ErrWalletNotFound
+		}
 
-		logger.Log.Debugf("Executing query: %s with params: %v", QueryGetWalletID, walletUUID)
-		if err = tx.QueryRow(QueryGetWalletID, walletUUID).Scan(&walletID); err != nil {
-			logger.Log.Errorf("Failed to retrieve wallet ID for UUID %s: %v", walletUUID, err)
+		if err = scanRow(ctx, tx, "GetWalletID", QueryGetWalletID, []any{walletUUID, tenantOrDefault(ctx)}, &walletID); err != nil {
+			log.Errorf("Failed to retrieve wallet ID for UUID %s: %v", walletUUID, err)
 			return fmt.Errorf("failed to get wallet ID: %w", err)
 		}
 
-		if _, err = tx.Exec(QueryCreateTransaction, walletID, "DEPOSIT", amount, "ACTIVE"); err != nil {
-			logger.Log.Errorf("Failed to create transaction for wallet UUID %s: %v", walletUUID, err)
+		if _, err = execSQL(ctx, tx, "CreateTransaction", QueryCreateTransaction, walletID, "DEPOSIT", amount, "ACTIVE"); err != nil {
+			log.Errorf("Failed to create transaction for wallet UUID %s: %v", walletUUID, err)
 			return fmt.Errorf("failed to create transaction: %w", err)
 		}
 
-		logger.Log.Infof("Wallet with UUID %s created successfully with initial deposit of %d.", walletUUID, amount)
+		log.Infof("Wallet with UUID %s created successfully with initial deposit of %d.", walletUUID, logger.Amount(amount))
 	} else if err != nil {
-		logger.Log.Errorf("Failed to lock wallet with UUID %s for update: %v", walletUUID, err)
+		log.Errorf("Failed to lock wallet with UUID %s for update: %v", walletUUID, err)
 		return fmt.Errorf("failed to lock wallet for update: %w", err)
+	} else if frozen && !frozenAllowed(ctx) {
+		log.Warnf("Deposit to frozen wallet %s rejected", walletUUID)
+		return ErrWalletFrozen
 	} else {
 		// Обновляем баланс, если кошелек существует
-		logger.Log.Infof("Wallet with UUID %s exists. Depositing amount: %d.", walletUUID, amount)
-		if _, err = tx.Exec(QueryUpdateBalance, amount, walletUUID); err != nil {
-			logger.Log.Errorf("Failed to deposit money to wallet UUID %s: %v", walletUUID, err)
+		log.Infof("Wallet with UUID %s exists. Depositing amount: %d.", walletUUID, logger.Amount(amount))
+		if _, err = execSQL(ctx, tx, "UpdateBalance", QueryUpdateBalance, amount, walletUUID, tenantOrDefault(ctx)); err != nil {
+			log.Errorf("Failed to deposit money to wallet UUID %s: %v", walletUUID, err)
 			return fmt.Errorf("failed to deposit money: %w", err)
 		}
 
 		// Получаем wallet_id для создания транзакции
-		logger.Log.Debugf("Executing query: %s with params: %v", QueryGetWalletID, walletUUID)
-		if err = tx.QueryRow(QueryGetWalletID, walletUUID).Scan(&walletID); err != nil {
-			logger.Log.Errorf("Failed to retrieve wallet ID for UUID %s: %v", walletUUID, err)
+		if err = scanRow(ctx, tx, "GetWalletID", QueryGetWalletID, []any{walletUUID, tenantOrDefault(ctx)}, &walletID); err != nil {
+			log.Errorf("Failed to retrieve wallet ID for UUID %s: %v", walletUUID, err)
 			return fmt.Errorf("failed to get wallet ID: %w", err)
 		}
 
 		//Создаем транзакцию
-		if _, err = tx.Exec(QueryCreateTransaction, walletID, "DEPOSIT", amount, "ACTIVE"); err != nil {
-			logger.Log.Errorf("Failed to create transaction for wallet UUID %s: %v", walletUUID, err)
+		if _, err = execSQL(ctx, tx, "CreateTransaction", QueryCreateTransaction, walletID, "DEPOSIT", amount, "ACTIVE"); err != nil {
+			log.Errorf("Failed to create transaction for wallet UUID %s: %v", walletUUID, err)
 			return fmt.Errorf("failed to create transaction: %w", err)
 		}
 
-		logger.Log.Infof("Deposit of %d to wallet UUID %s completed successfully.", amount, walletUUID)
+		log.Infof("Deposit of %d to wallet UUID %s completed successfully.", logger.Amount(amount), walletUUID)
 	}
 
-	if err = tx.Commit(); err != nil {
-		logger.Log.Errorf("Failed to commit transaction: %v", err)
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
+	return r.notifyBalanceChanged(ctx, tx, walletUUID)
+}
 
-	committed = true
-	logger.Log.Info("Transaction committed successfully")
-	return nil
+func (r *PostgresRepository) withdrawMoney(ctx context.Context, walletUUID string, amount int64) error {
+	return r.runInTx(ctx, r.Isolation.Withdraw, func(tx *sql.Tx) error {
+		return r.withdrawWithFeeInTx(ctx, tx, walletUUID, amount)
+	})
 }
 
-func (r *PostgresRepository) WithdrawMoney(walletUUID string, amount int64) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		logger.Log.Errorf("Failed to start transaction: %v", err)
-		return fmt.Errorf("failed to start transaction: %w", err)
+// withdrawWithFeeInTx - снятие средств с комиссией арендатора (Fees): сумма и комиссия списываются
+// отдельными транзакциями WITHDRAW, а комиссия записывается в начисления (fee_accruals). На кошелек
+// комиссий ее зачисляет SettleFeeAccruals, поэтому снятия не ждут друг друга на строке этого кошелька
+func (r *PostgresRepository) withdrawWithFeeInTx(ctx context.Context, tx *sql.Tx, walletUUID string, amount int64) error {
+	if err := r.withdrawInTx(ctx, tx, walletUUID, amount); err != nil {
+		return err
+	}
+	if r.Fees == nil {
+		return nil
 	}
 
-	var committed bool
+	fee, feeWallet := r.Fees.WithdrawFee(tenantOrDefault(ctx), amount)
+	if fee <= 0 {
+		return nil
+	}
+	if err := r.withdrawInTx(ctx, tx, walletUUID, fee); err != nil {
+		return err
+	}
 
-	defer func() {
-		if p := recover(); p != nil {
-			_ = tx.Rollback()
-			logger.Log.Errorf("Transaction panicked: %v", p)
-		} else if !committed {
-			_ = tx.Rollback()
-			logger.Log.Errorf("Transaction rolled back: %v", err)
-		}
-	}()
+	var walletID int
+	if err := scanRow(ctx, tx, "GetWalletID", QueryGetWalletID, []any{walletUUID, tenantOrDefault(ctx)}, &walletID); err != nil {
+		return fmt.Errorf("failed to get wallet ID: %w", err)
+	}
+	if _, err := execSQL(ctx, tx, "CreateFeeAccrual", QueryCreateFeeAccrual, tenantOrDefault(ctx), feeWallet, walletID, fee); err != nil {
+		logger.Log.WithContext(ctx).Errorf("Failed to record fee of %d for wallet UUID %s: %v", logger.Amount(fee), walletUUID, err)
+		return fmt.Errorf("failed to record fee: %w", err)
+	}
+	return nil
+}
+
+// withdrawInTx - снятие средств в рамках уже открытой транзакции
+func (r *PostgresRepository) withdrawInTx(ctx context.Context, tx *sql.Tx, walletUUID string, amount int64) error {
+	log := logger.Log.WithContext(ctx)
 
 	var balance int64
+	var shardCount int
 	var walletID int
+	var frozen bool
 
-	logger.Log.Debugf("Executing query: %s with params: %v", QueryGetWalletForUpdate, walletUUID)
-	err = tx.QueryRow(QueryGetWalletForUpdate, walletUUID).Scan(&balance)
+	err := scanRow(ctx, tx, "GetWalletForUpdate", QueryGetWalletForUpdate, []any{walletUUID, tenantOrDefault(ctx)}, &balance, &shardCount, &frozen)
 	if err == sql.ErrNoRows {
-		logger.Log.Error(ErrWalletNotFound)
+		log.Warnf("Wallet with UUID %s not found.", walletUUID)
 		return ErrWalletNotFound
 	} else if err != nil {
-		logger.Log.Errorf("Failed to lock wallet with UUID %s for update: %v", walletUUID, err)
+		log.Errorf("Failed to lock wallet with UUID %s for update: %v", walletUUID, err)
 		return fmt.Errorf("failed to lock wallet for update: %w", err)
 	}
 
+	if frozen {
+		log.Warnf("Withdraw from frozen wallet %s rejected", walletUUID)
+		return ErrWalletFrozen
+	}
+
+	if shardCount > 1 {
+		return r.withdrawFromShards(ctx, tx, walletUUID, balance, amount)
+	}
+
 	if balance < amount {
-		logger.Log.Error(ErrInsufficientFunds)
+		log.Warnf("Withdraw of %d from wallet %s rejected: %v", logger.Amount(amount), walletUUID, ErrInsufficientFunds)
 		return ErrInsufficientFunds
 	}
 
-	if _, err = tx.Exec(QueryWithdraw, amount, walletUUID); err != nil {
-		logger.Log.Errorf("Failed to withdraw money from wallet UUID %s: %v", walletUUID, err)
+	if _, err = execSQL(ctx, tx, "Withdraw", QueryWithdraw, amount, walletUUID, tenantOrDefault(ctx)); err != nil {
+		log.Errorf("Failed to withdraw money from wallet UUID %s: %v", walletUUID, err)
 		return fmt.Errorf("failed to withdraw money: %w", err)
 	}
 
-	logger.Log.Debugf("Executing query: %s with params: %v", QueryGetWalletID, walletUUID)
-	if err = tx.QueryRow(QueryGetWalletID, walletUUID).Scan(&walletID); err != nil {
-		logger.Log.Errorf("Failed to get wallet ID for UUID %s: %v", walletUUID, err)
+	if err = scanRow(ctx, tx, "GetWalletID", QueryGetWalletID, []any{walletUUID, tenantOrDefault(ctx)}, &walletID); err != nil {
+		log.Errorf("Failed to get wallet ID for UUID %s: %v", walletUUID, err)
 		return fmt.Errorf("failed to get wallet ID: %w", err)
 	}
 
-	if _, err = tx.Exec(QueryCreateTransaction, walletID, "WITHDRAW", amount, "ACTIVE"); err != nil {
-		logger.Log.Errorf("Failed to create transaction for wallet UUID %s: %v", walletUUID, err)
+	if _, err = execSQL(ctx, tx, "CreateTransaction", QueryCreateTransaction, walletID, "WITHDRAW", amount, "ACTIVE"); err != nil {
+		log.Errorf("Failed to create transaction for wallet UUID %s: %v", walletUUID, err)
 		return fmt.Errorf("failed to create transaction: %w", err)
 	}
 
-	if err = tx.Commit(); err != nil {
-		logger.Log.Errorf("Failed to commit transaction: %v", err)
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	committed = true
-	logger.Log.Info("Transaction committed successfully")
-	return nil
+	return r.notifyBalanceChanged(ctx, tx, walletUUID)
 }
 
-func (r *PostgresRepository) GetBalance(walletUUID string) (int64, error) {
+func (r *PostgresRepository) getBalance(ctx context.Context, walletUUID string) (int64, error) {
+	log := logger.Log.WithContext(ctx)
+
 	var balance int64
 
-	logger.Log.Infof("Fetching balance for wallet UUID: %s", walletUUID)
+	log.Infof("Fetching balance for wallet UUID: %s", walletUUID)
 
 	// Выполняем запрос для получения баланса
-	if err := r.db.QueryRow(QueryGetBalance, walletUUID).Scan(&balance); err != nil {
+	if err := scanRow(ctx, r.reader(ctx), "GetBalance", QueryGetBalance, []any{walletUUID, tenantOrDefault(ctx)}, &balance); err != nil {
 		if err == sql.ErrNoRows {
-			logger.Log.Warnf("Wallet with UUID %s not found.", walletUUID)
+			log.Warnf("Wallet with UUID %s not found.", walletUUID)
 			return 0, ErrWalletNotFound
 		}
-		logger.Log.Errorf("Failed to fetch balance for wallet UUID %s: %v", walletUUID, err)
+		log.Errorf("Failed to fetch balance for wallet UUID %s: %v", walletUUID, err)
 		return 0, fmt.Errorf("failed to get wallet balance: %w", err)
 	}
 
-	logger.Log.Infof("Successfully retrieved balance for wallet UUID %s: %d", walletUUID, balance)
+	log.Infof("Successfully retrieved balance for wallet UUID %s: %d", walletUUID, logger.Amount(balance))
 	return balance, nil
 
 }
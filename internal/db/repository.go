@@ -1,17 +1,77 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"time"
 )
 
 type Repository interface {
-	DepositMoney(walletUUID string, amount int64) error
-	WithdrawMoney(walletUUID string, amount int64) error
-	GetBalance(walletUUID string) (int64, error)
+	DepositMoney(ctx context.Context, walletUUID string, amount int64) error
+	WithdrawMoney(ctx context.Context, walletUUID string, amount int64) error
+	GetBalance(ctx context.Context, walletUUID string) (int64, error)
+}
+
+// HistoryRepository - чтение истории кошелька: выписки и баланс на момент времени
+type HistoryRepository interface {
+	StreamStatement(ctx context.Context, walletUUID string, from, to time.Time,
+		opening func(balance int64) error, entry func(tx LedgerTransaction) error) error
+	GetBalanceAt(ctx context.Context, walletUUID string, asOf time.Time) (int64, error)
+}
+
+// HotWalletRepository - управление горячими кошельками, баланс которых разделен на части
+type HotWalletRepository interface {
+	SetShardCount(ctx context.Context, walletUUID string, shardCount int) error
+}
+
+// TransferRepository - переводы между кошельками
+type TransferRepository interface {
+	Transfer(ctx context.Context, fromWalletUUID, toWalletUUID string, amount int64) (*Transfer, error)
+	GetTransfer(ctx context.Context, id string) (*Transfer, error)
+}
+
+// AdminRepository - операции административной CLI: создание, заморозка и сведения о кошельке,
+// журнал действий операторов. Запись журнала создается до действия и завершается после него
+type AdminRepository interface {
+	CreateWallet(ctx context.Context, walletUUID string) error
+	FreezeWallet(ctx context.Context, walletUUID, reason string) error
+	UnfreezeWallet(ctx context.Context, walletUUID string) error
+	GetWalletInfo(ctx context.Context, walletUUID string) (*WalletInfo, error)
+	StartAudit(ctx context.Context, rec *AuditRecord) error
+	FinishAudit(ctx context.Context, rec *AuditRecord) error
+}
+
+// RiskRepository - проверки рисков перед движением денег: данные для правил, журнал решений
+// и операции, ожидающие ручной проверки
+type RiskRepository interface {
+	GetRiskStats(ctx context.Context, walletUUID, client string, windows RiskWindows) (*RiskStats, error)
+	RecordRiskDecision(ctx context.Context, d *RiskDecision) error
+	ListPendingOperations(ctx context.Context, limit int) ([]PendingOperation, error)
+	DecidePendingOperation(ctx context.Context, id, status, decidedBy, reason string) (*PendingOperation, error)
+	FailPendingOperation(ctx context.Context, id, reason string) error
+}
+
+// OperationQueue - асинхронные операции: постановка в очередь и статус
+type OperationQueue interface {
+	EnqueueOperation(ctx context.Context, walletUUID, operationType string, amount int64) (*AsyncOperation, error)
+	GetOperation(ctx context.Context, id string) (*AsyncOperation, error)
 }
 
 type PostgresRepository struct {
 	db *sql.DB
+	// Timeouts - дедлайны операций, по умолчанию не заданы
+	Timeouts Timeouts
+	// Isolation - уровни изоляции транзакций депозита и снятия
+	Isolation Isolation
+	// Retry - повторы транзакций при конфликтах сериализации и дедлоках (DefaultRetryPolicy, если не задано)
+	Retry RetryPolicy
+	// Replicas - реплики для чтения баланса и истории, nil - все запросы идут на основную базу
+	Replicas *ReplicaSet
+	// Fees - комиссии арендаторов за снятие средств, nil - без комиссий
+	Fees FeePolicy
+	// NotifyBalanceChanges - отправлять NOTIFY об изменении баланса для сброса кэшей балансов.
+	// Транзакции с NOTIFY коммитятся по одной на всю базу, поэтому по умолчанию выключено
+	NotifyBalanceChanges bool
 }
 
 func NewPostgresRepository(db *sql.DB) *PostgresRepository {
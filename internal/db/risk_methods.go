@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"wallet-service/internal/logger"
+)
+
+// Решения проверки рисков
+const (
+	RiskAllow  = "allow"
+	RiskReview = "review"
+	RiskDeny   = "deny"
+)
+
+// Состояния операций на ручной проверке
+const (
+	PendingStatusPending  = "PENDING"
+	PendingStatusApproved = "APPROVED"
+	PendingStatusRejected = "REJECTED"
+	PendingStatusFailed   = "FAILED"
+)
+
+var (
+	ErrPendingOperationNotFound = errors.New("pending operation not found")
+	// ErrPendingOperationDecided - по операции уже принято решение
+	ErrPendingOperationDecided = errors.New("pending operation is already decided")
+)
+
+// RiskWindows - периоды, за которые собираются данные для правил риска
+type RiskWindows struct {
+	// History - история кошелька: число, средняя и максимальная сумма транзакций
+	History time.Duration
+	// Recent - недавние пополнения кошелька
+	Recent time.Duration
+	// Client - новые кошельки клиента
+	Client time.Duration
+}
+
+// RiskStats - данные кошелька и клиента для правил риска
+type RiskStats struct {
+	TxCount        int64
+	AvgAmount      float64
+	MaxAmount      int64
+	RecentDeposits int64
+	// LastDepositAge - давность последнего пополнения за период History, nil - пополнений не было
+	LastDepositAge *time.Duration
+	// WalletNew - у кошелька еще нет транзакций
+	WalletNew        bool
+	ClientNewWallets int64
+}
+
+// RiskDecision - решение проверки рисков по операции
+type RiskDecision struct {
+	ID            string
+	WalletUUID    string
+	Client        string
+	OperationType string
+	Amount        int64
+	Outcome       string
+	Score         int
+	Rules         []string
+	// Facts - значения переменных правил на момент решения
+	Facts     map[string]any
+	WalletNew bool
+}
+
+// PendingOperation - операция, отложенная до ручной проверки
+type PendingOperation struct {
+	ID            string     `json:"id"`
+	WalletUUID    string     `json:"walletId"`
+	Client        string     `json:"client"`
+	OperationType string     `json:"operationType"`
+	Amount        int64      `json:"amount"`
+	Score         int        `json:"score"`
+	Rules         []string   `json:"rules"`
+	Status        string     `json:"status"`
+	DecidedBy     string     `json:"decidedBy,omitempty"`
+	Reason        string     `json:"reason,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	DecidedAt     *time.Time `json:"decidedAt,omitempty"`
+	// TenantID - арендатор кошелька: одобренная операция выполняется от его имени
+	TenantID string `json:"tenantId"`
+}
+
+// GetRiskStats собирает данные кошелька walletUUID и клиента client за периоды windows
+func (r *PostgresRepository) GetRiskStats(ctx context.Context, walletUUID, client string, windows RiskWindows) (*RiskStats, error) {
+	stats := &RiskStats{}
+	var lastDeposit sql.NullFloat64
+	var hasTransactions bool
+	err := scanRow(ctx, r.db, "GetWalletRiskStats", QueryGetWalletRiskStats,
+		[]any{walletUUID, windows.History.Seconds(), windows.Recent.Seconds(), tenantOrDefault(ctx)},
+		&stats.TxCount, &stats.AvgAmount, &stats.MaxAmount, &stats.RecentDeposits, &lastDeposit, &hasTransactions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet risk stats: %w", err)
+	}
+	if lastDeposit.Valid {
+		age := time.Duration(lastDeposit.Float64 * float64(time.Second))
+		stats.LastDepositAge = &age
+	}
+	stats.WalletNew = !hasTransactions
+
+	if err := scanRow(ctx, r.db, "CountClientNewWallets", QueryCountClientNewWallets,
+		[]any{client, windows.Client.Seconds(), tenantArg(ctx)}, &stats.ClientNewWallets); err != nil {
+		return nil, fmt.Errorf("failed to count client new wallets: %w", err)
+	}
+	return stats, nil
+}
+
+// RecordRiskDecision записывает решение. Операцию с решением review в той же транзакции
+// ставит на ручную проверку
+func (r *PostgresRepository) RecordRiskDecision(ctx context.Context, d *RiskDecision) error {
+	facts, err := json.Marshal(d.Facts)
+	if err != nil {
+		return fmt.Errorf("failed to encode risk facts: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := execSQL(ctx, tx, "CreateRiskDecision", QueryCreateRiskDecision,
+		d.ID, d.WalletUUID, d.Client, d.OperationType, d.Amount, d.Outcome, d.Score,
+		strings.Join(d.Rules, ","), string(facts), d.WalletNew, tenantOrDefault(ctx)); err != nil {
+		logger.Log.WithContext(ctx).Errorf("Failed to record risk decision for wallet %s: %v", d.WalletUUID, err)
+		return fmt.Errorf("failed to record risk decision: %w", err)
+	}
+	if d.Outcome == RiskReview {
+		if _, err := execSQL(ctx, tx, "CreatePendingOperation", QueryCreatePendingOperation, d.ID); err != nil {
+			return fmt.Errorf("failed to create pending operation: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListPendingOperations - до limit операций, ожидающих проверки, начиная с самых старых
+func (r *PostgresRepository) ListPendingOperations(ctx context.Context, limit int) ([]PendingOperation, error) {
+	rows, err := querySQL(ctx, r.db, "ListPendingOperations", QueryListPendingOperations, PendingStatusPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending operations: %w", err)
+	}
+	defer rows.Close()
+
+	ops := []PendingOperation{}
+	for rows.Next() {
+		op, err := scanPendingOperation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan pending operation: %w", err)
+		}
+		ops = append(ops, *op)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate pending operations: %w", err)
+	}
+	return ops, nil
+}
+
+// DecidePendingOperation переводит операцию из PENDING в status (APPROVED или REJECTED).
+// Решение принимается один раз: ErrPendingOperationDecided, если оно уже есть
+func (r *PostgresRepository) DecidePendingOperation(ctx context.Context, id, status, decidedBy, reason string) (*PendingOperation, error) {
+	res, err := execSQL(ctx, r.db, "DecidePendingOperation", QueryDecidePendingOperation, id, status, decidedBy, reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decide pending operation: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decide pending operation: %w", err)
+	}
+
+	op, err := scanPendingOperation(r.db.QueryRowContext(ctx, QueryGetPendingOperation, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrPendingOperationNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get pending operation: %w", err)
+	}
+	if affected == 0 {
+		return op, ErrPendingOperationDecided
+	}
+	return op, nil
+}
+
+// FailPendingOperation отмечает одобренную операцию, которую не удалось выполнить
+func (r *PostgresRepository) FailPendingOperation(ctx context.Context, id, reason string) error {
+	if _, err := execSQL(ctx, r.db, "FailPendingOperation", QueryFailPendingOperation, id, reason); err != nil {
+		return fmt.Errorf("failed to mark pending operation as failed: %w", err)
+	}
+	return nil
+}
+
+func scanPendingOperation(row rowScanner) (*PendingOperation, error) {
+	op := &PendingOperation{}
+	var rules string
+	if err := row.Scan(&op.ID, &op.WalletUUID, &op.Client, &op.OperationType, &op.Amount, &op.Score, &rules,
+		&op.Status, &op.DecidedBy, &op.Reason, &op.Error, &op.CreatedAt, &op.DecidedAt, &op.TenantID); err != nil {
+		return nil, err
+	}
+	op.Rules = []string{}
+	if rules != "" {
+		op.Rules = strings.Split(rules, ",")
+	}
+	return op, nil
+}
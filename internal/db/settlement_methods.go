@@ -0,0 +1,207 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-service/internal/logger"
+)
+
+var ErrSettlementRunNotFound = errors.New("settlement run not found")
+
+// LedgerTransaction - транзакция из журнала, участвующая в сверке с файлом расчетов
+type LedgerTransaction struct {
+	ID            int64
+	WalletUUID    string
+	OperationType string
+	Amount        int64
+	CreatedAt     time.Time
+}
+
+// SettlementItem - результат сопоставления одной строки файла или одной транзакции журнала
+type SettlementItem struct {
+	Status        string     `json:"status"`
+	LineNo        *int       `json:"lineNo,omitempty"`
+	Reference     *string    `json:"reference,omitempty"`
+	Amount        int64      `json:"amount"`
+	OperationType *string    `json:"operationType,omitempty"`
+	OccurredAt    *time.Time `json:"occurredAt,omitempty"`
+	TransactionID *int64     `json:"transactionId,omitempty"`
+}
+
+// SettlementRun - сохраненный прогон сверки с файлом расчетов
+type SettlementRun struct {
+	ID                   int64            `json:"id"`
+	Source               string           `json:"source"`
+	Format               string           `json:"format"`
+	MatchRule            string           `json:"matchRule"`
+	DateWindowSeconds    int64            `json:"dateWindowSeconds"`
+	PeriodFrom           *time.Time       `json:"periodFrom,omitempty"`
+	PeriodTo             *time.Time       `json:"periodTo,omitempty"`
+	MatchedCount         int              `json:"matchedCount"`
+	UnmatchedFileCount   int              `json:"unmatchedFileCount"`
+	UnmatchedLedgerCount int              `json:"unmatchedLedgerCount"`
+	CreatedAt            time.Time        `json:"createdAt"`
+	Items                []SettlementItem `json:"items,omitempty"`
+}
+
+func (r *PostgresRepository) ListTransactionsBetween(ctx context.Context, from, to time.Time) ([]LedgerTransaction, error) {
+	log := logger.Log.WithContext(ctx)
+
+	log.Debugf("Fetching ledger transactions between %s and %s", from, to)
+
+	rows, err := querySQL(ctx, r.db, "ListTransactionsBetween", QueryListTransactionsBetween, from, to, tenantArg(ctx))
+	if err != nil {
+		log.Errorf("Failed to fetch ledger transactions: %v", err)
+		return nil, fmt.Errorf("failed to fetch ledger transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var txs []LedgerTransaction
+	for rows.Next() {
+		var t LedgerTransaction
+		if err := rows.Scan(&t.ID, &t.WalletUUID, &t.OperationType, &t.Amount, &t.CreatedAt); err != nil {
+			log.Errorf("Failed to scan ledger transaction: %v", err)
+			return nil, fmt.Errorf("failed to scan ledger transaction: %w", err)
+		}
+		txs = append(txs, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorf("Failed to iterate ledger transactions: %v", err)
+		return nil, fmt.Errorf("failed to iterate ledger transactions: %w", err)
+	}
+
+	return txs, nil
+}
+
+// SaveSettlementRun сохраняет прогон вместе со всеми результатами в одной транзакции
+// и заполняет run.ID и run.CreatedAt
+func (r *PostgresRepository) SaveSettlementRun(ctx context.Context, run *SettlementRun) error {
+	log := logger.Log.WithContext(ctx)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("Failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, QueryCreateSettlementRun,
+		run.Source, run.Format, run.MatchRule, run.DateWindowSeconds, run.PeriodFrom, run.PeriodTo,
+		run.MatchedCount, run.UnmatchedFileCount, run.UnmatchedLedgerCount, tenantOrDefault(ctx),
+	).Scan(&run.ID, &run.CreatedAt)
+	if err != nil {
+		log.Errorf("Failed to create settlement run: %v", err)
+		return fmt.Errorf("failed to create settlement run: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, QueryCreateSettlementItem)
+	if err != nil {
+		log.Errorf("Failed to prepare settlement item insert: %v", err)
+		return fmt.Errorf("failed to prepare settlement item insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, item := range run.Items {
+		if _, err := stmt.ExecContext(ctx, run.ID, item.Status, item.LineNo, item.Reference, item.Amount,
+			item.OperationType, item.OccurredAt, item.TransactionID); err != nil {
+			log.Errorf("Failed to create settlement item for run %d: %v", run.ID, err)
+			return fmt.Errorf("failed to create settlement item: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Errorf("Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Infof("Settlement run %d saved with %d items", run.ID, len(run.Items))
+	return nil
+}
+
+// GetSettlementRun возвращает прогон вместе с результатами
+func (r *PostgresRepository) GetSettlementRun(ctx context.Context, id int64) (*SettlementRun, error) {
+	log := logger.Log.WithContext(ctx)
+
+	run, err := scanSettlementRun(r.db.QueryRowContext(ctx, QueryGetSettlementRun, id, tenantArg(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Warnf("Settlement run %d not found", id)
+			return nil, ErrSettlementRunNotFound
+		}
+		log.Errorf("Failed to fetch settlement run %d: %v", id, err)
+		return nil, fmt.Errorf("failed to fetch settlement run: %w", err)
+	}
+
+	rows, err := querySQL(ctx, r.db, "ListSettlementItems", QueryListSettlementItems, id)
+	if err != nil {
+		log.Errorf("Failed to fetch settlement items for run %d: %v", id, err)
+		return nil, fmt.Errorf("failed to fetch settlement items: %w", err)
+	}
+	defer rows.Close()
+
+	run.Items = []SettlementItem{}
+	for rows.Next() {
+		var item SettlementItem
+		if err := rows.Scan(&item.Status, &item.LineNo, &item.Reference, &item.Amount,
+			&item.OperationType, &item.OccurredAt, &item.TransactionID); err != nil {
+			log.Errorf("Failed to scan settlement item: %v", err)
+			return nil, fmt.Errorf("failed to scan settlement item: %w", err)
+		}
+		run.Items = append(run.Items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorf("Failed to iterate settlement items: %v", err)
+		return nil, fmt.Errorf("failed to iterate settlement items: %w", err)
+	}
+
+	return run, nil
+}
+
+// ListSettlementRuns возвращает последние limit прогонов без результатов
+func (r *PostgresRepository) ListSettlementRuns(ctx context.Context, limit int) ([]SettlementRun, error) {
+	log := logger.Log.WithContext(ctx)
+
+	rows, err := querySQL(ctx, r.db, "ListSettlementRuns", QueryListSettlementRuns, limit, tenantArg(ctx))
+	if err != nil {
+		log.Errorf("Failed to fetch settlement runs: %v", err)
+		return nil, fmt.Errorf("failed to fetch settlement runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []SettlementRun{}
+	for rows.Next() {
+		run, err := scanSettlementRun(rows)
+		if err != nil {
+			log.Errorf("Failed to scan settlement run: %v", err)
+			return nil, fmt.Errorf("failed to scan settlement run: %w", err)
+		}
+		runs = append(runs, *run)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorf("Failed to iterate settlement runs: %v", err)
+		return nil, fmt.Errorf("failed to iterate settlement runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSettlementRun(row rowScanner) (*SettlementRun, error) {
+	var run SettlementRun
+	err := row.Scan(&run.ID, &run.Source, &run.Format, &run.MatchRule, &run.DateWindowSeconds,
+		&run.PeriodFrom, &run.PeriodTo, &run.MatchedCount, &run.UnmatchedFileCount,
+		&run.UnmatchedLedgerCount, &run.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+	"wallet-service/internal/logger"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteTimeFormat - формат времени в SQLite: фиксированная ширина, чтобы строки сравнивались как моменты времени
+const sqliteTimeFormat = "2006-01-02 15:04:05.000000000"
+
+// SQLiteRepository - хранилище кошельков в файле SQLite для однобинарной редакции сервиса.
+// Транзакции записи открываются через BEGIN IMMEDIATE: блокировка записи берется в начале транзакции,
+// поэтому чтение баланса и списание не могут перемежаться с другой записью, как при SELECT ... FOR UPDATE
+type SQLiteRepository struct {
+	db *sql.DB
+	// Timeouts - дедлайны операций, по умолчанию не заданы. Lock не используется:
+	// ожидание блокировки ограничено busy_timeout из OpenSQLite
+	Timeouts Timeouts
+	now      func() time.Time
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{db: db, now: time.Now}
+}
+
+// OpenSQLite открывает файл базы SQLite. Все транзакции начинаются с BEGIN IMMEDIATE,
+// ожидание блокировки другой записью ограничено busyTimeout
+func OpenSQLite(path string, busyTimeout time.Duration) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_txlock", "immediate")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "foreign_keys(1)")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	return db, nil
+}
+
+func (r *SQLiteRepository) DepositMoney(ctx context.Context, walletUUID string, amount int64) error {
+	ctx, span := startRepositorySpan(ctx, "DepositMoney", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Deposit)
+	defer cancel()
+	start := time.Now()
+	err := sqliteError(ctx, r.inTx(ctx, false, func(tx *sql.Tx) error {
+		return r.depositInTx(ctx, tx, walletUUID, amount)
+	}))
+	observeOperation(tenantOrDefault(ctx), "deposit", amount, err)
+	observeRepositoryCall("DepositMoney", start, span, err)
+	return err
+}
+
+func (r *SQLiteRepository) WithdrawMoney(ctx context.Context, walletUUID string, amount int64) error {
+	ctx, span := startRepositorySpan(ctx, "WithdrawMoney", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Withdraw)
+	defer cancel()
+	start := time.Now()
+	err := sqliteError(ctx, r.inTx(ctx, false, func(tx *sql.Tx) error {
+		return r.withdrawInTx(ctx, tx, walletUUID, amount)
+	}))
+	observeOperation(tenantOrDefault(ctx), "withdraw", amount, err)
+	observeRepositoryCall("WithdrawMoney", start, span, err)
+	return err
+}
+
+func (r *SQLiteRepository) GetBalance(ctx context.Context, walletUUID string) (int64, error) {
+	ctx, span := startRepositorySpan(ctx, "GetBalance", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Read)
+	defer cancel()
+	start := time.Now()
+
+	var balance int64
+	err := scanRow(ctx, r.db, "SQLiteGetBalance", SQLiteQueryGetBalance, []any{walletUUID}, &balance)
+	if err == sql.ErrNoRows {
+		err = ErrWalletNotFound
+	} else if err != nil {
+		err = sqliteError(ctx, fmt.Errorf("failed to get wallet balance: %w", err))
+	}
+	observeRepositoryCall("GetBalance", start, span, err)
+	return balance, err
+}
+
+func (r *SQLiteRepository) StreamStatement(ctx context.Context, walletUUID string, from, to time.Time,
+	opening func(balance int64) error, entry func(tx LedgerTransaction) error) error {
+	ctx, span := startRepositorySpan(ctx, "StreamStatement", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.WalletStatement)
+	defer cancel()
+	start := time.Now()
+	err := sqliteError(ctx, r.inTx(ctx, true, func(tx *sql.Tx) error {
+		return r.streamStatementInTx(ctx, tx, walletUUID, from, to, opening, entry)
+	}))
+	observeRepositoryCall("StreamStatement", start, span, err)
+	return err
+}
+
+func (r *SQLiteRepository) GetBalanceAt(ctx context.Context, walletUUID string, asOf time.Time) (int64, error) {
+	ctx, span := startRepositorySpan(ctx, "GetBalanceAt", walletUUID)
+	ctx, cancel := withTimeout(ctx, r.Timeouts.Read)
+	defer cancel()
+	start := time.Now()
+
+	var balance int64
+	err := r.inTx(ctx, true, func(tx *sql.Tx) error {
+		walletID, err := r.walletID(ctx, tx, walletUUID)
+		if err != nil {
+			return err
+		}
+		if err := scanRow(ctx, tx, "SQLiteGetBalanceAt", SQLiteQueryGetBalanceAt, []any{walletID, sqliteTime(asOf)}, &balance); err != nil {
+			return fmt.Errorf("failed to compute balance as of %s: %w", asOf, err)
+		}
+		return nil
+	})
+	err = sqliteError(ctx, err)
+	observeRepositoryCall("GetBalanceAt", start, span, err)
+	return balance, err
+}
+
+// inTx выполняет fn в транзакции. Из-за _txlock=immediate транзакция записи сразу берет блокировку записи,
+// read-only транзакция начинается обычным BEGIN и в режиме WAL читает снимок, не мешая записи
+func (r *SQLiteRepository) inTx(ctx context.Context, readOnly bool, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: readOnly})
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// depositInTx пополняет кошелек, создавая его при необходимости, и записывает транзакцию
+func (r *SQLiteRepository) depositInTx(ctx context.Context, tx *sql.Tx, walletUUID string, amount int64) error {
+	now := sqliteTime(r.now())
+
+	var walletID int64
+	if err := scanRow(ctx, tx, "SQLiteUpsertWallet", SQLiteQueryUpsertWallet, []any{walletUUID, amount, now}, &walletID); err != nil {
+		logger.Log.WithContext(ctx).Errorf("Failed to deposit money to wallet UUID %s: %v", walletUUID, err)
+		return fmt.Errorf("failed to deposit money: %w", err)
+	}
+
+	if _, err := execSQL(ctx, tx, "SQLiteCreateTransaction", SQLiteQueryCreateTransaction, walletID, "DEPOSIT", amount, now); err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	return nil
+}
+
+// withdrawInTx списывает средства и записывает транзакцию
+func (r *SQLiteRepository) withdrawInTx(ctx context.Context, tx *sql.Tx, walletUUID string, amount int64) error {
+	var walletID, balance int64
+	err := scanRow(ctx, tx, "SQLiteGetWallet", SQLiteQueryGetWallet, []any{walletUUID}, &walletID, &balance)
+	if err == sql.ErrNoRows {
+		return ErrWalletNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to get wallet: %w", err)
+	}
+
+	if balance < amount {
+		return ErrInsufficientFunds
+	}
+
+	now := sqliteTime(r.now())
+	if _, err := execSQL(ctx, tx, "SQLiteWithdraw", SQLiteQueryWithdraw, amount, now, walletID); err != nil {
+		logger.Log.WithContext(ctx).Errorf("Failed to withdraw money from wallet UUID %s: %v", walletUUID, err)
+		return fmt.Errorf("failed to withdraw money: %w", err)
+	}
+	if _, err := execSQL(ctx, tx, "SQLiteCreateTransaction", SQLiteQueryCreateTransaction, walletID, "WITHDRAW", amount, now); err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) streamStatementInTx(ctx context.Context, tx *sql.Tx, walletUUID string, from, to time.Time,
+	opening func(balance int64) error, entry func(tx LedgerTransaction) error) error {
+	walletID, err := r.walletID(ctx, tx, walletUUID)
+	if err != nil {
+		return err
+	}
+
+	var openingBalance int64
+	if err := scanRow(ctx, tx, "SQLiteGetLedgerBalanceBefore", SQLiteQueryGetLedgerBalanceBefore, []any{walletID, sqliteTime(from)}, &openingBalance); err != nil {
+		return fmt.Errorf("failed to compute opening balance: %w", err)
+	}
+	if err := opening(openingBalance); err != nil {
+		return err
+	}
+
+	rows, err := querySQL(ctx, tx, "SQLiteListWalletTransactions", SQLiteQueryListWalletTransactions, walletID, sqliteTime(from), sqliteTime(to))
+	if err != nil {
+		return fmt.Errorf("failed to fetch wallet transactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t := LedgerTransaction{WalletUUID: walletUUID}
+		var createdAt string
+		if err := rows.Scan(&t.ID, &t.OperationType, &t.Amount, &createdAt); err != nil {
+			return fmt.Errorf("failed to scan wallet transaction: %w", err)
+		}
+		if t.CreatedAt, err = time.Parse(sqliteTimeFormat, createdAt); err != nil {
+			return fmt.Errorf("failed to parse transaction time %q: %w", createdAt, err)
+		}
+		if err := entry(t); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate wallet transactions: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) walletID(ctx context.Context, tx *sql.Tx, walletUUID string) (int64, error) {
+	var walletID int64
+	err := scanRow(ctx, tx, "SQLiteGetWalletID", SQLiteQueryGetWalletID, []any{walletUUID}, &walletID)
+	if err == sql.ErrNoRows {
+		return 0, ErrWalletNotFound
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get wallet ID: %w", err)
+	}
+	return walletID, nil
+}
+
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// sqliteError дополняет contextError: SQLITE_BUSY (не дождались блокировки за busy_timeout)
+// считается таймаутом, как lock_timeout в PostgreSQL
+func sqliteError(ctx context.Context, err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY {
+		return fmt.Errorf("%w: %v", ErrOperationTimeout, err)
+	}
+	return contextError(ctx, err)
+}
@@ -0,0 +1,19 @@
+--Кошельки (SQLite-редакция)
+CREATE TABLE wallets (
+    wallet_id INTEGER PRIMARY KEY AUTOINCREMENT,           -- Автоинкрементируемый ID
+    uuid TEXT NOT NULL UNIQUE,                             -- Приходит с запросом
+    balance INTEGER NOT NULL DEFAULT 0,                    -- Баланс кошелька
+    created_at TEXT NOT NULL,                              -- Дата создания кошелька (UTC, формат sqliteTimeFormat)
+    updated_at TEXT NOT NULL                               -- Дата последнего обновления
+);
+
+--Транзакции
+CREATE TABLE transactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,                  -- Автоинкрементируемый ID
+    wallet_id INTEGER NOT NULL REFERENCES wallets(wallet_id), -- Связь с кошельком
+    operation_type TEXT NOT NULL,                          -- Тип операции DEPOSIT или WITHDRAW
+    amount INTEGER NOT NULL CHECK (amount > 0),            -- Сумма операции (должна быть > 0)
+    created_at TEXT NOT NULL                               -- Дата создания транзакции
+);
+
+CREATE INDEX idx_transactions_wallet_created ON transactions (wallet_id, created_at);
@@ -0,0 +1,69 @@
+package db
+
+// Запросы SQLiteRepository. Время хранится текстом в UTC в формате sqliteTimeFormat,
+// поэтому строки сравниваются так же, как моменты времени
+const (
+	//создание кошелька или пополнение существующего одним запросом
+	SQLiteQueryUpsertWallet = `
+		INSERT INTO wallets (uuid, balance, created_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (uuid) DO UPDATE SET balance = balance + excluded.balance, updated_at = excluded.updated_at
+		RETURNING wallet_id
+	`
+
+	//кошелек для снятия средств. Транзакция открыта через BEGIN IMMEDIATE и уже держит блокировку записи
+	SQLiteQueryGetWallet = `
+		SELECT wallet_id, balance
+		FROM wallets
+		WHERE uuid = $1
+	`
+
+	//списание средств
+	SQLiteQueryWithdraw = `
+		UPDATE wallets
+		SET balance = balance - $1, updated_at = $2
+		WHERE wallet_id = $3
+	`
+
+	//запись транзакции
+	SQLiteQueryCreateTransaction = `
+		INSERT INTO transactions (wallet_id, operation_type, amount, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	//баланс кошелька
+	SQLiteQueryGetBalance = `
+		SELECT balance
+		FROM wallets
+		WHERE uuid = $1
+	`
+
+	//ID кошелька по UUID
+	SQLiteQueryGetWalletID = `
+		SELECT wallet_id
+		FROM wallets
+		WHERE uuid = $1
+	`
+
+	//баланс по журналу транзакций, проведенных раньше $2
+	SQLiteQueryGetLedgerBalanceBefore = `
+		SELECT COALESCE(SUM(CASE WHEN operation_type = 'DEPOSIT' THEN amount ELSE -amount END), 0)
+		FROM transactions
+		WHERE wallet_id = $1 AND created_at < $2
+	`
+
+	//баланс по журналу транзакций, проведенных не позже $2
+	SQLiteQueryGetBalanceAt = `
+		SELECT COALESCE(SUM(CASE WHEN operation_type = 'DEPOSIT' THEN amount ELSE -amount END), 0)
+		FROM transactions
+		WHERE wallet_id = $1 AND created_at <= $2
+	`
+
+	//транзакции кошелька за период [$2, $3)
+	SQLiteQueryListWalletTransactions = `
+		SELECT id, operation_type, amount, created_at
+		FROM transactions
+		WHERE wallet_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at, id
+	`
+)
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+)
+
+// DefaultTenant - арендатор кошельков, созданных без арендатора: до разделения на арендаторов,
+// без TENANTS_FILE или административной CLI без -tenant
+const DefaultTenant = "default"
+
+type tenantKey struct{}
+
+// WithTenant привязывает к контексту арендатора. Запросы репозитория в этом контексте видят
+// только кошельки, переводы и операции арендатора, чужие считаются несуществующими.
+// UUID кошелька уникален в пределах арендатора: у разных арендаторов это разные кошельки
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext - арендатор из контекста, "" - системный контекст (CLI, фоновые задания):
+// списки и сверки без ограничения по арендатору, кошелек по UUID - кошелек арендатора по умолчанию
+func TenantFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	return tenantID
+}
+
+// tenantArg - параметр запроса для условия ($N::TEXT IS NULL OR tenant_id = $N): NULL снимает ограничение
+func tenantArg(ctx context.Context) any {
+	if tenantID := TenantFromContext(ctx); tenantID != "" {
+		return tenantID
+	}
+	return nil
+}
+
+// tenantOrDefault - арендатор новых записей и кошелька, который ищется по UUID
+func tenantOrDefault(ctx context.Context) string {
+	if tenantID := TenantFromContext(ctx); tenantID != "" {
+		return tenantID
+	}
+	return DefaultTenant
+}
+
+// FeePolicy - комиссии арендаторов за снятие средств
+type FeePolicy interface {
+	// WithdrawFee - комиссия за снятие amount и кошелек арендатора, на который она зачисляется.
+	// 0 - без комиссии
+	WithdrawFee(tenantID string, amount int64) (fee int64, feeWallet string)
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrOperationTimeout - не уложились в дедлайн операции или в lock_timeout при ожидании блокировки
+	ErrOperationTimeout = errors.New("operation timed out")
+	// ErrOperationCanceled - контекст отменен, например клиент разорвал соединение
+	ErrOperationCanceled = errors.New("operation canceled")
+)
+
+// Timeouts - дедлайны операций репозитория. Нулевое значение означает "без дедлайна",
+// тогда операция ограничена только контекстом вызывающего
+type Timeouts struct {
+	Deposit  time.Duration
+	Withdraw time.Duration
+	Read     time.Duration
+	// WalletStatement - формирование выписки по кошельку (StreamStatement) целиком, а не отдельный SQL-запрос
+	WalletStatement time.Duration
+	// Lock - lock_timeout для транзакций, ожидающих SELECT ... FOR UPDATE
+	Lock time.Duration
+}
+
+// withTimeout добавляет к контексту дедлайн операции, если он задан
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// setLockTimeout ограничивает ожидание блокировок в текущей транзакции (SET LOCAL lock_timeout)
+func (r *PostgresRepository) setLockTimeout(ctx context.Context, tx *sql.Tx) error {
+	if r.Timeouts.Lock <= 0 {
+		return nil
+	}
+	value := fmt.Sprintf("%dms", r.Timeouts.Lock.Milliseconds())
+	var applied string
+	return scanRow(ctx, tx, "SetLockTimeout", QuerySetLockTimeout, []any{value}, &applied)
+}
+
+// contextError приводит ошибки отмены и таймаутов к ErrOperationCanceled и ErrOperationTimeout,
+// чтобы обработчики могли отличить их от прочих ошибок базы данных
+func contextError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrOperationTimeout) || errors.Is(err, ErrOperationCanceled) {
+		return err
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "55P03" { // lock_not_available
+		return fmt.Errorf("%w: %v", ErrOperationTimeout, err)
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: %v", ErrOperationTimeout, err)
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%w: %v", ErrOperationCanceled, err)
+	}
+
+	return err
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("wallet-service/internal/db")
+
+// queryer - общее у *sql.DB и *sql.Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// startSQLSpan открывает спан для одного SQL-запроса. name - имя запроса из queries.go без префикса Query
+func startSQLSpan(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "sql "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", name),
+			attribute.String("db.query.text", query),
+		),
+	)
+}
+
+// endSpan фиксирует ошибку в спане. sql.ErrNoRows ошибкой не считается: это штатный результат
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// execSQL выполняет запрос без результата в отдельном спане
+func execSQL(ctx context.Context, q queryer, name, query string, args ...any) (sql.Result, error) {
+	ctx, span := startSQLSpan(ctx, name, query)
+	res, err := q.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return res, err
+}
+
+// scanRow выполняет запрос, возвращающий одну строку, и сканирует ее в dest в отдельном спане.
+// Для SELECT ... FOR UPDATE время спана включает ожидание блокировки строки
+func scanRow(ctx context.Context, q queryer, name, query string, args []any, dest ...any) error {
+	ctx, span := startSQLSpan(ctx, name, query)
+	err := q.QueryRowContext(ctx, query, args...).Scan(dest...)
+	endSpan(span, err)
+	return err
+}
+
+// querySQL выполняет запрос, возвращающий строки. Спан покрывает выполнение запроса, но не чтение строк
+func querySQL(ctx context.Context, q queryer, name, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startSQLSpan(ctx, name, query)
+	rows, err := q.QueryContext(ctx, query, args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+// startRepositorySpan открывает спан для вызова метода репозитория
+func startRepositorySpan(ctx context.Context, method, walletUUID string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "Repository."+method, trace.WithAttributes(
+		attribute.String("wallet.uuid", walletUUID),
+	))
+}
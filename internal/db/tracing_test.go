@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spanRecorderOnce sync.Once
+	spanRecorder     *tracetest.SpanRecorder
+)
+
+// newSpanRecorder устанавливает провайдер, который хранит завершенные спаны в памяти. tracer пакета
+// получает провайдер из otel только при первой установке, поэтому провайдер один на все тесты
+func newSpanRecorder() {
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	})
+}
+
+// recordedSpan - последний завершенный спан с именем name
+func recordedSpan(t *testing.T, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	var found sdktrace.ReadOnlySpan
+	for _, span := range spanRecorder.Ended() {
+		if span.Name() == name {
+			found = span
+		}
+	}
+	require.NotNil(t, found, "span %s is not recorded", name)
+	return found
+}
+
+func Test_SQLSpans(t *testing.T) {
+	newSpanRecorder()
+	ctx := context.Background()
+	conn, replica := newLagReplica(t)
+
+	t.Run("scanRow success", func(t *testing.T) {
+		replica.set(3*time.Second, nil)
+		var lag float64
+		require.NoError(t, scanRow(ctx, conn, "TraceLag", "SELECT lag", nil, &lag))
+
+		span := recordedSpan(t, "sql TraceLag")
+		assert.Equal(t, codes.Unset, span.Status().Code)
+		assert.Contains(t, span.Attributes(), attribute.String("db.operation.name", "TraceLag"))
+		assert.Contains(t, span.Attributes(), attribute.String("db.query.text", "SELECT lag"))
+	})
+
+	t.Run("scanRow failure", func(t *testing.T) {
+		replica.set(0, errors.New("connection reset"))
+		var lag float64
+		require.Error(t, scanRow(ctx, conn, "TraceLagFailed", "SELECT lag", nil, &lag))
+
+		span := recordedSpan(t, "sql TraceLagFailed")
+		assert.Equal(t, codes.Error, span.Status().Code)
+		assert.Equal(t, "connection reset", span.Status().Description)
+		require.Len(t, span.Events(), 1)
+		assert.Equal(t, "exception", span.Events()[0].Name)
+	})
+
+	t.Run("execSQL failure", func(t *testing.T) {
+		// Драйвер не выполняет запросы без результата
+		_, err := execSQL(ctx, conn, "TraceExecFailed", "UPDATE wallets SET balance = 0")
+		require.Error(t, err)
+
+		span := recordedSpan(t, "sql TraceExecFailed")
+		assert.Equal(t, codes.Error, span.Status().Code)
+		assert.Equal(t, err.Error(), span.Status().Description)
+	})
+}
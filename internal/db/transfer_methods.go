@@ -0,0 +1,265 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-service/internal/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTransferNotFound = errors.New("transfer not found")
+	ErrSameWallet       = errors.New("cannot transfer to the same wallet")
+	// ErrTransferAborted - зачисление перевода отменено, сага компенсируется
+	ErrTransferAborted = errors.New("transfer credit aborted")
+)
+
+// Состояния перевода. Перевод в пределах одной базы сразу завершается (COMPLETED),
+// перевод между шардами проходит PENDING -> DEBITED -> COMPLETED или COMPENSATED
+const (
+	TransferPending     = "PENDING"
+	TransferDebited     = "DEBITED"
+	TransferCompleted   = "COMPLETED"
+	TransferCompensated = "COMPENSATED"
+	TransferFailed      = "FAILED"
+)
+
+const (
+	transferCreditCredited = "CREDITED"
+	transferCreditAborted  = "ABORTED"
+)
+
+type Transfer struct {
+	ID         string    `json:"id"`
+	FromWallet string    `json:"fromWalletId"`
+	ToWallet   string    `json:"toWalletId"`
+	Amount     int64     `json:"amount"`
+	State      string    `json:"state"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	// TenantID - арендатор обоих кошельков
+	TenantID string `json:"-"`
+}
+
+// transfer - перевод в одной транзакции: списание, зачисление и запись перевода.
+// Кошельки блокируются в порядке UUID, чтобы встречные переводы не приводили к дедлокам
+func (r *PostgresRepository) transfer(ctx context.Context, fromWalletUUID, toWalletUUID string, amount int64) (*Transfer, error) {
+	if fromWalletUUID == toWalletUUID {
+		return nil, ErrSameWallet
+	}
+
+	t := &Transfer{ID: uuid.NewString(), FromWallet: fromWalletUUID, ToWallet: toWalletUUID, Amount: amount, State: TransferCompleted,
+		TenantID: tenantOrDefault(ctx)}
+
+	err := r.runInTx(ctx, r.Isolation.Withdraw, func(tx *sql.Tx) error {
+		if fromWalletUUID < toWalletUUID {
+			if err := r.withdrawInTx(ctx, tx, fromWalletUUID, amount); err != nil {
+				return err
+			}
+			if err := r.depositInTx(ctx, tx, toWalletUUID, amount); err != nil {
+				return err
+			}
+		} else {
+			if err := r.depositInTx(ctx, tx, toWalletUUID, amount); err != nil {
+				return err
+			}
+			if err := r.withdrawInTx(ctx, tx, fromWalletUUID, amount); err != nil {
+				return err
+			}
+		}
+		return r.createTransferInTx(ctx, tx, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.WithContext(ctx).Infof("Transfer %s of %d from wallet %s to wallet %s completed", t.ID, logger.Amount(amount), fromWalletUUID, toWalletUUID)
+	return t, nil
+}
+
+func (r *PostgresRepository) createTransferInTx(ctx context.Context, q queryer, t *Transfer) error {
+	err := scanRow(ctx, q, "CreateTransfer", QueryCreateTransfer,
+		[]any{t.ID, t.FromWallet, t.ToWallet, t.Amount, t.State, t.Error, t.TenantID}, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		logger.Log.WithContext(ctx).Errorf("Failed to create transfer %s: %v", t.ID, err)
+		return fmt.Errorf("failed to create transfer: %w", err)
+	}
+	return nil
+}
+
+// updateTransferState переводит сагу из состояния from в to. Если состояние уже другое
+// (шаг выполнен параллельно, например восстановлением), возвращает ErrTransferNotFound
+func updateTransferState(ctx context.Context, q queryer, t *Transfer, from, to, reason string) error {
+	res, err := execSQL(ctx, q, "UpdateTransferState", QueryUpdateTransferState, t.ID, to, reason, from)
+	if err != nil {
+		return fmt.Errorf("failed to update transfer state: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update transfer state: %w", err)
+	} else if affected == 0 {
+		return fmt.Errorf("%w: %s is not in state %s", ErrTransferNotFound, t.ID, from)
+	}
+	t.State, t.Error = to, reason
+	return nil
+}
+
+// Шаги саги перевода между шардами. Запись перевода хранится на шарде отправителя,
+// отметки о зачислении - на шарде получателя.
+
+// CreateTransfer записывает новую сагу в состоянии PENDING
+func (r *PostgresRepository) CreateTransfer(ctx context.Context, t *Transfer) error {
+	if t.FromWallet == t.ToWallet {
+		return ErrSameWallet
+	}
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+	}
+	if t.TenantID == "" {
+		t.TenantID = tenantOrDefault(ctx)
+	}
+	t.State = TransferPending
+	return r.createTransferInTx(ctx, r.db, t)
+}
+
+// DebitTransfer списывает сумму с отправителя и переводит сагу в DEBITED одной транзакцией.
+// Если списание невозможно (нет кошелька, недостаточно средств, кошелек заморожен), сага завершается как FAILED
+func (r *PostgresRepository) DebitTransfer(ctx context.Context, t *Transfer) error {
+	err := r.runInTx(ctx, r.Isolation.Withdraw, func(tx *sql.Tx) error {
+		if err := r.withdrawInTx(ctx, tx, t.FromWallet, t.Amount); err != nil {
+			return err
+		}
+		return updateTransferState(ctx, tx, t, TransferPending, TransferDebited, "")
+	})
+	if errors.Is(err, ErrInsufficientFunds) || errors.Is(err, ErrWalletNotFound) || errors.Is(err, ErrWalletFrozen) {
+		if failErr := r.FailTransfer(ctx, t, err.Error()); failErr != nil {
+			logger.Log.WithContext(ctx).Errorf("Failed to mark transfer %s as failed: %v", t.ID, failErr)
+		}
+	}
+	return err
+}
+
+// FailTransfer завершает сагу, по которой деньги не списывались
+func (r *PostgresRepository) FailTransfer(ctx context.Context, t *Transfer, reason string) error {
+	return updateTransferState(ctx, r.db, t, TransferPending, TransferFailed, reason)
+}
+
+// CreditTransfer зачисляет перевод получателю не больше одного раза.
+// Если зачисление уже отменено AbortTransferCredit, возвращает ErrTransferAborted
+func (r *PostgresRepository) CreditTransfer(ctx context.Context, t *Transfer) error {
+	return r.runInTx(ctx, r.Isolation.Deposit, func(tx *sql.Tx) error {
+		res, err := execSQL(ctx, tx, "CreateTransferCredit", QueryCreateTransferCredit, t.ID, transferCreditCredited)
+		if err != nil {
+			return fmt.Errorf("failed to record transfer credit: %w", err)
+		}
+		if affected, err := res.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to record transfer credit: %w", err)
+		} else if affected == 0 {
+			// Отметка уже есть: перевод зачислен раньше или зачисление отменено
+			status, err := transferCreditStatus(ctx, tx, t.ID)
+			if err != nil {
+				return err
+			}
+			if status == transferCreditAborted {
+				return ErrTransferAborted
+			}
+			return nil
+		}
+		return r.depositInTx(ctx, tx, t.ToWallet, t.Amount)
+	})
+}
+
+// AbortTransferCredit запрещает зачисление перевода перед компенсацией.
+// Возвращает true, если перевод уже был зачислен и компенсировать его нельзя
+func (r *PostgresRepository) AbortTransferCredit(ctx context.Context, transferID string) (bool, error) {
+	res, err := execSQL(ctx, r.db, "CreateTransferCredit", QueryCreateTransferCredit, transferID, transferCreditAborted)
+	if err != nil {
+		return false, fmt.Errorf("failed to abort transfer credit: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return false, fmt.Errorf("failed to abort transfer credit: %w", err)
+	} else if affected == 1 {
+		return false, nil
+	}
+
+	status, err := transferCreditStatus(ctx, r.db, transferID)
+	if err != nil {
+		return false, err
+	}
+	return status == transferCreditCredited, nil
+}
+
+func transferCreditStatus(ctx context.Context, q queryer, transferID string) (string, error) {
+	var status string
+	if err := scanRow(ctx, q, "GetTransferCredit", QueryGetTransferCredit, []any{transferID}, &status); err != nil {
+		return "", fmt.Errorf("failed to get transfer credit: %w", err)
+	}
+	return status, nil
+}
+
+// CompleteTransfer завершает сагу после зачисления
+func (r *PostgresRepository) CompleteTransfer(ctx context.Context, t *Transfer) error {
+	return updateTransferState(ctx, r.db, t, TransferDebited, TransferCompleted, "")
+}
+
+// CompensateTransfer возвращает списанную сумму отправителю и завершает сагу как COMPENSATED
+// одной транзакцией. Вызывается только после AbortTransferCredit. Возврат проходит и на замороженный кошелек
+func (r *PostgresRepository) CompensateTransfer(ctx context.Context, t *Transfer, reason string) error {
+	ctx = allowFrozen(ctx)
+	return r.runInTx(ctx, r.Isolation.Deposit, func(tx *sql.Tx) error {
+		if err := updateTransferState(ctx, tx, t, TransferDebited, TransferCompensated, reason); err != nil {
+			return err
+		}
+		return r.depositInTx(ctx, tx, t.FromWallet, t.Amount)
+	})
+}
+
+// ListUnfinishedTransfers возвращает саги в состояниях PENDING и DEBITED, не менявшиеся с before
+func (r *PostgresRepository) ListUnfinishedTransfers(ctx context.Context, before time.Time, limit int) ([]Transfer, error) {
+	rows, err := querySQL(ctx, r.db, "ListUnfinishedTransfers", QueryListUnfinishedTransfers, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unfinished transfers: %w", err)
+	}
+	defer rows.Close()
+
+	var transfers []Transfer
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transfer: %w", err)
+		}
+		transfers = append(transfers, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate transfers: %w", err)
+	}
+	return transfers, nil
+}
+
+func (r *PostgresRepository) getTransfer(ctx context.Context, id string) (*Transfer, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrTransferNotFound
+	}
+
+	var t Transfer
+	err := scanRow(ctx, r.db, "GetTransfer", QueryGetTransfer, []any{id, tenantArg(ctx)},
+		&t.ID, &t.FromWallet, &t.ToWallet, &t.Amount, &t.State, &t.Error, &t.CreatedAt, &t.UpdatedAt, &t.TenantID)
+	if err == sql.ErrNoRows {
+		return nil, ErrTransferNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get transfer: %w", err)
+	}
+	return &t, nil
+}
+
+func scanTransfer(row rowScanner) (*Transfer, error) {
+	var t Transfer
+	if err := row.Scan(&t.ID, &t.FromWallet, &t.ToWallet, &t.Amount, &t.State, &t.Error, &t.CreatedAt, &t.UpdatedAt, &t.TenantID); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
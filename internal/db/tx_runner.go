@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/metrics"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrTransactionConflict - транзакция так и не прошла из-за конфликтов сериализации
+	// или дедлоков после всех повторов
+	ErrTransactionConflict = errors.New("transaction conflict, retry later")
+	// ErrCommitUnknown - COMMIT отправлен, но ответ базы не получен (разрыв соединения, отмена контекста):
+	// транзакция могла быть зафиксирована, повторять ее нельзя
+	ErrCommitUnknown = errors.New("transaction commit outcome is unknown")
+)
+
+// Коды ошибок PostgreSQL, при которых транзакцию безопасно выполнить заново целиком
+const (
+	pqSerializationFailure = "40001"
+	pqDeadlockDetected     = "40P01"
+)
+
+// RetryPolicy - повторы транзакций с экспоненциальной задержкой и случайным разбросом (full jitter)
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    500 * time.Millisecond,
+}
+
+// Isolation - уровень изоляции транзакций по операциям. sql.LevelDefault - уровень по умолчанию в базе
+type Isolation struct {
+	Deposit  sql.IsolationLevel
+	Withdraw sql.IsolationLevel
+}
+
+// ParseIsolationLevel разбирает уровень изоляции из конфигурации: "read_committed",
+// "repeatable_read", "serializable" или пустую строку (уровень по умолчанию)
+func ParseIsolationLevel(value string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(strings.ReplaceAll(value, "_", " "))) {
+	case "", "default":
+		return sql.LevelDefault, nil
+	case "read committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unsupported isolation level %q", value)
+	}
+}
+
+// runInTx выполняет единицу работы fn в транзакции с заданным уровнем изоляции.
+// При конфликте сериализации (40001) или дедлоке (40P01) транзакция откатывается
+// и выполняется заново целиком, поэтому fn не должна иметь побочных эффектов вне tx.
+func (r *PostgresRepository) runInTx(ctx context.Context, isolation sql.IsolationLevel, fn func(tx *sql.Tx) error) error {
+	log := logger.Log.WithContext(ctx)
+
+	policy := r.Retry
+	if policy.MaxAttempts <= 0 {
+		policy = DefaultRetryPolicy
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := r.runInTxOnce(ctx, isolation, fn)
+
+		code, retryable := retryableCode(err)
+		if !retryable {
+			return err
+		}
+
+		metrics.TxRetries.WithLabelValues(code).Inc()
+		if attempt >= policy.MaxAttempts {
+			log.Errorf("Transaction failed after %d attempts: %v", attempt, err)
+			return fmt.Errorf("%w: %v", ErrTransactionConflict, err)
+		}
+
+		delay := policy.backoff(attempt)
+		log.Warnf("Transaction attempt %d failed with retryable error %s, retrying in %s: %v", attempt, code, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *PostgresRepository) runInTxOnce(ctx context.Context, isolation sql.IsolationLevel, fn func(tx *sql.Tx) error) (err error) {
+	log := logger.Log.WithContext(ctx)
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: isolation})
+	if err != nil {
+		log.Errorf("Failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	var committed bool
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			log.Errorf("Transaction panicked: %v", p)
+			panic(p)
+		} else if !committed {
+			_ = tx.Rollback()
+			if isRejection(err) {
+				log.Warnf("Transaction rolled back: %v", err)
+			} else {
+				log.Errorf("Transaction rolled back: %v", err)
+			}
+		}
+	}()
+
+	if err = r.setLockTimeout(ctx, tx); err != nil {
+		log.Errorf("Failed to set lock timeout: %v", err)
+		return fmt.Errorf("failed to set lock timeout: %w", err)
+	}
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Errorf("Failed to commit transaction: %v", err)
+		// Ошибка от сервера означает откат, любая другая - что исход неизвестен
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) {
+			return fmt.Errorf("%w: failed to commit transaction: %w", ErrCommitUnknown, err)
+		}
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	committed = true
+	log.Info("Transaction committed successfully")
+	return nil
+}
+
+// rejections - отказы по правилам сервиса, а не сбои базы: после них транзакция откатывается штатно
+var rejections = []error{
+	ErrWalletNotFound,
+	ErrInsufficientFunds,
+	ErrWalletFrozen,
+	ErrWalletExists,
+	ErrWalletMoving,
+	ErrSameWallet,
+	ErrTransferNotFound,
+	ErrPendingOperationNotFound,
+	ErrPendingOperationDecided,
+}
+
+// isRejection - ошибка fn означает отказ в операции, а не сбой
+func isRejection(err error) bool {
+	for _, rejection := range rejections {
+		if errors.Is(err, rejection) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryableCode возвращает код ошибки PostgreSQL, если транзакцию можно повторить
+func retryableCode(err error) (string, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return "", false
+	}
+	switch string(pqErr.Code) {
+	case pqSerializationFailure, pqDeadlockDetected:
+		return string(pqErr.Code), true
+	}
+	return "", false
+}
+
+// backoff - случайная задержка в [0, min(MaxDelay, BaseDelay*2^(attempt-1))]
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
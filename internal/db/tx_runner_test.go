@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+	"wallet-service/internal/logger"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// txDriver - драйвер database/sql, который умеет только начинать, коммитить и откатывать транзакции.
+// Достаточно для runInTx, когда fn не выполняет запросов
+type txDriver struct {
+	commits, rollbacks atomic.Int32
+	// commitErr - ошибка COMMIT, nil - коммит успешен
+	commitErr error
+}
+
+type txConn struct{ driver *txDriver }
+
+type txHandle struct{ driver *txDriver }
+
+func (d *txDriver) Open(string) (driver.Conn, error) { return &txConn{driver: d}, nil }
+
+func (c *txConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *txConn) Close() error                        { return nil }
+func (c *txConn) Begin() (driver.Tx, error)           { return &txHandle{driver: c.driver}, nil }
+
+func (c *txConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return &txHandle{driver: c.driver}, nil
+}
+
+func (t *txHandle) Commit() error   { t.driver.commits.Add(1); return t.driver.commitErr }
+func (t *txHandle) Rollback() error { t.driver.rollbacks.Add(1); return nil }
+
+var txDriverSeq atomic.Int32
+
+func newTxRunnerRepository(t *testing.T, policy RetryPolicy) (*PostgresRepository, *txDriver) {
+	d := &txDriver{}
+	name := fmt.Sprintf("txrunner-%d", txDriverSeq.Add(1))
+	sql.Register(name, d)
+	conn, err := sql.Open(name, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	repo := NewPostgresRepository(conn)
+	repo.Retry = policy
+	return repo, d
+}
+
+func Test_RunInTxRetries(t *testing.T) {
+	ctx := context.Background()
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	conflict := &pq.Error{Code: pqSerializationFailure}
+
+	t.Run("retries until success", func(t *testing.T) {
+		repo, d := newTxRunnerRepository(t, policy)
+		var attempts int
+		err := repo.runInTx(ctx, sql.LevelSerializable, func(tx *sql.Tx) error {
+			attempts++
+			if attempts < 3 {
+				return conflict
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, int32(1), d.commits.Load())
+		assert.Equal(t, int32(2), d.rollbacks.Load())
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		repo, d := newTxRunnerRepository(t, policy)
+		var attempts int
+		err := repo.runInTx(ctx, sql.LevelSerializable, func(tx *sql.Tx) error {
+			attempts++
+			return &pq.Error{Code: pqDeadlockDetected}
+		})
+		assert.ErrorIs(t, err, ErrTransactionConflict)
+		assert.Equal(t, 3, attempts)
+		assert.Zero(t, d.commits.Load())
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		repo, _ := newTxRunnerRepository(t, policy)
+		var attempts int
+		err := repo.runInTx(ctx, sql.LevelDefault, func(tx *sql.Tx) error {
+			attempts++
+			return fmt.Errorf("failed to withdraw money: %w", &pq.Error{Code: "23505"})
+		})
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrTransactionConflict)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("commit without server response", func(t *testing.T) {
+		repo, d := newTxRunnerRepository(t, policy)
+		d.commitErr = driver.ErrBadConn
+		err := repo.runInTx(ctx, sql.LevelDefault, func(tx *sql.Tx) error { return nil })
+		assert.ErrorIs(t, err, ErrCommitUnknown)
+
+		d.commitErr = &pq.Error{Code: "23505"}
+		err = repo.runInTx(ctx, sql.LevelDefault, func(tx *sql.Tx) error { return nil })
+		assert.NotErrorIs(t, err, ErrCommitUnknown, "server rejected the commit, the transaction is rolled back")
+	})
+
+	t.Run("stops waiting when context is canceled", func(t *testing.T) {
+		repo, _ := newTxRunnerRepository(t, RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour})
+		ctx, cancel := context.WithCancel(ctx)
+		var attempts int
+		err := repo.runInTx(ctx, sql.LevelSerializable, func(tx *sql.Tx) error {
+			attempts++
+			cancel()
+			return conflict
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func Test_RunInTxRejectionLogLevel(t *testing.T) {
+	hooks := logger.Log.ReplaceHooks(make(logrus.LevelHooks))
+	defer logger.Log.ReplaceHooks(hooks)
+	hook := logtest.NewLocal(logger.Log)
+	repo, _ := newTxRunnerRepository(t, DefaultRetryPolicy)
+
+	for _, tt := range []struct {
+		err   error
+		level logrus.Level
+	}{
+		{ErrInsufficientFunds, logrus.WarnLevel},
+		{ErrWalletFrozen, logrus.WarnLevel},
+		{fmt.Errorf("fee wallet: %w", ErrWalletNotFound), logrus.WarnLevel},
+		{errors.New("connection reset"), logrus.ErrorLevel},
+	} {
+		hook.Reset()
+		err := repo.runInTx(context.Background(), sql.LevelDefault, func(tx *sql.Tx) error { return tt.err })
+		require.ErrorIs(t, err, tt.err)
+		require.NotNil(t, hook.LastEntry())
+		assert.Equal(t, tt.level, hook.LastEntry().Level, tt.err.Error())
+	}
+}
+
+func Test_RetryableCode(t *testing.T) {
+	var tests = []struct {
+		err       error
+		code      string
+		retryable bool
+	}{
+		{&pq.Error{Code: pqSerializationFailure}, pqSerializationFailure, true},
+		{&pq.Error{Code: pqDeadlockDetected}, pqDeadlockDetected, true},
+		{fmt.Errorf("failed to commit transaction: %w", &pq.Error{Code: pqSerializationFailure}), pqSerializationFailure, true},
+		{&pq.Error{Code: "23505"}, "", false}, // unique_violation
+		{&pq.Error{Code: "55P03"}, "", false}, // lock_not_available
+		{ErrInsufficientFunds, "", false},
+		{context.DeadlineExceeded, "", false},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		code, retryable := retryableCode(tt.err)
+		assert.Equal(t, tt.code, code, "%v", tt.err)
+		assert.Equal(t, tt.retryable, retryable, "%v", tt.err)
+	}
+}
+
+func Test_RetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 10 * time.Millisecond, MaxDelay: 500 * time.Millisecond}
+	for attempt := 1; attempt <= 10; attempt++ {
+		ceiling := min(policy.BaseDelay<<(attempt-1), policy.MaxDelay)
+		for range 100 {
+			delay := policy.backoff(attempt)
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, ceiling, "attempt %d", attempt)
+		}
+	}
+
+	// Сдвиг переполняет Duration - задержка все равно ограничена MaxDelay
+	assert.LessOrEqual(t, policy.backoff(80), policy.MaxDelay)
+	assert.Zero(t, RetryPolicy{}.backoff(3))
+}
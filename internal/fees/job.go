@@ -0,0 +1,54 @@
+package fees
+
+import (
+	"context"
+	"time"
+	"wallet-service/internal/logger"
+)
+
+// Store - начисленные комиссии за снятие, реализуется db.PostgresRepository
+type Store interface {
+	SettleFeeAccruals(ctx context.Context, limit int) (int64, error)
+}
+
+// StartJob раз в interval зачисляет начисленные комиссии на кошельки комиссий пачками по batchSize.
+// Пачка целиком - значит, начислений больше, и следующая зачисляется сразу, не дожидаясь интервала.
+// Задание можно запускать на всех репликах. Возвращает функцию остановки.
+func StartJob(store Store, interval time.Duration, batchSize int) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	logger.Log.Infof("Fee settlement scheduled every %s", interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				settle(store, batchSize, done)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func settle(store Store, batchSize int, done <-chan struct{}) {
+	for {
+		settled, err := store.SettleFeeAccruals(context.Background(), batchSize)
+		if err != nil {
+			logger.Log.Errorf("Fee settlement job failed: %v", err)
+			return
+		}
+		if settled < int64(batchSize) {
+			return
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
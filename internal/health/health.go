@@ -0,0 +1,124 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrShuttingDown - сервис завершает работу и не должен получать новые запросы
+var ErrShuttingDown = errors.New("shutting down")
+
+// Check - одна проверка готовности. nil - проверка пройдена
+type Check func(ctx context.Context) error
+
+// Readiness собирает проверки готовности сервиса принимать запросы (/readyz).
+// После StartShutdown сервис считается неготовым независимо от проверок,
+// чтобы балансировщик перестал направлять на него трафик, пока завершаются текущие запросы
+type Readiness struct {
+	mu       sync.RWMutex
+	names    []string
+	checks   map[string]Check
+	timeout  time.Duration
+	shutdown atomic.Bool
+}
+
+// NewReadiness создает набор проверок, каждая из которых ограничена timeout
+func NewReadiness(timeout time.Duration) *Readiness {
+	return &Readiness{checks: map[string]Check{}, timeout: timeout}
+}
+
+// Add добавляет проверку с именем name
+func (r *Readiness) Add(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.checks[name]; !ok {
+		r.names = append(r.names, name)
+		sort.Strings(r.names)
+	}
+	r.checks[name] = check
+}
+
+// StartShutdown переводит сервис в состояние "не готов"
+func (r *Readiness) StartShutdown() {
+	r.shutdown.Store(true)
+}
+
+// Result - результат проверки готовности: общий статус и ошибка каждой непройденной проверки
+type Result struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Check выполняет все проверки параллельно
+func (r *Readiness) Check(ctx context.Context) Result {
+	result := Result{Ready: true, Checks: map[string]string{}}
+	if r.shutdown.Load() {
+		result.Ready = false
+		result.Checks["shutdown"] = ErrShuttingDown.Error()
+		return result
+	}
+
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	r.mu.RLock()
+	names := append([]string(nil), r.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.RUnlock()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for i, name := range names {
+		if errs[i] != nil {
+			result.Ready = false
+			result.Checks[name] = errs[i].Error()
+		} else {
+			result.Checks[name] = "ok"
+		}
+	}
+	return result
+}
+
+// DBPing - база отвечает на ping
+func DBPing(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// PoolSaturation - занято не больше maxRatio соединений пула. Без ограничения размера пула
+// (SetMaxOpenConns не вызывался) проверка всегда проходит
+func PoolSaturation(db *sql.DB, maxRatio float64) Check {
+	return func(ctx context.Context) error {
+		stats := db.Stats()
+		if stats.MaxOpenConnections <= 0 || maxRatio <= 0 {
+			return nil
+		}
+		ratio := float64(stats.InUse) / float64(stats.MaxOpenConnections)
+		if ratio > maxRatio {
+			return fmt.Errorf("connection pool saturated: %d of %d connections in use", stats.InUse, stats.MaxOpenConnections)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Readiness(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("all checks pass", func(t *testing.T) {
+		r := NewReadiness(time.Second)
+		r.Add("database", func(context.Context) error { return nil })
+
+		result := r.Check(ctx)
+		assert.True(t, result.Ready)
+		assert.Equal(t, map[string]string{"database": "ok"}, result.Checks)
+	})
+
+	t.Run("failing check", func(t *testing.T) {
+		r := NewReadiness(time.Second)
+		r.Add("database", func(context.Context) error { return nil })
+		r.Add("schema", func(context.Context) error { return errors.New("outdated") })
+
+		result := r.Check(ctx)
+		assert.False(t, result.Ready)
+		assert.Equal(t, "outdated", result.Checks["schema"])
+		assert.Equal(t, "ok", result.Checks["database"])
+	})
+
+	t.Run("check is bounded by timeout", func(t *testing.T) {
+		r := NewReadiness(10 * time.Millisecond)
+		r.Add("database", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		result := r.Check(ctx)
+		assert.False(t, result.Ready)
+		assert.Equal(t, context.DeadlineExceeded.Error(), result.Checks["database"])
+	})
+
+	t.Run("not ready after shutdown starts", func(t *testing.T) {
+		r := NewReadiness(time.Second)
+		r.Add("database", func(context.Context) error { return nil })
+		r.StartShutdown()
+
+		result := r.Check(ctx)
+		assert.False(t, result.Ready)
+		assert.Equal(t, ErrShuttingDown.Error(), result.Checks["shutdown"])
+	})
+}
@@ -1,19 +1,90 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
 var Log = logrus.New()
 
+// Options - формат, уровень и назначение логов
+type Options struct {
+	// Format - text или json
+	Format string
+	Level  string
+	// Output - stdout, stderr или путь к файлу (записи дописываются в конец)
+	Output string
+	Redact Redaction
+}
+
+var (
+	outputMu sync.Mutex
+	// output - открытый Configure файл логов, закрывается при следующей настройке
+	output io.Closer
+)
+
+// InitLogger настраивает логгер до загрузки конфигурации: текст в stdout, уровень info
 func InitLogger() {
 	Log.SetFormatter(&logrus.TextFormatter{
 		FullTimestamp: true,
 	})
 
-	Log.SetLevel(logrus.DebugLevel)
+	Log.SetLevel(logrus.InfoLevel)
 
 	Log.SetOutput(os.Stdout)
+
+	Log.AddHook(TraceHook{})
+	Log.AddHook(RequestIDHook{})
+	Log.AddHook(RedactHook{})
+}
+
+// Configure применяет настройки из конфигурации. При ошибке текущие настройки не меняются
+func Configure(opts Options) error {
+	var formatter logrus.Formatter
+	switch opts.Format {
+	case "text":
+		formatter = &logrus.TextFormatter{FullTimestamp: true}
+	case "json":
+		formatter = &logrus.JSONFormatter{DisableHTMLEscape: true}
+	default:
+		return fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	level, err := logrus.ParseLevel(opts.Level)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	var out io.Writer
+	var closer io.Closer
+	switch opts.Output {
+	case "", "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		file, err := os.OpenFile(opts.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		out, closer = file, file
+	}
+
+	Log.SetFormatter(formatter)
+	Log.SetLevel(level)
+	Log.SetOutput(out)
+	SetRedaction(opts.Redact)
+
+	outputMu.Lock()
+	previous := output
+	output = closer
+	outputMu.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+	return nil
 }
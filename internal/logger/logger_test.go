@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_Redaction(t *testing.T) {
+	t.Cleanup(func() { SetRedaction(Redaction{}) })
+	const wallet = "d7af0768-704e-4f1c-9793-a44c2d1f9b75"
+
+	var tests = []struct {
+		name      string
+		redaction Redaction
+		message   string
+		amount    any
+	}{
+		{name: "No redaction", message: "deposit of 100 to " + wallet, amount: 100},
+		{name: "UUIDs", redaction: Redaction{UUIDs: true}, message: "deposit of 100 to d7af0768-***", amount: 100},
+		{name: "Amounts", redaction: Redaction{Amounts: true}, message: "deposit of *** to " + wallet, amount: masked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetRedaction(tt.redaction)
+			entry := logrus.NewEntry(logrus.New()).WithField("amount", 100)
+			entry.Message = fmt.Sprintf("deposit of %d to %s", Amount(100), wallet)
+
+			require.NoError(t, RedactHook{}.Fire(entry))
+			assert.Equal(t, tt.message, entry.Message)
+			assert.Equal(t, tt.amount, entry.Data["amount"])
+		})
+	}
+}
+
+func Test_ParseRedaction(t *testing.T) {
+	r, err := ParseRedaction([]string{"uuid", " amount"})
+	require.NoError(t, err)
+	assert.Equal(t, Redaction{UUIDs: true, Amounts: true}, r)
+
+	_, err = ParseRedaction([]string{"email"})
+	assert.Error(t, err)
+}
+
+func Test_RequestIDInJSONLog(t *testing.T) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.SetOutput(&buf)
+	log.AddHook(RequestIDHook{})
+
+	log.WithContext(WithRequestID(context.Background(), "req-42")).Info("hello")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "req-42", record["request_id"])
+	assert.Equal(t, "hello", record["msg"])
+}
+
+func Test_TraceHook(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	valid := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+
+	var tests = []struct {
+		name    string
+		ctx     context.Context
+		traceID any
+		spanID  any
+	}{
+		{name: "Valid span", ctx: trace.ContextWithSpanContext(context.Background(), valid),
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736", spanID: "00f067aa0ba902b7"},
+		{name: "No span", ctx: context.Background()},
+		{name: "Invalid span", ctx: trace.ContextWithSpanContext(context.Background(), trace.SpanContext{})},
+		{name: "No context"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := logrus.New()
+			log.SetFormatter(&logrus.JSONFormatter{})
+			log.SetOutput(&buf)
+			log.AddHook(TraceHook{})
+
+			if tt.ctx != nil {
+				log.WithContext(tt.ctx).Info("hello")
+			} else {
+				log.Info("hello")
+			}
+
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+			assert.Equal(t, tt.traceID, record["trace_id"])
+			assert.Equal(t, tt.spanID, record["span_id"])
+		})
+	}
+}
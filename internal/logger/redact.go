@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// masked - замена скрытого значения в логах
+const masked = "***"
+
+// Redaction - какие значения скрываются в логах
+type Redaction struct {
+	// UUIDs - от UUID кошельков и переводов остаются первые 8 символов
+	UUIDs bool
+	// Amounts - суммы и балансы, записанные через Amount или в поле amount
+	Amounts bool
+}
+
+// ParseRedaction разбирает список скрываемых значений: uuid, amount
+func ParseRedaction(values []string) (Redaction, error) {
+	var r Redaction
+	for _, value := range values {
+		switch strings.TrimSpace(value) {
+		case "":
+		case "uuid":
+			r.UUIDs = true
+		case "amount":
+			r.Amounts = true
+		default:
+			return r, fmt.Errorf("unknown redaction %q, expected uuid or amount", value)
+		}
+	}
+	return r, nil
+}
+
+var redaction atomic.Pointer[Redaction]
+
+// SetRedaction меняет политику скрытия значений
+func SetRedaction(r Redaction) {
+	redaction.Store(&r)
+}
+
+func currentRedaction() Redaction {
+	if r := redaction.Load(); r != nil {
+		return *r
+	}
+	return Redaction{}
+}
+
+// Amount - сумма в записи лога: logger.Log.Infof("deposit of %d", logger.Amount(amount)).
+// При скрытии сумм выводится как ***
+type Amount int64
+
+func (a Amount) Format(f fmt.State, verb rune) {
+	if currentRedaction().Amounts {
+		io.WriteString(f, masked)
+		return
+	}
+	fmt.Fprintf(f, fmt.FormatString(f, verb), int64(a))
+}
+
+var uuidPattern = regexp.MustCompile(`(?i)\b([0-9a-f]{8})-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+
+func maskUUIDs(s string) string {
+	return uuidPattern.ReplaceAllString(s, "$1-"+masked)
+}
+
+// RedactHook скрывает UUID в сообщении и строковых полях записи и поле amount по текущей политике
+type RedactHook struct{}
+
+func (RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (RedactHook) Fire(entry *logrus.Entry) error {
+	r := currentRedaction()
+	if !r.UUIDs && !r.Amounts {
+		return nil
+	}
+
+	if r.UUIDs {
+		entry.Message = maskUUIDs(entry.Message)
+	}
+	for key, value := range entry.Data {
+		if r.Amounts && key == "amount" {
+			entry.Data[key] = masked
+			continue
+		}
+		if !r.UUIDs {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = maskUUIDs(v)
+		case error:
+			entry.Data[key] = maskUUIDs(v.Error())
+		}
+	}
+	return nil
+}
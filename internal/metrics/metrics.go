@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Метки метрик должны иметь малую кардинальность: UUID кошельков и прочие
+// идентификаторы в метки не попадают. Арендаторы задаются в TENANTS_FILE, их немного.
+const (
+	OutcomeSuccess           = "success"
+	OutcomeInsufficientFunds = "insufficient_funds"
+	OutcomeNotFound          = "not_found"
+	OutcomeFrozen            = "frozen"
+	OutcomeTimeout           = "timeout"
+	OutcomeCanceled          = "canceled"
+	OutcomeConflict          = "conflict"
+	OutcomeError             = "error"
+)
+
+// Registry - отдельный реестр сервиса вместо глобального prometheus.DefaultRegisterer
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Операции с кошельками по арендатору, типу (deposit, withdraw) и результату
+	Operations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wallet",
+		Name:      "operations_total",
+		Help:      "Wallet operations by tenant, type and outcome.",
+	}, []string{"tenant", "operation", "outcome"})
+
+	// Распределение сумм успешных операций по арендатору
+	OperationAmount = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "wallet",
+		Name:      "operation_amount",
+		Help:      "Amounts of successful wallet operations by tenant.",
+		Buckets:   prometheus.ExponentialBuckets(100, 10, 8),
+	}, []string{"tenant", "operation"})
+
+	// Повторы транзакций по коду ошибки PostgreSQL (40001, 40P01)
+	TxRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wallet",
+		Name:      "transaction_retries_total",
+		Help:      "Transactions retried after serialization failures or deadlocks.",
+	}, []string{"code"})
+
+	// Размер пачек групповой фиксации депозитов
+	DepositBatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "wallet",
+		Name:      "deposit_batch_size",
+		Help:      "Number of deposits committed in one batch transaction.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+
+	// Пачки депозитов, которые не прошли и были проведены по одному
+	DepositBatchFallbacks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "wallet",
+		Name:      "deposit_batch_fallbacks_total",
+		Help:      "Deposit batches that failed and were processed one deposit at a time.",
+	})
+
+	// Чтения баланса через кэш: hit, miss и bypass (строго согласованное чтение)
+	BalanceCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wallet",
+		Name:      "balance_cache_requests_total",
+		Help:      "Balance reads served by the cache by result.",
+	}, []string{"result"})
+
+	// Отставание реплик в секундах по номеру реплики
+	ReplicaLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "wallet",
+		Name:      "db_replica_lag_seconds",
+		Help:      "Replication lag of read replicas.",
+	}, []string{"replica"})
+
+	// Чтения из базы по цели: primary или replica
+	DBReads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wallet",
+		Name:      "db_reads_total",
+		Help:      "Read-only repository queries by routing target.",
+	}, []string{"target"})
+
+	// Запросы, отклоненные ограничением частоты, по операции и области (client, wallet, global)
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wallet",
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by rate limits by operation and scope.",
+	}, []string{"operation", "scope"})
+
+	// Решения проверки рисков: allow, review, deny
+	RiskDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wallet",
+		Name:      "risk_decisions_total",
+		Help:      "Risk decisions for wallet operations by outcome.",
+	}, []string{"outcome"})
+
+	// Длительность HTTP-запросов по шаблону маршрута
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "wallet",
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// Длительность вызовов репозитория
+	RepositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "wallet",
+		Name:      "repository_duration_seconds",
+		Help:      "Repository method latency by method and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Operations,
+		OperationAmount,
+		TxRetries,
+		DepositBatchSize,
+		DepositBatchFallbacks,
+		BalanceCacheRequests,
+		ReplicaLag,
+		DBReads,
+		RateLimited,
+		RiskDecisions,
+		HTTPRequestDuration,
+		RepositoryDuration,
+	)
+}
+
+// RegisterDBStats экспортирует sql.DB.Stats() пула соединений как метрики go_sql_* с меткой db_name=name.
+// Имена пулов должны быть уникальны: повторное имя - ошибка, а не паника
+func RegisterDBStats(db *sql.DB, name string) error {
+	if err := Registry.Register(collectors.NewDBStatsCollector(db, name)); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			return fmt.Errorf("connection pool %q is already registered, pool names must be unique", name)
+		}
+		return fmt.Errorf("failed to register stats of connection pool %q: %w", name, err)
+	}
+	return nil
+}
+
+// Handler - обработчик для /metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegisterDBStats(t *testing.T) {
+	// sql.Open не подключается к базе, для метрик пула соединение не нужно
+	first, err := sql.Open("postgres", "host=localhost")
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := sql.Open("postgres", "host=localhost")
+	require.NoError(t, err)
+	defer second.Close()
+
+	require.NoError(t, RegisterDBStats(first, "stats-test"))
+	// Пул с тем же именем, например шард с именем основной базы, - ошибка, а не паника
+	err = RegisterDBStats(second, "stats-test")
+	assert.ErrorContains(t, err, `connection pool "stats-test" is already registered`)
+	require.NoError(t, RegisterDBStats(second, "stats-test-replica"))
+}
+
+func Test_Handler(t *testing.T) {
+	conn, err := sql.Open("postgres", "host=localhost")
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, RegisterDBStats(conn, "handler-test"))
+	Operations.WithLabelValues("handler-test", "deposit", OutcomeSuccess).Inc()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	body := w.Body.String()
+	assert.Contains(t, body, `wallet_operations_total{operation="deposit",outcome="success",tenant="handler-test"} 1`)
+	assert.Contains(t, body, `go_sql_open_connections{db_name="handler-test"} 0`)
+	assert.Contains(t, body, "go_goroutines")
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requestCounts - число измеренных запросов по метке route
+func requestCounts(t *testing.T, method string) map[string]uint64 {
+	families, err := Registry.Gather()
+	require.NoError(t, err)
+
+	counts := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "wallet_http_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["method"] == method {
+				counts[labels["route"]+" "+labels["status"]] += metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return counts
+}
+
+func Test_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.PATCH("/wallets/:walletUUID", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, path := range []string{
+		"/wallets/d7af0768-704e-4f1c-9793-a44c2d1f9b75",
+		"/wallets/0b8a5a8e-6f7c-4c4e-9c2b-1d1e2f3a4b5c",
+		"/unknown/d7af0768-704e-4f1c-9793-a44c2d1f9b75",
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, path, nil))
+	}
+
+	// В метке шаблон маршрута или unmatched, фактические пути с UUID в метки не попадают
+	assert.Equal(t, map[string]uint64{
+		"/wallets/:walletUUID 204": 2,
+		"unmatched 404":            1,
+	}, requestCounts(t, http.MethodPatch))
+}
@@ -0,0 +1,131 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+)
+
+// Store - очередь операций в PostgreSQL (db.PostgresRepository)
+type Store interface {
+	db.OperationQueue
+	ProcessNextOperation(ctx context.Context, retry db.OperationRetry) (*db.AsyncOperation, error)
+}
+
+// Options - параметры воркеров
+type Options struct {
+	// Workers - число воркеров этой реплики, 0 - реплика только ставит операции в очередь
+	Workers int
+	// PollInterval - как часто свободный воркер проверяет очередь
+	PollInterval time.Duration
+	Retry        db.OperationRetry
+	// Timeout - дедлайн выполнения одной операции, 0 - без дедлайна
+	Timeout time.Duration
+	// OnCompleted вызывается после успешного выполнения операции, например для сброса кэша баланса
+	OnCompleted func(op *db.AsyncOperation)
+}
+
+// Queue - асинхронные операции с кошельками. Операция, поставленная в очередь, будит воркеров
+// этой реплики; воркеры других реплик находят ее при очередной проверке очереди
+type Queue struct {
+	store Store
+	opts  Options
+	wake  chan struct{}
+}
+
+func NewQueue(store Store, opts Options) *Queue {
+	return &Queue{store: store, opts: opts, wake: make(chan struct{}, 1)}
+}
+
+// EnqueueOperation ставит операцию в очередь
+func (q *Queue) EnqueueOperation(ctx context.Context, walletUUID, operationType string, amount int64) (*db.AsyncOperation, error) {
+	op, err := q.store.EnqueueOperation(ctx, walletUUID, operationType, amount)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return op, nil
+}
+
+// GetOperation - статус операции
+func (q *Queue) GetOperation(ctx context.Context, id string) (*db.AsyncOperation, error) {
+	return q.store.GetOperation(ctx, id)
+}
+
+// Start запускает воркеров. Возвращает функцию остановки, которая дожидается завершения
+// операций, выполняемых в этот момент
+func (q *Queue) Start() func() {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	logger.Log.Infof("Starting %d async operation workers (poll interval %s)", q.opts.Workers, q.opts.PollInterval)
+	for i := 0; i < q.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(stop)
+		}()
+	}
+
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+func (q *Queue) work(stop <-chan struct{}) {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Операции выполняются подряд, пока очередь не опустеет
+		for q.processNext() {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// processNext выполняет очередную операцию. false - очередь пуста или недоступна
+func (q *Queue) processNext() bool {
+	ctx := context.Background()
+	if q.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.opts.Timeout)
+		defer cancel()
+	}
+
+	op, err := q.store.ProcessNextOperation(ctx, q.opts.Retry)
+	if op == nil {
+		if err != nil {
+			logger.Log.Errorf("Failed to process async operations: %v", err)
+		}
+		return false
+	}
+
+	switch op.Status {
+	case db.OperationCompleted:
+		logger.Log.Infof("Async operation %s (%s of %d on wallet %s) completed", op.ID, op.OperationType, logger.Amount(op.Amount), op.WalletUUID)
+		if q.opts.OnCompleted != nil {
+			q.opts.OnCompleted(op)
+		}
+	case db.OperationFailed:
+		logger.Log.Warnf("Async operation %s (%s of %d on wallet %s) failed: %s", op.ID, op.OperationType, logger.Amount(op.Amount), op.WalletUUID, op.Error)
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wallet-service/internal/db"
+)
+
+// fakeStore - очередь в памяти: операции на кошельке failWallet отклоняются
+type fakeStore struct {
+	mu         sync.Mutex
+	ops        map[string]*db.AsyncOperation
+	pending    []string
+	failWallet string
+	claimErr   error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{ops: map[string]*db.AsyncOperation{}}
+}
+
+func (f *fakeStore) EnqueueOperation(ctx context.Context, walletUUID, operationType string, amount int64) (*db.AsyncOperation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	op := &db.AsyncOperation{ID: walletUUID + "-" + operationType, WalletUUID: walletUUID, OperationType: operationType, Amount: amount, Status: db.OperationPending}
+	f.ops[op.ID] = op
+	f.pending = append(f.pending, op.ID)
+	copied := *op
+	return &copied, nil
+}
+
+func (f *fakeStore) GetOperation(ctx context.Context, id string) (*db.AsyncOperation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	op, ok := f.ops[id]
+	if !ok {
+		return nil, db.ErrOperationNotFound
+	}
+	copied := *op
+	return &copied, nil
+}
+
+func (f *fakeStore) ProcessNextOperation(ctx context.Context, retry db.OperationRetry) (*db.AsyncOperation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.claimErr != nil {
+		return nil, f.claimErr
+	}
+	if len(f.pending) == 0 {
+		return nil, nil
+	}
+	op := f.ops[f.pending[0]]
+	f.pending = f.pending[1:]
+	op.Attempts++
+	op.Status = db.OperationCompleted
+	if op.WalletUUID == f.failWallet {
+		op.Status, op.Error = db.OperationFailed, db.ErrInsufficientFunds.Error()
+	}
+	copied := *op
+	return &copied, nil
+}
+
+func Test_QueueProcessesOperations(t *testing.T) {
+	store := newFakeStore()
+	store.failWallet = "w2"
+
+	var mu sync.Mutex
+	var completed []string
+	q := NewQueue(store, Options{
+		Workers:      2,
+		PollInterval: time.Hour,
+		OnCompleted: func(op *db.AsyncOperation) {
+			mu.Lock()
+			defer mu.Unlock()
+			completed = append(completed, op.WalletUUID)
+		},
+	})
+	stop := q.Start()
+	defer stop()
+
+	ctx := context.Background()
+	op, err := q.EnqueueOperation(ctx, "w1", "DEPOSIT", 100)
+	require.NoError(t, err)
+	assert.Equal(t, db.OperationPending, op.Status)
+	_, err = q.EnqueueOperation(ctx, "w2", "WITHDRAW", 100)
+	require.NoError(t, err)
+
+	// Постановка в очередь будит воркеров, не дожидаясь PollInterval
+	require.Eventually(t, func() bool {
+		first, _ := q.GetOperation(ctx, "w1-DEPOSIT")
+		second, _ := q.GetOperation(ctx, "w2-WITHDRAW")
+		return first.Status == db.OperationCompleted && second.Status == db.OperationFailed
+	}, time.Second, 5*time.Millisecond)
+
+	failed, err := q.GetOperation(ctx, "w2-WITHDRAW")
+	require.NoError(t, err)
+	assert.Equal(t, db.ErrInsufficientFunds.Error(), failed.Error)
+
+	mu.Lock()
+	assert.Equal(t, []string{"w1"}, completed)
+	mu.Unlock()
+}
+
+func Test_QueuePollsAfterStoreError(t *testing.T) {
+	store := newFakeStore()
+	store.claimErr = errors.New("connection refused")
+	q := NewQueue(store, Options{Workers: 1, PollInterval: 10 * time.Millisecond})
+	stop := q.Start()
+	defer stop()
+
+	ctx := context.Background()
+	_, err := q.EnqueueOperation(ctx, "w1", "DEPOSIT", 100)
+	require.NoError(t, err)
+
+	time.Sleep(30 * time.Millisecond)
+	store.mu.Lock()
+	store.claimErr = nil
+	store.mu.Unlock()
+
+	// После восстановления базы операция выполняется при очередной проверке очереди
+	require.Eventually(t, func() bool {
+		op, _ := q.GetOperation(ctx, "w1-DEPOSIT")
+		return op.Status == db.OperationCompleted
+	}, time.Second, 5*time.Millisecond)
+}
+
+func Test_QueueWithoutWorkers(t *testing.T) {
+	store := newFakeStore()
+	q := NewQueue(store, Options{PollInterval: time.Millisecond})
+	stop := q.Start()
+
+	_, err := q.EnqueueOperation(context.Background(), "w1", "DEPOSIT", 100)
+	require.NoError(t, err)
+	stop()
+
+	// Реплика без воркеров только ставит операции в очередь
+	op, err := q.GetOperation(context.Background(), "w1-DEPOSIT")
+	require.NoError(t, err)
+	assert.Equal(t, db.OperationPending, op.Status)
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/metrics"
+)
+
+// Store - хранилище корзин токенов. Take пополняет корзину key по limit, забирает из нее токен,
+// если он есть, и возвращает оставшееся число токенов
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (tokens float64, allowed bool, err error)
+}
+
+// Request - запрос, к которому применяются ограничения. Пустой Wallet - правила по кошельку не применяются
+type Request struct {
+	Operation string
+	Client    string
+	Wallet    string
+}
+
+// Decision - результат проверки. Limit, Remaining и Reset относятся к самому строгому из примененных
+// ограничений и отдаются в заголовках RateLimit-*
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+	// Scope - область, ограничение которой отклонило запрос
+	Scope string
+	// Limited - к запросу применялось хотя бы одно правило
+	Limited bool
+}
+
+// Limiter проверяет запросы по правилам. Правила можно менять во время работы
+type Limiter struct {
+	store Store
+	rules atomic.Pointer[[]Rule]
+}
+
+func NewLimiter(store Store, rules []Rule) *Limiter {
+	l := &Limiter{store: store}
+	l.SetRules(rules)
+	return l
+}
+
+// SetRules заменяет правила. Корзины существующих правил сохраняются
+func (l *Limiter) SetRules(rules []Rule) {
+	l.rules.Store(&rules)
+}
+
+// Allow забирает по токену из корзины каждого подходящего правила: клиента, кошелька и общей.
+// Первая пустая корзина отклоняет запрос, следующие не расходуются. Если хранилище недоступно,
+// правило пропускается: отказ хранилища не должен останавливать операции с кошельками
+func (l *Limiter) Allow(ctx context.Context, req Request) Decision {
+	decision := Decision{Allowed: true, Remaining: math.MaxInt}
+
+	for _, rule := range *l.rules.Load() {
+		if rule.Operation != OperationAny && rule.Operation != req.Operation {
+			continue
+		}
+
+		var id string
+		switch rule.Scope {
+		case ScopeClient:
+			id = req.Client
+		case ScopeWallet:
+			if req.Wallet == "" {
+				continue
+			}
+			id = req.Wallet
+		}
+
+		key := rule.Operation + ":" + rule.Scope + ":" + id
+		tokens, allowed, err := l.store.Take(ctx, key, rule.Limit)
+		if err != nil {
+			logger.Log.WithContext(ctx).Warnf("Rate limit %s.%s skipped: %v", rule.Operation, rule.Scope, err)
+			continue
+		}
+
+		remaining := int(math.Floor(tokens))
+		if !decision.Limited || remaining < decision.Remaining {
+			decision.Limit = rule.Limit.Burst
+			decision.Remaining = remaining
+			decision.Reset = rule.Limit.resetAfter(tokens)
+		}
+		decision.Limited = true
+
+		if !allowed {
+			decision.Allowed = false
+			decision.Scope = rule.Scope
+			decision.RetryAfter = rule.Limit.retryAfter(tokens)
+			operation := req.Operation
+			if operation == "" {
+				operation = "unknown"
+			}
+			metrics.RateLimited.WithLabelValues(operation, rule.Scope).Inc()
+			return decision
+		}
+	}
+	return decision
+}
@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseRules(t *testing.T) {
+	rules, err := ParseRules([]string{"*.global=600/m", "withdraw.client=5/s:10", " withdraw.wallet=2/s "})
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	// Правила упорядочены по области: клиент, кошелек, общие
+	assert.Equal(t, Rule{Operation: "withdraw", Scope: ScopeClient, Limit: Limit{Rate: 5, Burst: 10}}, rules[0])
+	assert.Equal(t, Rule{Operation: "withdraw", Scope: ScopeWallet, Limit: Limit{Rate: 2, Burst: 2}}, rules[1])
+	assert.Equal(t, Rule{Operation: "*", Scope: ScopeGlobal, Limit: Limit{Rate: 10, Burst: 600}}, rules[2])
+
+	for _, spec := range []string{"withdraw.client", "refund.client=1/s", "withdraw.ip=1/s", "withdraw.client=0/s", "withdraw.client=1/d", "withdraw.client=1/s:0"} {
+		_, err := ParseRules([]string{spec})
+		assert.Error(t, err, spec)
+	}
+	_, err = ParseRules([]string{"withdraw.client=1/s", "withdraw.client=2/s"})
+	assert.ErrorContains(t, err, "duplicate")
+}
+
+func Test_MemoryStore(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 2, Burst: 3}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, allowed, err := store.Take(ctx, "k", limit)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+	tokens, allowed, _ := store.Take(ctx, "k", limit)
+	assert.False(t, allowed)
+	assert.Equal(t, 0.0, tokens)
+
+	// За полсекунды при 2 токенах в секунду появляется один токен
+	now = now.Add(500 * time.Millisecond)
+	_, allowed, _ = store.Take(ctx, "k", limit)
+	assert.True(t, allowed)
+
+	// Корзина не переполняется сверх burst
+	now = now.Add(time.Hour)
+	tokens, _, _ = store.Take(ctx, "k", limit)
+	assert.Equal(t, 2.0, tokens)
+}
+
+// failingStore - недоступное хранилище корзин
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit) (float64, bool, error) {
+	return 0, false, errors.New("connection refused")
+}
+
+func Test_Limiter(t *testing.T) {
+	ctx := context.Background()
+	rules, err := ParseRules([]string{"withdraw.client=2/h", "withdraw.wallet=1/h", "*.global=100/h"})
+	require.NoError(t, err)
+
+	t.Run("wallet limit", func(t *testing.T) {
+		limiter := NewLimiter(NewMemoryStore(), rules)
+
+		decision := limiter.Allow(ctx, Request{Operation: OperationWithdraw, Client: "c1", Wallet: "w1"})
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 0, decision.Remaining)
+		assert.Equal(t, 1, decision.Limit)
+
+		decision = limiter.Allow(ctx, Request{Operation: OperationWithdraw, Client: "c1", Wallet: "w1"})
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, ScopeWallet, decision.Scope)
+		assert.InDelta(t, time.Hour.Seconds(), decision.RetryAfter.Seconds(), 1)
+
+		// Отклоненный запрос уже израсходовал токен клиента, другой кошелек клиенту не доступен
+		decision = limiter.Allow(ctx, Request{Operation: OperationWithdraw, Client: "c1", Wallet: "w2"})
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, ScopeClient, decision.Scope)
+
+		decision = limiter.Allow(ctx, Request{Operation: OperationWithdraw, Client: "c2", Wallet: "w2"})
+		assert.True(t, decision.Allowed)
+	})
+
+	t.Run("other operations use only common rules", func(t *testing.T) {
+		limiter := NewLimiter(NewMemoryStore(), rules)
+
+		decision := limiter.Allow(ctx, Request{Operation: OperationBalance, Client: "c1", Wallet: "w1"})
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 100, decision.Limit)
+		assert.Equal(t, 99, decision.Remaining)
+	})
+
+	t.Run("rules replaced at runtime", func(t *testing.T) {
+		limiter := NewLimiter(NewMemoryStore(), nil)
+		assert.False(t, limiter.Allow(ctx, Request{Operation: OperationWithdraw}).Limited)
+
+		limiter.SetRules(rules)
+		assert.True(t, limiter.Allow(ctx, Request{Operation: OperationWithdraw}).Limited)
+	})
+
+	t.Run("store failure lets requests through", func(t *testing.T) {
+		limiter := NewLimiter(failingStore{}, rules)
+		assert.True(t, limiter.Allow(ctx, Request{Operation: OperationWithdraw, Client: "c1", Wallet: "w1"}).Allowed)
+	})
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Области ограничения: клиент API, кошелек и все запросы сервиса
+const (
+	ScopeClient = "client"
+	ScopeWallet = "wallet"
+	ScopeGlobal = "global"
+)
+
+// Операции, для которых задаются ограничения. OperationAny - правило для всех операций
+// с общей корзиной
+const (
+	OperationDeposit   = "deposit"
+	OperationWithdraw  = "withdraw"
+	OperationBalance   = "balance"
+	OperationStatement = "statement"
+	OperationTransfer  = "transfer"
+	OperationAny       = "*"
+)
+
+var (
+	operations = []string{OperationDeposit, OperationWithdraw, OperationBalance, OperationStatement, OperationTransfer, OperationAny}
+	// scopes в порядке проверки: сначала клиент, чтобы превысивший лимит клиент не расходовал общие токены
+	scopes  = []string{ScopeClient, ScopeWallet, ScopeGlobal}
+	periods = map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+)
+
+// Limit - корзина токенов: Rate токенов в секунду, не больше Burst
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Rule - ограничение операции Operation в области Scope
+type Rule struct {
+	Operation string
+	Scope     string
+	Limit     Limit
+}
+
+// ParseRules разбирает правила вида операция.область=N/период[:burst], например withdraw.client=5/s:10
+// или *.global=1000/m. Период - s, m или h, burst по умолчанию равен N
+func ParseRules(specs []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(specs))
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		rule, err := parseRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit %q: %w", spec, err)
+		}
+		name := rule.Operation + "." + rule.Scope
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate rate limit for %s", name)
+		}
+		seen[name] = true
+		rules = append(rules, rule)
+	}
+
+	slices.SortStableFunc(rules, func(a, b Rule) int {
+		return slices.Index(scopes, a.Scope) - slices.Index(scopes, b.Scope)
+	})
+	return rules, nil
+}
+
+func parseRule(spec string) (Rule, error) {
+	var rule Rule
+
+	target, value, ok := strings.Cut(spec, "=")
+	if !ok {
+		return rule, fmt.Errorf("expected operation.scope=N/period[:burst]")
+	}
+	operation, scope, ok := strings.Cut(target, ".")
+	if !ok || !slices.Contains(operations, operation) {
+		return rule, fmt.Errorf("operation must be one of %s", strings.Join(operations, ", "))
+	}
+	if !slices.Contains(scopes, scope) {
+		return rule, fmt.Errorf("scope must be one of %s", strings.Join(scopes, ", "))
+	}
+	rule.Operation, rule.Scope = operation, scope
+
+	rate, burst, hasBurst := strings.Cut(value, ":")
+	count, period, ok := strings.Cut(rate, "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n < 1 {
+		return rule, fmt.Errorf("rate must be a positive number of requests per period")
+	}
+	unit, ok := periods[period]
+	if !ok {
+		return rule, fmt.Errorf("period must be s, m or h")
+	}
+	rule.Limit = Limit{Rate: float64(n) / unit.Seconds(), Burst: n}
+
+	if hasBurst {
+		b, err := strconv.Atoi(burst)
+		if err != nil || b < 1 {
+			return rule, fmt.Errorf("burst must be a positive number")
+		}
+		rule.Limit.Burst = b
+	}
+	return rule, nil
+}
+
+// retryAfter - через сколько в корзине появится токен
+func (l Limit) retryAfter(tokens float64) time.Duration {
+	return secondsToDuration(math.Max(1-tokens, 0) / l.Rate)
+}
+
+// resetAfter - через сколько корзина снова будет полной
+func (l Limit) resetAfter(tokens float64) time.Duration {
+	return secondsToDuration(math.Max(float64(l.Burst)-tokens, 0) / l.Rate)
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+	"wallet-service/internal/logger"
+)
+
+// sweepEvery - раз в столько обращений MemoryStore удаляет снова заполнившиеся корзины
+const sweepEvery = 1024
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// MemoryStore - корзины в памяти процесса. Каждая реплика сервиса ограничивает запросы независимо
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.calls++
+	if s.calls%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	b.tokens = refill(b.tokens, now.Sub(b.updated), limit)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return b.tokens, false, nil
+	}
+	b.tokens--
+	return b.tokens, true, nil
+}
+
+// sweep удаляет корзины, которые уже снова полные: новая корзина создается полной
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if refill(b.tokens, now.Sub(b.updated), b.limit) >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.Rate)
+}
+
+// BucketRepository - корзины в PostgreSQL (db.PostgresRepository)
+type BucketRepository interface {
+	TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (float64, bool, error)
+	DeleteIdleRateLimitBuckets(ctx context.Context, idle time.Duration) (int64, error)
+}
+
+// PostgresStore - корзины в общей таблице rate_limit_buckets: ограничения действуют на все реплики сервиса
+type PostgresStore struct {
+	repo BucketRepository
+}
+
+func NewPostgresStore(repo BucketRepository) *PostgresStore {
+	return &PostgresStore{repo: repo}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (float64, bool, error) {
+	return s.repo.TakeRateLimitToken(ctx, key, limit.Rate, limit.Burst)
+}
+
+// StartCleanup раз в interval удаляет корзины, к которым не обращались дольше idle. Возвращает функцию остановки
+func (s *PostgresStore) StartCleanup(interval, idle time.Duration) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := s.repo.DeleteIdleRateLimitBuckets(ctx, idle)
+				if err != nil {
+					logger.Log.Warnf("Rate limit bucket cleanup failed: %v", err)
+					continue
+				}
+				logger.Log.Debugf("Deleted %d idle rate limit buckets", deleted)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,133 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+)
+
+const DefaultBatchSize = 1000
+
+// LedgerSource - источник данных для сверки, реализуется db.PostgresRepository
+type LedgerSource interface {
+	GetWalletLedgerBatch(ctx context.Context, afterID int64, limit int) ([]db.WalletLedger, error)
+}
+
+// Locker - блокировка плановой сверки в базе шарда, реализуется db.PostgresRepository
+type Locker interface {
+	// TryLockReconciliation возвращает locked = false, если блокировку держит другая реплика
+	TryLockReconciliation(ctx context.Context) (unlock func(), locked bool, err error)
+}
+
+// Shard - источник сверки одного шарда. Name пустое без шардирования
+type Shard struct {
+	Name   string
+	Source LedgerSource
+	// Lock - блокировка плановой сверки шарда (StartScheduler). Без нее сверка выполняется на каждой реплике
+	Lock Locker
+}
+
+// Mismatch - кошелек, у которого сохраненный баланс расходится с журналом транзакций
+type Mismatch struct {
+	// Shard - шард кошелька: внутренние ID кошельков уникальны только в пределах шарда
+	Shard         string `json:"shard,omitempty"`
+	WalletID      int64  `json:"walletInternalId"`
+	WalletUUID    string `json:"walletId"`
+	StoredBalance int64  `json:"storedBalance"`
+	LedgerBalance int64  `json:"ledgerBalance"`
+	Drift         int64  `json:"drift"`
+}
+
+// Report - результат одного прогона сверки
+type Report struct {
+	StartedAt      time.Time  `json:"startedAt"`
+	Shards         []string   `json:"shards,omitempty"`
+	FinishedAt     time.Time  `json:"finishedAt"`
+	WalletsChecked int64      `json:"walletsChecked"`
+	TotalDrift     int64      `json:"totalDrift"`
+	Mismatches     []Mismatch `json:"mismatches"`
+}
+
+type Reconciler struct {
+	Shards    []Shard
+	BatchSize int
+}
+
+func NewReconciler(source LedgerSource, batchSize int) *Reconciler {
+	return NewShardedReconciler([]Shard{{Source: source}}, batchSize)
+}
+
+// NewShardedReconciler сверяет кошельки всех шардов в одном отчете
+func NewShardedReconciler(shards []Shard, batchSize int) *Reconciler {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Reconciler{Shards: shards, BatchSize: batchSize}
+}
+
+// Run обходит все кошельки пачками по BatchSize и собирает расхождения
+// между wallets.balance и суммой DEPOSIT - WITHDRAW по таблице transactions.
+// Шарды сверяются по очереди, перенесенные с шарда кошельки сверяются на шарде, где они живут
+func (r *Reconciler) Run(ctx context.Context) (*Report, error) {
+	report := &Report{
+		StartedAt:  time.Now().UTC(),
+		Mismatches: []Mismatch{},
+	}
+
+	logger.Log.Infof("Starting balance reconciliation with batch size %d", r.BatchSize)
+
+	for _, shard := range r.Shards {
+		if shard.Name != "" {
+			report.Shards = append(report.Shards, shard.Name)
+		}
+		if err := r.runShard(ctx, shard, report); err != nil {
+			return nil, err
+		}
+	}
+
+	report.FinishedAt = time.Now().UTC()
+	logger.Log.Infof("Reconciliation finished: %d wallets checked, %d mismatches", report.WalletsChecked, len(report.Mismatches))
+	return report, nil
+}
+
+func (r *Reconciler) runShard(ctx context.Context, shard Shard, report *Report) error {
+	var afterID int64
+	for {
+		batch, err := shard.Source.GetWalletLedgerBatch(ctx, afterID, r.BatchSize)
+		if err != nil {
+			if shard.Name != "" {
+				err = fmt.Errorf("shard %s: %w", shard.Name, err)
+			}
+			logger.Log.Errorf("Reconciliation aborted after wallet_id %d: %v", afterID, err)
+			return fmt.Errorf("reconciliation aborted after wallet_id %d: %w", afterID, err)
+		}
+
+		for _, wl := range batch {
+			report.WalletsChecked++
+			if wl.Balance == wl.LedgerBalance {
+				continue
+			}
+
+			drift := wl.Balance - wl.LedgerBalance
+			report.TotalDrift += drift
+			report.Mismatches = append(report.Mismatches, Mismatch{
+				Shard:         shard.Name,
+				WalletID:      wl.WalletID,
+				WalletUUID:    wl.WalletUUID,
+				StoredBalance: wl.Balance,
+				LedgerBalance: wl.LedgerBalance,
+				Drift:         drift,
+			})
+			logger.Log.Warnf("Balance drift for wallet %s: stored %d, ledger %d", wl.WalletUUID, logger.Amount(wl.Balance), logger.Amount(wl.LedgerBalance))
+		}
+
+		//последняя неполная пачка - кошельков больше нет
+		if len(batch) < r.BatchSize {
+			break
+		}
+		afterID = batch[len(batch)-1].WalletID
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wallet-service/internal/db"
+)
+
+type fakeSource struct {
+	wallets []db.WalletLedger
+	calls   int
+}
+
+func (f *fakeSource) GetWalletLedgerBatch(ctx context.Context, afterID int64, limit int) ([]db.WalletLedger, error) {
+	f.calls++
+	batch := []db.WalletLedger{}
+	for _, wl := range f.wallets {
+		if wl.WalletID > afterID && len(batch) < limit {
+			batch = append(batch, wl)
+		}
+	}
+	return batch, nil
+}
+
+func Test_ReconcilerRun(t *testing.T) {
+	source := &fakeSource{wallets: []db.WalletLedger{
+		{WalletID: 1, WalletUUID: "a", Balance: 100, LedgerBalance: 100},
+		{WalletID: 2, WalletUUID: "b", Balance: 150, LedgerBalance: 100},
+		{WalletID: 3, WalletUUID: "c", Balance: 0, LedgerBalance: 0},
+		{WalletID: 4, WalletUUID: "d", Balance: 10, LedgerBalance: 30},
+	}}
+
+	report, err := NewReconciler(source, 2).Run(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), report.WalletsChecked)
+	assert.Equal(t, 3, source.calls)
+	assert.Equal(t, int64(30), report.TotalDrift)
+	assert.Equal(t, []Mismatch{
+		{WalletID: 2, WalletUUID: "b", StoredBalance: 150, LedgerBalance: 100, Drift: 50},
+		{WalletID: 4, WalletUUID: "d", StoredBalance: 10, LedgerBalance: 30, Drift: -20},
+	}, report.Mismatches)
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.Write(&buf, FormatCSV))
+	assert.Equal(t, "wallet_id,wallet_uuid,stored_balance,ledger_balance,drift\n2,b,150,100,50\n4,d,10,30,-20\n", buf.String())
+}
+
+func Test_ReconcilerRunShards(t *testing.T) {
+	// Внутренние ID кошельков на шардах совпадают, в отчете их различает шард
+	first := &fakeSource{wallets: []db.WalletLedger{
+		{WalletID: 1, WalletUUID: "a", Balance: 100, LedgerBalance: 100},
+		{WalletID: 2, WalletUUID: "b", Balance: 150, LedgerBalance: 100},
+	}}
+	second := &fakeSource{wallets: []db.WalletLedger{
+		{WalletID: 1, WalletUUID: "c", Balance: 10, LedgerBalance: 30},
+		{WalletID: 2, WalletUUID: "d", Balance: 0, LedgerBalance: 0},
+		{WalletID: 3, WalletUUID: "e", Balance: 5, LedgerBalance: 5},
+	}}
+
+	report, err := NewShardedReconciler([]Shard{{Name: "s1", Source: first}, {Name: "s2", Source: second}}, 2).Run(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s1", "s2"}, report.Shards)
+	assert.Equal(t, int64(5), report.WalletsChecked)
+	assert.Equal(t, int64(30), report.TotalDrift)
+	assert.Equal(t, []Mismatch{
+		{Shard: "s1", WalletID: 2, WalletUUID: "b", StoredBalance: 150, LedgerBalance: 100, Drift: 50},
+		{Shard: "s2", WalletID: 1, WalletUUID: "c", StoredBalance: 10, LedgerBalance: 30, Drift: -20},
+	}, report.Mismatches)
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.Write(&buf, FormatCSV))
+	assert.Equal(t, "shard,wallet_id,wallet_uuid,stored_balance,ledger_balance,drift\ns1,2,b,150,100,50\ns2,1,c,10,30,-20\n", buf.String())
+}
+
+// fakeLock - блокировка сверки, которую может держать другая реплика
+type fakeLock struct {
+	held     bool
+	unlocked int
+}
+
+func (l *fakeLock) TryLockReconciliation(ctx context.Context) (func(), bool, error) {
+	if l.held {
+		return nil, false, nil
+	}
+	l.held = true
+	return func() { l.held = false; l.unlocked++ }, true, nil
+}
+
+func Test_SchedulerLocks(t *testing.T) {
+	source := &fakeSource{wallets: []db.WalletLedger{{WalletID: 1, WalletUUID: "a", Balance: 100, LedgerBalance: 100}}}
+
+	t.Run("runs under locks of all shards", func(t *testing.T) {
+		first, second := &fakeLock{}, &fakeLock{}
+		reconciler := NewShardedReconciler([]Shard{
+			{Name: "s1", Source: source, Lock: first},
+			{Name: "s2", Source: source, Lock: second},
+		}, 10)
+		dir := t.TempDir()
+
+		require.NoError(t, runAndSave(reconciler, dir, FormatJSON))
+
+		reports, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, reports, 1)
+		assert.Equal(t, 1, first.unlocked)
+		assert.Equal(t, 1, second.unlocked)
+	})
+
+	t.Run("skips when another instance holds a shard lock", func(t *testing.T) {
+		first, second := &fakeLock{}, &fakeLock{held: true}
+		reconciler := NewShardedReconciler([]Shard{
+			{Name: "s1", Source: source, Lock: first},
+			{Name: "s2", Source: source, Lock: second},
+		}, 10)
+		dir := t.TempDir()
+		calls := source.calls
+
+		require.NoError(t, runAndSave(reconciler, dir, FormatJSON))
+
+		reports, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, reports)
+		assert.Equal(t, calls, source.calls, "no shard must be reconciled")
+		// Блокировка первого шарда снята, чужая не тронута
+		assert.False(t, first.held)
+		assert.True(t, second.held)
+	})
+}
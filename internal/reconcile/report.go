@@ -0,0 +1,72 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Write записывает отчет в w в указанном формате (json или csv)
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		return r.WriteJSON(w)
+	case FormatCSV:
+		return r.WriteCSV(w)
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("failed to encode report as json: %w", err)
+	}
+	return nil
+}
+
+// WriteCSV записывает только расхождения, по одному кошельку на строку.
+// При сверке шардов первая колонка - шард кошелька
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	sharded := len(r.Shards) > 0
+
+	header := []string{"wallet_id", "wallet_uuid", "stored_balance", "ledger_balance", "drift"}
+	if sharded {
+		header = append([]string{"shard"}, header...)
+	}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("failed to write csv header: %w", err)
+	}
+
+	for _, m := range r.Mismatches {
+		record := []string{
+			strconv.FormatInt(m.WalletID, 10),
+			m.WalletUUID,
+			strconv.FormatInt(m.StoredBalance, 10),
+			strconv.FormatInt(m.LedgerBalance, 10),
+			strconv.FormatInt(m.Drift, 10),
+		}
+		if sharded {
+			record = append([]string{m.Shard}, record...)
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write csv record: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to flush csv report: %w", err)
+	}
+	return nil
+}
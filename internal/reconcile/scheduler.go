@@ -0,0 +1,106 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"wallet-service/internal/logger"
+)
+
+// StartScheduler запускает сверку каждые interval и сохраняет отчеты в reportDir.
+// Сверка выполняется под блокировками всех шардов (Shard.Lock): если сверка уже идет на другой реплике,
+// прогон пропускается, и реплики не строят одновременно одинаковые отчеты.
+// Возвращает функцию остановки планировщика.
+func StartScheduler(r *Reconciler, interval time.Duration, reportDir, format string) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	logger.Log.Infof("Reconciliation scheduled every %s, reports in %s", interval, reportDir)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := runAndSave(r, reportDir, format); err != nil {
+					logger.Log.Errorf("Scheduled reconciliation failed: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func runAndSave(r *Reconciler, reportDir, format string) error {
+	ctx := context.Background()
+	unlock, locked, err := lockShards(ctx, r.Shards)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		logger.Log.Info("Reconciliation is running on another instance, skipping")
+		return nil
+	}
+	defer unlock()
+
+	report, err := r.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(reportDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	name := fmt.Sprintf("reconciliation-%s.%s", report.StartedAt.Format("20060102T150405Z"), format)
+	path := filepath.Join(reportDir, name)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer f.Close()
+
+	if err := report.Write(f, format); err != nil {
+		return err
+	}
+
+	logger.Log.Infof("Reconciliation report saved to %s", path)
+	return nil
+}
+
+// lockShards берет блокировки сверки всех шардов по порядку. Если какую-то держит другая реплика,
+// взятые блокировки снимаются и возвращается locked = false
+func lockShards(ctx context.Context, shards []Shard) (unlock func(), locked bool, err error) {
+	var unlocks []func()
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+
+	for _, shard := range shards {
+		if shard.Lock == nil {
+			continue
+		}
+		unlockShard, locked, err := shard.Lock.TryLockReconciliation(ctx)
+		if err != nil {
+			unlockAll()
+			if shard.Name != "" {
+				err = fmt.Errorf("shard %s: %w", shard.Name, err)
+			}
+			return nil, false, err
+		}
+		if !locked {
+			unlockAll()
+			return nil, false, nil
+		}
+		unlocks = append(unlocks, unlockShard)
+	}
+	return unlockAll, true, nil
+}
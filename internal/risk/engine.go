@@ -0,0 +1,129 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+// maxScore - оценка риска ограничена сверху, сумма оценок сработавших правил обрезается до нее
+const maxScore = 100
+
+// severity - порядок решений от мягкого к строгому
+var severity = map[string]int{db.RiskAllow: 0, db.RiskReview: 1, db.RiskDeny: 2}
+
+// Store - данные для правил и журнал решений (db.PostgresRepository)
+type Store interface {
+	GetRiskStats(ctx context.Context, walletUUID, client string, windows db.RiskWindows) (*db.RiskStats, error)
+	RecordRiskDecision(ctx context.Context, d *db.RiskDecision) error
+}
+
+// Operation - проверяемая операция с кошельком
+type Operation struct {
+	Type       string
+	WalletUUID string
+	Client     string
+	Amount     int64
+}
+
+// Decision - решение по операции. ID совпадает с ID операции на ручной проверке при решении review
+type Decision struct {
+	ID      string
+	Outcome string
+	Score   int
+	Rules   []string
+}
+
+// Engine проверяет операции по правилам до движения денег
+type Engine struct {
+	store Store
+	rules *RuleSet
+}
+
+func NewEngine(store Store, rules *RuleSet) *Engine {
+	return &Engine{store: store, rules: rules}
+}
+
+// Evaluate вычисляет правила для операции и записывает решение вместе со значениями переменных.
+// Решение - самое строгое из действий сработавших правил и порогов суммарной оценки.
+// Ошибка означает, что решение не принято и не записано: операцию выполнять нельзя
+func (e *Engine) Evaluate(ctx context.Context, op Operation) (*Decision, error) {
+	stats, err := e.store.GetRiskStats(ctx, op.WalletUUID, op.Client, e.rules.Windows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect risk data: %w", err)
+	}
+	env := e.facts(op, stats)
+
+	decision := &Decision{ID: uuid.NewString(), Outcome: db.RiskAllow, Rules: []string{}}
+	for _, rule := range e.rules.Rules {
+		if !rule.When.Eval(env) {
+			continue
+		}
+		decision.Rules = append(decision.Rules, rule.Name)
+		decision.Score += rule.Score
+		decision.Outcome = stricter(decision.Outcome, rule.Action)
+	}
+	decision.Score = min(decision.Score, maxScore)
+
+	if e.rules.DenyScore > 0 && decision.Score >= e.rules.DenyScore {
+		decision.Outcome = db.RiskDeny
+	} else if e.rules.ReviewScore > 0 && decision.Score >= e.rules.ReviewScore {
+		decision.Outcome = stricter(decision.Outcome, db.RiskReview)
+	}
+
+	if err := e.store.RecordRiskDecision(ctx, &db.RiskDecision{
+		ID:            decision.ID,
+		WalletUUID:    op.WalletUUID,
+		Client:        op.Client,
+		OperationType: op.Type,
+		Amount:        op.Amount,
+		Outcome:       decision.Outcome,
+		Score:         decision.Score,
+		Rules:         decision.Rules,
+		Facts:         env,
+		WalletNew:     stats.WalletNew,
+	}); err != nil {
+		return nil, err
+	}
+	metrics.RiskDecisions.WithLabelValues(decision.Outcome).Inc()
+
+	if decision.Outcome != db.RiskAllow {
+		logger.Log.WithContext(ctx).Warnf("Risk decision %s for %s of %d on wallet %s: score %d, rules %s",
+			decision.Outcome, op.Type, logger.Amount(op.Amount), op.WalletUUID, decision.Score, strings.Join(decision.Rules, ", "))
+	}
+	return decision, nil
+}
+
+// facts - значения переменных условий для операции
+func (e *Engine) facts(op Operation, stats *db.RiskStats) Env {
+	// Давность последнего пополнения -1, если пополнений за период истории не было
+	lastDepositAge := -1.0
+	if stats.LastDepositAge != nil {
+		lastDepositAge = stats.LastDepositAge.Seconds()
+	}
+	return Env{
+		VarAmount:            float64(op.Amount),
+		VarOperation:         op.Type,
+		VarWalletNew:         stats.WalletNew,
+		VarWalletBlocklisted: e.rules.BlockedWallets[strings.ToLower(op.WalletUUID)],
+		VarWalletTxCount:     float64(stats.TxCount),
+		VarWalletAvgAmount:   stats.AvgAmount,
+		VarWalletMaxAmount:   float64(stats.MaxAmount),
+		VarRecentDeposits:    float64(stats.RecentDeposits),
+		VarLastDepositAge:    lastDepositAge,
+		VarClientNewWallets:  float64(stats.ClientNewWallets),
+		VarClientBlocklisted: e.rules.BlockedClients[op.Client],
+	}
+}
+
+func stricter(a, b string) string {
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,352 @@
+package risk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kind - тип значения выражения
+type Kind int
+
+const (
+	KindNumber Kind = iota
+	KindString
+	KindBool
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNumber:
+		return "number"
+	case KindString:
+		return "string"
+	default:
+		return "bool"
+	}
+}
+
+// Env - значения переменных при вычислении: float64, string или bool по объявленному типу
+type Env map[string]any
+
+// Expr - скомпилированное условие правила
+type Expr struct {
+	src  string
+	eval func(env Env) any
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval вычисляет условие. Все переменные условия должны быть в env
+func (e *Expr) Eval(env Env) bool {
+	return e.eval(env).(bool)
+}
+
+// Compile разбирает условие и проверяет типы. Язык условий:
+//
+//	числа (100, 0.5), строки ("WITHDRAW"), true, false, переменные из vars (wallet.avg_amount)
+//	арифметика + - * /, сравнения == != < <= > >=, логика && || ! и скобки
+//
+// Условие должно иметь тип bool. Деление на ноль дает бесконечность, как для float64
+func Compile(src string, vars map[string]Kind) (*Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, vars: vars}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+	if n.kind != KindBool {
+		return nil, fmt.Errorf("condition must be bool, got %s", n.kind)
+	}
+	return &Expr{src: src, eval: n.eval}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// operators - в порядке убывания длины, чтобы <= не разбиралось как <
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "!", "(", ")"}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, src[start:i], start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i])) || src[i] == '_' || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, src[start:i], start})
+		case c == '"':
+			end := strings.IndexByte(src[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, token{tokenString, src[i+1 : i+1+end], i})
+			i += end + 2
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{tokenOp, op, i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, text: "end of expression", pos: len(src)}), nil
+}
+
+// node - разобранное подвыражение с известным типом
+type node struct {
+	kind Kind
+	eval func(env Env) any
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	vars   map[string]Kind
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// accept забирает оператор op, если он следующий
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) or() (node, error) {
+	return p.logical("||", p.and)
+}
+
+func (p *parser) and() (node, error) {
+	return p.logical("&&", p.not)
+}
+
+// logical - цепочка && или || с вычислением слева направо до первого определяющего значения
+func (p *parser) logical(op string, next func() (node, error)) (node, error) {
+	left, err := next()
+	if err != nil {
+		return left, err
+	}
+	for {
+		if _, ok := p.accept(op); !ok {
+			return left, nil
+		}
+		right, err := next()
+		if err != nil {
+			return right, err
+		}
+		if left.kind != KindBool || right.kind != KindBool {
+			return node{}, fmt.Errorf("%s requires bool operands, got %s and %s", op, left.kind, right.kind)
+		}
+		l, r := left.eval, right.eval
+		if op == "&&" {
+			left = node{KindBool, func(env Env) any { return l(env).(bool) && r(env).(bool) }}
+		} else {
+			left = node{KindBool, func(env Env) any { return l(env).(bool) || r(env).(bool) }}
+		}
+	}
+}
+
+func (p *parser) not() (node, error) {
+	if _, ok := p.accept("!"); !ok {
+		return p.comparison()
+	}
+	operand, err := p.not()
+	if err != nil {
+		return operand, err
+	}
+	if operand.kind != KindBool {
+		return node{}, fmt.Errorf("! requires a bool operand, got %s", operand.kind)
+	}
+	eval := operand.eval
+	return node{KindBool, func(env Env) any { return !eval(env).(bool) }}, nil
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.sum()
+	if err != nil {
+		return left, err
+	}
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.sum()
+	if err != nil {
+		return right, err
+	}
+	if left.kind != right.kind {
+		return node{}, fmt.Errorf("cannot compare %s with %s", left.kind, right.kind)
+	}
+	l, r := left.eval, right.eval
+
+	switch op {
+	case "==":
+		return node{KindBool, func(env Env) any { return l(env) == r(env) }}, nil
+	case "!=":
+		return node{KindBool, func(env Env) any { return l(env) != r(env) }}, nil
+	}
+	if left.kind != KindNumber {
+		return node{}, fmt.Errorf("%s requires number operands, got %s", op, left.kind)
+	}
+	var cmp func(a, b float64) bool
+	switch op {
+	case "<":
+		cmp = func(a, b float64) bool { return a < b }
+	case "<=":
+		cmp = func(a, b float64) bool { return a <= b }
+	case ">":
+		cmp = func(a, b float64) bool { return a > b }
+	default:
+		cmp = func(a, b float64) bool { return a >= b }
+	}
+	return node{KindBool, func(env Env) any { return cmp(l(env).(float64), r(env).(float64)) }}, nil
+}
+
+func (p *parser) sum() (node, error) {
+	return p.arithmetic(p.product, "+", "-")
+}
+
+func (p *parser) product() (node, error) {
+	return p.arithmetic(p.unary, "*", "/")
+}
+
+func (p *parser) arithmetic(next func() (node, error), ops ...string) (node, error) {
+	left, err := next()
+	if err != nil {
+		return left, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := next()
+		if err != nil {
+			return right, err
+		}
+		if left.kind != KindNumber || right.kind != KindNumber {
+			return node{}, fmt.Errorf("%s requires number operands, got %s and %s", op, left.kind, right.kind)
+		}
+		l, r := left.eval, right.eval
+		var f func(a, b float64) float64
+		switch op {
+		case "+":
+			f = func(a, b float64) float64 { return a + b }
+		case "-":
+			f = func(a, b float64) float64 { return a - b }
+		case "*":
+			f = func(a, b float64) float64 { return a * b }
+		default:
+			f = func(a, b float64) float64 { return a / b }
+		}
+		left = node{KindNumber, func(env Env) any { return f(l(env).(float64), r(env).(float64)) }}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	if _, ok := p.accept("-"); !ok {
+		return p.primary()
+	}
+	operand, err := p.unary()
+	if err != nil {
+		return operand, err
+	}
+	if operand.kind != KindNumber {
+		return node{}, fmt.Errorf("unary - requires a number operand, got %s", operand.kind)
+	}
+	eval := operand.eval
+	return node{KindNumber, func(env Env) any { return -eval(env).(float64) }}, nil
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.peek()
+	p.pos++
+
+	switch t.kind {
+	case tokenNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return node{}, fmt.Errorf("invalid number %q at position %d", t.text, t.pos)
+		}
+		return node{KindNumber, func(Env) any { return n }}, nil
+	case tokenString:
+		s := t.text
+		return node{KindString, func(Env) any { return s }}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true", "false":
+			b := t.text == "true"
+			return node{KindBool, func(Env) any { return b }}, nil
+		}
+		kind, ok := p.vars[t.text]
+		if !ok {
+			return node{}, fmt.Errorf("unknown variable %q at position %d", t.text, t.pos)
+		}
+		name := t.text
+		return node{kind, func(env Env) any { return env[name] }}, nil
+	case tokenOp:
+		if t.text == "(" {
+			n, err := p.or()
+			if err != nil {
+				return n, err
+			}
+			if _, ok := p.accept(")"); !ok {
+				return node{}, fmt.Errorf("expected ) at position %d", p.peek().pos)
+			}
+			return n, nil
+		}
+	}
+	return node{}, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
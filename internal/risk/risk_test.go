@@ -0,0 +1,211 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wallet-service/internal/db"
+)
+
+func Test_Compile(t *testing.T) {
+	env := Env{
+		VarAmount:          500.0,
+		VarOperation:       "WITHDRAW",
+		VarWalletNew:       false,
+		VarWalletTxCount:   10.0,
+		VarWalletAvgAmount: 40.0,
+	}
+
+	var tests = []struct {
+		src  string
+		want bool
+	}{
+		{`amount > 10 * wallet.avg_amount`, true},
+		{`amount > 10 * wallet.avg_amount && wallet.tx_count >= 20`, false},
+		{`operation == "WITHDRAW" || wallet.new`, true},
+		{`!(amount <= 100) && operation != "DEPOSIT"`, true},
+		{`-amount + 600 == 100`, true},
+		{`(amount - 100) / 4 < wallet.avg_amount`, false},
+		{`wallet.new == false`, true},
+	}
+	for _, tt := range tests {
+		expr, err := Compile(tt.src, Variables)
+		require.NoError(t, err, tt.src)
+		assert.Equal(t, tt.want, expr.Eval(env), tt.src)
+	}
+
+	for _, src := range []string{
+		`amount`,                     // не bool
+		`amount > "100"`,             // разные типы
+		`operation < "X"`,            // строки только == и !=
+		`wallet.unknown > 1`,         // неизвестная переменная
+		`amount > 1 &&`,              // неполное выражение
+		`(amount > 1`,                // нет скобки
+		`operation == "WITHDRAW`,     // незакрытая строка
+		`amount > 1 ; amount < 5`,    // лишний символ
+		`wallet.new && amount > 1 1`, // лишний токен
+	} {
+		_, err := Compile(src, Variables)
+		assert.Error(t, err, src)
+	}
+}
+
+func Test_ParseRules(t *testing.T) {
+	rules, err := ParseRules([]byte(`
+thresholds: {review: 50, deny: 90}
+windows: {recent: 10m}
+blocklist:
+  wallets: [D7AF0768-704E-4F1C-9793-A44C2D1F9B75]
+rules:
+  - name: blocklisted_wallet
+    when: wallet.blocklisted
+    action: deny
+    score: 100
+  - name: large_amount
+    when: amount > 1000
+    score: 30
+`))
+	require.NoError(t, err)
+	require.Len(t, rules.Rules, 2)
+	assert.Equal(t, db.RiskAllow, rules.Rules[1].Action)
+	assert.Equal(t, 10*time.Minute, rules.Windows.Recent)
+	assert.Equal(t, defaultHistoryWindow, rules.Windows.History)
+	assert.True(t, rules.BlockedWallets["d7af0768-704e-4f1c-9793-a44c2d1f9b75"])
+
+	empty, err := ParseRules(nil)
+	require.NoError(t, err)
+	assert.Empty(t, empty.Rules)
+
+	for name, data := range map[string]string{
+		"unknown field":    "rule: []",
+		"bad action":       "rules: [{name: a, when: 'amount > 1', action: block}]",
+		"bad condition":    "rules: [{name: a, when: 'amount >'}]",
+		"bad name":         "rules: [{name: A-1, when: 'amount > 1'}]",
+		"duplicate":        "rules: [{name: a, when: 'amount > 1'}, {name: a, when: 'amount > 2'}]",
+		"score too high":   "rules: [{name: a, when: 'amount > 1', score: 101}]",
+		"thresholds order": "thresholds: {review: 90, deny: 50}",
+		"bad window":       "windows: {history: week}",
+	} {
+		_, err := ParseRules([]byte(data))
+		assert.Error(t, err, name)
+	}
+}
+
+// fakeStore - данные для правил и записанные решения
+type fakeStore struct {
+	stats     db.RiskStats
+	err       error
+	decisions []*db.RiskDecision
+}
+
+func (f *fakeStore) GetRiskStats(ctx context.Context, walletUUID, client string, windows db.RiskWindows) (*db.RiskStats, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	stats := f.stats
+	return &stats, nil
+}
+
+func (f *fakeStore) RecordRiskDecision(ctx context.Context, d *db.RiskDecision) error {
+	f.decisions = append(f.decisions, d)
+	return nil
+}
+
+func Test_Engine(t *testing.T) {
+	rules, err := ParseRules([]byte(`
+thresholds: {review: 50, deny: 90}
+blocklist:
+  clients: [10.0.0.66]
+rules:
+  - name: blocklisted_client
+    when: client.blocklisted
+    action: deny
+    score: 100
+  - name: unusual_amount
+    when: wallet.tx_count >= 5 && amount > 10 * wallet.avg_amount
+    score: 40
+  - name: deposit_then_withdraw
+    when: operation == "WITHDRAW" && wallet.last_deposit_age >= 0 && wallet.last_deposit_age < 600 && amount >= wallet.recent_deposits * 0.8
+    score: 30
+  - name: many_new_wallets
+    when: wallet.new && client.new_wallets >= 5
+    action: review
+`))
+	require.NoError(t, err)
+	ctx := context.Background()
+	minute := time.Minute
+
+	var tests = []struct {
+		name    string
+		op      Operation
+		stats   db.RiskStats
+		outcome string
+		score   int
+		rules   []string
+	}{
+		{
+			name:    "Ordinary deposit",
+			op:      Operation{Type: "DEPOSIT", Amount: 100, Client: "10.0.0.1"},
+			stats:   db.RiskStats{TxCount: 10, AvgAmount: 80},
+			outcome: db.RiskAllow,
+			rules:   []string{},
+		},
+		{
+			name:    "Unusual amount alone is scored only",
+			op:      Operation{Type: "DEPOSIT", Amount: 1000, Client: "10.0.0.1"},
+			stats:   db.RiskStats{TxCount: 10, AvgAmount: 80},
+			outcome: db.RiskAllow,
+			score:   40,
+			rules:   []string{"unusual_amount"},
+		},
+		{
+			name:    "Scores add up to review threshold",
+			op:      Operation{Type: "WITHDRAW", Amount: 1000, Client: "10.0.0.1"},
+			stats:   db.RiskStats{TxCount: 10, AvgAmount: 80, RecentDeposits: 1000, LastDepositAge: &minute},
+			outcome: db.RiskReview,
+			score:   70,
+			rules:   []string{"unusual_amount", "deposit_then_withdraw"},
+		},
+		{
+			name:    "Rule action review",
+			op:      Operation{Type: "DEPOSIT", Amount: 10, Client: "10.0.0.1"},
+			stats:   db.RiskStats{WalletNew: true, ClientNewWallets: 7},
+			outcome: db.RiskReview,
+			rules:   []string{"many_new_wallets"},
+		},
+		{
+			name:    "Blocklisted client",
+			op:      Operation{Type: "DEPOSIT", Amount: 10, Client: "10.0.0.66"},
+			outcome: db.RiskDeny,
+			score:   100,
+			rules:   []string{"blocklisted_client"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{stats: tt.stats}
+			decision, err := NewEngine(store, rules).Evaluate(ctx, tt.op)
+			require.NoError(t, err)
+			assert.Equal(t, tt.outcome, decision.Outcome)
+			assert.Equal(t, tt.score, decision.Score)
+			assert.Equal(t, tt.rules, decision.Rules)
+
+			// Каждое решение записывается вместе со значениями переменных
+			require.Len(t, store.decisions, 1)
+			recorded := store.decisions[0]
+			assert.Equal(t, decision.ID, recorded.ID)
+			assert.Equal(t, tt.outcome, recorded.Outcome)
+			assert.Equal(t, float64(tt.op.Amount), recorded.Facts[VarAmount])
+			assert.Len(t, recorded.Facts, len(Variables))
+		})
+	}
+
+	_, err = NewEngine(&fakeStore{err: errors.New("connection refused")}, rules).Evaluate(ctx, Operation{Type: "DEPOSIT", Amount: 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,203 @@
+package risk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"wallet-service/internal/db"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Переменные условий правил
+const (
+	VarAmount            = "amount"
+	VarOperation         = "operation"
+	VarWalletNew         = "wallet.new"
+	VarWalletBlocklisted = "wallet.blocklisted"
+	VarWalletTxCount     = "wallet.tx_count"
+	VarWalletAvgAmount   = "wallet.avg_amount"
+	VarWalletMaxAmount   = "wallet.max_amount"
+	VarRecentDeposits    = "wallet.recent_deposits"
+	VarLastDepositAge    = "wallet.last_deposit_age"
+	VarClientNewWallets  = "client.new_wallets"
+	VarClientBlocklisted = "client.blocklisted"
+)
+
+// Variables - переменные, доступные в условиях правил, и их типы
+var Variables = map[string]Kind{
+	VarAmount:            KindNumber,
+	VarOperation:         KindString,
+	VarWalletNew:         KindBool,
+	VarWalletBlocklisted: KindBool,
+	VarWalletTxCount:     KindNumber,
+	VarWalletAvgAmount:   KindNumber,
+	VarWalletMaxAmount:   KindNumber,
+	VarRecentDeposits:    KindNumber,
+	VarLastDepositAge:    KindNumber,
+	VarClientNewWallets:  KindNumber,
+	VarClientBlocklisted: KindBool,
+}
+
+// Периоды по умолчанию, если в файле правил они не заданы
+const (
+	defaultHistoryWindow = 30 * 24 * time.Hour
+	defaultRecentWindow  = time.Hour
+	defaultClientWindow  = 24 * time.Hour
+)
+
+var ruleName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Rule - правило риска: если условие When выполнено, к оценке добавляется Score,
+// а решение становится не мягче Action
+type Rule struct {
+	Name   string
+	When   *Expr
+	Action string
+	Score  int
+}
+
+// RuleSet - правила из файла RISK_RULES_FILE
+type RuleSet struct {
+	Rules []Rule
+	// ReviewScore и DenyScore - пороги суммарной оценки для review и deny, 0 - порог не задан
+	ReviewScore int
+	DenyScore   int
+	Windows     db.RiskWindows
+	// BlockedWallets и BlockedClients - значения wallet.blocklisted и client.blocklisted
+	BlockedWallets map[string]bool
+	BlockedClients map[string]bool
+}
+
+// ruleFile - формат файла правил
+type ruleFile struct {
+	Thresholds struct {
+		Review int `yaml:"review"`
+		Deny   int `yaml:"deny"`
+	} `yaml:"thresholds"`
+	Windows struct {
+		History string `yaml:"history"`
+		Recent  string `yaml:"recent"`
+		Client  string `yaml:"client"`
+	} `yaml:"windows"`
+	Blocklist struct {
+		Wallets []string `yaml:"wallets"`
+		Clients []string `yaml:"clients"`
+	} `yaml:"blocklist"`
+	Rules []struct {
+		Name   string `yaml:"name"`
+		When   string `yaml:"when"`
+		Action string `yaml:"action"`
+		Score  int    `yaml:"score"`
+	} `yaml:"rules"`
+}
+
+// LoadRules читает правила из YAML-файла path
+func LoadRules(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read risk rules: %w", err)
+	}
+	rules, err := ParseRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid risk rules in %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// ParseRules разбирает файл правил:
+//
+//	thresholds: {review: 50, deny: 90}
+//	windows: {history: 720h, recent: 1h, client: 24h}
+//	blocklist: {wallets: [...], clients: [...]}
+//	rules:
+//	  - name: unusual_amount
+//	    when: wallet.tx_count >= 5 && amount > 10 * wallet.avg_amount
+//	    action: review
+//	    score: 40
+//
+// action - allow (только оценка), review или deny
+func ParseRules(data []byte) (*RuleSet, error) {
+	var file ruleFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// Пустой файл - без правил
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	set := &RuleSet{
+		ReviewScore:    file.Thresholds.Review,
+		DenyScore:      file.Thresholds.Deny,
+		BlockedWallets: map[string]bool{},
+		BlockedClients: map[string]bool{},
+	}
+	if set.ReviewScore < 0 || set.DenyScore < 0 {
+		return nil, fmt.Errorf("thresholds must not be negative")
+	}
+	if set.ReviewScore > 0 && set.DenyScore > 0 && set.ReviewScore > set.DenyScore {
+		return nil, fmt.Errorf("review threshold %d is above deny threshold %d", set.ReviewScore, set.DenyScore)
+	}
+
+	var err error
+	if set.Windows.History, err = parseWindow("history", file.Windows.History, defaultHistoryWindow); err != nil {
+		return nil, err
+	}
+	if set.Windows.Recent, err = parseWindow("recent", file.Windows.Recent, defaultRecentWindow); err != nil {
+		return nil, err
+	}
+	if set.Windows.Client, err = parseWindow("client", file.Windows.Client, defaultClientWindow); err != nil {
+		return nil, err
+	}
+
+	for _, wallet := range file.Blocklist.Wallets {
+		set.BlockedWallets[strings.ToLower(strings.TrimSpace(wallet))] = true
+	}
+	for _, client := range file.Blocklist.Clients {
+		set.BlockedClients[strings.TrimSpace(client)] = true
+	}
+
+	seen := map[string]bool{}
+	for i, r := range file.Rules {
+		if !ruleName.MatchString(r.Name) {
+			return nil, fmt.Errorf("rule %d: name %q must match %s", i+1, r.Name, ruleName)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("duplicate rule %s", r.Name)
+		}
+		seen[r.Name] = true
+
+		if r.Action == "" {
+			r.Action = db.RiskAllow
+		}
+		if !slices.Contains([]string{db.RiskAllow, db.RiskReview, db.RiskDeny}, r.Action) {
+			return nil, fmt.Errorf("rule %s: action %q is not one of allow, review, deny", r.Name, r.Action)
+		}
+		if r.Score < 0 || r.Score > maxScore {
+			return nil, fmt.Errorf("rule %s: score must be between 0 and %d, got %d", r.Name, maxScore, r.Score)
+		}
+		when, err := Compile(r.When, Variables)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		set.Rules = append(set.Rules, Rule{Name: r.Name, When: when, Action: r.Action, Score: r.Score})
+	}
+	return set, nil
+}
+
+func parseWindow(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("windows.%s: invalid duration %q", name, value)
+	}
+	return d, nil
+}
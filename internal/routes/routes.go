@@ -2,34 +2,192 @@ package routes
 
 import (
 	"errors"
-	"fmt"
 	"wallet-service/internal/api"
 	"wallet-service/internal/db"
+	"wallet-service/internal/health"
 	"wallet-service/internal/logger"
+	"wallet-service/internal/metrics"
+	"wallet-service/internal/ratelimit"
+	"wallet-service/internal/risk"
+	"wallet-service/internal/settings"
+	"wallet-service/internal/settlement"
+	"wallet-service/internal/tenant"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func SetupRoutes(router *gin.Engine, repo db.Repository) error {
-	fmt.Printf("Repository: %+v\n", repo)
-	if repo == nil {
+// Dependencies - сервисы, из которых собираются обработчики маршрутов.
+// Необязательные сервисы (nil) просто не регистрируют свои маршруты
+type Dependencies struct {
+	Repo        db.Repository
+	Settlements *settlement.Service
+	History     db.HistoryRepository
+	HotWallets  db.HotWalletRepository
+	Transfers   db.TransferRepository
+	// Operations - очередь асинхронных операций ("mode": "async"), nil - асинхронный режим недоступен
+	Operations db.OperationQueue
+	// Readiness - проверки для /readyz, nil - сервис всегда готов
+	Readiness *health.Readiness
+	// Settings и AdminToken - /admin/config для настроек времени выполнения,
+	// маршруты регистрируются только при заданном токене
+	Settings   *settings.Manager
+	AdminToken string
+	// RateLimiter - ограничение частоты запросов к API, nil - без ограничений
+	RateLimiter *api.RateLimiter
+	// Risk - проверка рисков перед пополнением и снятием, nil - проверки отключены.
+	// RiskOperations - ручная проверка отложенных операций в /admin/risk
+	Risk           *risk.Engine
+	RiskOperations db.RiskRepository
+	// Tenants - арендаторы и их API-ключи, nil - API без ключей, все кошельки одного арендатора
+	Tenants *tenant.Registry
+
+	// ServiceName - имя сервера в спанах HTTP-запросов
+	ServiceName string
+}
+
+func SetupRoutes(router *gin.Engine, deps Dependencies) error {
+	if deps.Repo == nil {
 		err := errors.New("repository is nil")
 		logger.Log.Error(err)
 		return err
 	}
 
-	walletHandlers := api.NewWalletHandler(repo)
+	// Пробы Kubernetes регистрируются до трассировки и метрик, чтобы не засорять их частыми запросами
+	readiness := deps.Readiness
+	if readiness == nil {
+		readiness = health.NewReadiness(0)
+	}
+	healthHandlers := api.NewHealthHandler(readiness)
+	router.GET("/healthz", healthHandlers.Liveness)
+	router.GET("/readyz", healthHandlers.Readiness)
+
+	// Идентификатор запроса из X-Request-ID во всех записях логов запроса
+	router.Use(api.RequestID())
+
+	// Трассировка: спан на каждый запрос с продолжением трассы из заголовков traceparent
+	router.Use(otelgin.Middleware(deps.ServiceName))
+
+	// Журнал запросов вместо стандартного логгера gin, внутри спана запроса, чтобы в записи попал trace_id
+	router.Use(api.AccessLog())
+
+	// Метрики Prometheus: длительность всех запросов и эндпоинт для сбора
+	router.Use(metrics.Middleware())
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Read-your-writes: после записи чтения в том же запросе идут на основную базу, а не на реплики
+	router.Use(api.ReadYourWrites())
+
+	walletHandlers := api.NewWalletHandler(deps.Repo)
+
+	var statementHandlers *api.StatementHandlers
+	if deps.History != nil {
+		walletHandlers.History = deps.History
+		statementHandlers = api.NewStatementHandler(deps.History)
+	}
+
+	var settlementHandlers *api.SettlementHandlers
+	if deps.Settlements != nil {
+		settlementHandlers = api.NewSettlementHandler(deps.Settlements)
+	}
+
+	var hotWalletHandlers *api.HotWalletHandlers
+	if deps.HotWallets != nil {
+		hotWalletHandlers = api.NewHotWalletHandler(deps.HotWallets)
+	}
+
+	var operationHandlers *api.OperationHandlers
+	if deps.Operations != nil {
+		walletHandlers.Operations = deps.Operations
+		operationHandlers = api.NewOperationHandler(deps.Operations)
+	}
+
+	var transferHandlers *api.TransferHandlers
+	if deps.Transfers != nil {
+		transferHandlers = api.NewTransferHandler(deps.Transfers)
+	}
+
+	rateLimiter := deps.RateLimiter
+	if rateLimiter == nil {
+		rateLimiter = &api.RateLimiter{Limiter: ratelimit.NewLimiter(ratelimit.NewMemoryStore(), nil)}
+	}
+
+	// Клиент для правил риска определяется так же, как для ограничения частоты
+	walletHandlers.Risk = deps.Risk
+	walletHandlers.ClientHeader = rateLimiter.ClientHeader
+
+	if deps.Settings != nil && deps.AdminToken != "" {
+		admin := router.Group("/admin", api.AdminAuth(deps.AdminToken))
+
+		// Просмотр и изменение настроек без перезапуска
+		adminConfigHandlers := api.NewAdminConfigHandler(deps.Settings)
+		admin.GET("/config", adminConfigHandlers.GetConfig)
+		admin.PUT("/config", adminConfigHandlers.PutConfig)
+
+		if deps.Risk != nil && deps.RiskOperations != nil {
+			// Ручная проверка операций, отложенных правилами риска
+			riskHandlers := api.NewRiskHandler(deps.RiskOperations, deps.Repo)
+			admin.GET("/risk/pending", riskHandlers.ListPending)
+			admin.POST("/risk/pending/:id/approve", riskHandlers.Approve)
+			admin.POST("/risk/pending/:id/reject", riskHandlers.Reject)
+		}
+	} else if deps.Settings != nil {
+		logger.Log.Warn("Admin endpoints are disabled: ADMIN_TOKEN is not set")
+		if deps.Risk != nil {
+			logger.Log.Warn("Operations held for risk review cannot be decided until ADMIN_TOKEN is set")
+		}
+	}
+
+	if settlementHandlers != nil && deps.AdminToken != "" {
+		// Сверка с файлами расчетов банка/PSP - операция бэк-офиса по всем кошелькам, только с токеном администратора
+		settlements := router.Group("/api/v1/settlements", api.AdminAuth(deps.AdminToken))
+		settlements.POST("/runs", settlementHandlers.PostSettlementRun)
+		settlements.GET("/runs", settlementHandlers.ListSettlementRuns)
+		settlements.GET("/runs/:id", settlementHandlers.GetSettlementRun)
+	} else if settlementHandlers != nil {
+		logger.Log.Warn("Settlement endpoints are disabled: ADMIN_TOKEN is not set")
+	}
+
+	if hotWalletHandlers != nil && deps.AdminToken != "" {
+		// Перебалансировка горячего кошелька: новое число частей баланса, только с токеном администратора
+		router.PUT("/api/v1/wallets/:walletUUID/shards", api.AdminAuth(deps.AdminToken), hotWalletHandlers.PutWalletShards)
+	} else if hotWalletHandlers != nil {
+		logger.Log.Warn("Hot wallet endpoints are disabled: ADMIN_TOKEN is not set")
+	}
+
+	// Арендатор по API-ключу до ограничения частоты: запросы без ключа не расходуют лимиты
+	var apiMiddleware []gin.HandlerFunc
+	if deps.Tenants != nil {
+		apiMiddleware = append(apiMiddleware, api.TenantAuth(deps.Tenants))
+	}
 
-	api := router.Group("/api/v1")
+	api := router.Group("/api/v1", apiMiddleware...)
 	{
 		// POST запросы для депозита и снятия
-		api.POST("/wallet", walletHandlers.PostWalletOperation)
+		api.POST("/wallet", rateLimiter.WalletOperation(), walletHandlers.PostWalletOperation)
 
 		// GET запрос для получения баланса
-		api.GET("/wallets/:walletUUID", walletHandlers.GetBalance)
+		api.GET("/wallets/:walletUUID", rateLimiter.Wallet(ratelimit.OperationBalance), walletHandlers.GetBalance)
 
 		//Для корректной и предсказуемой обработки ошибки, когда не указан walletUUID
 		api.GET("/wallets", walletHandlers.GetBalance)
 	}
+
+	if statementHandlers != nil {
+		// Выписка по кошельку за период
+		api.GET("/wallets/:walletUUID/statement", rateLimiter.Wallet(ratelimit.OperationStatement), statementHandlers.GetStatement)
+	}
+
+	if operationHandlers != nil {
+		// Статус асинхронной операции
+		api.GET("/operations/:id", operationHandlers.GetOperation)
+	}
+
+	if transferHandlers != nil {
+		// Переводы между кошельками
+		api.POST("/transfers", rateLimiter.Transfer(), transferHandlers.PostTransfer)
+		api.GET("/transfers/:id", transferHandlers.GetTransfer)
+	}
+
 	return nil
 }
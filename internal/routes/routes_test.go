@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"wallet-service/internal/db"
+	"wallet-service/internal/db/mocks"
+	"wallet-service/internal/settlement"
+)
+
+type settlementStore struct{}
+
+func (settlementStore) ListTransactionsBetween(ctx context.Context, from, to time.Time) ([]db.LedgerTransaction, error) {
+	return nil, nil
+}
+
+func (settlementStore) SaveSettlementRun(ctx context.Context, run *db.SettlementRun) error {
+	return nil
+}
+
+func (settlementStore) GetSettlementRun(ctx context.Context, id int64) (*db.SettlementRun, error) {
+	return nil, db.ErrSettlementRunNotFound
+}
+
+func (settlementStore) ListSettlementRuns(ctx context.Context, limit int) ([]db.SettlementRun, error) {
+	return []db.SettlementRun{}, nil
+}
+
+func Test_SettlementRoutesRequireAdminToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	gin.SetMode(gin.TestMode)
+
+	var tests = []struct {
+		name       string
+		adminToken string
+		auth       string
+		statusCode int
+	}{
+		{name: "Without ADMIN_TOKEN", statusCode: http.StatusNotFound},
+		{name: "Missing token", adminToken: "secret", statusCode: http.StatusUnauthorized},
+		{name: "Wrong token", adminToken: "secret", auth: "Bearer other", statusCode: http.StatusUnauthorized},
+		{name: "Admin token", adminToken: "secret", auth: "Bearer secret", statusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			err := SetupRoutes(router, Dependencies{
+				Repo:        mocks.NewMockRepository(ctrl),
+				Settlements: settlement.NewService(settlementStore{}, settlement.Rule{Name: settlement.RuleExact}),
+				AdminToken:  tt.adminToken,
+			})
+			assert.NoError(t, err)
+
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/settlements/runs", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
+
+func Test_HotWalletRoutesRequireAdminToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	gin.SetMode(gin.TestMode)
+
+	const wallet = "d7af0768-704e-4f1c-9793-a44c2d1f9b75"
+
+	var tests = []struct {
+		name       string
+		adminToken string
+		auth       string
+		statusCode int
+	}{
+		{name: "Without ADMIN_TOKEN", statusCode: http.StatusNotFound},
+		{name: "Missing token", adminToken: "secret", statusCode: http.StatusUnauthorized},
+		{name: "Wrong token", adminToken: "secret", auth: "Bearer other", statusCode: http.StatusUnauthorized},
+		{name: "Admin token", adminToken: "secret", auth: "Bearer secret", statusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hotWallets := mocks.NewMockHotWalletRepository(ctrl)
+			if tt.statusCode == http.StatusOK {
+				hotWallets.EXPECT().SetShardCount(gomock.Any(), wallet, 8).Return(nil)
+			}
+
+			router := gin.New()
+			err := SetupRoutes(router, Dependencies{
+				Repo:       mocks.NewMockRepository(ctrl),
+				HotWallets: hotWallets,
+				AdminToken: tt.adminToken,
+			})
+			assert.NoError(t, err)
+
+			req, _ := http.NewRequest(http.MethodPut, "/api/v1/wallets/"+wallet+"/shards", strings.NewReader(`{"shardCount":8}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"wallet-service/config"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/ratelimit"
+	"wallet-service/internal/tenant"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Settings - настройки, которые меняются без перезапуска сервиса: при изменении файла конфигурации
+// или через PUT /admin/config. JSON-ключи совпадают с ключами конфигурации, кроме TENANTS -
+// содержимого файла TENANTS_FILE (лимиты, комиссии и ключи арендаторов)
+type Settings struct {
+	LogLevel   string   `json:"LOG_LEVEL"`
+	LogRedact  []string `json:"LOG_REDACT"`
+	RateLimits []string `json:"RATE_LIMITS"`
+	// Tenants - арендаторы, nil - TENANTS_FILE не задан
+	Tenants tenant.Configs `json:"TENANTS,omitempty"`
+}
+
+// FromConfig - настройки времени выполнения из загруженной конфигурации и файла арендаторов
+func FromConfig(cfg *config.Config) (Settings, error) {
+	s := Settings{
+		LogLevel:   cfg.LogLevel,
+		LogRedact:  cfg.LogRedact,
+		RateLimits: cfg.RateLimits,
+	}
+	if cfg.TenantsFile != "" {
+		tenants, err := tenant.ReadConfigs(cfg.TenantsFile)
+		if err != nil {
+			return Settings{}, fmt.Errorf("TENANTS_FILE: %w", err)
+		}
+		s.Tenants = tenants
+	}
+	return s, nil
+}
+
+// Validate проверяет настройки до применения
+func (s Settings) Validate() error {
+	var errs []error
+	if _, err := logrus.ParseLevel(s.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: %w", err))
+	}
+	if _, err := logger.ParseRedaction(s.LogRedact); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_REDACT: %w", err))
+	}
+	if _, err := ratelimit.ParseRules(s.RateLimits); err != nil {
+		errs = append(errs, fmt.Errorf("RATE_LIMITS: %w", err))
+	}
+	if s.Tenants != nil {
+		if _, err := tenant.NewRegistry(s.Tenants); err != nil {
+			errs = append(errs, fmt.Errorf("TENANTS: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Keys - ключи конфигурации, которые меняются без перезапуска
+func Keys() []string {
+	t := reflect.TypeOf(Settings{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		keys = append(keys, t.Field(i).Tag.Get("json"))
+	}
+	return keys
+}
+
+// Manager хранит действующие настройки и применяет изменения: проверяет их, пишет в лог
+// и передает подписчикам (логгеру и другим компонентам с настройками времени выполнения)
+type Manager struct {
+	mu          sync.Mutex
+	current     Settings
+	config      *config.Config
+	subscribers []func(Settings)
+	checks      []func(Settings) error
+}
+
+// NewManager - настройки из конфигурации, загруженной при старте
+func NewManager(cfg *config.Config) (*Manager, error) {
+	current, err := FromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{current: current, config: cfg}, nil
+}
+
+// AddCheck регистрирует проверку настроек сверх Validate: ограничения, которые зависят от того,
+// как запущен сервис. Настройки, не прошедшие проверку, не применяются
+func (m *Manager) AddCheck(check func(Settings) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checks = append(m.checks, check)
+}
+
+// Subscribe регистрирует fn и сразу вызывает ее с действующими настройками
+func (m *Manager) Subscribe(fn func(Settings)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribers = append(m.subscribers, fn)
+	fn(m.current)
+}
+
+// Current - копия действующих настроек: изменения копии (например, разбор тела PUT /admin/config
+// поверх нее) не затрагивают действующие настройки
+func (m *Manager) Current() Settings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := m.current
+	current.LogRedact = slices.Clone(current.LogRedact)
+	current.RateLimits = slices.Clone(current.RateLimits)
+	current.Tenants = current.Tenants.Clone()
+	return current
+}
+
+// Config - последняя успешно загруженная конфигурация. Значения настроек времени выполнения в ней
+// могут отличаться от Current, если они менялись через /admin/config
+func (m *Manager) Config() *config.Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config
+}
+
+// Update применяет настройки next. source попадает в лог (config file, admin API)
+func (m *Manager) Update(source string, next Settings) error {
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current.Tenants == nil && next.Tenants != nil {
+		return errors.New("TENANTS: tenants are not enabled, set TENANTS_FILE and restart")
+	}
+	if m.current.Tenants != nil && next.Tenants == nil {
+		return errors.New("TENANTS: tenants can not be disabled without a restart")
+	}
+	for _, check := range m.checks {
+		if err := check(next); err != nil {
+			return err
+		}
+	}
+
+	changes := diff(m.current, next)
+	if len(changes) == 0 {
+		return nil
+	}
+	m.current = next
+	for _, fn := range m.subscribers {
+		fn(next)
+	}
+	logger.Log.Infof("Runtime settings changed by %s: %s", source, strings.Join(changes, ", "))
+	return nil
+}
+
+// Reload применяет перечитанную конфигурацию. Изменения остальных ключей требуют перезапуска,
+// о них пишется предупреждение без значений: среди них могут быть секреты
+func (m *Manager) Reload(cfg *config.Config) {
+	m.mu.Lock()
+	previous := m.config
+	m.config = cfg
+	m.mu.Unlock()
+
+	runtimeKeys := Keys()
+	var restart []string
+	oldFields := previous.Fields(false)
+	for i, field := range cfg.Fields(false) {
+		if field.Value != oldFields[i].Value && !slices.Contains(runtimeKeys, field.Key) {
+			restart = append(restart, field.Key)
+		}
+	}
+	if len(restart) > 0 {
+		logger.Log.Warnf("Config changes require a restart and are not applied: %s", strings.Join(restart, ", "))
+	}
+
+	next, err := FromConfig(cfg)
+	if err == nil {
+		err = m.Update("config file", next)
+	}
+	if err != nil {
+		logger.Log.Errorf("Ignoring invalid runtime settings from config file: %v", err)
+	}
+}
+
+// diff - изменившиеся ключи в виде KEY: old -> new
+func diff(old, next Settings) []string {
+	var changes []string
+	oldValue, nextValue := reflect.ValueOf(old), reflect.ValueOf(next)
+	for i := 0; i < oldValue.NumField(); i++ {
+		before, after := fmt.Sprint(oldValue.Field(i).Interface()), fmt.Sprint(nextValue.Field(i).Interface())
+		changed := before != after
+		// Хеши ключей арендаторов в выводе скрыты, поэтому арендаторы сравниваются по значению
+		if _, ok := oldValue.Field(i).Interface().(tenant.Configs); ok {
+			changed = !reflect.DeepEqual(oldValue.Field(i).Interface(), nextValue.Field(i).Interface())
+		}
+		if changed {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", oldValue.Type().Field(i).Tag.Get("json"), before, after))
+		}
+	}
+	return changes
+}
+
+// ApplyLogging - подписчик, меняющий уровень логов и скрытие значений
+func ApplyLogging(s Settings) {
+	if level, err := logrus.ParseLevel(s.LogLevel); err == nil {
+		logger.Log.SetLevel(level)
+	}
+	if redaction, err := logger.ParseRedaction(s.LogRedact); err == nil {
+		logger.SetRedaction(redaction)
+	}
+}
+
+// ApplyTenants - подписчик, заменяющий арендаторов реестра: их лимиты, комиссии и ключи
+func ApplyTenants(registry *tenant.Registry) func(Settings) {
+	return func(s Settings) {
+		if s.Tenants == nil {
+			return
+		}
+		if next, err := tenant.NewRegistry(s.Tenants); err == nil {
+			registry.Replace(next)
+		}
+	}
+}
+
+// ApplyRateLimits - подписчик, заменяющий правила ограничения частоты запросов
+func ApplyRateLimits(limiter *ratelimit.Limiter) func(Settings) {
+	return func(s Settings) {
+		if rules, err := ratelimit.ParseRules(s.RateLimits); err == nil {
+			limiter.SetRules(rules)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package settings
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"wallet-service/config"
+	"wallet-service/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Manager(t *testing.T) {
+	cfg := &config.Config{LogLevel: "info", AppPort: "8080"}
+
+	t.Run("update notifies subscribers", func(t *testing.T) {
+		m, err := NewManager(cfg)
+		require.NoError(t, err)
+		var seen []Settings
+		m.Subscribe(func(s Settings) { seen = append(seen, s) })
+
+		require.NoError(t, m.Update("test", Settings{LogLevel: "debug"}))
+		// Повторное применение тех же значений подписчиков не вызывает
+		require.NoError(t, m.Update("test", Settings{LogLevel: "debug"}))
+
+		require.Len(t, seen, 2)
+		assert.Equal(t, "info", seen[0].LogLevel)
+		assert.Equal(t, "debug", seen[1].LogLevel)
+		assert.Equal(t, "debug", m.Current().LogLevel)
+	})
+
+	t.Run("invalid settings are not applied", func(t *testing.T) {
+		m, err := NewManager(cfg)
+		require.NoError(t, err)
+
+		err = m.Update("test", Settings{LogLevel: "loud", LogRedact: []string{"email"}})
+		assert.ErrorContains(t, err, "LOG_LEVEL")
+		assert.ErrorContains(t, err, "LOG_REDACT")
+		assert.Equal(t, "info", m.Current().LogLevel)
+	})
+
+	t.Run("reload applies only runtime settings", func(t *testing.T) {
+		m, err := NewManager(cfg)
+		require.NoError(t, err)
+		m.Reload(&config.Config{LogLevel: "warn", AppPort: "9090"})
+
+		assert.Equal(t, "warn", m.Current().LogLevel)
+		assert.Equal(t, "9090", m.Config().AppPort)
+	})
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func Test_ManagerTenants(t *testing.T) {
+	tenantsFile := filepath.Join(t.TempDir(), "tenants.yaml")
+	writeTenants := func(withdrawLimit string) {
+		require.NoError(t, os.WriteFile(tenantsFile, []byte(`
+tenants:
+  - id: acme
+    api_keys: [`+hashKey("acme-key")+`]
+    limits: {withdraw: `+withdrawLimit+`}
+`), 0o600))
+	}
+	writeTenants("1000")
+	cfg := &config.Config{LogLevel: "info", TenantsFile: tenantsFile}
+
+	newManager := func(t *testing.T) (*Manager, *tenant.Registry) {
+		m, err := NewManager(cfg)
+		require.NoError(t, err)
+		registry, err := tenant.NewRegistry(m.Current().Tenants)
+		require.NoError(t, err)
+		m.Subscribe(ApplyTenants(registry))
+		return m, registry
+	}
+
+	t.Run("reload replaces tenants", func(t *testing.T) {
+		m, registry := newManager(t)
+		assert.Equal(t, int64(1000), registry.Authenticate("acme-key").Limit("WITHDRAW"))
+
+		writeTenants("50")
+		defer writeTenants("1000")
+		m.Reload(cfg)
+		assert.Equal(t, int64(50), registry.Authenticate("acme-key").Limit("WITHDRAW"))
+
+		// Некорректный файл не применяется
+		writeTenants("-1")
+		m.Reload(cfg)
+		assert.Equal(t, int64(50), registry.Authenticate("acme-key").Limit("WITHDRAW"))
+	})
+
+	t.Run("invalid tenants are not applied", func(t *testing.T) {
+		m, registry := newManager(t)
+
+		next := m.Current()
+		next.Tenants = tenant.Configs{{ID: "acme", APIKeys: []string{"plain-key"}}}
+		assert.ErrorContains(t, m.Update("test", next), "TENANTS")
+		assert.NotNil(t, registry.Authenticate("acme-key"))
+
+		next.Tenants = nil
+		assert.ErrorContains(t, m.Update("test", next), "TENANTS")
+	})
+
+	t.Run("checks reject settings", func(t *testing.T) {
+		m, registry := newManager(t)
+		m.AddCheck(func(s Settings) error {
+			if len(s.Tenants) > 1 {
+				return errors.New("TENANTS: too many tenants")
+			}
+			return nil
+		})
+
+		next := m.Current()
+		next.Tenants = append(tenant.Configs{}, next.Tenants...)
+		next.Tenants = append(next.Tenants, tenant.Config{ID: "globex", APIKeys: []string{hashKey("globex-key")}})
+		assert.ErrorContains(t, m.Update("test", next), "too many tenants")
+		assert.Nil(t, registry.Authenticate("globex-key"))
+	})
+
+	t.Run("tenants can not be enabled at runtime", func(t *testing.T) {
+		m, err := NewManager(&config.Config{LogLevel: "info"})
+		require.NoError(t, err)
+
+		next := m.Current()
+		next.Tenants = tenant.Configs{{ID: "acme", APIKeys: []string{hashKey("acme-key")}}}
+		assert.ErrorContains(t, m.Update("test", next), "TENANTS_FILE")
+	})
+}
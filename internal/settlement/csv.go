@@ -0,0 +1,74 @@
+package settlement
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVParser разбирает CSV с заголовком. Обязательные колонки: reference, amount, date.
+// Колонка operation_type необязательна. Порядок колонок не важен.
+type CSVParser struct {
+	Comma rune
+}
+
+func NewCSVParser() *CSVParser {
+	return &CSVParser{Comma: ','}
+}
+
+func (p *CSVParser) Parse(r io.Reader) ([]Line, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = p.Comma
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("settlement file is empty")
+		}
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"reference", "amount", "date"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header is missing column %q", required)
+		}
+	}
+	typeColumn, hasType := columns["operation_type"]
+
+	var lines []Line
+	for lineNo := 2; ; lineNo++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		line := Line{LineNo: lineNo, Reference: strings.TrimSpace(record[columns["reference"]])}
+
+		if line.Amount, err = strconv.ParseInt(strings.TrimSpace(record[columns["amount"]]), 10, 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount: %w", lineNo, err)
+		}
+		if line.OccurredAt, err = parseDate(record[columns["date"]]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if hasType {
+			if line.OperationType, err = parseOperationType(record[typeColumn]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
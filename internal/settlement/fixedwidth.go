@@ -0,0 +1,100 @@
+package settlement
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Field - позиция поля в строке фиксированной ширины, [Start, End) в байтах
+type Field struct {
+	Start int
+	End   int
+}
+
+// FixedWidthLayout описывает расположение полей. Поле OperationType с End == 0 отсутствует
+type FixedWidthLayout struct {
+	Reference     Field
+	Amount        Field
+	OperationType Field
+	Date          Field
+}
+
+// DefaultFixedWidthLayout: референс 20 символов, сумма 15 (с ведущими нулями),
+// тип операции 10, дата YYYYMMDDHHMMSS
+var DefaultFixedWidthLayout = FixedWidthLayout{
+	Reference:     Field{Start: 0, End: 20},
+	Amount:        Field{Start: 20, End: 35},
+	OperationType: Field{Start: 35, End: 45},
+	Date:          Field{Start: 45, End: 59},
+}
+
+type FixedWidthParser struct {
+	Layout FixedWidthLayout
+}
+
+func NewFixedWidthParser(layout FixedWidthLayout) *FixedWidthParser {
+	return &FixedWidthParser{Layout: layout}
+}
+
+func (p *FixedWidthParser) Parse(r io.Reader) ([]Line, error) {
+	var lines []Line
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		raw := scanner.Text()
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+
+		line := Line{LineNo: lineNo}
+		var err error
+
+		if line.Reference, err = p.field(raw, p.Layout.Reference); err != nil {
+			return nil, fmt.Errorf("line %d: reference: %w", lineNo, err)
+		}
+
+		amount, err := p.field(raw, p.Layout.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: amount: %w", lineNo, err)
+		}
+		if line.Amount, err = strconv.ParseInt(amount, 10, 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount: %w", lineNo, err)
+		}
+
+		if p.Layout.OperationType.End > 0 {
+			opType, err := p.field(raw, p.Layout.OperationType)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: operation type: %w", lineNo, err)
+			}
+			if line.OperationType, err = parseOperationType(opType); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+
+		date, err := p.field(raw, p.Layout.Date)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: date: %w", lineNo, err)
+		}
+		if line.OccurredAt, err = parseDate(date); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fixed-width file: %w", err)
+	}
+
+	return lines, nil
+}
+
+func (p *FixedWidthParser) field(raw string, f Field) (string, error) {
+	if f.End > len(raw) {
+		return "", fmt.Errorf("line is too short (%d bytes, field ends at %d)", len(raw), f.End)
+	}
+	return strings.TrimSpace(raw[f.Start:f.End]), nil
+}
@@ -0,0 +1,254 @@
+package settlement
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+	"wallet-service/internal/db"
+)
+
+const (
+	// RuleExact - совпадение референса (ID транзакции в журнале) и суммы
+	RuleExact = "exact"
+	// RuleAmountDate - совпадение суммы, дата транзакции в пределах окна от даты строки файла
+	RuleAmountDate = "amount_date"
+)
+
+const (
+	StatusMatched         = "MATCHED"
+	StatusUnmatchedFile   = "UNMATCHED_FILE"
+	StatusUnmatchedLedger = "UNMATCHED_LEDGER"
+)
+
+// Rule - правило сопоставления строк файла с транзакциями журнала.
+// DateWindow также определяет, насколько расширяется период выборки журнала вокруг дат из файла.
+type Rule struct {
+	Name       string
+	DateWindow time.Duration
+}
+
+func (r Rule) Validate() error {
+	if r.Name != RuleExact && r.Name != RuleAmountDate {
+		return fmt.Errorf("unsupported match rule %q", r.Name)
+	}
+	if r.DateWindow < 0 {
+		return fmt.Errorf("date window must not be negative")
+	}
+	return nil
+}
+
+// Pair - строка файла и сопоставленная ей транзакция
+type Pair struct {
+	Line        Line
+	Transaction db.LedgerTransaction
+}
+
+type MatchResult struct {
+	Matched         []Pair
+	UnmatchedFile   []Line
+	UnmatchedLedger []db.LedgerTransaction
+}
+
+// Match сопоставляет строки файла с транзакциями. Каждая транзакция используется не более одного раза.
+func Match(lines []Line, ledger []db.LedgerTransaction, rule Rule) MatchResult {
+	var result MatchResult
+	used := make([]bool, len(ledger))
+
+	byReference := make(map[string]int, len(ledger))
+	var byDate *dateIndex
+	switch rule.Name {
+	case RuleExact:
+		for i, tx := range ledger {
+			ref := strconv.FormatInt(tx.ID, 10)
+			if _, ok := byReference[ref]; ok {
+				// Одинаковые ID у транзакций разных шардов: по референсу не сопоставить
+				byReference[ref] = -1
+				continue
+			}
+			byReference[ref] = i
+		}
+	case RuleAmountDate:
+		byDate = newDateIndex(ledger)
+	}
+
+	for _, line := range lines {
+		idx := -1
+		switch rule.Name {
+		case RuleExact:
+			if i, ok := byReference[line.Reference]; ok && i >= 0 && !used[i] && compatible(line, ledger[i]) {
+				idx = i
+			}
+		case RuleAmountDate:
+			idx = byDate.closest(line, rule.DateWindow)
+		}
+
+		if idx < 0 {
+			result.UnmatchedFile = append(result.UnmatchedFile, line)
+			continue
+		}
+		used[idx] = true
+		result.Matched = append(result.Matched, Pair{Line: line, Transaction: ledger[idx]})
+	}
+
+	for i, tx := range ledger {
+		if !used[i] {
+			result.UnmatchedLedger = append(result.UnmatchedLedger, tx)
+		}
+	}
+
+	return result
+}
+
+func compatible(line Line, tx db.LedgerTransaction) bool {
+	if line.Amount != tx.Amount {
+		return false
+	}
+	return line.OperationType == "" || line.OperationType == tx.OperationType
+}
+
+// dateIndex - транзакции журнала, сгруппированные по сумме и типу операции и отсортированные по дате.
+// Ближайшая по дате транзакция ищется двоичным поиском, а не перебором всего журнала
+type dateIndex struct {
+	groups map[dateKey]*dateGroup
+}
+
+type dateKey struct {
+	amount        int64
+	operationType string
+}
+
+// dateGroup - транзакции одной суммы и типа по возрастанию даты. left и right пропускают
+// уже сопоставленные транзакции (система непересекающихся множеств со сжатием путей)
+type dateGroup struct {
+	ledger []db.LedgerTransaction
+	// indexes - позиции транзакций группы в исходном журнале
+	indexes []int
+	left    []int
+	right   []int
+}
+
+func newDateIndex(ledger []db.LedgerTransaction) *dateIndex {
+	index := &dateIndex{groups: map[dateKey]*dateGroup{}}
+	for i, tx := range ledger {
+		key := dateKey{amount: tx.Amount, operationType: tx.OperationType}
+		group := index.groups[key]
+		if group == nil {
+			group = &dateGroup{ledger: ledger}
+			index.groups[key] = group
+		}
+		group.indexes = append(group.indexes, i)
+	}
+
+	for _, group := range index.groups {
+		// Устойчивая сортировка: при равных датах раньше идет транзакция, раньше стоящая в журнале
+		slices.SortStableFunc(group.indexes, func(a, b int) int {
+			return ledger[a].CreatedAt.Compare(ledger[b].CreatedAt)
+		})
+		// Позиции 0..n - 1 - транзакции, -1 и n - границы
+		n := len(group.indexes)
+		group.left = make([]int, n+1)
+		group.right = make([]int, n+1)
+		for i := 0; i <= n; i++ {
+			group.left[i], group.right[i] = i, i
+		}
+	}
+	return index
+}
+
+// closest возвращает позицию в журнале несопоставленной транзакции той же суммы (и типа, если он
+// указан в строке) с ближайшей датой в пределах window и отмечает ее сопоставленной, -1 - такой нет.
+// При равном расстоянии выбирается транзакция, раньше стоящая в журнале
+func (d *dateIndex) closest(line Line, window time.Duration) int {
+	var groups []*dateGroup
+	if line.OperationType != "" {
+		groups = append(groups, d.groups[dateKey{amount: line.Amount, operationType: line.OperationType}])
+	} else {
+		for key, group := range d.groups {
+			if key.amount == line.Amount {
+				groups = append(groups, group)
+			}
+		}
+	}
+
+	var best *dateGroup
+	bestPos, bestIdx := -1, -1
+	var bestDiff time.Duration
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		pos, diff := group.closest(line.OccurredAt, window)
+		if pos < 0 {
+			continue
+		}
+		idx := group.indexes[pos]
+		if best == nil || diff < bestDiff || (diff == bestDiff && idx < bestIdx) {
+			best, bestPos, bestIdx, bestDiff = group, pos, idx, diff
+		}
+	}
+
+	if best == nil {
+		return -1
+	}
+	best.remove(bestPos)
+	return bestIdx
+}
+
+// closest - позиция в группе ближайшей по дате несопоставленной транзакции в пределах window
+func (g *dateGroup) closest(at time.Time, window time.Duration) (int, time.Duration) {
+	n := len(g.indexes)
+	pos := g.search(at)
+
+	best, bestDiff := -1, time.Duration(0)
+	if left := g.findLeft(pos); left >= 0 {
+		createdAt := g.ledger[g.indexes[left]].CreatedAt
+		if diff := at.Sub(createdAt); diff <= window {
+			// Из транзакций с той же датой - первая несопоставленная
+			best, bestDiff = g.findRight(g.search(createdAt)), diff
+		}
+	}
+	if right := g.findRight(pos); right < n {
+		diff := g.ledger[g.indexes[right]].CreatedAt.Sub(at)
+		if diff <= window && (best < 0 || diff < bestDiff ||
+			(diff == bestDiff && g.indexes[right] < g.indexes[best])) {
+			best, bestDiff = right, diff
+		}
+	}
+	return best, bestDiff
+}
+
+// search - позиция первой транзакции с датой не раньше at
+func (g *dateGroup) search(at time.Time) int {
+	pos, _ := slices.BinarySearchFunc(g.indexes, at, func(idx int, at time.Time) int {
+		return g.ledger[idx].CreatedAt.Compare(at)
+	})
+	return pos
+}
+
+// findLeft - ближайшая несопоставленная позиция меньше pos, -1 - такой нет
+func (g *dateGroup) findLeft(pos int) int {
+	// left хранит позиции со сдвигом на 1, чтобы граница -1 была индексом 0
+	i := pos
+	for g.left[i] != i {
+		g.left[i] = g.left[g.left[i]]
+		i = g.left[i]
+	}
+	return i - 1
+}
+
+// findRight - ближайшая несопоставленная позиция не меньше pos, n - такой нет
+func (g *dateGroup) findRight(pos int) int {
+	i := pos
+	for g.right[i] != i {
+		g.right[i] = g.right[g.right[i]]
+		i = g.right[i]
+	}
+	return i
+}
+
+// remove отмечает позицию сопоставленной
+func (g *dateGroup) remove(pos int) {
+	g.left[pos+1] = pos
+	g.right[pos] = pos + 1
+}
@@ -0,0 +1,101 @@
+package settlement
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FormatCSV        = "csv"
+	FormatFixedWidth = "fixed"
+)
+
+// Line - одна строка файла расчетов в нормализованном виде
+type Line struct {
+	LineNo        int
+	Reference     string
+	Amount        int64
+	OperationType string // DEPOSIT, WITHDRAW или пусто, если в файле нет типа операции
+	OccurredAt    time.Time
+}
+
+// Parser разбирает файл расчетов конкретного формата
+type Parser interface {
+	Parse(r io.Reader) ([]Line, error)
+}
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = map[string]Parser{
+		FormatCSV:        NewCSVParser(),
+		FormatFixedWidth: NewFixedWidthParser(DefaultFixedWidthLayout),
+	}
+)
+
+// RegisterParser добавляет или заменяет парсер для формата
+func RegisterParser(format string, p Parser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[format] = p
+}
+
+// ParserFor возвращает парсер, зарегистрированный для формата
+func ParserFor(format string) (Parser, error) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+
+	p, ok := parsers[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported settlement file format %q", format)
+	}
+	return p, nil
+}
+
+// Formats возвращает список зарегистрированных форматов
+func Formats() []string {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+
+	formats := make([]string, 0, len(parsers))
+	for f := range parsers {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// dateLayouts - форматы дат, которые принимаются в файлах расчетов
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"20060102150405",
+	"2006-01-02",
+	"20060102",
+}
+
+func parseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+func parseOperationType(value string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "":
+		return "", nil
+	case "DEPOSIT", "CREDIT", "CR":
+		return "DEPOSIT", nil
+	case "WITHDRAW", "DEBIT", "DR":
+		return "WITHDRAW", nil
+	default:
+		return "", fmt.Errorf("invalid operation type %q", value)
+	}
+}
@@ -0,0 +1,132 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+)
+
+// ErrInvalidInput - файл или параметры сверки некорректны (ошибка клиента, а не сервиса)
+var ErrInvalidInput = errors.New("invalid settlement input")
+
+// Store - хранилище журнала и прогонов сверки, реализуется db.PostgresRepository
+type Store interface {
+	ListTransactionsBetween(ctx context.Context, from, to time.Time) ([]db.LedgerTransaction, error)
+	SaveSettlementRun(ctx context.Context, run *db.SettlementRun) error
+	GetSettlementRun(ctx context.Context, id int64) (*db.SettlementRun, error)
+	ListSettlementRuns(ctx context.Context, limit int) ([]db.SettlementRun, error)
+}
+
+type Service struct {
+	Store       Store
+	DefaultRule Rule
+}
+
+func NewService(store Store, defaultRule Rule) *Service {
+	return &Service{Store: store, DefaultRule: defaultRule}
+}
+
+// Import разбирает файл расчетов, сопоставляет его с журналом и сохраняет прогон
+func (s *Service) Import(ctx context.Context, source, format string, r io.Reader, rule Rule) (*db.SettlementRun, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+
+	parser, err := ParserFor(format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+
+	lines, err := parser.Parse(r)
+	if err != nil {
+		logger.Log.Warnf("Failed to parse settlement file %s: %v", source, err)
+		return nil, fmt.Errorf("%w: failed to parse settlement file: %v", ErrInvalidInput, err)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: settlement file has no lines", ErrInvalidInput)
+	}
+
+	from, to := lines[0].OccurredAt, lines[0].OccurredAt
+	for _, line := range lines[1:] {
+		if line.OccurredAt.Before(from) {
+			from = line.OccurredAt
+		}
+		if line.OccurredAt.After(to) {
+			to = line.OccurredAt
+		}
+	}
+	from, to = from.Add(-rule.DateWindow), to.Add(rule.DateWindow)
+
+	ledger, err := s.Store.ListTransactionsBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.Infof("Matching %d settlement lines from %s against %d ledger transactions (rule %s)", len(lines), source, len(ledger), rule.Name)
+	result := Match(lines, ledger, rule)
+
+	run := &db.SettlementRun{
+		Source:               source,
+		Format:               format,
+		MatchRule:            rule.Name,
+		DateWindowSeconds:    int64(rule.DateWindow / time.Second),
+		PeriodFrom:           &from,
+		PeriodTo:             &to,
+		MatchedCount:         len(result.Matched),
+		UnmatchedFileCount:   len(result.UnmatchedFile),
+		UnmatchedLedgerCount: len(result.UnmatchedLedger),
+		Items:                make([]db.SettlementItem, 0, len(lines)+len(result.UnmatchedLedger)),
+	}
+
+	for _, pair := range result.Matched {
+		item := lineItem(StatusMatched, pair.Line)
+		item.TransactionID = &pair.Transaction.ID
+		run.Items = append(run.Items, item)
+	}
+	for _, line := range result.UnmatchedFile {
+		run.Items = append(run.Items, lineItem(StatusUnmatchedFile, line))
+	}
+	for _, tx := range result.UnmatchedLedger {
+		run.Items = append(run.Items, db.SettlementItem{
+			Status:        StatusUnmatchedLedger,
+			Amount:        tx.Amount,
+			OperationType: &tx.OperationType,
+			OccurredAt:    &tx.CreatedAt,
+			TransactionID: &tx.ID,
+		})
+	}
+
+	if err := s.Store.SaveSettlementRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	logger.Log.Infof("Settlement run %d: %d matched, %d unmatched in file, %d unmatched in ledger",
+		run.ID, run.MatchedCount, run.UnmatchedFileCount, run.UnmatchedLedgerCount)
+	return run, nil
+}
+
+func (s *Service) GetRun(ctx context.Context, id int64) (*db.SettlementRun, error) {
+	return s.Store.GetSettlementRun(ctx, id)
+}
+
+func (s *Service) ListRuns(ctx context.Context, limit int) ([]db.SettlementRun, error) {
+	return s.Store.ListSettlementRuns(ctx, limit)
+}
+
+func lineItem(status string, line Line) db.SettlementItem {
+	item := db.SettlementItem{
+		Status:     status,
+		LineNo:     &line.LineNo,
+		Reference:  &line.Reference,
+		Amount:     line.Amount,
+		OccurredAt: &line.OccurredAt,
+	}
+	if line.OperationType != "" {
+		item.OperationType = &line.OperationType
+	}
+	return item
+}
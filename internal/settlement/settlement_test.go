@@ -0,0 +1,151 @@
+package settlement
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"wallet-service/internal/db"
+)
+
+func Test_Parsers(t *testing.T) {
+	csvFile := "reference,amount,operation_type,date\n" +
+		"101,500,credit,2024-03-01T10:00:00Z\n" +
+		"102,250,,2024-03-01\n"
+
+	lines, err := NewCSVParser().Parse(strings.NewReader(csvFile))
+	assert.NoError(t, err)
+	assert.Equal(t, []Line{
+		{LineNo: 2, Reference: "101", Amount: 500, OperationType: "DEPOSIT", OccurredAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{LineNo: 3, Reference: "102", Amount: 250, OccurredAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}, lines)
+
+	fixedFile := "101                 000000000000500DEPOSIT   20240301100000\n"
+
+	lines, err = NewFixedWidthParser(DefaultFixedWidthLayout).Parse(strings.NewReader(fixedFile))
+	assert.NoError(t, err)
+	assert.Equal(t, []Line{
+		{LineNo: 1, Reference: "101", Amount: 500, OperationType: "DEPOSIT", OccurredAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+	}, lines)
+
+	_, err = NewCSVParser().Parse(strings.NewReader("reference,amount\n1,2\n"))
+	assert.Error(t, err)
+}
+
+func Test_Match(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	ledger := []db.LedgerTransaction{
+		{ID: 101, OperationType: "DEPOSIT", Amount: 500, CreatedAt: day.Add(10 * time.Hour)},
+		{ID: 102, OperationType: "WITHDRAW", Amount: 250, CreatedAt: day.Add(12 * time.Hour)},
+		{ID: 103, OperationType: "DEPOSIT", Amount: 700, CreatedAt: day.Add(30 * time.Hour)},
+	}
+	lines := []Line{
+		{LineNo: 1, Reference: "101", Amount: 500, OccurredAt: day},
+		{LineNo: 2, Reference: "102", Amount: 999, OccurredAt: day},
+		{LineNo: 3, Reference: "x", Amount: 700, OccurredAt: day.Add(29 * time.Hour)},
+	}
+
+	exact := Match(lines, ledger, Rule{Name: RuleExact})
+	assert.Len(t, exact.Matched, 1)
+	assert.Equal(t, int64(101), exact.Matched[0].Transaction.ID)
+	assert.Len(t, exact.UnmatchedFile, 2)
+	assert.Len(t, exact.UnmatchedLedger, 2)
+
+	byDate := Match(lines, ledger, Rule{Name: RuleAmountDate, DateWindow: 12 * time.Hour})
+	assert.Len(t, byDate.Matched, 2)
+	assert.Equal(t, int64(101), byDate.Matched[0].Transaction.ID)
+	assert.Equal(t, int64(103), byDate.Matched[1].Transaction.ID)
+	assert.Equal(t, []Line{lines[1]}, byDate.UnmatchedFile)
+	assert.Equal(t, []db.LedgerTransaction{ledger[1]}, byDate.UnmatchedLedger)
+}
+
+func Test_MatchExactAmbiguousReference(t *testing.T) {
+	// Журналы шардов: ID 101 есть на двух шардах
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	ledger := []db.LedgerTransaction{
+		{ID: 101, WalletUUID: "a", OperationType: "DEPOSIT", Amount: 500, CreatedAt: day},
+		{ID: 101, WalletUUID: "b", OperationType: "DEPOSIT", Amount: 500, CreatedAt: day},
+		{ID: 102, WalletUUID: "b", OperationType: "DEPOSIT", Amount: 300, CreatedAt: day},
+	}
+	lines := []Line{
+		{LineNo: 1, Reference: "101", Amount: 500, OccurredAt: day},
+		{LineNo: 2, Reference: "102", Amount: 300, OccurredAt: day},
+	}
+
+	result := Match(lines, ledger, Rule{Name: RuleExact})
+	assert.Len(t, result.Matched, 1)
+	assert.Equal(t, int64(102), result.Matched[0].Transaction.ID)
+	assert.Equal(t, []Line{lines[0]}, result.UnmatchedFile)
+	assert.Equal(t, ledger[:2], result.UnmatchedLedger)
+}
+
+// Test_MatchAmountDateClosest сверяет поиск по дате с полным перебором журнала
+func Test_MatchAmountDateClosest(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	random := rand.New(rand.NewSource(1))
+	operations := []string{"", "DEPOSIT", "WITHDRAW"}
+
+	for round := 0; round < 50; round++ {
+		var ledger []db.LedgerTransaction
+		for i := 0; i < 200; i++ {
+			ledger = append(ledger, db.LedgerTransaction{
+				ID:            int64(i + 1),
+				OperationType: operations[1+random.Intn(2)],
+				Amount:        int64(100 * (1 + random.Intn(5))),
+				CreatedAt:     day.Add(time.Duration(random.Intn(48)) * time.Hour),
+			})
+		}
+		slices.SortStableFunc(ledger, func(a, b db.LedgerTransaction) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+		var lines []Line
+		for i := 0; i < 200; i++ {
+			lines = append(lines, Line{
+				LineNo:        i + 1,
+				OperationType: operations[random.Intn(3)],
+				Amount:        int64(100 * (1 + random.Intn(5))),
+				OccurredAt:    day.Add(time.Duration(random.Intn(48*60)) * time.Minute),
+			})
+		}
+
+		rule := Rule{Name: RuleAmountDate, DateWindow: 3 * time.Hour}
+		assert.Equal(t, matchByScan(lines, ledger, rule.DateWindow), Match(lines, ledger, rule), "round %d", round)
+	}
+}
+
+// matchByScan - сопоставление по дате полным перебором журнала для каждой строки
+func matchByScan(lines []Line, ledger []db.LedgerTransaction, window time.Duration) MatchResult {
+	var result MatchResult
+	used := make([]bool, len(ledger))
+	for _, line := range lines {
+		best := -1
+		var bestDiff time.Duration
+		for i, tx := range ledger {
+			if used[i] || !compatible(line, tx) {
+				continue
+			}
+			diff := tx.CreatedAt.Sub(line.OccurredAt)
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff <= window && (best < 0 || diff < bestDiff) {
+				best, bestDiff = i, diff
+			}
+		}
+		if best < 0 {
+			result.UnmatchedFile = append(result.UnmatchedFile, line)
+			continue
+		}
+		used[best] = true
+		result.Matched = append(result.Matched, Pair{Line: line, Transaction: ledger[best]})
+	}
+	for i, tx := range ledger {
+		if !used[i] {
+			result.UnmatchedLedger = append(result.UnmatchedLedger, tx)
+		}
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+)
+
+// MoveWallet переносит кошелек вместе с журналом на шард target (см. порядок шагов в db/move_methods.go).
+// На время переноса операции с кошельком ждут блокировку на шарде-источнике.
+// Прерванный перенос завершается повторным вызовом
+func (s *ShardedRepository) MoveWallet(ctx context.Context, walletUUID, target string) error {
+	log := logger.Log.WithContext(ctx)
+
+	dst, ok := s.shards[target]
+	if !ok {
+		return fmt.Errorf("unknown shard %q", target)
+	}
+
+	src, err := s.Locate(ctx, walletUUID)
+	if errors.Is(err, db.ErrWalletMoving) {
+		return s.resumeMove(ctx, walletUUID, dst)
+	} else if err != nil {
+		return err
+	}
+	if src == dst {
+		log.Infof("Wallet %s is already on shard %s", walletUUID, target)
+		return nil
+	}
+
+	export, err := src.Repo.ExportWallet(ctx, walletUUID)
+	if err != nil {
+		return err
+	}
+
+	if err := dst.Repo.ImportWallet(ctx, export, src.Name); err != nil {
+		export.Rollback()
+		return fmt.Errorf("failed to import wallet into shard %s: %w", dst.Name, err)
+	}
+
+	if err := export.Commit(ctx, dst.Name); err != nil {
+		return fmt.Errorf("failed to mark wallet as moved on shard %s: %w", src.Name, err)
+	}
+
+	if err := dst.Repo.ActivateWallet(ctx, walletUUID, src.Name); err != nil {
+		return fmt.Errorf("failed to activate wallet on shard %s (re-run the move to finish): %w", dst.Name, err)
+	}
+
+	if err := s.updateForwarding(ctx, walletUUID, src, dst); err != nil {
+		return err
+	}
+
+	log.Infof("Wallet %s moved from shard %s to %s with balance %d and %d transactions",
+		walletUUID, src.Name, dst.Name, logger.Amount(export.Balance), len(export.Transactions))
+	return nil
+}
+
+// resumeMove завершает перенос, прерванный после фиксации на источнике: копия на шарде назначения
+// указывает на источник, а источник - на шард назначения
+func (s *ShardedRepository) resumeMove(ctx context.Context, walletUUID string, dst *Shard) error {
+	found, source, err := dst.Repo.WalletLocation(ctx, walletUUID)
+	if err != nil {
+		return err
+	}
+	src, ok := s.shards[source]
+	if !found || !ok {
+		return fmt.Errorf("wallet %s has no pending copy on shard %s", walletUUID, dst.Name)
+	}
+
+	_, movedTo, err := src.Repo.WalletLocation(ctx, walletUUID)
+	if err != nil {
+		return err
+	}
+	if movedTo != dst.Name {
+		return fmt.Errorf("wallet %s on shard %s is not moved to shard %s", walletUUID, src.Name, dst.Name)
+	}
+
+	if err := dst.Repo.ActivateWallet(ctx, walletUUID, src.Name); err != nil {
+		return fmt.Errorf("failed to activate wallet on shard %s: %w", dst.Name, err)
+	}
+	if err := s.updateForwarding(ctx, walletUUID, src, dst); err != nil {
+		return err
+	}
+
+	logger.Log.WithContext(ctx).Infof("Interrupted move of wallet %s from shard %s to %s finished", walletUUID, src.Name, dst.Name)
+	return nil
+}
+
+// updateForwarding направляет указатель на домашнем шарде на новый шард кошелька.
+// Если домашний шард - источник или назначение, указатель уже верный
+func (s *ShardedRepository) updateForwarding(ctx context.Context, walletUUID string, src, dst *Shard) error {
+	home := s.Home(walletUUID)
+	if home == src || home == dst {
+		return nil
+	}
+	if err := home.Repo.SetWalletForwarding(ctx, walletUUID, dst.Name); err != nil {
+		return fmt.Errorf("failed to update forwarding on home shard %s: %w", home.Name, err)
+	}
+	return nil
+}
+
+// RebalanceResult - итог перебалансировки шардов
+type RebalanceResult struct {
+	Checked int
+	Moved   int
+	Failed  int
+}
+
+// Rebalance переносит на домашние шарды кошельки, которые живут не там, например после
+// добавления шарда в конфигурацию. Кошельки переносятся от имени их арендаторов. При dryRun только считает такие кошельки.
+// onMove вызывается для каждого кошелька, который нужно перенести
+func (s *ShardedRepository) Rebalance(ctx context.Context, batchSize int, dryRun bool,
+	onMove func(walletUUID, from, to string, err error)) (RebalanceResult, error) {
+	var result RebalanceResult
+
+	for _, name := range s.names {
+		shard := s.shards[name]
+		var afterID int64
+
+		for {
+			batch, err := shard.Repo.ListWalletUUIDs(ctx, afterID, batchSize)
+			if err != nil {
+				return result, fmt.Errorf("shard %s: %w", shard.Name, err)
+			}
+			if len(batch) == 0 {
+				break
+			}
+
+			for _, ref := range batch {
+				afterID = ref.ID
+				result.Checked++
+
+				home := s.Home(ref.UUID)
+				if home == shard {
+					continue
+				}
+
+				var moveErr error
+				if !dryRun {
+					moveErr = s.MoveWallet(db.WithTenant(ctx, ref.TenantID), ref.UUID, home.Name)
+				}
+				if moveErr != nil {
+					result.Failed++
+				} else {
+					result.Moved++
+				}
+				if onMove != nil {
+					onMove(ref.UUID, shard.Name, home.Name, moveErr)
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"wallet-service/internal/db"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPostgresShards - шарды в отдельных схемах базы TEST_DATABASE_URL. Без нее тест пропускается
+func newPostgresShards(t *testing.T, names ...string) (*ShardedRepository, map[string]*db.PostgresRepository) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := db.Open(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() })
+
+	repos := map[string]*db.PostgresRepository{}
+	var shards []*Shard
+	for _, name := range names {
+		schema := fmt.Sprintf("shard_%s_%s", name, uuid.NewString()[:8])
+		_, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema)
+		require.NoError(t, err)
+
+		conn, err := db.Open(withSearchPath(t, dsn, schema))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			conn.Close()
+			admin.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE")
+		})
+		require.NoError(t, db.RunMigrations(ctx, conn))
+
+		repos[name] = db.NewPostgresRepository(conn)
+		shards = append(shards, &Shard{Name: name, Repo: repos[name]})
+	}
+
+	sharded, err := NewShardedRepository(shards, 16)
+	require.NoError(t, err)
+	return sharded, repos
+}
+
+// withSearchPath добавляет к строке подключения (URL или ключ=значение) схему по умолчанию
+func withSearchPath(t *testing.T, dsn, schema string) string {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsn + " search_path=" + schema
+	}
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// ledger - журнал шарда или всех шардов для сверки с файлами расчетов
+type ledger interface {
+	ListTransactionsBetween(ctx context.Context, from, to time.Time) ([]db.LedgerTransaction, error)
+}
+
+// ledgerCount - число транзакций кошелька в журнале за последний час
+func ledgerCount(t *testing.T, store ledger, walletUUID string) int {
+	txs, err := store.ListTransactionsBetween(context.Background(), time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	var count int
+	for _, tx := range txs {
+		if tx.WalletUUID == walletUUID {
+			count++
+		}
+	}
+	return count
+}
+
+func Test_PostgresMoveWallet(t *testing.T) {
+	ctx := context.Background()
+	sharded, repos := newPostgresShards(t, "a", "b")
+	walletUUID := walletOn(t, sharded, "a")
+
+	require.NoError(t, sharded.DepositMoney(ctx, walletUUID, 100))
+	require.NoError(t, sharded.WithdrawMoney(ctx, walletUUID, 30))
+
+	require.NoError(t, sharded.MoveWallet(ctx, walletUUID, "b"))
+
+	shard, err := sharded.Locate(ctx, walletUUID)
+	require.NoError(t, err)
+	assert.Equal(t, "b", shard.Name)
+
+	found, movedTo, err := repos["a"].WalletLocation(ctx, walletUUID)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "b", movedTo, "home shard forwards to the new shard")
+
+	balance, err := repos["b"].GetBalance(ctx, walletUUID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(70), balance)
+	assert.Equal(t, 2, ledgerCount(t, repos["b"], walletUUID), "journal is copied with the wallet")
+
+	// Операции идут на новый шард, журнал перенесенного кошелька не учитывается дважды
+	require.NoError(t, sharded.DepositMoney(ctx, walletUUID, 5))
+	assert.Equal(t, 3, ledgerCount(t, sharded, walletUUID))
+
+	// Повторный перенос на тот же шард ничего не делает
+	require.NoError(t, sharded.MoveWallet(ctx, walletUUID, "b"))
+
+	t.Run("moves back to the home shard", func(t *testing.T) {
+		require.NoError(t, sharded.MoveWallet(ctx, walletUUID, "a"))
+
+		shard, err := sharded.Locate(ctx, walletUUID)
+		require.NoError(t, err)
+		assert.Equal(t, "a", shard.Name)
+
+		balance, err := sharded.GetBalance(ctx, walletUUID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(75), balance)
+		assert.Equal(t, 3, ledgerCount(t, repos["a"], walletUUID), "only new transactions are copied to the old row")
+		assert.Equal(t, 3, ledgerCount(t, sharded, walletUUID))
+	})
+
+	t.Run("unknown target shard", func(t *testing.T) {
+		assert.ErrorContains(t, sharded.MoveWallet(ctx, walletUUID, "c"), "unknown shard")
+	})
+
+	t.Run("rebalance finds nothing to move", func(t *testing.T) {
+		result, err := sharded.Rebalance(ctx, 10, true, nil)
+		require.NoError(t, err)
+		assert.Zero(t, result.Moved)
+	})
+}
@@ -0,0 +1,278 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+
+	"github.com/lib/pq"
+)
+
+// maxForwardHops ограничивает цепочку указателей moved_to между шардами
+const maxForwardHops = 4
+
+// Store - база одного шарда, реализуется db.PostgresRepository
+type Store interface {
+	db.Repository
+	db.HistoryRepository
+	db.HotWalletRepository
+	db.TransferRepository
+	db.AdminRepository
+	db.RiskRepository
+
+	// перенос кошельков между шардами (move.go)
+	WalletLocation(ctx context.Context, walletUUID string) (found bool, movedTo string, err error)
+	ExportWallet(ctx context.Context, walletUUID string) (*db.WalletExport, error)
+	ImportWallet(ctx context.Context, export *db.WalletExport, source string) error
+	ActivateWallet(ctx context.Context, walletUUID, source string) error
+	SetWalletForwarding(ctx context.Context, walletUUID, shard string) error
+	ListWalletUUIDs(ctx context.Context, afterID int64, limit int) ([]db.WalletRef, error)
+
+	// шаги саги перевода между шардами (saga.go)
+	CreateTransfer(ctx context.Context, t *db.Transfer) error
+	DebitTransfer(ctx context.Context, t *db.Transfer) error
+	CreditTransfer(ctx context.Context, t *db.Transfer) error
+	AbortTransferCredit(ctx context.Context, transferID string) (bool, error)
+	CompleteTransfer(ctx context.Context, t *db.Transfer) error
+	CompensateTransfer(ctx context.Context, t *db.Transfer, reason string) error
+	FailTransfer(ctx context.Context, t *db.Transfer, reason string) error
+	ListUnfinishedTransfers(ctx context.Context, before time.Time, limit int) ([]db.Transfer, error)
+
+	// сверка с файлами расчетов (settlement.go)
+	ListTransactionsBetween(ctx context.Context, from, to time.Time) ([]db.LedgerTransaction, error)
+	SaveSettlementRun(ctx context.Context, run *db.SettlementRun) error
+	GetSettlementRun(ctx context.Context, id int64) (*db.SettlementRun, error)
+	ListSettlementRuns(ctx context.Context, limit int) ([]db.SettlementRun, error)
+}
+
+var _ Store = (*db.PostgresRepository)(nil)
+
+// Shard - одна база данных в шардированной конфигурации
+type Shard struct {
+	Name string
+	Repo Store
+}
+
+// ShardedRepository распределяет кошельки по шардам консистентным хешированием UUID.
+// Кошелек живет на домашнем шарде, если не был перенесен: тогда на домашнем шарде остается
+// указатель moved_to на шард, куда он перенесен. Операции с одним кошельком выполняются
+// целиком на его шарде, переводы между шардами - сагой (saga.go)
+type ShardedRepository struct {
+	shards map[string]*Shard
+	names  []string
+	ring   *Ring
+}
+
+func NewShardedRepository(shards []*Shard, vnodes int) (*ShardedRepository, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("no shards configured")
+	}
+
+	s := &ShardedRepository{shards: make(map[string]*Shard, len(shards))}
+	for _, shard := range shards {
+		if shard.Name == "" {
+			return nil, errors.New("shard name is empty")
+		}
+		if _, ok := s.shards[shard.Name]; ok {
+			return nil, fmt.Errorf("duplicate shard %q", shard.Name)
+		}
+		s.shards[shard.Name] = shard
+		s.names = append(s.names, shard.Name)
+	}
+	sort.Strings(s.names)
+	s.ring = NewRing(s.names, vnodes)
+	return s, nil
+}
+
+// Shards возвращает шарды в порядке имен
+func (s *ShardedRepository) Shards() []*Shard {
+	shards := make([]*Shard, 0, len(s.names))
+	for _, name := range s.names {
+		shards = append(shards, s.shards[name])
+	}
+	return shards
+}
+
+// Home возвращает домашний шард кошелька по кольцу
+func (s *ShardedRepository) Home(walletUUID string) *Shard {
+	return s.shards[s.ring.Locate(walletUUID)]
+}
+
+// Locate находит шард, на котором живет кошелек: домашний шард, затем указатели moved_to.
+// Если кошелька нет на домашнем шарде и нет указателя (например, после добавления шарда
+// до перебалансировки), он ищется на остальных шардах. Новый кошелек создается на домашнем шарде
+func (s *ShardedRepository) Locate(ctx context.Context, walletUUID string) (*Shard, error) {
+	home := s.Home(walletUUID)
+	shard := home
+
+	for range maxForwardHops {
+		found, movedTo, err := shard.Repo.WalletLocation(ctx, walletUUID)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", shard.Name, err)
+		}
+
+		switch {
+		case !found && shard == home:
+			return s.search(ctx, walletUUID, home)
+		case !found:
+			return nil, fmt.Errorf("wallet %s forwarded to shard %s but not found there", walletUUID, shard.Name)
+		case movedTo == "":
+			return shard, nil
+		}
+
+		next, ok := s.shards[movedTo]
+		if !ok {
+			return nil, fmt.Errorf("wallet %s moved to unknown shard %q", walletUUID, movedTo)
+		}
+		shard = next
+	}
+
+	return nil, db.ErrWalletMoving
+}
+
+// search ищет активный кошелек на всех шардах, кроме домашнего
+func (s *ShardedRepository) search(ctx context.Context, walletUUID string, home *Shard) (*Shard, error) {
+	for _, name := range s.names {
+		shard := s.shards[name]
+		if shard == home {
+			continue
+		}
+		found, movedTo, err := shard.Repo.WalletLocation(ctx, walletUUID)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", shard.Name, err)
+		}
+		if found && movedTo == "" {
+			logger.Log.WithContext(ctx).Warnf("Wallet %s found on shard %s instead of home shard %s", walletUUID, shard.Name, home.Name)
+			return shard, nil
+		}
+	}
+	return home, nil
+}
+
+// withWallet выполняет операцию на шарде кошелька. Если кошелек был перенесен, пока шла операция
+// (кошелек пропал с шарда или его строка мешает созданию нового), операция повторяется на новом шарде
+func (s *ShardedRepository) withWallet(ctx context.Context, walletUUID string, fn func(shard *Shard) error) error {
+	shard, err := s.Locate(ctx, walletUUID)
+	if err != nil {
+		return err
+	}
+
+	err = fn(shard)
+	if err == nil || !movedDuringOperation(err) {
+		return err
+	}
+
+	again, locateErr := s.Locate(ctx, walletUUID)
+	if locateErr != nil || again == shard {
+		return err
+	}
+	logger.Log.WithContext(ctx).Infof("Wallet %s moved from shard %s to %s during operation, retrying", walletUUID, shard.Name, again.Name)
+	return fn(again)
+}
+
+func movedDuringOperation(err error) bool {
+	var pqErr *pq.Error
+	return errors.Is(err, db.ErrWalletNotFound) ||
+		(errors.As(err, &pqErr) && pqErr.Code == "23505") // unique_violation
+}
+
+func (s *ShardedRepository) DepositMoney(ctx context.Context, walletUUID string, amount int64) error {
+	return s.withWallet(ctx, walletUUID, func(shard *Shard) error {
+		return shard.Repo.DepositMoney(ctx, walletUUID, amount)
+	})
+}
+
+func (s *ShardedRepository) WithdrawMoney(ctx context.Context, walletUUID string, amount int64) error {
+	return s.withWallet(ctx, walletUUID, func(shard *Shard) error {
+		return shard.Repo.WithdrawMoney(ctx, walletUUID, amount)
+	})
+}
+
+func (s *ShardedRepository) GetBalance(ctx context.Context, walletUUID string) (int64, error) {
+	var balance int64
+	err := s.withWallet(ctx, walletUUID, func(shard *Shard) (err error) {
+		balance, err = shard.Repo.GetBalance(ctx, walletUUID)
+		return err
+	})
+	return balance, err
+}
+
+func (s *ShardedRepository) GetBalanceAt(ctx context.Context, walletUUID string, asOf time.Time) (int64, error) {
+	var balance int64
+	err := s.withWallet(ctx, walletUUID, func(shard *Shard) (err error) {
+		balance, err = shard.Repo.GetBalanceAt(ctx, walletUUID, asOf)
+		return err
+	})
+	return balance, err
+}
+
+func (s *ShardedRepository) StreamStatement(ctx context.Context, walletUUID string, from, to time.Time,
+	opening func(balance int64) error, entry func(tx db.LedgerTransaction) error) error {
+	// Повтор после начала выписки продублировал бы строки, поэтому без withWallet
+	shard, err := s.Locate(ctx, walletUUID)
+	if err != nil {
+		return err
+	}
+	return shard.Repo.StreamStatement(ctx, walletUUID, from, to, opening, entry)
+}
+
+func (s *ShardedRepository) SetShardCount(ctx context.Context, walletUUID string, shardCount int) error {
+	return s.withWallet(ctx, walletUUID, func(shard *Shard) error {
+		return shard.Repo.SetShardCount(ctx, walletUUID, shardCount)
+	})
+}
+
+// CreateWallet создает кошелек на домашнем шарде, если его нет ни на одном шарде
+func (s *ShardedRepository) CreateWallet(ctx context.Context, walletUUID string) error {
+	shard, err := s.Locate(ctx, walletUUID)
+	if err != nil {
+		return err
+	}
+	return shard.Repo.CreateWallet(ctx, walletUUID)
+}
+
+func (s *ShardedRepository) FreezeWallet(ctx context.Context, walletUUID, reason string) error {
+	return s.withWallet(ctx, walletUUID, func(shard *Shard) error {
+		return shard.Repo.FreezeWallet(ctx, walletUUID, reason)
+	})
+}
+
+func (s *ShardedRepository) UnfreezeWallet(ctx context.Context, walletUUID string) error {
+	return s.withWallet(ctx, walletUUID, func(shard *Shard) error {
+		return shard.Repo.UnfreezeWallet(ctx, walletUUID)
+	})
+}
+
+func (s *ShardedRepository) GetWalletInfo(ctx context.Context, walletUUID string) (*db.WalletInfo, error) {
+	var info *db.WalletInfo
+	err := s.withWallet(ctx, walletUUID, func(shard *Shard) (err error) {
+		info, err = shard.Repo.GetWalletInfo(ctx, walletUUID)
+		return err
+	})
+	return info, err
+}
+
+// primary - первый по имени шард: на нем хранятся данные, не относящиеся к одному кошельку
+func (s *ShardedRepository) primary() *Shard {
+	return s.shards[s.names[0]]
+}
+
+// auditShard - шард журнала операторов: домашний шард кошелька, для действий без кошелька - первый по имени
+func (s *ShardedRepository) auditShard(rec *db.AuditRecord) *Shard {
+	if rec.WalletUUID != "" {
+		return s.Home(rec.WalletUUID)
+	}
+	return s.primary()
+}
+
+func (s *ShardedRepository) StartAudit(ctx context.Context, rec *db.AuditRecord) error {
+	return s.auditShard(rec).Repo.StartAudit(ctx, rec)
+}
+
+func (s *ShardedRepository) FinishAudit(ctx context.Context, rec *db.AuditRecord) error {
+	return s.auditShard(rec).Repo.FinishAudit(ctx, rec)
+}
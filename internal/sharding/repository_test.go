@@ -0,0 +1,71 @@
+package sharding
+
+import (
+	"context"
+	"testing"
+	"wallet-service/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Locate(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name string
+		// wallets - строки кошелька на шардах: шард -> moved_to
+		wallets map[string]string
+		want    string
+		err     error
+	}{
+		{name: "home shard", wallets: map[string]string{"a": ""}, want: "a"},
+		{name: "new wallet", wallets: map[string]string{}, want: "a"},
+		{name: "follows moved_to", wallets: map[string]string{"a": "b", "b": ""}, want: "b"},
+		{name: "follows a chain", wallets: map[string]string{"a": "b", "b": "c", "c": ""}, want: "c"},
+		{name: "searches without forwarding", wallets: map[string]string{"c": ""}, want: "c"},
+		{name: "skips moved copies when searching", wallets: map[string]string{"b": "c", "c": ""}, want: "c"},
+		// Копия на шарде назначения указывает на источник, пока перенос не активирован
+		{name: "move in progress", wallets: map[string]string{"a": "b", "b": "a"}, err: db.ErrWalletMoving},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sharded, stores := newTestSharded(t, "a", "b", "c")
+			walletUUID := walletOn(t, sharded, "a")
+			for shard, movedTo := range tt.wallets {
+				stores[shard].put(walletUUID, 0, movedTo)
+			}
+
+			shard, err := sharded.Locate(ctx, walletUUID)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, shard.Name)
+		})
+	}
+
+	t.Run("broken forwarding", func(t *testing.T) {
+		sharded, stores := newTestSharded(t, "a", "b")
+		walletUUID := walletOn(t, sharded, "a")
+
+		stores["a"].put(walletUUID, 0, "b")
+		_, err := sharded.Locate(ctx, walletUUID)
+		assert.ErrorContains(t, err, "not found there")
+
+		stores["a"].put(walletUUID, 0, "unknown")
+		_, err = sharded.Locate(ctx, walletUUID)
+		assert.ErrorContains(t, err, "unknown shard")
+	})
+
+	t.Run("operations follow the wallet", func(t *testing.T) {
+		sharded, stores := newTestSharded(t, "a", "b")
+		walletUUID := walletOn(t, sharded, "a")
+		stores["a"].put(walletUUID, 5, "b")
+		stores["b"].put(walletUUID, 50, "")
+
+		balance, err := sharded.GetBalance(ctx, walletUUID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(50), balance)
+	})
+}
@@ -0,0 +1,68 @@
+package sharding
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Ring - консистентное хеширование UUID кошельков на шарды. Каждый шард занимает vnodes точек
+// на кольце, кошелек принадлежит шарду первой точки по часовой стрелке от хеша его UUID.
+// При добавлении шарда к нему переходит примерно 1/N кошельков, остальные остаются на месте
+type Ring struct {
+	hashes []uint64
+	owners []string
+}
+
+func NewRing(shards []string, vnodes int) *Ring {
+	if vnodes < 1 {
+		vnodes = 1
+	}
+
+	type point struct {
+		hash  uint64
+		owner string
+	}
+	points := make([]point, 0, len(shards)*vnodes)
+	for _, shard := range shards {
+		for i := range vnodes {
+			points = append(points, point{hash: xxhash.Sum64String(shard + "#" + strconv.Itoa(i)), owner: shard})
+		}
+	}
+	slices.SortFunc(points, func(a, b point) int {
+		switch {
+		case a.hash < b.hash:
+			return -1
+		case a.hash > b.hash:
+			return 1
+		}
+		// Совпадение хешей разных шардов: порядок не должен зависеть от порядка в конфигурации
+		switch {
+		case a.owner < b.owner:
+			return -1
+		case a.owner > b.owner:
+			return 1
+		}
+		return 0
+	})
+
+	r := &Ring{hashes: make([]uint64, len(points)), owners: make([]string, len(points))}
+	for i, p := range points {
+		r.hashes[i], r.owners[i] = p.hash, p.owner
+	}
+	return r
+}
+
+// Locate возвращает домашний шард кошелька
+func (r *Ring) Locate(walletUUID string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := xxhash.Sum64String(walletUUID)
+	i, _ := slices.BinarySearch(r.hashes, h)
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[i]
+}
@@ -0,0 +1,51 @@
+package sharding
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RingLocate(t *testing.T) {
+	ring := NewRing([]string{"a", "b", "c"}, 128)
+	reordered := NewRing([]string{"c", "a", "b"}, 128)
+
+	counts := map[string]int{}
+	const wallets = 30000
+	for i := range wallets {
+		id := uuid.NewSHA1(uuid.NameSpaceOID, []byte(fmt.Sprint(i))).String()
+		shard := ring.Locate(id)
+		assert.Equal(t, shard, ring.Locate(id))
+		assert.Equal(t, shard, reordered.Locate(id))
+		counts[shard]++
+	}
+
+	for _, shard := range []string{"a", "b", "c"} {
+		assert.InDelta(t, wallets/3, counts[shard], wallets/3*0.2, "shard %s", shard)
+	}
+}
+
+func Test_RingAddShard(t *testing.T) {
+	before := NewRing([]string{"a", "b", "c"}, 128)
+	after := NewRing([]string{"a", "b", "c", "d"}, 128)
+
+	moved := 0
+	const wallets = 20000
+	for i := range wallets {
+		id := uuid.NewSHA1(uuid.NameSpaceOID, []byte(fmt.Sprint(i))).String()
+		from, to := before.Locate(id), after.Locate(id)
+		if from != to {
+			// Кошельки переезжают только на новый шард
+			assert.Equal(t, "d", to)
+			moved++
+		}
+	}
+	// Примерно 1/N кошельков, с учетом неравномерности кольца
+	assert.InDelta(t, wallets/4, moved, wallets/8)
+}
+
+func Test_RingEmpty(t *testing.T) {
+	assert.Equal(t, "", NewRing(nil, 128).Locate(uuid.NewString()))
+}
@@ -0,0 +1,48 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"wallet-service/internal/db"
+)
+
+// GetRiskStats собирает историю кошелька на шарде, где он живет, а число новых кошельков клиента -
+// по решениям проверки рисков, которые хранятся на первом по имени шарде
+func (s *ShardedRepository) GetRiskStats(ctx context.Context, walletUUID, client string, windows db.RiskWindows) (*db.RiskStats, error) {
+	shard, err := s.Locate(ctx, walletUUID)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := shard.Repo.GetRiskStats(ctx, walletUUID, client, windows)
+	if err != nil {
+		return nil, fmt.Errorf("shard %s: %w", shard.Name, err)
+	}
+
+	primary := s.primary()
+	if shard == primary {
+		return stats, nil
+	}
+	clientStats, err := primary.Repo.GetRiskStats(ctx, walletUUID, client, windows)
+	if err != nil {
+		return nil, fmt.Errorf("shard %s: %w", primary.Name, err)
+	}
+	stats.ClientNewWallets = clientStats.ClientNewWallets
+	return stats, nil
+}
+
+// RecordRiskDecision сохраняет решение и операцию на ручной проверке на первом по имени шарде
+func (s *ShardedRepository) RecordRiskDecision(ctx context.Context, d *db.RiskDecision) error {
+	return s.primary().Repo.RecordRiskDecision(ctx, d)
+}
+
+func (s *ShardedRepository) ListPendingOperations(ctx context.Context, limit int) ([]db.PendingOperation, error) {
+	return s.primary().Repo.ListPendingOperations(ctx, limit)
+}
+
+func (s *ShardedRepository) DecidePendingOperation(ctx context.Context, id, status, decidedBy, reason string) (*db.PendingOperation, error) {
+	return s.primary().Repo.DecidePendingOperation(ctx, id, status, decidedBy, reason)
+}
+
+func (s *ShardedRepository) FailPendingOperation(ctx context.Context, id, reason string) error {
+	return s.primary().Repo.FailPendingOperation(ctx, id, reason)
+}
@@ -0,0 +1,58 @@
+package sharding
+
+import (
+	"context"
+	"testing"
+	"wallet-service/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetRiskStats(t *testing.T) {
+	ctx := context.Background()
+	windows := db.RiskWindows{}
+
+	for _, tt := range []struct {
+		name string
+		// wallets - строки кошелька на шардах: шард -> moved_to
+		wallets map[string]string
+		// history - шард, на котором у кошелька есть транзакции
+		history string
+	}{
+		{name: "wallet on the primary shard", wallets: map[string]string{"a": ""}, history: "a"},
+		{name: "wallet on another shard", wallets: map[string]string{"b": ""}, history: "b"},
+		{name: "moved wallet", wallets: map[string]string{"a": "b", "b": ""}, history: "b"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sharded, stores := newTestSharded(t, "a", "b")
+			walletUUID := walletOn(t, sharded, "a")
+			for shard, movedTo := range tt.wallets {
+				stores[shard].put(walletUUID, 0, movedTo)
+			}
+			stores[tt.history].txCounts[walletUUID] = 5
+			stores["a"].clientNewWallets = 3
+
+			stats, err := sharded.GetRiskStats(ctx, walletUUID, "client-1", windows)
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), stats.TxCount, "history comes from the wallet's shard")
+			assert.False(t, stats.WalletNew)
+			assert.Equal(t, int64(3), stats.ClientNewWallets, "client wallets come from decisions on the primary shard")
+		})
+	}
+
+	t.Run("new wallet", func(t *testing.T) {
+		sharded, _ := newTestSharded(t, "a", "b")
+		stats, err := sharded.GetRiskStats(ctx, walletOn(t, sharded, "b"), "client-1", windows)
+		require.NoError(t, err)
+		assert.True(t, stats.WalletNew)
+	})
+
+	t.Run("decisions are stored on the primary shard", func(t *testing.T) {
+		sharded, stores := newTestSharded(t, "a", "b")
+		walletUUID := walletOn(t, sharded, "b")
+		require.NoError(t, sharded.RecordRiskDecision(ctx, &db.RiskDecision{WalletUUID: walletUUID}))
+		assert.Len(t, stores["a"].decisions, 1)
+		assert.Empty(t, stores["b"].decisions)
+	})
+}
@@ -0,0 +1,165 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"time"
+	"wallet-service/internal/db"
+	"wallet-service/internal/logger"
+)
+
+// Transfer переводит сумму между кошельками. Если оба кошелька на одном шарде, перевод выполняется
+// одной транзакцией. Иначе - сага, состояние которой хранится на шарде отправителя:
+//  1. CreateTransfer (PENDING);
+//  2. DebitTransfer - списание и переход в DEBITED одной транзакцией;
+//  3. CreditTransfer на шарде получателя - зачисление не больше одного раза;
+//  4. CompleteTransfer (COMPLETED).
+//
+// Если зачисление не удалось, сага компенсируется: на шарде получателя ставится запрет зачисления,
+// после чего сумма возвращается отправителю (COMPENSATED). Если запрет поставить не удалось,
+// перевод остается в DEBITED и завершается фоновым восстановлением (RecoverTransfers)
+func (s *ShardedRepository) Transfer(ctx context.Context, fromWalletUUID, toWalletUUID string, amount int64) (*db.Transfer, error) {
+	if fromWalletUUID == toWalletUUID {
+		return nil, db.ErrSameWallet
+	}
+
+	src, err := s.Locate(ctx, fromWalletUUID)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := s.Locate(ctx, toWalletUUID)
+	if err != nil {
+		return nil, err
+	}
+	if src == dst {
+		return src.Repo.Transfer(ctx, fromWalletUUID, toWalletUUID, amount)
+	}
+
+	log := logger.Log.WithContext(ctx)
+	t := &db.Transfer{FromWallet: fromWalletUUID, ToWallet: toWalletUUID, Amount: amount}
+
+	if err := src.Repo.CreateTransfer(ctx, t); err != nil {
+		return nil, err
+	}
+	log.Infof("Transfer %s started: %d from wallet %s (shard %s) to wallet %s (shard %s)",
+		t.ID, logger.Amount(amount), fromWalletUUID, src.Name, toWalletUUID, dst.Name)
+
+	if err := src.Repo.DebitTransfer(ctx, t); err != nil {
+		return t, err
+	}
+
+	s.finishTransfer(ctx, src, t)
+	return t, nil
+}
+
+// finishTransfer выполняет шаги после списания: зачисление и завершение или компенсацию.
+// Ошибки только логируются: незавершенный перевод остается в DEBITED до восстановления
+func (s *ShardedRepository) finishTransfer(ctx context.Context, src *Shard, t *db.Transfer) {
+	log := logger.Log.WithContext(ctx)
+
+	dst, err := s.Locate(ctx, t.ToWallet)
+	if err != nil {
+		log.Warnf("Transfer %s: failed to locate recipient wallet %s, will retry: %v", t.ID, t.ToWallet, err)
+		return
+	}
+
+	creditErr := dst.Repo.CreditTransfer(ctx, t)
+	if creditErr == nil {
+		if err := src.Repo.CompleteTransfer(ctx, t); err != nil {
+			log.Warnf("Transfer %s credited but not marked completed, will retry: %v", t.ID, err)
+			return
+		}
+		log.Infof("Transfer %s completed", t.ID)
+		return
+	}
+
+	log.Warnf("Transfer %s: credit to wallet %s failed, compensating: %v", t.ID, t.ToWallet, creditErr)
+
+	// Запрет зачисления: после него зачисление уже не пройдет, даже если оно еще выполняется
+	credited, err := dst.Repo.AbortTransferCredit(ctx, t.ID)
+	if err != nil {
+		log.Warnf("Transfer %s: failed to abort credit, will retry: %v", t.ID, err)
+		return
+	}
+	if credited {
+		// Зачисление все-таки прошло - компенсировать нечего
+		if err := src.Repo.CompleteTransfer(ctx, t); err != nil {
+			log.Warnf("Transfer %s credited but not marked completed, will retry: %v", t.ID, err)
+		}
+		return
+	}
+
+	if err := src.Repo.CompensateTransfer(ctx, t, creditErr.Error()); err != nil {
+		log.Warnf("Transfer %s: compensation failed, will retry: %v", t.ID, err)
+		return
+	}
+	log.Infof("Transfer %s compensated", t.ID)
+}
+
+// GetTransfer ищет перевод на всех шардах
+func (s *ShardedRepository) GetTransfer(ctx context.Context, id string) (*db.Transfer, error) {
+	for _, name := range s.names {
+		t, err := s.shards[name].Repo.GetTransfer(ctx, id)
+		if errors.Is(err, db.ErrTransferNotFound) {
+			continue
+		}
+		return t, err
+	}
+	return nil, db.ErrTransferNotFound
+}
+
+// RecoverTransfers доводит до конца саги, не менявшиеся дольше olderThan: переводы в PENDING
+// не списывались (списание атомарно с переходом в DEBITED) и завершаются как FAILED,
+// переводы в DEBITED продолжаются с зачисления
+func (s *ShardedRepository) RecoverTransfers(ctx context.Context, olderThan time.Duration) error {
+	log := logger.Log.WithContext(ctx)
+
+	for _, name := range s.names {
+		shard := s.shards[name]
+		transfers, err := shard.Repo.ListUnfinishedTransfers(ctx, time.Now().Add(-olderThan), 100)
+		if err != nil {
+			return err
+		}
+
+		for i := range transfers {
+			t := &transfers[i]
+			log.Infof("Recovering transfer %s in state %s on shard %s", t.ID, t.State, shard.Name)
+
+			// Шаги саги выполняются от имени арендатора перевода
+			tenantCtx := db.WithTenant(ctx, t.TenantID)
+			switch t.State {
+			case db.TransferPending:
+				if err := shard.Repo.FailTransfer(tenantCtx, t, "abandoned before debit"); err != nil {
+					log.Warnf("Failed to fail abandoned transfer %s: %v", t.ID, err)
+				}
+			case db.TransferDebited:
+				s.finishTransfer(tenantCtx, shard, t)
+			}
+		}
+	}
+	return nil
+}
+
+// StartTransferRecovery запускает RecoverTransfers каждые interval. Возвращает функцию остановки
+func (s *ShardedRepository) StartTransferRecovery(interval, olderThan time.Duration) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	logger.Log.Infof("Transfer recovery scheduled every %s", interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.RecoverTransfers(context.Background(), olderThan); err != nil {
+					logger.Log.Errorf("Transfer recovery failed: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
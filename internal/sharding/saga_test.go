@@ -0,0 +1,132 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"wallet-service/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TransferSaga(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*ShardedRepository, *memStore, *memStore, string, string) {
+		sharded, stores := newTestSharded(t, "a", "b")
+		from, to := walletOn(t, sharded, "a"), walletOn(t, sharded, "b")
+		stores["a"].put(from, 100, "")
+		stores["b"].put(to, 10, "")
+		return sharded, stores["a"], stores["b"], from, to
+	}
+
+	t.Run("completes", func(t *testing.T) {
+		sharded, src, dst, from, to := setup(t)
+
+		transfer, err := sharded.Transfer(ctx, from, to, 30)
+		require.NoError(t, err)
+		assert.Equal(t, db.TransferCompleted, transfer.State)
+		assert.Equal(t, db.TransferCompleted, src.transfer(transfer.ID).State)
+		assert.Equal(t, int64(70), src.balance(from))
+		assert.Equal(t, int64(40), dst.balance(to))
+
+		// Запись перевода хранится на шарде отправителя
+		found, err := sharded.GetTransfer(ctx, transfer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, db.TransferCompleted, found.State)
+		_, err = sharded.GetTransfer(ctx, "missing")
+		assert.ErrorIs(t, err, db.ErrTransferNotFound)
+	})
+
+	t.Run("fails without debit", func(t *testing.T) {
+		sharded, src, dst, from, to := setup(t)
+
+		transfer, err := sharded.Transfer(ctx, from, to, 500)
+		assert.ErrorIs(t, err, db.ErrInsufficientFunds)
+		assert.Equal(t, db.TransferFailed, src.transfer(transfer.ID).State)
+		assert.Equal(t, int64(100), src.balance(from))
+		assert.Equal(t, int64(10), dst.balance(to))
+	})
+
+	t.Run("compensates when credit fails", func(t *testing.T) {
+		sharded, src, dst, from, to := setup(t)
+		dst.creditErr = db.ErrWalletFrozen
+
+		transfer, err := sharded.Transfer(ctx, from, to, 30)
+		require.NoError(t, err)
+		assert.Equal(t, db.TransferCompensated, transfer.State)
+		assert.Equal(t, db.ErrWalletFrozen.Error(), src.transfer(transfer.ID).Error)
+		assert.Equal(t, int64(100), src.balance(from))
+		assert.Equal(t, int64(10), dst.balance(to))
+
+		// Запрет зачисления: повтор зачисления после компенсации не проходит
+		dst.creditErr = nil
+		assert.ErrorIs(t, dst.CreditTransfer(ctx, transfer), db.ErrTransferAborted)
+	})
+
+	t.Run("completes when failed credit went through", func(t *testing.T) {
+		sharded, src, dst, from, to := setup(t)
+		dst.creditErr, dst.creditApplied = errCreditTimeout, true
+
+		transfer, err := sharded.Transfer(ctx, from, to, 30)
+		require.NoError(t, err)
+		assert.Equal(t, db.TransferCompleted, transfer.State)
+		assert.Equal(t, int64(70), src.balance(from))
+		assert.Equal(t, int64(40), dst.balance(to))
+	})
+
+	t.Run("stays debited when abort fails", func(t *testing.T) {
+		sharded, src, dst, from, to := setup(t)
+		dst.creditErr, dst.abortErr = errCreditTimeout, errors.New("connection refused")
+
+		transfer, err := sharded.Transfer(ctx, from, to, 30)
+		require.NoError(t, err)
+		assert.Equal(t, db.TransferDebited, src.transfer(transfer.ID).State)
+		assert.Equal(t, int64(70), src.balance(from))
+		assert.Equal(t, int64(10), dst.balance(to))
+	})
+}
+
+func Test_RecoverTransfers(t *testing.T) {
+	ctx := context.Background()
+	sharded, stores := newTestSharded(t, "a", "b")
+	src, dst := stores["a"], stores["b"]
+	from, to := walletOn(t, sharded, "a"), walletOn(t, sharded, "b")
+	src.put(from, 100, "")
+	dst.put(to, 0, "")
+
+	// Сага, прерванная до списания
+	pending := &db.Transfer{FromWallet: from, ToWallet: to, Amount: 10}
+	require.NoError(t, src.CreateTransfer(db.WithTenant(ctx, "acme"), pending))
+
+	// Сага, прерванная после списания: зачисление не прошло, запрет зачисления тоже
+	dst.creditErr, dst.abortErr = errCreditTimeout, errors.New("connection refused")
+	debited, err := sharded.Transfer(db.WithTenant(ctx, "acme"), from, to, 30)
+	require.NoError(t, err)
+	require.Equal(t, db.TransferDebited, src.transfer(debited.ID).State)
+
+	t.Run("recent transfers are left alone", func(t *testing.T) {
+		require.NoError(t, sharded.RecoverTransfers(ctx, time.Hour))
+		assert.Equal(t, db.TransferPending, src.transfer(pending.ID).State)
+		assert.Equal(t, db.TransferDebited, src.transfer(debited.ID).State)
+	})
+
+	t.Run("finishes abandoned transfers", func(t *testing.T) {
+		dst.creditErr, dst.abortErr, dst.creditTenants = nil, nil, nil
+		require.NoError(t, sharded.RecoverTransfers(ctx, -time.Minute))
+
+		assert.Equal(t, db.TransferFailed, src.transfer(pending.ID).State)
+		assert.Equal(t, db.TransferCompleted, src.transfer(debited.ID).State)
+		assert.Equal(t, int64(70), src.balance(from))
+		assert.Equal(t, int64(30), dst.balance(to))
+		assert.Equal(t, []string{"acme"}, dst.creditTenants, "saga steps run as the transfer tenant")
+	})
+
+	t.Run("nothing left to recover", func(t *testing.T) {
+		dst.creditTenants = nil
+		require.NoError(t, sharded.RecoverTransfers(ctx, -time.Minute))
+		assert.Empty(t, dst.creditTenants)
+	})
+}
@@ -0,0 +1,40 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+	"wallet-service/internal/db"
+)
+
+// ListTransactionsBetween объединяет журналы всех шардов по времени транзакции.
+// ID транзакций уникальны только в пределах шарда, одинаковые ID разных шардов
+// сверка по референсу считает неоднозначными
+func (s *ShardedRepository) ListTransactionsBetween(ctx context.Context, from, to time.Time) ([]db.LedgerTransaction, error) {
+	var txs []db.LedgerTransaction
+	for _, name := range s.names {
+		shardTxs, err := s.shards[name].Repo.ListTransactionsBetween(ctx, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", name, err)
+		}
+		txs = append(txs, shardTxs...)
+	}
+	slices.SortStableFunc(txs, func(a, b db.LedgerTransaction) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return txs, nil
+}
+
+// SaveSettlementRun сохраняет прогон сверки на первом по имени шарде
+func (s *ShardedRepository) SaveSettlementRun(ctx context.Context, run *db.SettlementRun) error {
+	return s.primary().Repo.SaveSettlementRun(ctx, run)
+}
+
+func (s *ShardedRepository) GetSettlementRun(ctx context.Context, id int64) (*db.SettlementRun, error) {
+	return s.primary().Repo.GetSettlementRun(ctx, id)
+}
+
+func (s *ShardedRepository) ListSettlementRuns(ctx context.Context, limit int) ([]db.SettlementRun, error) {
+	return s.primary().Repo.ListSettlementRuns(ctx, limit)
+}
@@ -0,0 +1,236 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"wallet-service/internal/db"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore - шард в памяти: кошельки с указателями moved_to и шаги саги перевода.
+// Остальные методы Store в тестах не вызываются
+type memStore struct {
+	Store
+
+	mu        sync.Mutex
+	wallets   map[string]*memWallet
+	transfers map[string]*db.Transfer
+	// credits - отметки о зачислении переводов: CREDITED или ABORTED
+	credits map[string]string
+
+	// creditErr - ошибка зачисления; при creditApplied зачисление проходит, но вызывающий получает ошибку
+	creditErr     error
+	creditApplied bool
+	abortErr      error
+	// creditTenants - арендаторы, от имени которых выполнялись зачисления
+	creditTenants []string
+
+	// txCounts - число транзакций кошельков для проверки рисков
+	txCounts map[string]int64
+	// clientNewWallets - новые кошельки клиента по решениям, записанным на этом шарде
+	clientNewWallets int64
+	decisions        []db.RiskDecision
+}
+
+type memWallet struct {
+	balance int64
+	movedTo string
+}
+
+func newMemStore() *memStore {
+	return &memStore{wallets: map[string]*memWallet{}, transfers: map[string]*db.Transfer{}, credits: map[string]string{},
+		txCounts: map[string]int64{}}
+}
+
+// newTestSharded - шардированный репозиторий из шардов в памяти с заданными именами
+func newTestSharded(t *testing.T, names ...string) (*ShardedRepository, map[string]*memStore) {
+	stores := map[string]*memStore{}
+	var shards []*Shard
+	for _, name := range names {
+		stores[name] = newMemStore()
+		shards = append(shards, &Shard{Name: name, Repo: stores[name]})
+	}
+	sharded, err := NewShardedRepository(shards, 16)
+	require.NoError(t, err)
+	return sharded, stores
+}
+
+func (m *memStore) put(walletUUID string, balance int64, movedTo string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wallets[walletUUID] = &memWallet{balance: balance, movedTo: movedTo}
+}
+
+func (m *memStore) balance(walletUUID string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if w := m.wallets[walletUUID]; w != nil {
+		return w.balance
+	}
+	return 0
+}
+
+func (m *memStore) transfer(id string) db.Transfer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.transfers[id]
+}
+
+func (m *memStore) WalletLocation(ctx context.Context, walletUUID string) (bool, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[walletUUID]
+	if w == nil {
+		return false, "", nil
+	}
+	return true, w.movedTo, nil
+}
+
+func (m *memStore) GetBalance(ctx context.Context, walletUUID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[walletUUID]
+	if w == nil || w.movedTo != "" {
+		return 0, db.ErrWalletNotFound
+	}
+	return w.balance, nil
+}
+
+func (m *memStore) CreateTransfer(ctx context.Context, t *db.Transfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t.ID, t.TenantID, t.State = uuid.NewString(), db.TenantFromContext(ctx), db.TransferPending
+	t.CreatedAt, t.UpdatedAt = time.Now(), time.Now()
+	stored := *t
+	m.transfers[t.ID] = &stored
+	return nil
+}
+
+func (m *memStore) setState(t *db.Transfer, from, to, reason string) error {
+	stored := m.transfers[t.ID]
+	if stored == nil || stored.State != from {
+		return db.ErrTransferNotFound
+	}
+	stored.State, stored.Error, stored.UpdatedAt = to, reason, time.Now()
+	t.State, t.Error = to, reason
+	return nil
+}
+
+func (m *memStore) DebitTransfer(ctx context.Context, t *db.Transfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[t.FromWallet]
+	if w == nil || w.balance < t.Amount {
+		m.setState(t, db.TransferPending, db.TransferFailed, db.ErrInsufficientFunds.Error())
+		return db.ErrInsufficientFunds
+	}
+	w.balance -= t.Amount
+	return m.setState(t, db.TransferPending, db.TransferDebited, "")
+}
+
+func (m *memStore) CreditTransfer(ctx context.Context, t *db.Transfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.creditTenants = append(m.creditTenants, db.TenantFromContext(ctx))
+	if m.creditErr != nil && !m.creditApplied {
+		return m.creditErr
+	}
+	switch m.credits[t.ID] {
+	case "ABORTED":
+		return db.ErrTransferAborted
+	case "":
+		m.credits[t.ID] = "CREDITED"
+		m.wallets[t.ToWallet].balance += t.Amount
+	}
+	return m.creditErr
+}
+
+func (m *memStore) AbortTransferCredit(ctx context.Context, transferID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.abortErr != nil {
+		return false, m.abortErr
+	}
+	if m.credits[transferID] == "" {
+		m.credits[transferID] = "ABORTED"
+	}
+	return m.credits[transferID] == "CREDITED", nil
+}
+
+func (m *memStore) CompleteTransfer(ctx context.Context, t *db.Transfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setState(t, db.TransferDebited, db.TransferCompleted, "")
+}
+
+func (m *memStore) CompensateTransfer(ctx context.Context, t *db.Transfer, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.setState(t, db.TransferDebited, db.TransferCompensated, reason); err != nil {
+		return err
+	}
+	m.wallets[t.FromWallet].balance += t.Amount
+	return nil
+}
+
+func (m *memStore) FailTransfer(ctx context.Context, t *db.Transfer, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setState(t, db.TransferPending, db.TransferFailed, reason)
+}
+
+func (m *memStore) GetTransfer(ctx context.Context, id string) (*db.Transfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.transfers[id]
+	if t == nil {
+		return nil, db.ErrTransferNotFound
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (m *memStore) ListUnfinishedTransfers(ctx context.Context, before time.Time, limit int) ([]db.Transfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var transfers []db.Transfer
+	for _, t := range m.transfers {
+		if (t.State == db.TransferPending || t.State == db.TransferDebited) && t.UpdatedAt.Before(before) && len(transfers) < limit {
+			transfers = append(transfers, *t)
+		}
+	}
+	return transfers, nil
+}
+
+func (m *memStore) GetRiskStats(ctx context.Context, walletUUID, client string, windows db.RiskWindows) (*db.RiskStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := m.txCounts[walletUUID]
+	return &db.RiskStats{TxCount: count, WalletNew: count == 0, ClientNewWallets: m.clientNewWallets}, nil
+}
+
+func (m *memStore) RecordRiskDecision(ctx context.Context, d *db.RiskDecision) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.decisions = append(m.decisions, *d)
+	return nil
+}
+
+// walletOn подбирает UUID кошелька, домашний шард которого - home
+func walletOn(t *testing.T, sharded *ShardedRepository, home string) string {
+	for range 1000 {
+		walletUUID := uuid.NewString()
+		if sharded.Home(walletUUID).Name == home {
+			return walletUUID
+		}
+	}
+	t.Fatalf("no wallet with home shard %s", home)
+	return ""
+}
+
+var errCreditTimeout = errors.New("credit timed out")
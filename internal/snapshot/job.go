@@ -0,0 +1,39 @@
+package snapshot
+
+import (
+	"context"
+	"time"
+	"wallet-service/internal/logger"
+)
+
+// Store - хранилище снимков балансов, реализуется db.PostgresRepository
+type Store interface {
+	CreateBalanceSnapshots(ctx context.Context) (int64, error)
+}
+
+// StartJob раз в interval создает снимки балансов по завершенным транзакциям.
+// Снимки ограничивают объем журнала, который нужно просуммировать для баланса на любой момент времени.
+// Задание можно запускать на всех репликах: прогон выполняет та, что первой взяла блокировку.
+// Возвращает функцию остановки.
+func StartJob(store Store, interval time.Duration) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	logger.Log.Infof("Balance snapshots scheduled every %s", interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := store.CreateBalanceSnapshots(context.Background()); err != nil {
+					logger.Log.Errorf("Balance snapshot job failed: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -0,0 +1,48 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvWriter: начальный и конечный баланс выводятся отдельными строками OPENING_BALANCE и CLOSING_BALANCE
+type csvWriter struct {
+	w  *csv.Writer
+	to time.Time
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (cw *csvWriter) Begin(h Header) error {
+	cw.to = h.To
+	if err := cw.w.Write([]string{"transaction_id", "date", "operation_type", "amount", "balance"}); err != nil {
+		return err
+	}
+	return cw.w.Write([]string{"", h.From.UTC().Format(time.RFC3339), "OPENING_BALANCE", "", strconv.FormatInt(h.OpeningBalance, 10)})
+}
+
+func (cw *csvWriter) Entry(e Entry) error {
+	return cw.w.Write([]string{
+		strconv.FormatInt(e.TransactionID, 10),
+		e.Date.UTC().Format(time.RFC3339),
+		e.OperationType,
+		strconv.FormatInt(e.Amount, 10),
+		strconv.FormatInt(e.Balance, 10),
+	})
+}
+
+func (cw *csvWriter) End(closingBalance int64) error {
+	if err := cw.w.Write([]string{"", cw.to.UTC().Format(time.RFC3339), "CLOSING_BALANCE", "", strconv.FormatInt(closingBalance, 10)}); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
@@ -0,0 +1,60 @@
+package statement
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+type jsonEntry struct {
+	TransactionID int64     `json:"transactionId"`
+	Date          time.Time `json:"date"`
+	OperationType string    `json:"operationType"`
+	Amount        int64     `json:"amount"`
+	Balance       int64     `json:"balance"`
+}
+
+// jsonWriter пишет один JSON-объект по частям, не собирая массив транзакций в памяти
+type jsonWriter struct {
+	w     io.Writer
+	first bool
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{w: w, first: true}
+}
+
+func (jw *jsonWriter) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (jw *jsonWriter) Begin(h Header) error {
+	walletID, err := json.Marshal(h.WalletUUID)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(jw.w, `{"walletId":%s,"from":%q,"to":%q,"openingBalance":%d,"transactions":[`,
+		walletID, h.From.UTC().Format(time.RFC3339), h.To.UTC().Format(time.RFC3339), h.OpeningBalance)
+	return err
+}
+
+func (jw *jsonWriter) Entry(e Entry) error {
+	data, err := json.Marshal(jsonEntry(e))
+	if err != nil {
+		return err
+	}
+	if !jw.first {
+		if _, err := io.WriteString(jw.w, ","); err != nil {
+			return err
+		}
+	}
+	jw.first = false
+	_, err = jw.w.Write(data)
+	return err
+}
+
+func (jw *jsonWriter) End(closingBalance int64) error {
+	_, err := fmt.Fprintf(jw.w, `],"closingBalance":%d}`, closingBalance)
+	return err
+}
@@ -0,0 +1,194 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Простейший генератор PDF: страницы A4, моноширинный шрифт Courier из стандартного набора PDF.
+// Каждая страница выводится сразу после заполнения, в памяти держится только текущая страница
+// и смещения объектов для таблицы xref.
+const (
+	pdfPageWidth    = 595
+	pdfPageHeight   = 842
+	pdfMarginLeft   = 40
+	pdfMarginTop    = 800
+	pdfFontSize     = 9
+	pdfLineHeight   = 12
+	pdfLinesPerPage = 62
+
+	// Номера объектов, известные заранее. Страницы нумеруются начиная с pdfFirstPageObject
+	pdfCatalogObject   = 1
+	pdfPagesObject     = 2
+	pdfFontObject      = 3
+	pdfFirstPageObject = 4
+)
+
+type pdfWriter struct {
+	w       *countingWriter
+	offsets map[int]int64
+	nextObj int
+	pages   []int
+	lines   []string
+	to      time.Time
+}
+
+func newPDFWriter(w io.Writer) *pdfWriter {
+	return &pdfWriter{
+		w:       &countingWriter{w: w},
+		offsets: map[int]int64{},
+		nextObj: pdfFirstPageObject,
+	}
+}
+
+func (pw *pdfWriter) ContentType() string {
+	return "application/pdf"
+}
+
+func (pw *pdfWriter) Begin(h Header) error {
+	pw.to = h.To
+
+	if _, err := io.WriteString(pw.w, "%PDF-1.4\n"); err != nil {
+		return err
+	}
+	if err := pw.writeObject(pdfCatalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObject)); err != nil {
+		return err
+	}
+	if err := pw.writeObject(pdfFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>"); err != nil {
+		return err
+	}
+
+	pw.lines = append(pw.lines,
+		"Wallet statement",
+		"Wallet:          "+h.WalletUUID,
+		fmt.Sprintf("Period:          %s - %s", h.From.UTC().Format(time.RFC3339), h.To.UTC().Format(time.RFC3339)),
+		fmt.Sprintf("Opening balance: %d", h.OpeningBalance),
+		"",
+	)
+	return pw.addLine(pdfTableHeader())
+}
+
+func (pw *pdfWriter) Entry(e Entry) error {
+	return pw.addLine(fmt.Sprintf("%-12d %-20s %-10s %15d %15d",
+		e.TransactionID, e.Date.UTC().Format("2006-01-02 15:04:05"), e.OperationType, e.Amount, e.Balance))
+}
+
+func (pw *pdfWriter) End(closingBalance int64) error {
+	if err := pw.addLine(""); err != nil {
+		return err
+	}
+	if err := pw.addLine(fmt.Sprintf("Closing balance: %d (as of %s)", closingBalance, pw.to.UTC().Format(time.RFC3339))); err != nil {
+		return err
+	}
+	if err := pw.flushPage(); err != nil {
+		return err
+	}
+
+	kids := make([]string, len(pw.pages))
+	for i, obj := range pw.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", obj)
+	}
+	pages := fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pw.pages))
+	if err := pw.writeObject(pdfPagesObject, pages); err != nil {
+		return err
+	}
+
+	return pw.writeTrailer()
+}
+
+func pdfTableHeader() string {
+	return fmt.Sprintf("%-12s %-20s %-10s %15s %15s", "ID", "Date", "Type", "Amount", "Balance")
+}
+
+func (pw *pdfWriter) addLine(line string) error {
+	if len(pw.lines) == pdfLinesPerPage {
+		if err := pw.flushPage(); err != nil {
+			return err
+		}
+		pw.lines = append(pw.lines, pdfTableHeader())
+	}
+	pw.lines = append(pw.lines, line)
+	return nil
+}
+
+// flushPage записывает поток содержимого текущей страницы и сам объект страницы
+func (pw *pdfWriter) flushPage() error {
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLineHeight, pdfMarginLeft, pdfMarginTop)
+	for _, line := range pw.lines {
+		fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+	}
+	content.WriteString("ET")
+
+	contentObj := pw.nextObj
+	pageObj := pw.nextObj + 1
+	pw.nextObj += 2
+
+	stream := fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String())
+	if err := pw.writeObject(contentObj, stream); err != nil {
+		return err
+	}
+
+	page := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesObject, pdfPageWidth, pdfPageHeight, pdfFontObject, contentObj)
+	if err := pw.writeObject(pageObj, page); err != nil {
+		return err
+	}
+
+	pw.pages = append(pw.pages, pageObj)
+	pw.lines = pw.lines[:0]
+	return nil
+}
+
+func (pw *pdfWriter) writeObject(num int, body string) error {
+	pw.offsets[num] = pw.w.n
+	_, err := fmt.Fprintf(pw.w, "%d 0 obj\n%s\nendobj\n", num, body)
+	return err
+}
+
+func (pw *pdfWriter) writeTrailer() error {
+	xref := pw.w.n
+	size := pw.nextObj
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", size)
+	for num := 1; num < size; num++ {
+		fmt.Fprintf(&b, "%010d 00000 n \n", pw.offsets[num])
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, pdfCatalogObject, xref)
+
+	_, err := io.WriteString(pw.w, b.String())
+	return err
+}
+
+// pdfEscape экранирует символы строкового литерала PDF. Стандартные шрифты не содержат
+// кириллицы, поэтому символы вне ASCII заменяются на '?'
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
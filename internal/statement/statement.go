@@ -0,0 +1,92 @@
+package statement
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+	"wallet-service/internal/db"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatPDF  = "pdf"
+)
+
+// Header - шапка выписки
+type Header struct {
+	WalletUUID     string
+	From           time.Time
+	To             time.Time
+	OpeningBalance int64
+}
+
+// Entry - строка выписки с балансом после проведения транзакции
+type Entry struct {
+	TransactionID int64
+	Date          time.Time
+	OperationType string
+	Amount        int64
+	Balance       int64
+}
+
+// Source - источник истории кошелька, реализуется db.PostgresRepository
+type Source interface {
+	StreamStatement(ctx context.Context, walletUUID string, from, to time.Time,
+		opening func(balance int64) error, entry func(tx db.LedgerTransaction) error) error
+}
+
+// Writer выводит выписку по мере чтения транзакций: Begin, Entry для каждой транзакции, End
+type Writer interface {
+	ContentType() string
+	Begin(h Header) error
+	Entry(e Entry) error
+	End(closingBalance int64) error
+}
+
+// NewWriter возвращает Writer для формата json, csv или pdf
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatJSON:
+		return newJSONWriter(w), nil
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatPDF:
+		return newPDFWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported statement format %q", format)
+	}
+}
+
+// Generate строит выписку по кошельку за период [from, to), считая текущий баланс после каждой транзакции
+func Generate(ctx context.Context, repo Source, walletUUID string, from, to time.Time, w Writer) error {
+	var balance int64
+
+	err := repo.StreamStatement(ctx, walletUUID, from, to,
+		func(opening int64) error {
+			balance = opening
+			return w.Begin(Header{WalletUUID: walletUUID, From: from, To: to, OpeningBalance: opening})
+		},
+		func(tx db.LedgerTransaction) error {
+			switch tx.OperationType {
+			case "DEPOSIT":
+				balance += tx.Amount
+			case "WITHDRAW":
+				balance -= tx.Amount
+			}
+			return w.Entry(Entry{
+				TransactionID: tx.ID,
+				Date:          tx.CreatedAt,
+				OperationType: tx.OperationType,
+				Amount:        tx.Amount,
+				Balance:       balance,
+			})
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return w.End(balance)
+}
@@ -0,0 +1,74 @@
+package statement
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"wallet-service/internal/db"
+)
+
+type fakeRepo struct {
+	opening int64
+	txs     []db.LedgerTransaction
+}
+
+func (f *fakeRepo) StreamStatement(ctx context.Context, walletUUID string, from, to time.Time,
+	opening func(balance int64) error, entry func(tx db.LedgerTransaction) error) error {
+	if err := opening(f.opening); err != nil {
+		return err
+	}
+	for _, tx := range f.txs {
+		if err := entry(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Test_Generate(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	repo := &fakeRepo{opening: 1000, txs: []db.LedgerTransaction{
+		{ID: 7, OperationType: "DEPOSIT", Amount: 500, CreatedAt: from.Add(time.Hour)},
+		{ID: 8, OperationType: "WITHDRAW", Amount: 300, CreatedAt: from.Add(2 * time.Hour)},
+	}}
+
+	var jsonOut bytes.Buffer
+	w, err := NewWriter(FormatJSON, &jsonOut)
+	assert.NoError(t, err)
+	assert.NoError(t, Generate(context.Background(), repo, "wallet", from, to, w))
+	assert.JSONEq(t, `{
+		"walletId": "wallet",
+		"from": "2024-03-01T00:00:00Z",
+		"to": "2024-04-01T00:00:00Z",
+		"openingBalance": 1000,
+		"transactions": [
+			{"transactionId": 7, "date": "2024-03-01T01:00:00Z", "operationType": "DEPOSIT", "amount": 500, "balance": 1500},
+			{"transactionId": 8, "date": "2024-03-01T02:00:00Z", "operationType": "WITHDRAW", "amount": 300, "balance": 1200}
+		],
+		"closingBalance": 1200
+	}`, jsonOut.String())
+
+	var csvOut bytes.Buffer
+	w, err = NewWriter(FormatCSV, &csvOut)
+	assert.NoError(t, err)
+	assert.NoError(t, Generate(context.Background(), repo, "wallet", from, to, w))
+	assert.Equal(t, "transaction_id,date,operation_type,amount,balance\n"+
+		",2024-03-01T00:00:00Z,OPENING_BALANCE,,1000\n"+
+		"7,2024-03-01T01:00:00Z,DEPOSIT,500,1500\n"+
+		"8,2024-03-01T02:00:00Z,WITHDRAW,300,1200\n"+
+		",2024-04-01T00:00:00Z,CLOSING_BALANCE,,1200\n", csvOut.String())
+
+	var pdfOut bytes.Buffer
+	w, err = NewWriter(FormatPDF, &pdfOut)
+	assert.NoError(t, err)
+	assert.NoError(t, Generate(context.Background(), repo, "wallet", from, to, w))
+	assert.True(t, strings.HasPrefix(pdfOut.String(), "%PDF-1.4\n"))
+	assert.True(t, strings.HasSuffix(pdfOut.String(), "%%EOF\n"))
+	assert.Contains(t, pdfOut.String(), "(Closing balance: 1200")
+}
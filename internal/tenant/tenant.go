@@ -0,0 +1,314 @@
+package tenant
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"wallet-service/internal/db"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	tenantID     = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+	currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+	keyHash      = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// Limits - максимальная сумма одной операции арендатора, 0 - без ограничения
+type Limits struct {
+	Deposit  int64
+	Withdraw int64
+	Transfer int64
+}
+
+// WithdrawFee - комиссия за снятие: фиксированная часть плюс процент от суммы,
+// округленный вверх до минимальной единицы
+type WithdrawFee struct {
+	Fixed int64
+	// BasisPoints - процент в сотых долях процента (150 - 1.5%)
+	BasisPoints int64
+}
+
+// Tenant - арендатор из файла TENANTS_FILE
+type Tenant struct {
+	ID string
+	// Currency - валюта кошельков арендатора (ISO 4217), "" - не задана
+	Currency    string
+	Limits      Limits
+	WithdrawFee WithdrawFee
+	// FeeWallet - кошелек арендатора, на который зачисляются комиссии
+	FeeWallet string
+}
+
+// Limit - ограничение суммы операции DEPOSIT, WITHDRAW или TRANSFER, 0 - без ограничения
+func (t *Tenant) Limit(operation string) int64 {
+	switch operation {
+	case "DEPOSIT":
+		return t.Limits.Deposit
+	case "WITHDRAW":
+		return t.Limits.Withdraw
+	case "TRANSFER":
+		return t.Limits.Transfer
+	}
+	return 0
+}
+
+// Fee - комиссия за снятие amount
+func (f WithdrawFee) Fee(amount int64) int64 {
+	if amount <= 0 {
+		return f.Fixed
+	}
+	// amount*bp/10000 с округлением вверх без переполнения int64
+	percent := amount/10000*f.BasisPoints + (amount%10000*f.BasisPoints+9999)/10000
+	return f.Fixed + percent
+}
+
+// Registry - арендаторы и их API-ключи. Содержимое заменяется без перезапуска (Replace),
+// поэтому ссылку на Registry можно хранить
+type Registry struct {
+	data atomic.Pointer[registryData]
+}
+
+type registryData struct {
+	tenants map[string]*Tenant
+	// keys - арендатор по SHA-256 API-ключа
+	keys map[string]*Tenant
+}
+
+// Config - арендатор в файле TENANTS_FILE. Те же поля в JSON - настройка TENANTS в /admin/config
+type Config struct {
+	ID       string   `yaml:"id" json:"id"`
+	APIKeys  []string `yaml:"api_keys" json:"api_keys"`
+	Currency string   `yaml:"currency" json:"currency,omitempty"`
+	Limits   struct {
+		Deposit  int64 `yaml:"deposit" json:"deposit,omitempty"`
+		Withdraw int64 `yaml:"withdraw" json:"withdraw,omitempty"`
+		Transfer int64 `yaml:"transfer" json:"transfer,omitempty"`
+	} `yaml:"limits" json:"limits"`
+	Fees struct {
+		Withdraw struct {
+			Fixed   int64   `yaml:"fixed" json:"fixed,omitempty"`
+			Percent float64 `yaml:"percent" json:"percent,omitempty"`
+		} `yaml:"withdraw" json:"withdraw"`
+		Wallet string `yaml:"wallet" json:"wallet,omitempty"`
+	} `yaml:"fees" json:"fees"`
+}
+
+// Configs - арендаторы из файла. В JSON и логах хеши API-ключей скрыты
+type Configs []Config
+
+// redactedKey заменяет хеш API-ключа в выводе
+const redactedKey = "[REDACTED]"
+
+// MarshalJSON выводит арендаторов со скрытыми хешами API-ключей
+func (c Configs) MarshalJSON() ([]byte, error) {
+	redacted := make([]Config, len(c))
+	for i, t := range c {
+		redacted[i] = t
+		redacted[i].APIKeys = make([]string, len(t.APIKeys))
+		for j := range t.APIKeys {
+			redacted[i].APIKeys[j] = redactedKey
+		}
+	}
+	return json.Marshal(redacted)
+}
+
+// Clone - копия арендаторов, не разделяющая с c списки ключей
+func (c Configs) Clone() Configs {
+	if c == nil {
+		return nil
+	}
+	clone := make(Configs, len(c))
+	for i, t := range c {
+		clone[i] = t
+		clone[i].APIKeys = slices.Clone(t.APIKeys)
+	}
+	return clone
+}
+
+func (c Configs) String() string {
+	data, _ := c.MarshalJSON()
+	return string(data)
+}
+
+// tenantFile - формат файла арендаторов
+type tenantFile struct {
+	Tenants Configs `yaml:"tenants"`
+}
+
+// LoadTenants читает арендаторов из YAML-файла path
+func LoadTenants(path string) (*Registry, error) {
+	configs, err := ReadConfigs(path)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := NewRegistry(configs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenants in %s: %w", path, err)
+	}
+	return registry, nil
+}
+
+// ReadConfigs читает арендаторов из YAML-файла path без проверки значений
+func ReadConfigs(path string) (Configs, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants: %w", err)
+	}
+	configs, err := decodeConfigs(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenants in %s: %w", path, err)
+	}
+	return configs, nil
+}
+
+func decodeConfigs(data []byte) (Configs, error) {
+	var file tenantFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if file.Tenants == nil {
+		file.Tenants = Configs{}
+	}
+	return file.Tenants, nil
+}
+
+// ParseTenants разбирает файл арендаторов:
+//
+//	tenants:
+//	  - id: acme
+//	    api_keys: [<sha256 ключа в hex>]
+//	    currency: USD
+//	    limits: {deposit: 1000000, withdraw: 500000, transfer: 500000}
+//	    fees:
+//	      withdraw: {fixed: 10, percent: 1.5}
+//	      wallet: <uuid кошелька для комиссий>
+//
+// В файле хранятся только хеши ключей, сами ключи знают арендаторы
+func ParseTenants(data []byte) (*Registry, error) {
+	configs, err := decodeConfigs(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewRegistry(configs)
+}
+
+// NewRegistry проверяет арендаторов и строит по ним реестр
+func NewRegistry(configs Configs) (*Registry, error) {
+	data := &registryData{tenants: map[string]*Tenant{}, keys: map[string]*Tenant{}}
+	for i, t := range configs {
+		if !tenantID.MatchString(t.ID) {
+			return nil, fmt.Errorf("tenant %d: id %q must match %s", i+1, t.ID, tenantID)
+		}
+		if data.tenants[t.ID] != nil {
+			return nil, fmt.Errorf("duplicate tenant %s", t.ID)
+		}
+		if len(t.APIKeys) == 0 {
+			return nil, fmt.Errorf("tenant %s: api_keys must not be empty", t.ID)
+		}
+		if t.Currency != "" && !currencyCode.MatchString(t.Currency) {
+			return nil, fmt.Errorf("tenant %s: currency %q is not an ISO 4217 code", t.ID, t.Currency)
+		}
+		if t.Limits.Deposit < 0 || t.Limits.Withdraw < 0 || t.Limits.Transfer < 0 {
+			return nil, fmt.Errorf("tenant %s: limits must not be negative", t.ID)
+		}
+
+		fee := t.Fees.Withdraw
+		if fee.Fixed < 0 || fee.Percent < 0 || fee.Percent > 100 {
+			return nil, fmt.Errorf("tenant %s: withdraw fee must be non-negative and at most 100%%", t.ID)
+		}
+		basisPoints := math.Round(fee.Percent * 100)
+		if math.Abs(basisPoints-fee.Percent*100) > 1e-6 {
+			return nil, fmt.Errorf("tenant %s: withdraw fee percent %v has more than two decimals", t.ID, fee.Percent)
+		}
+
+		tenant := &Tenant{
+			ID:       t.ID,
+			Currency: t.Currency,
+			Limits: Limits{
+				Deposit:  t.Limits.Deposit,
+				Withdraw: t.Limits.Withdraw,
+				Transfer: t.Limits.Transfer,
+			},
+			WithdrawFee: WithdrawFee{Fixed: fee.Fixed, BasisPoints: int64(basisPoints)},
+		}
+		if tenant.WithdrawFee != (WithdrawFee{}) {
+			wallet, err := uuid.Parse(t.Fees.Wallet)
+			if err != nil {
+				return nil, fmt.Errorf("tenant %s: fees.wallet must be a wallet UUID when fees are set", t.ID)
+			}
+			tenant.FeeWallet = wallet.String()
+		}
+
+		for _, key := range t.APIKeys {
+			key = strings.ToLower(strings.TrimSpace(key))
+			if !keyHash.MatchString(key) {
+				return nil, fmt.Errorf("tenant %s: api key must be a hex SHA-256 hash", t.ID)
+			}
+			if data.keys[key] != nil {
+				return nil, fmt.Errorf("tenant %s: api key is already used by tenant %s", t.ID, data.keys[key].ID)
+			}
+			data.keys[key] = tenant
+		}
+		data.tenants[t.ID] = tenant
+	}
+
+	registry := &Registry{}
+	registry.data.Store(data)
+	return registry, nil
+}
+
+// Replace заменяет арендаторов реестра арендаторами next. Запросы, уже получившие арендатора,
+// дорабатывают со старыми лимитами
+func (r *Registry) Replace(next *Registry) {
+	r.data.Store(next.data.Load())
+}
+
+// Authenticate возвращает арендатора по API-ключу или nil, если ключ неизвестен
+func (r *Registry) Authenticate(apiKey string) *Tenant {
+	if apiKey == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return r.data.Load().keys[hex.EncodeToString(sum[:])]
+}
+
+// Get возвращает арендатора по ID или nil
+func (r *Registry) Get(id string) *Tenant {
+	return r.data.Load().tenants[id]
+}
+
+// HasFees - задана ли комиссия хотя бы у одного арендатора
+func (r *Registry) HasFees() bool {
+	for _, t := range r.data.Load().tenants {
+		if t.FeeWallet != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// WithdrawFee реализует db.FeePolicy
+func (r *Registry) WithdrawFee(tenantID string, amount int64) (int64, string) {
+	t := r.data.Load().tenants[tenantID]
+	if t == nil || t.FeeWallet == "" {
+		return 0, ""
+	}
+	return t.WithdrawFee.Fee(amount), t.FeeWallet
+}
+
+var _ db.FeePolicy = (*Registry)(nil)
@@ -0,0 +1,118 @@
+package tenant
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func Test_ParseTenants(t *testing.T) {
+	registry, err := ParseTenants([]byte(`
+tenants:
+  - id: acme
+    api_keys: [` + hashKey("acme-key") + `]
+    currency: USD
+    limits: {deposit: 1000, withdraw: 500}
+    fees:
+      withdraw: {fixed: 10, percent: 1.5}
+      wallet: 0b8a5a8e-6f7c-4c4e-9c2b-1d1e2f3a4b5c
+  - id: globex
+    api_keys: [` + hashKey("globex-key") + `, ` + hashKey("globex-old-key") + `]
+`))
+	require.NoError(t, err)
+
+	acme := registry.Authenticate("acme-key")
+	require.NotNil(t, acme)
+	assert.Equal(t, "acme", acme.ID)
+	assert.Equal(t, "USD", acme.Currency)
+	assert.Equal(t, int64(1000), acme.Limit("DEPOSIT"))
+	assert.Equal(t, int64(500), acme.Limit("WITHDRAW"))
+	assert.Zero(t, acme.Limit("TRANSFER"))
+
+	assert.Equal(t, "globex", registry.Authenticate("globex-old-key").ID)
+	assert.Nil(t, registry.Authenticate("unknown"))
+	assert.Nil(t, registry.Authenticate(""))
+	assert.True(t, registry.HasFees())
+
+	// 10 + 1.5% от 1001 = 10 + 15.015, округляется вверх
+	fee, wallet := registry.WithdrawFee("acme", 1001)
+	assert.Equal(t, int64(26), fee)
+	assert.Equal(t, "0b8a5a8e-6f7c-4c4e-9c2b-1d1e2f3a4b5c", wallet)
+
+	fee, wallet = registry.WithdrawFee("globex", 1001)
+	assert.Zero(t, fee)
+	assert.Empty(t, wallet)
+}
+
+func Test_WithdrawFee(t *testing.T) {
+	var tests = []struct {
+		fee    WithdrawFee
+		amount int64
+		want   int64
+	}{
+		{WithdrawFee{BasisPoints: 100}, 100, 1},
+		{WithdrawFee{BasisPoints: 100}, 101, 2},
+		{WithdrawFee{BasisPoints: 1}, 1, 1},
+		{WithdrawFee{Fixed: 5}, 1000, 5},
+		{WithdrawFee{Fixed: 5, BasisPoints: 250}, 10000, 255},
+		// Без переполнения на больших суммах
+		{WithdrawFee{BasisPoints: 10000}, 1 << 62, 1 << 62},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.fee.Fee(tt.amount), "%+v %d", tt.fee, tt.amount)
+	}
+}
+
+func Test_ParseTenantsErrors(t *testing.T) {
+	key := hashKey("key")
+	for _, src := range []string{
+		`tenants: [{id: "Acme", api_keys: [` + key + `]}]`,                                      // недопустимый id
+		`tenants: [{id: acme}]`,                                                                 // нет ключей
+		`tenants: [{id: acme, api_keys: [plain-key]}]`,                                          // ключ не хеш
+		`tenants: [{id: acme, api_keys: [` + key + `]}, {id: globex, api_keys: [` + key + `]}]`, // ключ у двух арендаторов
+		`tenants: [{id: acme, api_keys: [` + key + `]}, {id: acme, api_keys: [` + hashKey("other") + `]}]`,
+		`tenants: [{id: acme, api_keys: [` + key + `], currency: usd}]`,
+		`tenants: [{id: acme, api_keys: [` + key + `], limits: {deposit: -1}}]`,
+		`tenants: [{id: acme, api_keys: [` + key + `], fees: {withdraw: {fixed: 10}}}]`,                           // нет кошелька комиссий
+		`tenants: [{id: acme, api_keys: [` + key + `], fees: {withdraw: {percent: 0.125}, wallet: ` + key + `}}]`, // кошелек не UUID
+		`tenants: [{id: acme, api_keys: [` + key + `], unknown: 1}]`,
+	} {
+		_, err := ParseTenants([]byte(src))
+		assert.Error(t, err, src)
+	}
+}
+
+func Test_Replace(t *testing.T) {
+	registry, err := NewRegistry(Configs{{ID: "acme", APIKeys: []string{hashKey("acme-key")}}})
+	require.NoError(t, err)
+
+	config := Config{ID: "acme", APIKeys: []string{hashKey("acme-new-key")}}
+	config.Limits.Withdraw = 50
+	next, err := NewRegistry(Configs{config})
+	require.NoError(t, err)
+	registry.Replace(next)
+
+	assert.Nil(t, registry.Authenticate("acme-key"))
+	assert.Equal(t, int64(50), registry.Authenticate("acme-new-key").Limit("WITHDRAW"))
+}
+
+func Test_ConfigsRedacted(t *testing.T) {
+	configs := Configs{{ID: "acme", APIKeys: []string{hashKey("acme-key"), hashKey("acme-old-key")}}}
+
+	data, err := json.Marshal(configs)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"api_keys":["[REDACTED]","[REDACTED]"]`)
+	assert.NotContains(t, string(data), hashKey("acme-key"))
+	assert.NotContains(t, configs.String(), hashKey("acme-key"))
+	// Исходные ключи не меняются
+	assert.Equal(t, hashKey("acme-key"), configs[0].APIKeys[0])
+}
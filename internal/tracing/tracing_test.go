@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func Test_Init(t *testing.T) {
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	var tests = []struct {
+		name        string
+		exporter    string
+		sampleRatio float64
+		provider    bool
+		sampled     bool
+		err         string
+	}{
+		{name: "None", exporter: ExporterNone, sampleRatio: 1},
+		{name: "Empty", exporter: "", sampleRatio: 1},
+		{name: "Stdout", exporter: ExporterStdout, sampleRatio: 1, provider: true, sampled: true},
+		{name: "Stdout not sampled", exporter: ExporterStdout, sampleRatio: 0, provider: true, sampled: false},
+		{name: "Invalid", exporter: "zipkin", sampleRatio: 1, err: `unsupported tracing exporter "zipkin"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otel.SetTracerProvider(noop.NewTracerProvider())
+
+			shutdown, err := Init(tt.exporter, "wallet-service-test", tt.sampleRatio)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			defer func() { assert.NoError(t, shutdown(context.Background())) }()
+
+			// Пропагатор W3C trace-context устанавливается при любом экспортере
+			assert.Contains(t, otel.GetTextMapPropagator().Fields(), "traceparent")
+
+			_, isSDK := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+			require.Equal(t, tt.provider, isSDK)
+			if !tt.provider {
+				return
+			}
+
+			_, span := otel.Tracer("test").Start(context.Background(), "root")
+			defer span.End()
+			assert.Equal(t, tt.sampled, span.SpanContext().IsSampled())
+		})
+	}
+}
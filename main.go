@@ -1,13 +1,41 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 	"wallet-service/config"
+	"wallet-service/internal/api"
+	"wallet-service/internal/cache"
+	"wallet-service/internal/cli"
 	"wallet-service/internal/db"
+	"wallet-service/internal/fees"
+	"wallet-service/internal/health"
 	"wallet-service/internal/logger"
+	"wallet-service/internal/metrics"
+	"wallet-service/internal/queue"
+	"wallet-service/internal/ratelimit"
+	"wallet-service/internal/reconcile"
+	"wallet-service/internal/risk"
 	"wallet-service/internal/routes"
+	"wallet-service/internal/settings"
+	"wallet-service/internal/settlement"
+	"wallet-service/internal/sharding"
+	"wallet-service/internal/snapshot"
+	"wallet-service/internal/tenant"
+	"wallet-service/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -22,30 +50,647 @@ func main() {
 		logger.Log.Fatalf("Failed to load config: %v", err)
 	}
 
+	redaction, err := logger.ParseRedaction(cfg.LogRedact)
+	if err != nil {
+		logger.Log.Fatalf("Invalid LOG_REDACT: %v", err)
+	}
+	if err := logger.Configure(logger.Options{
+		Format: cfg.LogFormat,
+		Level:  cfg.LogLevel,
+		Output: cfg.LogOutput,
+		Redact: redaction,
+	}); err != nil {
+		logger.Log.Fatalf("Invalid logging config: %v", err)
+	}
+
+	//действующая конфигурация, без подключения к базе
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := cli.Config(os.Args[2:], cfg); err != nil {
+			logger.Log.Fatalf("Config command failed: %v", err)
+		}
+		return
+	}
+
+	//Инициализация трассировки
+	shutdownTracing, err := tracing.Init(cfg.TracingExporter, cfg.TracingServiceName, cfg.TracingSampleRatio)
+	if err != nil {
+		logger.Log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	//хранилище в памяти: без базы, только операции с кошельками и история
+	if cfg.DBDriver == "memory" {
+		logger.Log.Warn("Using in-memory storage, data will be lost on restart")
+		repo := db.NewMemoryRepository()
+		serve(cfg, routes.Dependencies{Repo: repo, History: repo, ServiceName: cfg.TracingServiceName})
+		return
+	}
+
+	//SQLite: однобинарная редакция, только операции с кошельками и история
+	if cfg.DBDriver == "sqlite" {
+		conn, err := db.OpenSQLite(cfg.SQLitePath, cfg.SQLiteBusyTimeout)
+		if err != nil {
+			logger.Log.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		defer conn.Close()
+
+		if len(os.Args) > 1 && os.Args[1] == "migrate" {
+			open := func(shard string) (*db.Migrator, error) {
+				if shard != "" {
+					return nil, errors.New("shards are not supported with SQLite")
+				}
+				return db.NewSQLiteMigrator(conn)
+			}
+			if err := cli.Migrate(os.Args[2:], open, cfg.DBMigrateLockTimeout); err != nil {
+				logger.Log.Fatalf("Migration failed: %v", err)
+			}
+			return
+		}
+
+		// Файл SQLite открыт одним процессом, поэтому миграции применяются при каждом запуске
+		if err := db.RunSQLiteMigrations(conn); err != nil {
+			logger.Log.Fatalf("Failed to apply migrations: %v", err)
+		}
+
+		readiness := health.NewReadiness(cfg.ReadinessTimeout)
+		readiness.Add("database", health.DBPing(conn))
+		readiness.Add("schema", func(ctx context.Context) error { return db.CheckSQLiteSchemaVersion(ctx, conn) })
+
+		repo := db.NewSQLiteRepository(conn)
+		repo.Timeouts = db.Timeouts{
+			Deposit:         cfg.DBDepositTimeout,
+			Withdraw:        cfg.DBWithdrawTimeout,
+			Read:            cfg.DBReadTimeout,
+			WalletStatement: cfg.DBWalletStatementTimeout,
+		}
+		serve(cfg, routes.Dependencies{Repo: repo, History: repo, Readiness: readiness, ServiceName: cfg.TracingServiceName})
+		return
+	}
+
+	if cfg.DBDriver != "postgres" {
+		logger.Log.Fatalf("Unknown DB_DRIVER: %s", cfg.DBDriver)
+	}
+
 	//Инициализация базы данных
-	dataBase, err := db.InitDB(cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	dataBase, replicas, err := db.InitDB(connConfig(cfg), poolConfig(cfg), cfg.DBReplicaDSNs...)
 	if err != nil {
 		logger.Log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer dataBase.Close()
+	if err := registerPoolStats(cfg, dataBase, replicas); err != nil {
+		logger.Log.Fatalf("Failed to register database metrics: %v", err)
+	}
 
-	// Выполнение миграций
-	if err := db.RunMigrations(dataBase); err != nil {
-		logger.Log.Fatalf("Failed to apply migrations: %v", err)
+	//миграции схемы вручную: основная база или шард из DB_SHARDS
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		var shardConn *sql.DB
+		open := func(shard string) (*db.Migrator, error) {
+			conn := dataBase
+			if shard != "" {
+				dsn, err := shardDSN(cfg, shard)
+				if err != nil {
+					return nil, err
+				}
+				if shardConn, err = db.Open(dsn); err != nil {
+					return nil, fmt.Errorf("shard %s: %w", shard, err)
+				}
+				conn = shardConn
+			}
+			return db.NewMigrator(context.Background(), conn)
+		}
+		err := cli.Migrate(os.Args[2:], open, cfg.DBMigrateLockTimeout)
+		// Migrator не закрывает пул соединений, соединение с шардом закрывается здесь
+		if shardConn != nil {
+			shardConn.Close()
+		}
+		if err != nil {
+			logger.Log.Fatalf("Migration failed: %v", err)
+		}
+		return
 	}
 
+	// Выполнение миграций при старте включается DB_AUTO_MIGRATE, реплики сервиса применяют их по очереди
+	if cfg.DBAutoMigrate {
+		if err := runMigrations(cfg, dataBase); err != nil {
+			logger.Log.Fatalf("Failed to apply migrations: %v", err)
+		}
+	}
+
+	//проверки готовности для /readyz
+	readiness := health.NewReadiness(cfg.ReadinessTimeout)
+	readiness.Add("database", health.DBPing(dataBase))
+	readiness.Add("schema", func(ctx context.Context) error { return db.CheckSchemaVersion(ctx, dataBase) })
+	readiness.Add("pool", health.PoolSaturation(dataBase, cfg.ReadinessPoolSaturation))
+
 	//экземпляр репозитория
 	repo := db.NewPostgresRepository(dataBase)
+	if err := configureRepository(repo, cfg); err != nil {
+		logger.Log.Fatalf("Invalid repository config: %v", err)
+	}
 
-	//инициализация маршрутов
-	router := gin.Default()
-	if err := routes.SetupRoutes(router, repo); err != nil {
+	//чтение баланса и истории с реплик
+	if len(replicas) > 0 {
+		replicaSet := db.NewReplicaSet(replicas, cfg.DBReplicaMaxLag)
+		defer replicaSet.Close()
+		stop := replicaSet.Start(cfg.DBReplicaCheckInterval)
+		defer stop()
+		repo.Replicas = replicaSet
+	}
+
+	var walletRepo db.Repository = repo
+	var history db.HistoryRepository = repo
+	var hotWallets db.HotWalletRepository = repo
+	var transfers db.TransferRepository = repo
+
+	//шардирование кошельков по нескольким базам. Фоновые задания и сверки работают с базой каждого шарда,
+	//без шардирования - с одной основной базой
+	var sharded *sharding.ShardedRepository
+	shardDBs := []shardDB{{repo: repo, connStr: db.ConnString(connConfig(cfg))}}
+	if len(cfg.DBShards) > 0 {
+		var closeShards func()
+		sharded, shardDBs, closeShards, err = setupSharding(cfg, repo, readiness)
+		if err != nil {
+			logger.Log.Fatalf("Failed to set up shards: %v", err)
+		}
+		defer closeShards()
+		walletRepo, history, hotWallets, transfers = sharded, sharded, sharded, sharded
+	}
+
+	//групповая фиксация депозитов перед репозиторием
+	if cfg.DepositBatchEnabled && sharded != nil {
+		logger.Log.Warn("Deposit batching is not supported with sharding and is disabled")
+	} else if cfg.DepositBatchEnabled {
+		batcher := db.NewDepositBatcher(repo, cfg.DepositBatchSize, cfg.DepositBatchDelay)
+		defer batcher.Close()
+		walletRepo = batcher
+	}
+
+	//кэш балансов
+	var balanceCache *cache.CachedRepository
+	if cfg.BalanceCache != "none" {
+		cached, stop, err := setupBalanceCache(cfg, walletRepo, shardDBs)
+		if err != nil {
+			logger.Log.Fatalf("Failed to set up balance cache: %v", err)
+		}
+		defer stop()
+		walletRepo, balanceCache = cached, cached
+	}
+
+	//журналы шардов для сверки балансов
+	ledgers := make([]reconcile.Shard, 0, len(shardDBs))
+	for _, shard := range shardDBs {
+		ledgers = append(ledgers, reconcile.Shard{Name: shard.name, Source: shard.repo, Lock: shard.repo})
+	}
+
+	//подкоманды: без аргументов запускается HTTP-сервер
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "reconcile":
+			if err := cli.Reconcile(os.Args[2:], ledgers, cfg.ReconcileBatchSize); err != nil {
+				logger.Log.Fatalf("Reconciliation failed: %v", err)
+			}
+			return
+		case "admin":
+			var store cli.AdminStore = repo
+			if sharded != nil {
+				store = sharded
+			}
+			if err := cli.Admin(os.Args[2:], store, ledgers, cfg.ReconcileBatchSize); err != nil {
+				logger.Log.Fatalf("Admin command failed: %v", err)
+			}
+			return
+		case "shards":
+			if sharded == nil {
+				logger.Log.Fatal("Sharding is not configured (DB_SHARDS is empty)")
+			}
+			if err := cli.Shards(os.Args[2:], sharded); err != nil {
+				logger.Log.Fatalf("Shards command failed: %v", err)
+			}
+			return
+		default:
+			logger.Log.Fatalf("Unknown command: %s", os.Args[1])
+		}
+	}
+
+	//восстановление незавершенных переводов между шардами
+	if sharded != nil && cfg.TransferRecoveryInterval > 0 {
+		stop := sharded.StartTransferRecovery(cfg.TransferRecoveryInterval, cfg.TransferRecoveryAge)
+		defer stop()
+	}
+
+	//сверка балансов по расписанию
+	if cfg.ReconcileInterval > 0 {
+		reconciler := reconcile.NewShardedReconciler(ledgers, cfg.ReconcileBatchSize)
+		stop := reconcile.StartScheduler(reconciler, cfg.ReconcileInterval, cfg.ReconcileReportDir, cfg.ReconcileFormat)
+		defer stop()
+	}
+
+	//снимки балансов для запросов баланса на момент времени, на каждом шарде свои
+	if cfg.SnapshotInterval > 0 {
+		for _, shard := range shardDBs {
+			stop := snapshot.StartJob(shard.repo, cfg.SnapshotInterval)
+			defer stop()
+		}
+	}
+
+	//сверка с файлами расчетов банка/PSP по журналам всех шардов
+	var settlementStore settlement.Store = repo
+	if sharded != nil {
+		settlementStore = sharded
+	}
+	settlements := settlement.NewService(settlementStore, settlement.Rule{
+		Name:       cfg.SettlementMatchRule,
+		DateWindow: cfg.SettlementDateWindow,
+	})
+	if err := settlements.DefaultRule.Validate(); err != nil {
+		logger.Log.Fatalf("Invalid settlement config: %v", err)
+	}
+
+	//проверка рисков перед движением денег: история кошелька - с его шарда, решения - на первом шарде
+	var riskEngine *risk.Engine
+	var riskStore db.RiskRepository = repo
+	if sharded != nil {
+		riskStore = sharded
+	}
+	if cfg.RiskRulesFile != "" {
+		rules, err := risk.LoadRules(cfg.RiskRulesFile)
+		if err != nil {
+			logger.Log.Fatalf("Failed to load risk rules: %v", err)
+		}
+		riskEngine = risk.NewEngine(riskStore, rules)
+		logger.Log.Infof("Risk checks enabled with %d rules from %s", len(rules.Rules), cfg.RiskRulesFile)
+	}
+
+	//настройки, которые меняются без перезапуска: из файлов конфигурации и через /admin/config
+	runtimeSettings, err := settings.NewManager(cfg)
+	if err != nil {
+		logger.Log.Fatalf("Invalid runtime settings: %v", err)
+	}
+
+	//арендаторы по API-ключам: кошельки, лимиты, комиссии и валюта арендатора. Лимиты, комиссии и ключи
+	//меняются без перезапуска вместе с файлом арендаторов
+	var tenants *tenant.Registry
+	if cfg.TenantsFile != "" {
+		tenants, err = tenant.NewRegistry(runtimeSettings.Current().Tenants)
+		if err != nil {
+			logger.Log.Fatalf("Failed to load tenants: %v", err)
+		}
+		if sharded != nil {
+			// Начисления зачисляются в базе, где было снятие, а кошелек комиссий может быть на другом шарде
+			if tenants.HasFees() {
+				logger.Log.Fatal("Tenant withdraw fees are not supported with sharding")
+			}
+			runtimeSettings.AddCheck(rejectTenantFees)
+		} else {
+			repo.Fees = tenants
+
+			//комиссии начисляются при снятии и зачисляются на кошельки комиссий пачками
+			if cfg.FeeSettleInterval > 0 {
+				stop := fees.StartJob(repo, cfg.FeeSettleInterval, cfg.FeeSettleBatchSize)
+				defer stop()
+			}
+		}
+		runtimeSettings.Subscribe(settings.ApplyTenants(tenants))
+		logger.Log.Infof("Tenants loaded from %s", cfg.TenantsFile)
+	}
+
+	//очередь асинхронных операций в основной базе, воркеры всех реплик разбирают ее вместе
+	var operations db.OperationQueue
+	if sharded != nil {
+		logger.Log.Warn("Asynchronous operations are not supported with sharding and are disabled")
+	} else {
+		operationQueue := queue.NewQueue(repo, queue.Options{
+			Workers:      cfg.AsyncWorkers,
+			PollInterval: cfg.AsyncPollInterval,
+			Retry:        db.OperationRetry{MaxAttempts: cfg.AsyncMaxAttempts, Delay: cfg.AsyncRetryDelay},
+			Timeout:      max(cfg.DBDepositTimeout, cfg.DBWithdrawTimeout),
+			OnCompleted: func(op *db.AsyncOperation) {
+				// Воркер пишет мимо кэша балансов
+				if balanceCache != nil {
+					balanceCache.Invalidate(context.Background(), op.WalletUUID)
+				}
+			},
+		})
+		if cfg.AsyncWorkers > 0 {
+			stop := operationQueue.Start()
+			defer stop()
+		}
+		operations = operationQueue
+	}
+
+	//общие для всех реплик корзины ограничения частоты запросов в основной базе
+	var rateLimiter *api.RateLimiter
+	if cfg.RateLimitStore == "postgres" {
+		store := ratelimit.NewPostgresStore(repo)
+		stop := store.StartCleanup(cfg.RateLimitCleanupPeriod, rateLimitBucketIdle)
+		defer stop()
+		rateLimiter = &api.RateLimiter{Limiter: ratelimit.NewLimiter(store, nil)}
+	}
+
+	serve(cfg, routes.Dependencies{
+		Repo:           walletRepo,
+		Settlements:    settlements,
+		History:        history,
+		HotWallets:     hotWallets,
+		Transfers:      transfers,
+		Operations:     operations,
+		Readiness:      readiness,
+		RateLimiter:    rateLimiter,
+		Risk:           riskEngine,
+		RiskOperations: riskStore,
+		Tenants:        tenants,
+		Settings:       runtimeSettings,
+		ServiceName:    cfg.TracingServiceName,
+	})
+}
+
+// rejectTenantFees не дает включить комиссии арендаторов без перезапуска, когда они не поддерживаются
+func rejectTenantFees(s settings.Settings) error {
+	if registry, err := tenant.NewRegistry(s.Tenants); err == nil && registry.HasFees() {
+		return errors.New("TENANTS: withdraw fees are not supported with sharding")
+	}
+	return nil
+}
+
+// rateLimitBucketIdle - корзины в PostgreSQL, не использовавшиеся дольше, удаляются: при периоде правил
+// до часа они уже снова полные
+const rateLimitBucketIdle = time.Hour
+
+// serve регистрирует маршруты и запускает HTTP-сервер. По SIGTERM или SIGINT /readyz переходит в 503,
+// через SHUTDOWN_DELAY сервер перестает принимать соединения и ждет завершения текущих запросов.
+// После возврата main закрывает фоновые задания и соединения с базой
+func serve(cfg *config.Config, deps routes.Dependencies) {
+	if deps.Readiness == nil {
+		deps.Readiness = health.NewReadiness(cfg.ReadinessTimeout)
+	}
+
+	//настройки, которые меняются без перезапуска: из файлов конфигурации и через /admin/config
+	if deps.Settings == nil {
+		manager, err := settings.NewManager(cfg)
+		if err != nil {
+			logger.Log.Fatalf("Invalid runtime settings: %v", err)
+		}
+		deps.Settings = manager
+	}
+	deps.Settings.Subscribe(settings.ApplyLogging)
+	deps.AdminToken = cfg.AdminToken
+
+	//ограничение частоты запросов, правила меняются без перезапуска. Общие для реплик корзины
+	//в PostgreSQL подключаются заранее, по умолчанию корзины в памяти
+	if deps.RateLimiter == nil {
+		deps.RateLimiter = &api.RateLimiter{Limiter: ratelimit.NewLimiter(ratelimit.NewMemoryStore(), nil)}
+	}
+	deps.RateLimiter.ClientHeader = cfg.RateLimitClientHeader
+	deps.Settings.Subscribe(settings.ApplyRateLimits(deps.RateLimiter.Limiter))
+	if cfg.ConfigWatch {
+		stopWatch, err := config.Watch(deps.Settings.Reload, cfg.TenantsFile)
+		if err != nil {
+			logger.Log.Warnf("Config files are not watched: %v", err)
+		} else {
+			defer stopWatch()
+		}
+	}
+
+	//инициализация маршрутов: журнал запросов пишет api.AccessLog, стандартный логгер gin не нужен
+	router := gin.New()
+	router.Use(api.Recovery())
+	if err := routes.SetupRoutes(router, deps); err != nil {
 		logger.Log.Fatalf("Failed to set up routes: %v", err)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	//запуск сервера
-	if err := router.Run(":" + cfg.AppPort); err != nil {
+	srv := &http.Server{
+		Addr:              ":" + cfg.AppPort,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	logger.Log.Infof("Server listening on %s", srv.Addr)
+
+	select {
+	case err := <-serveErr:
 		logger.Log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	logger.Log.Info("Shutdown signal received, no longer ready")
+	deps.Readiness.StartShutdown()
+	// Балансировщику нужно время, чтобы увидеть 503 на /readyz и перестать направлять запросы
+	time.Sleep(cfg.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Log.Errorf("In-flight requests did not finish within %s: %v", cfg.ShutdownTimeout, err)
+		srv.Close()
+		return
+	}
+	logger.Log.Info("Server stopped gracefully")
+}
+
+// setupBalanceCache оборачивает репозиторий кэшем балансов и подписывается на изменения балансов в базе каждого шарда
+func setupBalanceCache(cfg *config.Config, repo db.Repository, shards []shardDB) (*cache.CachedRepository, func(), error) {
+	var store cache.BalanceCache
+	switch cfg.BalanceCache {
+	case "memory":
+		store = cache.NewLRU(cfg.BalanceCacheSize)
+	case "redis":
+		store = cache.NewRedis(redis.NewClient(&redis.Options{Addr: cfg.RedisAddr}), cfg.BalanceCacheMaxStaleness)
+	default:
+		return nil, nil, fmt.Errorf("unknown balance cache %q", cfg.BalanceCache)
+	}
+
+	cached := cache.NewCachedRepository(repo, store, cfg.BalanceCacheMaxStaleness)
+	if !cfg.BalanceCacheListen {
+		return cached, func() {}, nil
+	}
+
+	var stops []func()
+	stopAll := func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+	for _, shard := range shards {
+		stop, err := db.ListenBalanceChanges(shard.connStr,
+			func(walletUUID string) { cached.Invalidate(context.Background(), walletUUID) },
+			func() { cached.Purge(context.Background()) },
+		)
+		if err != nil {
+			stopAll()
+			if shard.name != "" {
+				return nil, nil, fmt.Errorf("shard %s: failed to listen for balance changes: %w", shard.name, err)
+			}
+			return nil, nil, fmt.Errorf("failed to listen for balance changes: %w", err)
+		}
+		stops = append(stops, stop)
+	}
+	return cached, stopAll, nil
+}
+
+// connConfig - параметры подключения к основной базе
+func connConfig(cfg *config.Config) db.ConnConfig {
+	return db.ConnConfig{
+		Host:           cfg.DBHost,
+		Port:           cfg.DBPort,
+		User:           cfg.DBUser,
+		Password:       cfg.DBPassword,
+		Name:           cfg.DBName,
+		SSLMode:        cfg.DBSSLMode,
+		SSLRootCert:    cfg.DBSSLRootCert,
+		ConnectTimeout: cfg.DBConnectTimeout,
+	}
+}
+
+// poolConfig - настройки пула соединений для основной базы, реплик и шардов
+func poolConfig(cfg *config.Config) db.PoolConfig {
+	return db.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	}
+}
+
+// configureRepository применяет к репозиторию дедлайны, уровни изоляции, повторы транзакций
+// и уведомления об изменении баланса из конфигурации
+func configureRepository(repo *db.PostgresRepository, cfg *config.Config) error {
+	repo.Timeouts = db.Timeouts{
+		Deposit:         cfg.DBDepositTimeout,
+		Withdraw:        cfg.DBWithdrawTimeout,
+		Read:            cfg.DBReadTimeout,
+		WalletStatement: cfg.DBWalletStatementTimeout,
+		Lock:            cfg.DBLockTimeout,
+	}
+
+	depositIsolation, err := db.ParseIsolationLevel(cfg.DBDepositIsolation)
+	if err != nil {
+		return fmt.Errorf("DB_DEPOSIT_ISOLATION: %w", err)
+	}
+	withdrawIsolation, err := db.ParseIsolationLevel(cfg.DBWithdrawIsolation)
+	if err != nil {
+		return fmt.Errorf("DB_WITHDRAW_ISOLATION: %w", err)
+	}
+	repo.Isolation = db.Isolation{Deposit: depositIsolation, Withdraw: withdrawIsolation}
+
+	repo.Retry = db.RetryPolicy{
+		MaxAttempts: cfg.DBTxMaxAttempts,
+		BaseDelay:   cfg.DBTxRetryBaseDelay,
+		MaxDelay:    cfg.DBTxRetryMaxDelay,
 	}
 
+	// NOTIFY об изменении баланса нужен только кэшам, которые его слушают
+	repo.NotifyBalanceChanges = cfg.BalanceCache != "none" && cfg.BalanceCacheListen
+	return nil
+}
+
+// shardDB - база шарда: репозиторий для фоновых заданий и строка подключения для LISTEN.
+// Без шардирования - основная база с пустым именем
+type shardDB struct {
+	name    string
+	repo    *db.PostgresRepository
+	connStr string
+}
+
+// registerPoolStats экспортирует метрики пулов соединений основной базы и ее реплик. Основная база
+// помечается именем шарда DB_SHARD_NAME, как и пулы шардов из DB_SHARDS, реплики - replica-<номер>,
+// номер тот же, что в wallet_db_replica_lag_seconds
+func registerPoolStats(cfg *config.Config, primary *sql.DB, replicas []*sql.DB) error {
+	if err := metrics.RegisterDBStats(primary, cfg.DBShardName); err != nil {
+		return err
+	}
+	for i, replica := range replicas {
+		if err := metrics.RegisterDBStats(replica, "replica-"+strconv.Itoa(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupSharding подключается к шардам из DB_SHARDS (элементы вида имя=строка подключения)
+// и применяет на них миграции. Основная база - шард с именем DB_SHARD_NAME
+func setupSharding(cfg *config.Config, mainRepo *db.PostgresRepository, readiness *health.Readiness) (*sharding.ShardedRepository, []shardDB, func(), error) {
+	dbs := []shardDB{{name: cfg.DBShardName, repo: mainRepo, connStr: db.ConnString(connConfig(cfg))}}
+	shards := []*sharding.Shard{{Name: cfg.DBShardName, Repo: mainRepo}}
+	var opened []*sql.DB
+	closeAll := func() {
+		for _, conn := range opened {
+			conn.Close()
+		}
+	}
+
+	for _, entry := range cfg.DBShards {
+		name, dsn, ok := strings.Cut(entry, "=")
+		if !ok || name == "" || dsn == "" {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("invalid shard %q, expected name=dsn", entry)
+		}
+
+		conn, err := db.Open(dsn)
+		if err != nil {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("shard %s: %w", name, err)
+		}
+		opened = append(opened, conn)
+		poolConfig(cfg).Apply(conn)
+		if err := metrics.RegisterDBStats(conn, name); err != nil {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("shard %s: %w", name, err)
+		}
+		readiness.Add("database:"+name, health.DBPing(conn))
+		readiness.Add("schema:"+name, func(ctx context.Context) error { return db.CheckSchemaVersion(ctx, conn) })
+
+		if cfg.DBAutoMigrate {
+			if err := runMigrations(cfg, conn); err != nil {
+				closeAll()
+				return nil, nil, nil, fmt.Errorf("shard %s: %w", name, err)
+			}
+		}
+
+		repo := db.NewPostgresRepository(conn)
+		if err := configureRepository(repo, cfg); err != nil {
+			closeAll()
+			return nil, nil, nil, err
+		}
+		dbs = append(dbs, shardDB{name: name, repo: repo, connStr: dsn})
+		shards = append(shards, &sharding.Shard{Name: name, Repo: repo})
+	}
+
+	sharded, err := sharding.NewShardedRepository(shards, cfg.DBShardVNodes)
+	if err != nil {
+		closeAll()
+		return nil, nil, nil, err
+	}
+	return sharded, dbs, closeAll, nil
+}
+
+// runMigrations применяет миграции при старте, ожидая блокировку миграций не дольше DB_MIGRATE_LOCK_TIMEOUT
+func runMigrations(cfg *config.Config, conn *sql.DB) error {
+	ctx := context.Background()
+	if cfg.DBMigrateLockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.DBMigrateLockTimeout)
+		defer cancel()
+	}
+	return db.RunMigrations(ctx, conn)
+}
+
+// shardDSN - строка подключения шарда из DB_SHARDS по имени
+func shardDSN(cfg *config.Config, shard string) (string, error) {
+	for _, entry := range cfg.DBShards {
+		if name, dsn, ok := strings.Cut(entry, "="); ok && name == shard {
+			return dsn, nil
+		}
+	}
+	return "", fmt.Errorf("unknown shard %q", shard)
 }